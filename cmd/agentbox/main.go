@@ -15,6 +15,8 @@ func main() {
 		switch {
 		case errors.Is(err, cli.ErrWaitTimeout):
 			os.Exit(2)
+		case errors.Is(err, cli.ErrSessionCancelled):
+			os.Exit(3)
 		default:
 			os.Exit(1)
 		}
@@ -49,6 +49,8 @@ func New(name string) (Agent, error) {
 		return NewAiderAgent(), nil
 	case "claude-cli":
 		return NewClaudeCLIAgent(), nil
+	case "mock":
+		return NewMockAgent(), nil
 	default:
 		return nil, fmt.Errorf("unknown agent: %s", name)
 	}
@@ -67,7 +69,7 @@ func GetAPIKey(agent string) string {
 			key = os.Getenv("ANTHROPIC_API_KEY")
 		}
 		return key
-	case "claude-cli":
+	case "claude-cli", "mock":
 		return ""
 	default:
 		return ""
@@ -16,6 +16,7 @@ func TestNewAgent(t *testing.T) {
 		{"claude-cli", false},
 		{"amp", false},
 		{"aider", false},
+		{"mock", false},
 		{"invalid", true},
 		{"Claude", false},
 		{"CLAUDE", false},
@@ -160,6 +161,33 @@ func TestAiderAgentCommand(t *testing.T) {
 	}
 }
 
+func TestMockAgentCommand(t *testing.T) {
+	t.Setenv("AGENTBOX_MOCK_FIXTURE", "fixtures/e2e.yaml")
+	ag := NewMockAgent()
+
+	cmd := ag.Command("ID: task-1")
+	want := []string{"/usr/local/bin/agentbox", "mock-agent", "--fixture", "fixtures/e2e.yaml", "--prompt", "ID: task-1"}
+	if strings.Join(cmd, "|") != strings.Join(want, "|") {
+		t.Errorf("Command() = %v, want %v", cmd, want)
+	}
+	if len(ag.AllowedEndpoints()) != 0 {
+		t.Error("expected mock agent to need no network endpoints")
+	}
+
+	t.Setenv("AGENTBOX_MOCK_FIXTURE", "")
+	if got := NewMockAgent().Command("")[3]; got != ".agentbox/mock-agent.yaml" {
+		t.Errorf("default fixture = %q", got)
+	}
+
+	out := ag.ParseOutput("Edited main.go\n<promise>COMPLETE</promise>\n")
+	if !out.Success || !out.Completed {
+		t.Errorf("expected success and completion, got %+v", out)
+	}
+	if len(out.Files) != 1 || out.Files[0] != "main.go" {
+		t.Errorf("expected edited file to be reported, got %v", out.Files)
+	}
+}
+
 func TestAmpAgentCommand(t *testing.T) {
 	ag := NewAmpAgent()
 
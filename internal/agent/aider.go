@@ -6,7 +6,10 @@ import (
 )
 
 // AiderAgent implements the Agent interface for Aider.
-type AiderAgent struct{}
+type AiderAgent struct {
+	baseURL string
+	model   string
+}
 
 // NewAiderAgent creates a new Aider agent adapter.
 func NewAiderAgent() *AiderAgent {
@@ -21,6 +24,12 @@ func (a *AiderAgent) Name() string {
 // Command returns the command to run Aider with a prompt.
 func (a *AiderAgent) Command(prompt string) []string {
 	args := []string{"aider", "--yes", "--no-git"}
+	if a.baseURL != "" {
+		args = append(args, "--openai-api-base", a.baseURL)
+	}
+	if a.model != "" {
+		args = append(args, "--model", a.model)
+	}
 	if prompt != "" {
 		args = append(args, "--message", prompt)
 	}
@@ -39,15 +48,20 @@ func (a *AiderAgent) Environment() []string {
 		env = append(env, "ANTHROPIC_API_KEY="+key)
 	}
 
+	if a.baseURL != "" {
+		env = append(env, "OPENAI_API_BASE="+a.baseURL)
+	}
+
 	env = append(env, "HOME=/home/agent")
 	env = append(env, "USER=agent")
 
 	return env
 }
 
-// AllowedEndpoints returns the endpoints Aider needs for API access.
+// AllowedEndpoints returns the endpoints Aider needs for API access,
+// including the configured base URL.
 func (a *AiderAgent) AllowedEndpoints() []string {
-	return []string{"api.openai.com:443", "api.anthropic.com:443"}
+	return withBaseURLEndpoint([]string{"api.openai.com:443", "api.anthropic.com:443"}, a.baseURL)
 }
 
 // StopSignal returns the signal that indicates Aider has completed its task.
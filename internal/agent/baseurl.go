@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// HostGateway is the hostname containers use to reach the Docker host. It is
+// mapped to Docker's host-gateway so it also works on Linux.
+const HostGateway = "host.docker.internal"
+
+// Options customizes an agent adapter beyond its defaults.
+type Options struct {
+	// BaseURL points the agent at an alternative API endpoint, such as an
+	// LLM gateway or a model server running on the host.
+	BaseURL string
+	// Network is the container's Docker network mode. A loopback BaseURL
+	// is rewritten to reach the host unless the container shares the
+	// host's network.
+	Network string
+	// Model overrides the agent's default model.
+	Model string
+}
+
+// NewWithOptions creates an agent adapter by name with the given options.
+func NewWithOptions(name string, opts Options) (Agent, error) {
+	baseURL := ""
+	if opts.BaseURL != "" {
+		if err := ValidateBaseURL(opts.BaseURL); err != nil {
+			return nil, err
+		}
+		baseURL = ContainerBaseURL(opts.BaseURL, opts.Network)
+	}
+
+	switch strings.ToLower(name) {
+	case "claude":
+		return &ClaudeAgent{baseURL: baseURL, model: opts.Model}, nil
+	case "claude-cli":
+		return &ClaudeCLIAgent{baseURL: baseURL, model: opts.Model}, nil
+	case "aider":
+		return &AiderAgent{baseURL: baseURL, model: opts.Model}, nil
+	case "amp", "mock":
+		if baseURL != "" {
+			return nil, fmt.Errorf("agent %s does not support base_url", name)
+		}
+		if opts.Model != "" {
+			return nil, fmt.Errorf("agent %s does not support model", name)
+		}
+		return New(name)
+	default:
+		return New(name)
+	}
+}
+
+// ValidateBaseURL checks that raw is an absolute http(s) URL with a host.
+func ValidateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid base_url %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid base_url %q: scheme must be http or https", raw)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid base_url %q: missing host", raw)
+	}
+	return nil
+}
+
+// ContainerBaseURL rewrites a loopback base URL so it reaches the Docker host
+// from inside a container on the given network mode. With host networking
+// the container shares the host's loopback, so the URL works as it is.
+// Other URLs are returned unchanged.
+func ContainerBaseURL(raw, network string) string {
+	if network == "host" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !isLoopback(u.Hostname()) {
+		return raw
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(HostGateway, port)
+	} else {
+		u.Host = HostGateway
+	}
+	return u.String()
+}
+
+// BaseURLEndpoint returns the host:port pair for a base URL as seen from a
+// container on the given network mode, for use in the restricted-network
+// allowlist. The port defaults from the scheme. It returns "" if raw is
+// empty or unparseable.
+func BaseURLEndpoint(raw, network string) string {
+	if raw == "" {
+		return ""
+	}
+	return urlEndpoint(ContainerBaseURL(raw, network))
+}
+
+// urlEndpoint returns the host:port pair for a URL, defaulting the port from
+// the scheme.
+func urlEndpoint(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// UsesHostGateway reports whether a base URL targets the Docker host from a
+// container on the given network mode, so the container needs a host-gateway
+// mapping for host.docker.internal.
+func UsesHostGateway(raw, network string) bool {
+	if raw == "" {
+		return false
+	}
+	u, err := url.Parse(ContainerBaseURL(raw, network))
+	return err == nil && u.Hostname() == HostGateway
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// withBaseURLEndpoint appends the endpoint of baseURL, already rewritten for
+// the container, to defaults if set.
+func withBaseURLEndpoint(defaults []string, baseURL string) []string {
+	if baseURL == "" {
+		return defaults
+	}
+	ep := urlEndpoint(baseURL)
+	if ep == "" {
+		return defaults
+	}
+	for _, d := range defaults {
+		if d == ep {
+			return defaults
+		}
+	}
+	return append(defaults, ep)
+}
@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContainerBaseURL(t *testing.T) {
+	tests := []struct {
+		in, network, want string
+	}{
+		{"http://localhost:11434/v1", "bridge", "http://host.docker.internal:11434/v1"},
+		{"http://127.0.0.1:8080", "", "http://host.docker.internal:8080"},
+		{"http://[::1]:4000/v1", "bridge", "http://host.docker.internal:4000/v1"},
+		{"http://localhost/v1", "bridge", "http://host.docker.internal/v1"},
+		{"http://localhost:4000", "restricted", "http://host.docker.internal:4000"},
+		{"http://localhost:4000", "none", "http://host.docker.internal:4000"},
+		{"http://localhost:4000", "host", "http://localhost:4000"},
+		{"http://127.0.0.1:8080/v1", "host", "http://127.0.0.1:8080/v1"},
+		{"https://gateway.example.com/anthropic", "bridge", "https://gateway.example.com/anthropic"},
+		{"https://gateway.example.com/anthropic", "host", "https://gateway.example.com/anthropic"},
+		{"http://host.docker.internal:4000", "bridge", "http://host.docker.internal:4000"},
+	}
+	for _, tt := range tests {
+		if got := ContainerBaseURL(tt.in, tt.network); got != tt.want {
+			t.Errorf("ContainerBaseURL(%q, %q) = %q, want %q", tt.in, tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestBaseURLEndpoint(t *testing.T) {
+	tests := []struct {
+		in, network, want string
+	}{
+		{"", "restricted", ""},
+		{"https://gateway.example.com/v1", "restricted", "gateway.example.com:443"},
+		{"http://gateway.example.com/v1", "restricted", "gateway.example.com:80"},
+		{"https://gateway.example.com:8443", "restricted", "gateway.example.com:8443"},
+		{"http://localhost:11434/v1", "restricted", "host.docker.internal:11434"},
+		{"http://localhost:11434/v1", "host", "localhost:11434"},
+	}
+	for _, tt := range tests {
+		if got := BaseURLEndpoint(tt.in, tt.network); got != tt.want {
+			t.Errorf("BaseURLEndpoint(%q, %q) = %q, want %q", tt.in, tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestUsesHostGateway(t *testing.T) {
+	tests := []struct {
+		in, network string
+		want        bool
+	}{
+		{"http://localhost:11434", "bridge", true},
+		{"http://localhost:11434", "restricted", true},
+		{"http://localhost:11434", "host", false},
+		{"http://host.docker.internal:4000", "bridge", true},
+		{"https://gateway.example.com", "bridge", false},
+		{"", "bridge", false},
+	}
+	for _, tt := range tests {
+		if got := UsesHostGateway(tt.in, tt.network); got != tt.want {
+			t.Errorf("UsesHostGateway(%q, %q) = %v, want %v", tt.in, tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestNewWithOptionsBaseURL(t *testing.T) {
+	const base = "http://localhost:4000"
+	const inContainer = "http://host.docker.internal:4000"
+
+	claude, err := NewWithOptions("claude", Options{BaseURL: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsEnv(claude.Environment(), "ANTHROPIC_BASE_URL="+inContainer) {
+		t.Errorf("claude env missing ANTHROPIC_BASE_URL: %v", claude.Environment())
+	}
+	eps := claude.AllowedEndpoints()
+	if eps[len(eps)-1] != "host.docker.internal:4000" {
+		t.Errorf("claude endpoints = %v, want base URL endpoint appended", eps)
+	}
+
+	cli, err := NewWithOptions("claude-cli", Options{BaseURL: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsEnv(cli.Environment(), "ANTHROPIC_BASE_URL="+inContainer) {
+		t.Errorf("claude-cli env missing ANTHROPIC_BASE_URL: %v", cli.Environment())
+	}
+
+	aider, err := NewWithOptions("aider", Options{BaseURL: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsEnv(aider.Environment(), "OPENAI_API_BASE="+inContainer) {
+		t.Errorf("aider env missing OPENAI_API_BASE: %v", aider.Environment())
+	}
+	if cmd := strings.Join(aider.Command("go"), " "); !strings.Contains(cmd, "--openai-api-base "+inContainer) {
+		t.Errorf("aider command missing --openai-api-base: %s", cmd)
+	}
+
+	hostClaude, err := NewWithOptions("claude", Options{BaseURL: base, Network: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsEnv(hostClaude.Environment(), "ANTHROPIC_BASE_URL="+base) {
+		t.Errorf("claude env on the host network = %v, want the base URL unchanged", hostClaude.Environment())
+	}
+
+	if _, err := NewWithOptions("amp", Options{BaseURL: base}); err == nil {
+		t.Error("expected error for amp with base_url")
+	}
+	if _, err := NewWithOptions("claude", Options{BaseURL: "ftp://example.com"}); err == nil {
+		t.Error("expected error for non-http base_url")
+	}
+}
+
+func TestNewWithOptionsNoBaseURL(t *testing.T) {
+	ag, err := NewWithOptions("claude", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range ag.Environment() {
+		if strings.HasPrefix(e, "ANTHROPIC_BASE_URL=") {
+			t.Errorf("unexpected %s without base_url", e)
+		}
+	}
+	if eps := ag.AllowedEndpoints(); len(eps) != 1 {
+		t.Errorf("endpoints = %v, want defaults only", eps)
+	}
+}
+
+func TestNewWithOptionsModel(t *testing.T) {
+	tests := []struct {
+		agent string
+		want  string
+	}{
+		{"claude", "--model 'claude-opus-4'"},
+		{"claude-cli", "--model 'claude-opus-4'"},
+		{"aider", "--model claude-opus-4"},
+	}
+	for _, tt := range tests {
+		ag, err := NewWithOptions(tt.agent, Options{Model: "claude-opus-4"})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.agent, err)
+		}
+		if cmd := strings.Join(ag.Command("go"), " "); !strings.Contains(cmd, tt.want) {
+			t.Errorf("%s command = %s, want it to contain %s", tt.agent, cmd, tt.want)
+		}
+	}
+
+	ag, err := NewWithOptions("claude", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd := strings.Join(ag.Command("go"), " "); strings.Contains(cmd, "--model") {
+		t.Errorf("unexpected --model without a model: %s", cmd)
+	}
+
+	if _, err := NewWithOptions("amp", Options{Model: "gpt-4o"}); err == nil {
+		t.Error("expected error for amp with model")
+	}
+}
+
+func containsEnv(env []string, want string) bool {
+	for _, e := range env {
+		if e == want {
+			return true
+		}
+	}
+	return false
+}
@@ -6,7 +6,10 @@ import (
 )
 
 // ClaudeAgent implements the Agent interface for Claude Code.
-type ClaudeAgent struct{}
+type ClaudeAgent struct {
+	baseURL string
+	model   string
+}
 
 // NewClaudeAgent creates a new Claude Code agent adapter.
 func NewClaudeAgent() *ClaudeAgent {
@@ -26,6 +29,9 @@ func (a *ClaudeAgent) Name() string {
 // a shell environment to properly initialize stdio for non-interactive execution.
 func (a *ClaudeAgent) Command(prompt string) []string {
 	cmd := "claude --dangerously-skip-permissions"
+	if a.model != "" {
+		cmd += " --model " + shellQuote(a.model)
+	}
 	if prompt != "" {
 		cmd += " -p " + shellQuote(prompt)
 	}
@@ -40,6 +46,10 @@ func (a *ClaudeAgent) Environment() []string {
 		env = append(env, "ANTHROPIC_API_KEY="+key)
 	}
 
+	if a.baseURL != "" {
+		env = append(env, "ANTHROPIC_BASE_URL="+a.baseURL)
+	}
+
 	env = append(env, "CLAUDE_CODE_SKIP_INTRO=1")
 	env = append(env, "HOME=/home/agent")
 	env = append(env, "USER=agent")
@@ -47,9 +57,10 @@ func (a *ClaudeAgent) Environment() []string {
 	return env
 }
 
-// AllowedEndpoints returns the endpoints Claude needs for API access.
+// AllowedEndpoints returns the endpoints Claude needs for API access,
+// including the configured base URL.
 func (a *ClaudeAgent) AllowedEndpoints() []string {
-	return []string{"api.anthropic.com:443"}
+	return withBaseURLEndpoint([]string{"api.anthropic.com:443"}, a.baseURL)
 }
 
 // StopSignal returns the signal that indicates Claude has completed its task.
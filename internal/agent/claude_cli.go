@@ -8,7 +8,10 @@ import (
 // subscription-based authentication (Pro/Max plan) instead of an API key.
 // It runs the same claude binary but relies on ~/.claude/ credentials
 // mounted into the container rather than ANTHROPIC_API_KEY.
-type ClaudeCLIAgent struct{}
+type ClaudeCLIAgent struct {
+	baseURL string
+	model   string
+}
 
 // NewClaudeCLIAgent creates a new Claude CLI agent adapter.
 func NewClaudeCLIAgent() *ClaudeCLIAgent {
@@ -27,6 +30,9 @@ func (a *ClaudeCLIAgent) Name() string {
 // a shell environment to properly initialize stdio for non-interactive execution.
 func (a *ClaudeCLIAgent) Command(prompt string) []string {
 	cmd := "claude --dangerously-skip-permissions"
+	if a.model != "" {
+		cmd += " --model " + shellQuote(a.model)
+	}
 	if prompt != "" {
 		cmd += " -p " + shellQuote(prompt)
 	}
@@ -36,16 +42,22 @@ func (a *ClaudeCLIAgent) Command(prompt string) []string {
 // Environment returns the environment variables needed by Claude Code
 // when using subscription auth. No ANTHROPIC_API_KEY is set.
 func (a *ClaudeCLIAgent) Environment() []string {
-	return []string{
-		"CLAUDE_CODE_SKIP_INTRO=1",
-		"HOME=/home/agent",
-		"USER=agent",
+	env := []string{}
+
+	if a.baseURL != "" {
+		env = append(env, "ANTHROPIC_BASE_URL="+a.baseURL)
 	}
+
+	env = append(env, "CLAUDE_CODE_SKIP_INTRO=1")
+	env = append(env, "HOME=/home/agent")
+	env = append(env, "USER=agent")
+
+	return env
 }
 
 // AllowedEndpoints returns the endpoints Claude CLI needs for subscription auth and API access.
 func (a *ClaudeCLIAgent) AllowedEndpoints() []string {
-	return []string{"api.anthropic.com:443"}
+	return withBaseURLEndpoint([]string{"api.anthropic.com:443"}, a.baseURL)
 }
 
 // StopSignal returns the signal that indicates Claude has completed its task.
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"os"
+	"strings"
+
+	"github.com/swamp-dev/agentbox/internal/mockagent"
+)
+
+// MockAgent implements the Agent interface for the built-in scripted agent.
+// It runs the agentbox binary itself (bind-mounted into the container, like
+// the egress proxy) and replays responses from a fixture file, so the whole
+// pipeline can be tested offline without API keys.
+type MockAgent struct {
+	fixture string
+}
+
+// NewMockAgent creates a mock agent adapter. The fixture path comes from
+// AGENTBOX_MOCK_FIXTURE (relative to the workspace) and defaults to
+// .agentbox/mock-agent.yaml.
+func NewMockAgent() *MockAgent {
+	fixture := os.Getenv("AGENTBOX_MOCK_FIXTURE")
+	if fixture == "" {
+		fixture = mockagent.DefaultFixture
+	}
+	return &MockAgent{fixture: fixture}
+}
+
+// Name returns the agent identifier.
+func (a *MockAgent) Name() string {
+	return "mock"
+}
+
+// Command returns the command to replay the next scripted response.
+func (a *MockAgent) Command(prompt string) []string {
+	return []string{"/usr/local/bin/agentbox", "mock-agent", "--fixture", a.fixture, "--prompt", prompt}
+}
+
+// Environment returns the environment variables needed by the mock agent.
+func (a *MockAgent) Environment() []string {
+	return []string{"HOME=/home/agent", "USER=agent"}
+}
+
+// AllowedEndpoints returns nil: the mock agent never touches the network.
+func (a *MockAgent) AllowedEndpoints() []string {
+	return nil
+}
+
+// StopSignal returns the signal that indicates the mock agent has completed its task.
+func (a *MockAgent) StopSignal() string {
+	return mockagent.StopSignal
+}
+
+// ParseOutput extracts structured information from the mock agent's output.
+func (a *MockAgent) ParseOutput(output string) *AgentOutput {
+	result := &AgentOutput{
+		Success: true,
+		Message: output,
+	}
+
+	if strings.Contains(output, a.StopSignal()) {
+		result.Completed = true
+	}
+
+	if strings.Contains(output, "Error:") || strings.Contains(output, "error:") {
+		result.Success = false
+	}
+
+	result.Completed = result.Success || result.Completed
+	result.Files = extractFilePaths(output)
+
+	return result
+}
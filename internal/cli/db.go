@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect and migrate the agentbox database",
+	Long: `Manage the SQLite store in .agentbox/agentbox.db.
+
+Agentbox migrates the store automatically when it opens it, after saving a
+backup copy next to it. These commands show the schema state and let you
+run or preview the migration explicitly.`,
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema version and pending migrations",
+	Args:  cobra.NoArgs,
+	RunE:  runDBStatus,
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Args:  cobra.NoArgs,
+	RunE:  runDBMigrate,
+}
+
+var (
+	dbProject       string
+	dbMigrateDryRun bool
+)
+
+func init() {
+	dbCmd.PersistentFlags().StringVarP(&dbProject, "project", "p", ".", "project directory")
+	dbMigrateCmd.Flags().BoolVar(&dbMigrateDryRun, "dry-run", false, "list pending migrations without applying them")
+
+	dbCmd.AddCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+}
+
+// storePath returns the location of a project's agentbox database.
+func storePath(project string) string {
+	return filepath.Join(project, ".agentbox", "agentbox.db")
+}
+
+func runDBStatus(cmd *cobra.Command, args []string) error {
+	st, err := store.InspectSchema(storePath(dbProject))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database:       %s\n", st.Path)
+	fmt.Printf("Schema version: %d\n", st.Version)
+	fmt.Printf("Latest version: %d\n", st.Latest)
+	switch {
+	case st.Version > st.Latest:
+		fmt.Println("The database was written by a newer agentbox; upgrade agentbox to use it.")
+	case len(st.Pending) == 0:
+		fmt.Println("Up to date.")
+	default:
+		fmt.Printf("Pending migrations (%d):\n", len(st.Pending))
+		printMigrations(st.Pending)
+	}
+	return nil
+}
+
+func runDBMigrate(cmd *cobra.Command, args []string) error {
+	st, err := store.InspectSchema(storePath(dbProject))
+	if err != nil {
+		return err
+	}
+	if st.Version > st.Latest {
+		return fmt.Errorf("schema version %d is newer than this agentbox supports (%d)", st.Version, st.Latest)
+	}
+	if len(st.Pending) == 0 {
+		fmt.Printf("Already at schema version %d.\n", st.Version)
+		return nil
+	}
+
+	if dbMigrateDryRun {
+		fmt.Printf("Would migrate %s from version %d to %d:\n", st.Path, st.Version, st.Latest)
+		printMigrations(st.Pending)
+		return nil
+	}
+
+	s, err := store.Open(st.Path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	fmt.Printf("Migrated %s from version %d to %d:\n", st.Path, st.Version, st.Latest)
+	printMigrations(s.AppliedMigrations())
+	if backup := s.BackupPath(); backup != "" {
+		fmt.Printf("Backup: %s\n", backup)
+	}
+	return nil
+}
+
+func printMigrations(ms []store.Migration) {
+	for _, m := range ms {
+		fmt.Printf("  %04d  %s\n", m.Version, m.Name)
+	}
+}
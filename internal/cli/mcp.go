@@ -1,7 +1,16 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -14,15 +23,26 @@ var mcpCmd = &cobra.Command{
 	Long: `MCP server commands for exposing agentbox capabilities to MCP clients.
 
 The MCP server uses JSON-RPC 2.0 over stdio to communicate with any
-MCP-compatible client (Claude Desktop, VS Code, etc).`,
+MCP-compatible client (Claude Desktop, VS Code, etc), or over HTTP so
+several clients can share one long-running server.`,
 }
 
 var mcpServeCmd = &cobra.Command{
 	Use:   "serve",
-	Short: "Start the MCP server on stdio",
+	Short: "Start the MCP server on stdio or HTTP",
 	Long: `Start an MCP server that reads JSON-RPC requests from stdin and writes
 responses to stdout. This is the standard MCP stdio transport.
 
+With --http, serve the MCP streamable HTTP transport on the given address
+instead. Clients POST to /mcp and open a GET stream there for progress
+notifications. Every request must carry "Authorization: Bearer <token>".
+The token comes from --token-file or the AGENTBOX_MCP_TOKEN environment
+variable. All clients share session state, so a session started by one
+editor can be watched or cancelled from another.
+
+Resources (journals, transcripts, retros, prd.json, progress.txt) and
+prompts are read from the --project directory.
+
 Usage with Claude Desktop (claude_desktop_config.json):
   {
     "mcpServers": {
@@ -32,14 +52,87 @@ Usage with Claude Desktop (claude_desktop_config.json):
       }
     }
   }`,
+	Example: `  agentbox mcp serve
+  AGENTBOX_MCP_TOKEN=secret agentbox mcp serve --http 127.0.0.1:8765
+  agentbox mcp serve --http :8765 --token-file ~/.config/agentbox/mcp-token`,
 	RunE: runMCPServe,
 }
 
+var (
+	mcpHTTPAddr  string
+	mcpTokenFile string
+	mcpProject   string
+)
+
 func init() {
+	mcpServeCmd.Flags().StringVar(&mcpHTTPAddr, "http", "", "serve the streamable HTTP transport on this address instead of stdio")
+	mcpServeCmd.Flags().StringVarP(&mcpProject, "project", "p", ".", "project directory whose PRD, progress and store are exposed as resources")
+	mcpServeCmd.Flags().StringVar(&mcpTokenFile, "token-file", "", "file containing the bearer token for --http (default: $AGENTBOX_MCP_TOKEN)")
 	mcpCmd.AddCommand(mcpServeCmd)
 }
 
 func runMCPServe(cmd *cobra.Command, args []string) error {
-	srv := mcp.NewServer(os.Stdin, os.Stdout, nil)
-	return srv.Run()
+	if mcpHTTPAddr == "" {
+		srv := mcp.NewServer(os.Stdin, os.Stdout, nil)
+		srv.SetProjectDir(mcpProject)
+		return srv.Run()
+	}
+
+	token, err := mcpToken()
+	if err != nil {
+		return err
+	}
+	handler, err := mcp.NewHTTPServer(token, logger)
+	if err != nil {
+		return err
+	}
+	handler.SetProjectDir(mcpProject)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle(mcp.HTTPPath, handler)
+	srv := &http.Server{
+		Addr:              mcpHTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Request contexts end on shutdown so open SSE streams close.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("MCP HTTP server listening", "addr", mcpHTTPAddr, "path", mcp.HTTPPath)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("serving MCP over HTTP: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// mcpToken returns the bearer token for the HTTP transport.
+func mcpToken() (string, error) {
+	if mcpTokenFile != "" {
+		data, err := os.ReadFile(mcpTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("reading token file: %w", err)
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("token file %s is empty", mcpTokenFile)
+	}
+	if token := os.Getenv("AGENTBOX_MCP_TOKEN"); token != "" {
+		return token, nil
+	}
+	return "", fmt.Errorf("--http requires a bearer token: set AGENTBOX_MCP_TOKEN or use --token-file")
 }
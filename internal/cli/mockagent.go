@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/swamp-dev/agentbox/internal/mockagent"
+)
+
+var (
+	mockAgentFixture string
+	mockAgentPrompt  string
+	mockAgentReset   bool
+)
+
+var mockAgentCmd = &cobra.Command{
+	Use:   "mock-agent",
+	Short: "Replay scripted agent responses from a fixture (internal use)",
+	Long: `Run the built-in mock agent. Each invocation replays the next unused
+response from the fixture whose "match" string appears in the prompt,
+applying its file edits and commands in the current directory.
+
+Used inside agent containers when the agent is "mock".`,
+	Hidden: true,
+	RunE:   runMockAgent,
+}
+
+func init() {
+	mockAgentCmd.Flags().StringVar(&mockAgentFixture, "fixture", mockagent.DefaultFixture, "fixture file (YAML or JSON)")
+	mockAgentCmd.Flags().StringVar(&mockAgentPrompt, "prompt", "", "prompt from the orchestrator")
+	mockAgentCmd.Flags().BoolVar(&mockAgentReset, "reset", false, "forget replay progress and exit")
+}
+
+func runMockAgent(cmd *cobra.Command, args []string) error {
+	if mockAgentReset {
+		return mockagent.Reset(mockAgentFixture)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	err = mockagent.Run(cmd.OutOrStdout(), mockAgentFixture, wd, mockAgentPrompt)
+	var exitErr *mockagent.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	return err
+}
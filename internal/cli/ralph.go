@@ -20,6 +20,7 @@ var (
 	ralphMaxIterations int
 	ralphPRDFile       string
 	ralphAutoCommit    bool
+	ralphBaseURL       string
 )
 
 var ralphCmd = &cobra.Command{
@@ -51,6 +52,7 @@ func init() {
 	ralphCmd.Flags().IntVar(&ralphMaxIterations, "max-iterations", 10, "maximum iterations before stopping")
 	ralphCmd.Flags().StringVar(&ralphPRDFile, "prd", "prd.json", "PRD file path")
 	ralphCmd.Flags().BoolVar(&ralphAutoCommit, "auto-commit", true, "automatically commit changes after each task")
+	ralphCmd.Flags().StringVar(&ralphBaseURL, "base-url", "", "alternative API endpoint for the agent (gateway or local model server)")
 }
 
 func runRalph(cmd *cobra.Command, args []string) error {
@@ -72,6 +74,9 @@ func runRalph(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("auto-commit") {
 		cfg.Ralph.AutoCommit = ralphAutoCommit
 	}
+	if cmd.Flags().Changed("base-url") {
+		cfg.Agent.BaseURL = ralphBaseURL
+	}
 
 	// Resolve the effective agent name for validation below.
 	ralphAgent = cfg.Agent.Name
@@ -88,7 +93,7 @@ func runRalph(cmd *cobra.Command, args []string) error {
 
 	// Set agent-default endpoints for restricted mode.
 	if cfg.Docker.Network == "restricted" && len(cfg.Docker.AllowedEndpoints) == 0 {
-		ag, _ := agent.New(ralphAgent)
+		ag, _ := agent.NewWithOptions(ralphAgent, agent.Options{BaseURL: cfg.Agent.BaseURL, Network: cfg.Docker.Network, Model: cfg.Agent.Model})
 		if ag != nil {
 			cfg.Docker.AllowedEndpoints = ag.AllowedEndpoints()
 		}
@@ -60,7 +60,12 @@ func init() {
 	rootCmd.AddCommand(journalCmd)
 	rootCmd.AddCommand(mcpCmd)
 	rootCmd.AddCommand(proxyCmd)
+	rootCmd.AddCommand(mockAgentCmd)
 	rootCmd.AddCommand(waitCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(sessionsCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(worktreesCmd)
 }
 
 func initConfig() {
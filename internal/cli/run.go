@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -12,6 +16,8 @@ import (
 	"github.com/swamp-dev/agentbox/internal/agent"
 	"github.com/swamp-dev/agentbox/internal/config"
 	"github.com/swamp-dev/agentbox/internal/container"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/workflow"
 )
 
 var (
@@ -23,6 +29,9 @@ var (
 	runInteractive    bool
 	runAllowNetwork   bool
 	runAllowEndpoints []string
+	runBaseURL        string
+	runIsolation      string
+	runApprove        bool
 )
 
 var runCmd = &cobra.Command{
@@ -34,7 +43,13 @@ The container is isolated from the host system by default.
 Examples:
   agentbox run --agent claude --project ./my-app --prompt "Fix the bug in auth.ts"
   agentbox run --agent aider --interactive
-  agentbox run --allow-network  # Enable network access for API calls`,
+  agentbox run --allow-network  # Enable network access for API calls
+  agentbox run --isolation copy --approve --prompt "Refactor the parser"
+
+With --isolation copy the agent works on a private copy of the project.
+When it exits, its changes are checked against the commit policy
+(protected paths and size limits) and the quality checks, and only then
+applied to the project. --approve shows the patch for review first.`,
 	RunE: runRun,
 }
 
@@ -47,6 +62,9 @@ func init() {
 	runCmd.Flags().BoolVarP(&runInteractive, "interactive", "i", false, "run in interactive mode")
 	runCmd.Flags().BoolVar(&runAllowNetwork, "allow-network", false, "allow outbound network access (restricted egress)")
 	runCmd.Flags().StringSliceVar(&runAllowEndpoints, "allow-endpoint", nil, "additional allowed endpoints for restricted mode (host:port)")
+	runCmd.Flags().StringVar(&runBaseURL, "base-url", "", "alternative API endpoint for the agent (gateway or local model server)")
+	runCmd.Flags().StringVar(&runIsolation, "isolation", "bind", "how the project is mounted (bind: writable; copy: private copy, changes applied as a patch)")
+	runCmd.Flags().BoolVar(&runApprove, "approve", false, "review the agent's patch before it is applied (implies --isolation copy)")
 
 	runCmd.MarkFlagsMutuallyExclusive("allow-network", "network")
 }
@@ -64,11 +82,23 @@ func runRun(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("image") {
 		cfg.Docker.Image = runImage
 	}
+	if cmd.Flags().Changed("base-url") {
+		cfg.Agent.BaseURL = runBaseURL
+	}
 	if runAllowNetwork {
 		cfg.Docker.Network = "restricted"
 	} else if cmd.Flags().Changed("network") {
 		cfg.Docker.Network = runNetwork
 	}
+	if cmd.Flags().Changed("isolation") {
+		cfg.Docker.Isolation = runIsolation
+	}
+	if runApprove {
+		if cfg.Docker.Isolation == "bind" && cmd.Flags().Changed("isolation") {
+			return fmt.Errorf("--approve needs --isolation copy")
+		}
+		cfg.Docker.Isolation = "copy"
+	}
 
 	// Resolve the effective agent name for use below.
 	runAgent = cfg.Agent.Name
@@ -85,7 +115,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 
 	// Merge agent-default endpoints with any user-specified endpoints.
 	if cfg.Docker.Network == "restricted" {
-		ag, agErr := agent.New(runAgent)
+		ag, agErr := agent.NewWithOptions(runAgent, agent.Options{BaseURL: cfg.Agent.BaseURL, Network: cfg.Docker.Network, Model: cfg.Agent.Model})
 		if agErr != nil {
 			logger.Warn("failed to create agent for endpoint config", "error", agErr)
 		}
@@ -100,7 +130,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	ag, err := agent.New(runAgent)
+	ag, err := agent.NewWithOptions(runAgent, agent.Options{BaseURL: cfg.Agent.BaseURL, Network: cfg.Docker.Network, Model: cfg.Agent.Model})
 	if err != nil {
 		return err
 	}
@@ -114,15 +144,33 @@ func runRun(cmd *cobra.Command, args []string) error {
 	agentCmd := ag.Command(runPrompt)
 	env := ag.Environment()
 
-	containerCfg, err := container.ConfigToContainerConfig(cfg, runProject, agentCmd, env)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mountPath := runProject
+	var sandbox *workflow.Sandbox
+	if cfg.Docker.Isolation == "copy" {
+		if err := container.ValidateProjectPath(runProject); err != nil {
+			return err
+		}
+		sandbox, err = workflow.NewSandbox(ctx, runProject)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := sandbox.Remove(); err != nil {
+				logger.Warn("could not remove sandbox", "dir", sandbox.Dir, "error", err)
+			}
+		}()
+		mountPath = sandbox.Dir
+	}
+
+	containerCfg, err := container.ConfigToContainerConfig(cfg, mountPath, agentCmd, env)
 	if err != nil {
 		return fmt.Errorf("building container config: %w", err)
 	}
 	containerCfg.Interactive = runInteractive
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
@@ -136,6 +184,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 		"project", runProject,
 		"image", containerCfg.Image,
 		"network", cfg.Docker.Network,
+		"isolation", cfg.Docker.Isolation,
 	)
 
 	if runInteractive {
@@ -146,7 +195,10 @@ func runRun(cmd *cobra.Command, args []string) error {
 		defer func() { _ = cm.Remove(context.Background(), containerID) }()
 
 		logger.Info("attaching to container", "id", containerID[:12])
-		return cm.Attach(ctx, containerID)
+		if err := cm.Attach(ctx, containerID); err != nil || sandbox == nil {
+			return err
+		}
+		return applySandbox(ctx, cfg, sandbox, runApprove, os.Stdin, os.Stdout)
 	}
 
 	output, err := cm.Run(ctx, containerCfg)
@@ -166,5 +218,65 @@ func runRun(cmd *cobra.Command, args []string) error {
 		logger.Info("agent completed task successfully")
 	}
 
+	if sandbox != nil {
+		return applySandbox(ctx, cfg, sandbox, runApprove, os.Stdin, os.Stdout)
+	}
+	return nil
+}
+
+// applySandbox applies the agent's changes in sb to the project once they
+// pass the commit policy and the quality checks and, with approve, the
+// user's review. Refused changes are saved to a patch file so the work is
+// not lost.
+func applySandbox(ctx context.Context, cfg *config.Config, sb *workflow.Sandbox, approve bool, in io.Reader, out io.Writer) error {
+	patch, changes, err := sb.Changes(ctx)
+	if err != nil {
+		return fmt.Errorf("reading the agent's changes: %w", err)
+	}
+	if patch == "" {
+		fmt.Fprintln(out, "The agent made no changes.")
+		return nil
+	}
+
+	refuse := func(reason error) error {
+		f, err := os.CreateTemp("", "agentbox-*.patch")
+		if err == nil {
+			_, err = f.WriteString(patch)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("changes not applied: %w (saving the patch failed: %v)", reason, err)
+		}
+		return fmt.Errorf("changes not applied, patch saved to %s: %w", f.Name(), reason)
+	}
+
+	exempt := []string{".agentbox", cfg.Ralph.ProgressFile, cfg.Ralph.PRDFile}
+	if perr := workflow.CheckChanges(cfg.CommitPolicy(), changes, exempt); perr != nil {
+		return refuse(perr)
+	}
+	var paths []string
+	for _, c := range changes {
+		if !slices.ContainsFunc(exempt, func(e string) bool { return workflow.MatchPath(e, c.Path) }) {
+			paths = append(paths, c.Path)
+		}
+	}
+	if err := ralph.RunCheckSets(ctx, sb.Dir, cfg.CheckSets("", paths), logger); err != nil {
+		return refuse(fmt.Errorf("quality check failed: %w", err))
+	}
+
+	if approve {
+		fmt.Fprintf(out, "\n%s\nApply these changes to %d files? [y/N] ", patch, len(changes))
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return refuse(fmt.Errorf("rejected on review"))
+		}
+	}
+
+	if err := sb.Apply(ctx, patch); err != nil {
+		return refuse(err)
+	}
+	fmt.Fprintf(out, "Applied the agent's changes to %d files.\n", len(changes))
 	return nil
 }
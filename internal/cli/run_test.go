@@ -2,10 +2,17 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/spf13/pflag"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/workflow"
 )
 
 // resetRunFlags resets package-level run flag variables to their defaults
@@ -21,6 +28,8 @@ func resetRunFlags() {
 	runInteractive = false
 	runAllowNetwork = false
 	runAllowEndpoints = nil
+	runIsolation = "bind"
+	runApprove = false
 
 	runCmd.Flags().VisitAll(func(f *pflag.Flag) {
 		f.Changed = false
@@ -46,6 +55,8 @@ func TestRunCmd_FlagRegistration(t *testing.T) {
 		{"interactive", "i"},
 		{"allow-network", ""},
 		{"allow-endpoint", ""},
+		{"isolation", ""},
+		{"approve", ""},
 	}
 
 	for _, f := range flags {
@@ -72,6 +83,7 @@ func TestRunCmd_DefaultFlagValues(t *testing.T) {
 		{"prompt", ""},
 		{"network", "none"},
 		{"image", "full"},
+		{"isolation", "bind"},
 	}
 
 	for _, tt := range tests {
@@ -147,3 +159,81 @@ func TestRunCmd_MutuallyExclusiveFlags(t *testing.T) {
 		t.Errorf("expected error about allow-network/network flag conflict, got: %v", err)
 	}
 }
+
+// newRunSandbox returns a git project with one committed file and a sandbox
+// of it in which the agent has rewritten that file and added another.
+func newRunSandbox(t *testing.T) (string, *workflow.Sandbox) {
+	t.Helper()
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=T", "add", "-A"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=T", "commit", "-qm", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", project}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	sb, err := workflow.NewSandbox(context.Background(), project)
+	if err != nil {
+		t.Fatalf("NewSandbox: %v", err)
+	}
+	t.Cleanup(func() { _ = sb.Remove() })
+	for name, content := range map[string]string{"main.go": "package main\n\nfunc main() {}\n", "go.mod": "module x\n"} {
+		if err := os.WriteFile(filepath.Join(sb.Dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return project, sb
+}
+
+func TestApplySandbox(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("approved", func(t *testing.T) {
+		project, sb := newRunSandbox(t)
+		var out bytes.Buffer
+		if err := applySandbox(ctx, config.DefaultConfig(), sb, true, strings.NewReader("y\n"), &out); err != nil {
+			t.Fatalf("applySandbox: %v", err)
+		}
+		if !strings.Contains(out.String(), "+func main() {}") {
+			t.Errorf("review output lacks the patch:\n%s", out.String())
+		}
+		if _, err := os.Stat(filepath.Join(project, "go.mod")); err != nil {
+			t.Errorf("go.mod not applied: %v", err)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		project, sb := newRunSandbox(t)
+		err := applySandbox(ctx, config.DefaultConfig(), sb, true, strings.NewReader("n\n"), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "rejected on review") {
+			t.Fatalf("applySandbox = %v, want a rejection", err)
+		}
+		if _, err := os.Stat(filepath.Join(project, "go.mod")); !os.IsNotExist(err) {
+			t.Errorf("rejected change applied: %v", err)
+		}
+	})
+
+	t.Run("protected path", func(t *testing.T) {
+		project, sb := newRunSandbox(t)
+		cfg := config.DefaultConfig()
+		cfg.Commit.ProtectedPaths = []string{"go.mod"}
+		err := applySandbox(ctx, cfg, sb, false, strings.NewReader(""), &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), "protected paths go.mod") {
+			t.Fatalf("applySandbox = %v, want a policy violation", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(project, "main.go")); string(data) != "package main\n" {
+			t.Errorf("main.go = %q, want nothing applied", data)
+		}
+		// The refused work is kept as a patch.
+		name := strings.TrimSpace(strings.SplitN(strings.SplitN(err.Error(), "patch saved to ", 2)[1], ":", 2)[0])
+		defer os.Remove(name)
+		if data, _ := os.ReadFile(name); !strings.Contains(string(data), "module x") {
+			t.Errorf("saved patch %s = %q", name, data)
+		}
+	})
+}
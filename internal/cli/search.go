@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search transcripts, journal entries, tasks and reviews",
+	Long: `Full-text search over everything the store has recorded: agent transcripts,
+journal entries, task titles and descriptions, and review findings.
+
+Results match every word of the query and are ranked best first. Each result
+names its session, task and, for transcripts, the attempt it came from.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSearch,
+}
+
+var (
+	searchProject string
+	searchSession int64
+	searchTask    string
+	searchKinds   []string
+	searchLimit   int
+	searchJSON    bool
+)
+
+func init() {
+	searchCmd.Flags().StringVarP(&searchProject, "project", "p", ".", "project directory")
+	searchCmd.Flags().Int64Var(&searchSession, "session", 0, "only search this session")
+	searchCmd.Flags().StringVar(&searchTask, "task", "", "only search this task")
+	searchCmd.Flags().StringSliceVar(&searchKinds, "kind", nil, "only search these kinds (transcript, journal, task, review)")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "maximum number of results")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "output as JSON")
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	s, err := openProjectStore(searchProject)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	results, err := s.Search(store.SearchQuery{
+		Text:      strings.Join(args, " "),
+		SessionID: searchSession,
+		TaskID:    searchTask,
+		Kinds:     searchKinds,
+		Limit:     searchLimit,
+	})
+	if err != nil {
+		return err
+	}
+	if searchJSON {
+		return writeJSON(os.Stdout, results)
+	}
+	if len(results) == 0 {
+		fmt.Println("No matches.")
+		return nil
+	}
+	printSearchResults(os.Stdout, results)
+	return nil
+}
+
+func printSearchResults(w io.Writer, results []*store.SearchResult) {
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\n  %s\n", searchRef(r), strings.ReplaceAll(r.Snippet, "\n", " "))
+	}
+}
+
+// searchRef describes where a result came from.
+func searchRef(r *store.SearchResult) string {
+	ref := fmt.Sprintf("session %d", r.SessionID)
+	if r.TaskID != "" {
+		ref += " task " + r.TaskID
+	}
+	switch r.Kind {
+	case "transcript":
+		ref += fmt.Sprintf(" attempt %d transcript", r.AttemptID)
+	case "journal":
+		ref += " journal entry " + r.Ref
+	case "review":
+		ref += " review " + r.Ref
+	case "task":
+		ref += " description"
+	}
+	return ref
+}
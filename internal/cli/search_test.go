@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+func TestPrintSearchResults(t *testing.T) {
+	var buf bytes.Buffer
+	printSearchResults(&buf, []*store.SearchResult{
+		{Kind: "transcript", Ref: "12", SessionID: 3, TaskID: "t-auth", AttemptID: 12, Snippet: "editing [auth.ts]\n[nil] pointer"},
+		{Kind: "journal", Ref: "4", SessionID: 3, Snippet: "the [nil] pointer"},
+	})
+	out := buf.String()
+	for _, want := range []string{
+		"session 3 task t-auth attempt 12 transcript",
+		"editing [auth.ts] [nil] pointer",
+		"session 3 journal entry 4",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
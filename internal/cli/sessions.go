@@ -0,0 +1,392 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Browse, compare and prune stored supervisor sessions",
+	Long: `Work with every supervisor session recorded in .agentbox/agentbox.db,
+not just the latest one.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions with status, duration, tasks and tokens",
+	Args:  cobra.NoArgs,
+	RunE:  runSessionsList,
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "Show one session's summary and timeline",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSessionsShow,
+}
+
+var sessionsDiffCmd = &cobra.Command{
+	Use:   "diff <session-id> <session-id>",
+	Short: "Compare two sessions' velocity and quality",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSessionsDiff,
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old transcripts and vacuum the database",
+	Long: `Clear agent transcripts from finished sessions by age and/or total size,
+then vacuum the database. Attempts, tasks and metrics are kept; only the
+transcript text is removed. Running sessions and sessions set to keep their
+transcripts are never pruned.
+
+Without --older-than or --max-size, the storage retention settings in
+agentbox.yaml are applied, with each session's own retention taking
+precedence.`,
+	Args: cobra.NoArgs,
+	RunE: runSessionsPrune,
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session's full history as JSON",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSessionsExport,
+}
+
+var (
+	sessionsProject     string
+	sessionsJSON        bool
+	sessionsLimit       int
+	sessionsOlderThan   string
+	sessionsMaxSize     string
+	sessionsDryRun      bool
+	sessionsTranscripts bool
+	sessionsOutput      string
+)
+
+func init() {
+	sessionsCmd.PersistentFlags().StringVarP(&sessionsProject, "project", "p", ".", "project directory")
+
+	sessionsListCmd.Flags().IntVar(&sessionsLimit, "limit", 0, "show only the N most recent sessions")
+	sessionsListCmd.Flags().BoolVar(&sessionsJSON, "json", false, "output as JSON")
+	sessionsShowCmd.Flags().BoolVar(&sessionsJSON, "json", false, "output as JSON")
+	sessionsDiffCmd.Flags().BoolVar(&sessionsJSON, "json", false, "output as JSON")
+
+	sessionsPruneCmd.Flags().StringVar(&sessionsOlderThan, "older-than", "", "prune transcripts of sessions started longer ago than this (e.g. 30d, 72h)")
+	sessionsPruneCmd.Flags().StringVar(&sessionsMaxSize, "max-size", "", "prune the oldest transcripts until they total at most this size (e.g. 500MB)")
+	sessionsPruneCmd.Flags().BoolVar(&sessionsDryRun, "dry-run", false, "report what would be pruned without changing anything")
+
+	sessionsExportCmd.Flags().BoolVar(&sessionsTranscripts, "transcripts", false, "include agent transcripts")
+	sessionsExportCmd.Flags().StringVarP(&sessionsOutput, "output", "o", "", "write to a file instead of stdout")
+
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsShowCmd)
+	sessionsCmd.AddCommand(sessionsDiffCmd)
+	sessionsCmd.AddCommand(sessionsPruneCmd)
+	sessionsCmd.AddCommand(sessionsExportCmd)
+}
+
+// openProjectStore opens an existing project database without creating one.
+func openProjectStore(project string) (*store.Store, error) {
+	path := storePath(project)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no agentbox database found at %s", path)
+	}
+	return store.Open(path)
+}
+
+func parseSessionID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid session id %q", arg)
+	}
+	return id, nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runSessionsList(cmd *cobra.Command, args []string) error {
+	s, err := openProjectStore(sessionsProject)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	sums, err := s.SessionSummaries(sessionsLimit)
+	if err != nil {
+		return fmt.Errorf("loading sessions: %w", err)
+	}
+	if sessionsJSON {
+		return writeJSON(os.Stdout, sums)
+	}
+	if len(sums) == 0 {
+		fmt.Println("No sessions found.")
+		return nil
+	}
+	printSessionList(os.Stdout, sums)
+	return nil
+}
+
+func printSessionList(w io.Writer, sums []*store.SessionSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tBRANCH\tSTARTED\tDURATION\tTASKS\tTOKENS")
+	for _, sum := range sums {
+		sess := sum.Session
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d/%d\t%d\n",
+			sess.ID, sess.Status, sess.BranchName,
+			sess.StartedAt.Local().Format("2006-01-02 15:04"),
+			formatMs(sum.DurationMs),
+			sum.Tasks.Completed, sum.Tasks.Total, sum.Tokens)
+	}
+	tw.Flush()
+}
+
+func runSessionsShow(cmd *cobra.Command, args []string) error {
+	id, err := parseSessionID(args[0])
+	if err != nil {
+		return err
+	}
+	s, err := openProjectStore(sessionsProject)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	export, err := s.ExportSession(id, false)
+	if err != nil {
+		return err
+	}
+	if sessionsJSON {
+		return writeJSON(os.Stdout, map[string]interface{}{
+			"summary":  export.Summary,
+			"timeline": export.Timeline(),
+		})
+	}
+	printSessionShow(os.Stdout, export)
+	return nil
+}
+
+func printSessionShow(w io.Writer, e *store.SessionExport) {
+	sum := e.Summary
+	sess := sum.Session
+	fmt.Fprintf(w, "Session:   #%d (%s)\n", sess.ID, sess.Status)
+	if sess.RepoURL != "" {
+		fmt.Fprintf(w, "Repo:      %s\n", sess.RepoURL)
+	}
+	if sess.BranchName != "" {
+		fmt.Fprintf(w, "Branch:    %s\n", sess.BranchName)
+	}
+	fmt.Fprintf(w, "Started:   %s\n", sess.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Duration:  %s\n", formatMs(sum.DurationMs))
+	fmt.Fprintf(w, "Tasks:     %d/%d completed, %d failed, %d deferred\n",
+		sum.Tasks.Completed, sum.Tasks.Total, sum.Tasks.Failed, sum.Tasks.Deferred)
+	fmt.Fprintf(w, "Attempts:  %d (%.0f%% succeeded)\n", sum.Attempts, sum.SuccessRate()*100)
+	fmt.Fprintf(w, "Sprints:   %d (velocity %.1f tasks/sprint)\n", sum.Sprints, sum.Velocity)
+	fmt.Fprintf(w, "Quality:   %s, %.1f%% tests passing\n", sum.QualityTrend, sum.TestPassRate*100)
+	fmt.Fprintf(w, "Tokens:    %d\n", sum.Tokens)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "--- Timeline ---")
+	for _, ev := range e.Timeline() {
+		task := ""
+		if ev.TaskID != "" {
+			task = " [" + ev.TaskID + "]"
+		}
+		fmt.Fprintf(w, "%s  %-8s%s %s\n", ev.Time.Local().Format("2006-01-02 15:04:05"), ev.Kind, task, ev.Detail)
+	}
+}
+
+// sessionMetric is one row of a session comparison.
+type sessionMetric struct {
+	Name   string  `json:"name"`
+	A      float64 `json:"a"`
+	B      float64 `json:"b"`
+	Format string  `json:"-"`
+}
+
+func compareSessions(a, b *store.SessionSummary) []sessionMetric {
+	pct := func(v float64) float64 { return v * 100 }
+	return []sessionMetric{
+		{"velocity (tasks/sprint)", a.Velocity, b.Velocity, "%.1f"},
+		{"sprints", float64(a.Sprints), float64(b.Sprints), "%.0f"},
+		{"tasks completed", float64(a.Tasks.Completed), float64(b.Tasks.Completed), "%.0f"},
+		{"tasks failed", float64(a.Tasks.Failed), float64(b.Tasks.Failed), "%.0f"},
+		{"attempt success (%)", pct(a.SuccessRate()), pct(b.SuccessRate()), "%.1f"},
+		{"test pass rate (%)", pct(a.TestPassRate), pct(b.TestPassRate), "%.1f"},
+		{"tokens", float64(a.Tokens), float64(b.Tokens), "%.0f"},
+		{"duration (min)", float64(a.DurationMs) / 60000, float64(b.DurationMs) / 60000, "%.1f"},
+	}
+}
+
+func runSessionsDiff(cmd *cobra.Command, args []string) error {
+	idA, err := parseSessionID(args[0])
+	if err != nil {
+		return err
+	}
+	idB, err := parseSessionID(args[1])
+	if err != nil {
+		return err
+	}
+	s, err := openProjectStore(sessionsProject)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	a, err := s.SummarizeSession(idA)
+	if err != nil {
+		return err
+	}
+	b, err := s.SummarizeSession(idB)
+	if err != nil {
+		return err
+	}
+
+	if sessionsJSON {
+		return writeJSON(os.Stdout, map[string]interface{}{
+			"a":       a,
+			"b":       b,
+			"metrics": compareSessions(a, b),
+		})
+	}
+	printSessionDiff(os.Stdout, a, b)
+	return nil
+}
+
+func printSessionDiff(w io.Writer, a, b *store.SessionSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "METRIC\t#%d\t#%d\tCHANGE\n", a.Session.ID, b.Session.ID)
+	for _, m := range compareSessions(a, b) {
+		fmt.Fprintf(tw, "%s\t"+m.Format+"\t"+m.Format+"\t%+"+strings.TrimPrefix(m.Format, "%")+"\n",
+			m.Name, m.A, m.B, m.B-m.A)
+	}
+	fmt.Fprintf(tw, "quality trend\t%s\t%s\t\n", a.QualityTrend, b.QualityTrend)
+	tw.Flush()
+}
+
+func runSessionsPrune(cmd *cobra.Command, args []string) error {
+	// Without flags, apply the retention policy from agentbox.yaml, which
+	// also honors per-session retention.
+	var policy *store.RetentionPolicy
+	opts := store.PruneOptions{DryRun: sessionsDryRun}
+	if sessionsOlderThan == "" && sessionsMaxSize == "" {
+		cfg, _, err := config.LoadForProject(sessionsProject)
+		if err != nil {
+			return err
+		}
+		maxAge, maxBytes, err := cfg.Storage.RetentionLimits()
+		if err != nil {
+			return err
+		}
+		if maxAge == 0 && maxBytes == 0 {
+			return fmt.Errorf("set --older-than or --max-size, or storage.transcript_retention or storage.max_transcript_size in agentbox.yaml")
+		}
+		policy = &store.RetentionPolicy{MaxAge: maxAge, MaxBytes: maxBytes}
+	}
+	if sessionsOlderThan != "" {
+		age, err := config.ParseAge(sessionsOlderThan)
+		if err != nil {
+			return err
+		}
+		opts.Before = time.Now().Add(-age)
+	}
+	if sessionsMaxSize != "" {
+		size, err := config.ParseSize(sessionsMaxSize)
+		if err != nil {
+			return err
+		}
+		opts.MaxBytes = size
+	}
+
+	s, err := openProjectStore(sessionsProject)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var res *store.PruneResult
+	if policy != nil {
+		res, err = s.ApplyRetention(*policy, sessionsDryRun)
+	} else {
+		res, err = s.PruneTranscripts(opts)
+	}
+	if err != nil {
+		return err
+	}
+	verb := "Pruned"
+	if sessionsDryRun {
+		verb = "Would prune"
+	}
+	fmt.Printf("%s %d transcripts (%s).\n", verb, res.Transcripts, formatBytes(res.Bytes))
+	if res.Vacuumed {
+		fmt.Println("Database vacuumed.")
+	}
+	return nil
+}
+
+func runSessionsExport(cmd *cobra.Command, args []string) error {
+	id, err := parseSessionID(args[0])
+	if err != nil {
+		return err
+	}
+	s, err := openProjectStore(sessionsProject)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	export, err := s.ExportSession(id, sessionsTranscripts)
+	if err != nil {
+		return err
+	}
+	if sessionsOutput == "" {
+		return writeJSON(os.Stdout, export)
+	}
+
+	f, err := os.Create(sessionsOutput)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", sessionsOutput, err)
+	}
+	if err := writeJSON(f, export); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", sessionsOutput, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported session %d to %s\n", id, sessionsOutput)
+	return nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func formatMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+func testSummary(id int64, velocity, passRate float64, completed int) *store.SessionSummary {
+	return &store.SessionSummary{
+		Session:      &store.Session{ID: id, Status: "completed", BranchName: "agentbox/run", StartedAt: time.Now()},
+		DurationMs:   90 * 60 * 1000,
+		Tasks:        &store.TaskStats{Total: 5, Completed: completed},
+		Attempts:     4,
+		Tokens:       12000,
+		Sprints:      2,
+		Velocity:     velocity,
+		TestPassRate: passRate,
+		QualityTrend: "stable",
+	}
+}
+
+func TestPrintSessionList(t *testing.T) {
+	var buf bytes.Buffer
+	printSessionList(&buf, []*store.SessionSummary{testSummary(7, 2, 0.9, 4)})
+	out := buf.String()
+	for _, want := range []string{"ID", "TOKENS", "7", "completed", "agentbox/run", "1h30m0s", "4/5", "12000"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("list output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintSessionDiff(t *testing.T) {
+	var buf bytes.Buffer
+	printSessionDiff(&buf, testSummary(1, 1.5, 0.5, 3), testSummary(2, 2.5, 0.75, 5))
+	out := buf.String()
+	for _, want := range []string{"#1", "#2", "velocity (tasks/sprint)", "+1.0", "test pass rate (%)", "+25.0", "+2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff output missing %q:\n%s", want, out)
+		}
+	}
+}
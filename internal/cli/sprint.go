@@ -6,13 +6,17 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/swamp-dev/agentbox/internal/config"
 	"github.com/swamp-dev/agentbox/internal/metrics"
 	"github.com/swamp-dev/agentbox/internal/ralph"
 	"github.com/swamp-dev/agentbox/internal/supervisor"
+	"github.com/swamp-dev/agentbox/internal/workflow"
 )
 
 var sprintCmd = &cobra.Command{
@@ -23,7 +27,12 @@ executes them iteratively using AI agents in containers, runs quality checks,
 performs code reviews, and opens a pull request with the results.
 
 Leave it running overnight or over a weekend — come back to a PR with finished
-code, retrospective reports, and a dev diary of the agent's experience.`,
+code, retrospective reports, and a dev diary of the agent's experience.
+
+With --address-review <PR>, the unresolved review comments on an open pull
+request become the tasks instead. One sprint runs on the pull request's branch,
+the fixes are pushed, and each addressed comment gets a reply naming the commit
+that fixed it and is resolved.`,
 	RunE: runSprint,
 }
 
@@ -44,8 +53,19 @@ var (
 	sprintDockerCPUs           string
 	sprintDockerNetwork        string
 	sprintDockerAllowEndpoints []string
+	sprintBaseURL              string
 	sprintResume               bool
 	sprintSessionID            int64
+	sprintMaxRetries           int
+	sprintEnsemble             []string
+	sprintEnsembleMinCx        int
+	sprintEnsembleJudge        bool
+	sprintRetention            string
+	sprintDraftPR              bool
+	sprintAddressReview        int
+	sprintCIGate               bool
+	sprintCITimeout            string
+	sprintSyncBase             string
 )
 
 func init() {
@@ -65,8 +85,19 @@ func init() {
 	sprintCmd.Flags().StringVar(&sprintDockerCPUs, "docker-cpus", "2", "container CPU limit")
 	sprintCmd.Flags().StringVar(&sprintDockerNetwork, "docker-network", "none", "container network mode (none, bridge, host, restricted)")
 	sprintCmd.Flags().StringSliceVar(&sprintDockerAllowEndpoints, "allow-endpoint", nil, "additional allowed endpoints for restricted mode (host:port)")
+	sprintCmd.Flags().StringVar(&sprintBaseURL, "base-url", "", "alternative API endpoint for the agent (gateway or local model server)")
 	sprintCmd.Flags().BoolVar(&sprintResume, "resume", false, "resume the most recent interrupted sprint session")
 	sprintCmd.Flags().Int64Var(&sprintSessionID, "session", 0, "session ID to resume (used with --resume)")
+	sprintCmd.Flags().StringSliceVar(&sprintEnsemble, "ensemble", nil, "agents to race on ensemble tasks as agent or agent:model (e.g. claude,aider or claude:opus,claude:sonnet); needs at least two")
+	sprintCmd.Flags().IntVar(&sprintEnsembleMinCx, "ensemble-min-complexity", 0, "run tasks at or above this complexity in ensemble mode (0 = PRD opt-in only)")
+	sprintCmd.Flags().BoolVar(&sprintEnsembleJudge, "ensemble-judge", false, "use the review agent to pick among passing ensemble candidates")
+	sprintCmd.Flags().IntVar(&sprintMaxRetries, "max-retries", 3, "retries for transient agent failures (rate limits, timeouts); 0 disables")
+	sprintCmd.Flags().StringVar(&sprintRetention, "transcript-retention", "", "how long to keep this session's transcripts (e.g. 7d, or keep)")
+	sprintCmd.Flags().IntVar(&sprintAddressReview, "address-review", 0, "fix the unresolved review comments on this PR number, then reply to and resolve them")
+	sprintCmd.Flags().BoolVar(&sprintDraftPR, "draft-pr", false, "open a draft PR after the first successful sprint and update it every sprint")
+	sprintCmd.Flags().BoolVar(&sprintCIGate, "ci-gate", false, "push after each sprint, wait for CI on the branch, and turn failing jobs into fix tasks")
+	sprintCmd.Flags().StringVar(&sprintCITimeout, "ci-timeout", "30m", "how long --ci-gate waits for CI to finish")
+	sprintCmd.Flags().StringVar(&sprintSyncBase, "sync-base", "", "bring new base branch commits into the sprint branch between sprints (merge or rebase)")
 }
 
 func runSprint(cmd *cobra.Command, args []string) error {
@@ -75,14 +106,70 @@ func runSprint(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("--session requires --resume")
 	}
 
+	if sprintResume && sprintAddressReview != 0 {
+		return fmt.Errorf("--address-review cannot be combined with --resume")
+	}
+
 	// Handle resume mode.
 	if sprintResume {
 		return runResume(cmd)
 	}
 
+	cfg, err := sprintConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Address-review dry runs go through the supervisor, which lists the
+	// threads it would work on.
+	if sprintDryRun && cfg.AddressReview == 0 {
+		return printDryRun(cfg)
+	}
+
+	// Set up signal handling.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		logger.Info("received shutdown signal, finishing current iteration...")
+		cancel()
+	}()
+
+	// Set budget duration.
+	if cfg.Budget.MaxDuration > 0 {
+		budgetCtx, budgetCancel := context.WithTimeout(ctx, cfg.Budget.MaxDuration)
+		defer budgetCancel()
+		ctx = budgetCtx
+	}
+
+	sup, err := supervisor.New(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("initializing supervisor: %w", err)
+	}
+
+	if cfg.AddressReview != 0 {
+		return sup.AddressReview(ctx)
+	}
+	return sup.Run(ctx)
+}
+
+// sprintConfig builds the supervisor config for a new sprint session from
+// the project's agentbox.yaml, with the flags the user set on top.
+func sprintConfig(cmd *cobra.Command) (*supervisor.Config, error) {
+	pc, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if err := pc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
 	cfg := supervisor.DefaultConfig()
+	cfg.ApplyProjectConfig(pc)
 
-	// Only override defaults when CLI flags are explicitly set.
+	// Only override the project config when CLI flags are explicitly set.
 	if cmd.Flags().Changed("repo") {
 		cfg.RepoURL = sprintRepo
 	}
@@ -92,6 +179,9 @@ func runSprint(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("agent") {
 		cfg.Agent = sprintAgent
 	}
+	if cmd.Flags().Changed("base-url") {
+		cfg.AgentBaseURL = sprintBaseURL
+	}
 	if cmd.Flags().Changed("review-agent") {
 		cfg.ReviewAgent = sprintReviewAgent
 	}
@@ -131,45 +221,60 @@ func runSprint(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("dry-run") {
 		cfg.DryRun = sprintDryRun
 	}
-
-	if err := cfg.ParseBudgetDuration(); err != nil {
-		return fmt.Errorf("invalid budget duration: %w", err)
+	if cmd.Flags().Changed("max-retries") {
+		cfg.Retry.MaxRetries = sprintMaxRetries
 	}
-
-	if cfg.RepoURL == "" {
-		cwd, _ := os.Getwd()
-		cfg.WorkDir = cwd
+	if len(sprintEnsemble) > 0 {
+		cfg.Ensemble.Agents = make([]supervisor.EnsembleAgent, len(sprintEnsemble))
+		for i, s := range sprintEnsemble {
+			cfg.Ensemble.Agents[i] = supervisor.ParseEnsembleAgent(s)
+		}
 	}
-
-	if sprintDryRun {
-		return printDryRun(cfg)
+	if cmd.Flags().Changed("ensemble-min-complexity") {
+		cfg.Ensemble.MinComplexity = sprintEnsembleMinCx
 	}
+	if cmd.Flags().Changed("ensemble-judge") {
+		cfg.Ensemble.Judge = sprintEnsembleJudge
+	}
+	if cmd.Flags().Changed("draft-pr") {
+		cfg.DraftPR = sprintDraftPR
+	}
+	if cmd.Flags().Changed("ci-gate") {
+		cfg.CIGate = sprintCIGate
+	}
+	if cmd.Flags().Changed("ci-timeout") {
+		if _, err := time.ParseDuration(sprintCITimeout); err != nil {
+			return nil, fmt.Errorf("invalid --ci-timeout: %w", err)
+		}
+		cfg.CITimeout = sprintCITimeout
+	}
+	if cmd.Flags().Changed("sync-base") {
+		if sprintSyncBase != workflow.SyncMerge && sprintSyncBase != workflow.SyncRebase {
+			return nil, fmt.Errorf("invalid --sync-base: %s (must be merge or rebase)", sprintSyncBase)
+		}
+		cfg.SyncBase = sprintSyncBase
+	}
+	if sprintAddressReview < 0 {
+		return nil, fmt.Errorf("--address-review needs a pull request number")
+	}
+	cfg.AddressReview = sprintAddressReview
 
-	// Set up signal handling.
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		logger.Info("received shutdown signal, finishing current iteration...")
-		cancel()
-	}()
-
-	// Set budget duration.
-	if cfg.Budget.MaxDuration > 0 {
-		budgetCtx, budgetCancel := context.WithTimeout(ctx, cfg.Budget.MaxDuration)
-		defer budgetCancel()
-		ctx = budgetCtx
+	if sprintRetention != "" {
+		if _, err := supervisor.ParseRetention(sprintRetention); err != nil {
+			return nil, err
+		}
+		cfg.TranscriptRetention = sprintRetention
 	}
 
-	sup, err := supervisor.New(cfg, logger)
-	if err != nil {
-		return fmt.Errorf("initializing supervisor: %w", err)
+	if err := cfg.ParseBudgetDuration(); err != nil {
+		return nil, fmt.Errorf("invalid budget duration: %w", err)
 	}
 
-	return sup.Run(ctx)
+	if cfg.RepoURL == "" {
+		cwd, _ := os.Getwd()
+		cfg.WorkDir = cwd
+	}
+	return cfg, nil
 }
 
 func runResume(cmd *cobra.Command) error {
@@ -242,6 +347,10 @@ func printDryRun(cfg *supervisor.Config) error {
 	fmt.Printf("Docker Network: %s\n", cfg.DockerNetwork)
 	fmt.Printf("Journal:        %v\n", cfg.JournalEnabled)
 	fmt.Printf("Review:         %v\n", cfg.ReviewEnabled)
+	if cfg.Ensemble.Enabled() {
+		fmt.Printf("Ensemble:       %s (min complexity %d, judge %v)\n",
+			strings.Join(cfg.Ensemble.Members(), ", "), cfg.Ensemble.MinComplexity, cfg.Ensemble.Judge)
+	}
 	fmt.Println()
 
 	// Print task summary from validated PRD.
@@ -252,7 +361,15 @@ func printDryRun(cfg *supervisor.Config) error {
 		if len(t.DependsOn) > 0 {
 			deps = fmt.Sprintf(" (depends on: %v)", t.DependsOn)
 		}
-		fmt.Printf("  • [%s] %s: %s%s\n", t.Status, t.ID, t.Title, deps)
+		ensemble := ""
+		if t.Ensemble && cfg.Ensemble.Enabled() {
+			ensemble = " [ensemble]"
+		}
+		workdir := ""
+		if dir := t.Dir(); dir != "" {
+			workdir = " in " + dir
+		}
+		fmt.Printf("  • [%s] %s: %s%s%s%s\n", t.Status, t.ID, t.Title, deps, ensemble, workdir)
 	}
 	fmt.Println()
 
@@ -261,6 +378,15 @@ func printDryRun(cfg *supervisor.Config) error {
 	fmt.Println("  2. Create worktree branch")
 	fmt.Println("  3. Import PRD → task database")
 	fmt.Printf("  4. Run up to %d sprints × %d iterations\n", cfg.MaxSprints, cfg.SprintSize)
+	if cfg.DraftPR {
+		fmt.Println("     Draft PR opened after the first successful sprint, updated each sprint")
+	}
+	if cfg.CIGate {
+		fmt.Println("     Branch pushed after each sprint; failing CI jobs become fix tasks")
+	}
+	if cfg.SyncBase != "" {
+		fmt.Printf("     Base branch brought in (%s) between sprints; conflicts become resolution tasks\n", cfg.SyncBase)
+	}
 	if cfg.ReviewEnabled {
 		fmt.Printf("  5. Code review after each %s\n", cfg.ReviewAfter)
 	}
@@ -2,12 +2,22 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/swamp-dev/agentbox/internal/metrics"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/supervisor"
+	"github.com/swamp-dev/agentbox/internal/workflow"
 )
 
 func TestSprintCmd_FlagRegistration(t *testing.T) {
@@ -230,3 +240,155 @@ func TestOrDefault(t *testing.T) {
 		})
 	}
 }
+
+// writeSprintProject creates a git project with the given agentbox.yaml and
+// points the sprint command at it.
+func writeSprintProject(t *testing.T, yamlConfig string) string {
+	t.Helper()
+	resetSprintFlags()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=T", "add", "-A"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=T", "commit", "-qm", "init"},
+		{"config", "user.email", "t@example.com"},
+		{"config", "user.name", "T"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	cfgFile = filepath.Join(dir, "agentbox.yaml")
+	if err := os.WriteFile(cfgFile, []byte(yamlConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(resetSprintFlags)
+	return dir
+}
+
+func TestSprintConfig_ProtectedPathBlocksCommit(t *testing.T) {
+	dir := writeSprintProject(t, "commit:\n  protected_paths: [go.mod]\n")
+	cfg, err := sprintConfig(sprintCmd)
+	if err != nil {
+		t.Fatalf("sprintConfig: %v", err)
+	}
+
+	wf := workflow.NewGitWorkflow("", dir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	wf.SetCommitConfig(cfg.CommitPolicy(), nil)
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n\nreplace y => ../y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = wf.Commit(context.Background(), "agent work", nil)
+	var perr *workflow.PolicyError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Commit = %v, want a policy violation", err)
+	}
+}
+
+func TestSprintConfig_FlagsOverrideProjectConfig(t *testing.T) {
+	writeSprintProject(t, "agent:\n  name: amp\ndocker:\n  image: go\n")
+	if err := sprintCmd.ParseFlags([]string{"--docker-image", "node"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := sprintConfig(sprintCmd)
+	if err != nil {
+		t.Fatalf("sprintConfig: %v", err)
+	}
+	if cfg.Agent != "amp" || cfg.DockerImage != "node" {
+		t.Errorf("agent = %s, image = %s; want amp from agentbox.yaml and node from the flag", cfg.Agent, cfg.DockerImage)
+	}
+}
+
+func TestSprintConfig_InvalidProjectConfig(t *testing.T) {
+	writeSprintProject(t, "docker:\n  image: java\n")
+	if _, err := sprintConfig(sprintCmd); err == nil || !strings.Contains(err.Error(), "invalid configuration") {
+		t.Fatalf("sprintConfig = %v, want an invalid configuration error", err)
+	}
+}
+
+func TestSprintConfig_CommitSection(t *testing.T) {
+	writeSprintProject(t, "commit:\n  template: \"{{.TaskID}}: {{.Title}}\"\n  squash: task\n")
+	cfg, err := sprintConfig(sprintCmd)
+	if err != nil {
+		t.Fatalf("sprintConfig: %v", err)
+	}
+	if cfg.Commit.Squash != workflow.SquashTask {
+		t.Errorf("squash = %q, want task", cfg.Commit.Squash)
+	}
+	msg, err := workflow.CommitMessage(cfg.CommitPolicy(), workflow.CommitInfo{TaskID: "t-1", Title: "Add login", Attempt: 1, SessionID: 7})
+	if err != nil {
+		t.Fatalf("CommitMessage: %v", err)
+	}
+	if !strings.HasPrefix(msg, "t-1: Add login\n") || !strings.Contains(msg, "Agentbox-Task: t-1") {
+		t.Errorf("commit message = %q", msg)
+	}
+}
+
+func TestSprintConfig_Packages(t *testing.T) {
+	writeSprintProject(t, `packages:
+  - path: services/api
+    image: go
+    quality_checks:
+      - name: test
+        command: go test ./...
+    protected_paths: [migrations]
+`)
+	cfg, err := sprintConfig(sprintCmd)
+	if err != nil {
+		t.Fatalf("sprintConfig: %v", err)
+	}
+	rc := cfg.ToRalphConfig()
+	if got := rc.ForWorkdir("services/api").Docker.Image; got != "go" {
+		t.Errorf("image for services/api = %s, want go", got)
+	}
+	if sets := rc.CheckSets("services/api", nil); len(sets) != 1 || sets[0].Dir != "services/api" {
+		t.Errorf("check sets = %+v", sets)
+	}
+	if got := cfg.CommitPolicy().ProtectedPaths; len(got) != 1 || got[0] != "services/api/**/migrations" {
+		t.Errorf("protected paths = %v", got)
+	}
+}
+
+func TestSprintConfig_StorageRetentionPrunesOnStart(t *testing.T) {
+	dir := writeSprintProject(t, "storage:\n  transcripts: blobs\n  max_transcript_size: 1KB\ncode_host:\n  provider: gitlab\n")
+	if err := os.MkdirAll(filepath.Join(dir, ".agentbox"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.Open(filepath.Join(dir, ".agentbox", "agentbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := s.CreateSession("", "old", "")
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: old, Title: "Old", Status: "completed", MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	attemptID, err := s.RecordAttempt(&store.Attempt{TaskID: "t-1", SessionID: old, Number: 1, AgentName: "claude", StartedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveTranscript(attemptID, strings.Repeat("agent output\n", 1000)); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.UpdateSessionStatus(old, "completed")
+	s.Close()
+
+	cfg, err := sprintConfig(sprintCmd)
+	if err != nil {
+		t.Fatalf("sprintConfig: %v", err)
+	}
+	if cfg.CodeHost.Provider != "gitlab" {
+		t.Errorf("code host provider = %q, want gitlab", cfg.CodeHost.Provider)
+	}
+	cfg.WorkDir = dir
+	sup, err := supervisor.New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("supervisor.New: %v", err)
+	}
+	defer sup.Store().Close()
+	if got, err := sup.Store().GetTranscript(attemptID); err != nil || got != "" {
+		t.Errorf("transcript after sprint start = %d bytes, %v; want it pruned", len(got), err)
+	}
+}
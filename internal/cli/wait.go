@@ -15,8 +15,9 @@ import (
 
 // Sentinel errors for wait exit codes.
 var (
-	ErrSessionFailed = errors.New("session failed")
-	ErrWaitTimeout   = errors.New("wait timeout exceeded")
+	ErrSessionFailed    = errors.New("session failed")
+	ErrSessionCancelled = errors.New("session cancelled")
+	ErrWaitTimeout      = errors.New("wait timeout exceeded")
 )
 
 // waitConfig holds the parameters for runWait, extracted from cobra flags.
@@ -33,7 +34,8 @@ var waitCmd = &cobra.Command{
 	Use:   "wait",
 	Short: "Block until an async session completes",
 	Long: `Wait blocks until the specified async session (started via ralph_start
-or sprint_start) completes or fails, then prints the result.
+or sprint_start) completes, fails or is cancelled, then prints the result.
+A paused session counts as still running.
 
 Designed for use with Claude Code's Bash run_in_background to get
 notified on completion without polling agentbox_status.
@@ -41,7 +43,8 @@ notified on completion without polling agentbox_status.
 Exit codes:
   0  session completed successfully
   1  session failed
-  2  timeout exceeded`,
+  2  timeout exceeded
+  3  session cancelled`,
 	Example: `  agentbox wait --session abc-123 --project /path/to/project
   agentbox wait --session abc-123 --project . --json
   agentbox wait --session abc-123 --project . --timeout 1h`,
@@ -96,6 +99,9 @@ func runWait(cfg waitConfig) error {
 		case "failed":
 			printWaitResult(state, cfg.jsonOutput)
 			return ErrSessionFailed
+		case "cancelled":
+			printWaitResult(state, cfg.jsonOutput)
+			return ErrSessionCancelled
 		default:
 			// Still running.
 			if time.Now().After(deadline) {
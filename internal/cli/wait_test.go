@@ -48,6 +48,25 @@ func TestRunWait_Failed(t *testing.T) {
 	}
 }
 
+func TestRunWait_Cancelled(t *testing.T) {
+	dir := t.TempDir()
+	sessionID := "test-sess-cancelled"
+
+	if err := mcp.WriteSessionState(dir, sessionID, "cancelled", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runWait(waitConfig{
+		session:      sessionID,
+		project:      dir,
+		timeout:      5 * time.Second,
+		pollInterval: 100 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrSessionCancelled) {
+		t.Errorf("runWait() error = %v, want ErrSessionCancelled", err)
+	}
+}
+
 func TestRunWait_Timeout(t *testing.T) {
 	dir := t.TempDir()
 	sessionID := "test-sess-timeout"
@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/supervisor"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+var worktreesCmd = &cobra.Command{
+	Use:   "worktrees",
+	Short: "List and clean up the git worktrees of supervisor sessions",
+	Long: `Each agentbox sprint works in a git worktree next to the repository.
+These commands cross-reference 'git worktree list' with the sessions in
+.agentbox/agentbox.db. Worktrees of finished sessions are also removed
+automatically by the next sprint once their pull requests have merged.`,
+}
+
+var worktreesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List worktrees with their sessions",
+	Args:  cobra.NoArgs,
+	RunE:  runWorktreesList,
+}
+
+var worktreesPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the worktrees of completed and failed sessions",
+	Long: `Remove the worktrees of completed and failed sessions, and git's records of
+worktrees whose directories are gone. Worktrees with uncommitted changes are
+kept, and a branch is only deleted if git considers it merged. Worktrees of
+running or interrupted sessions, and worktrees no session created, are never
+touched.`,
+	Args: cobra.NoArgs,
+	RunE: runWorktreesPrune,
+}
+
+var worktreesRemoveCmd = &cobra.Command{
+	Use:   "remove <session-id|path>",
+	Short: "Remove one session's worktree and branch",
+	Long: `Remove the worktree of a session, given its ID or the worktree path, and
+delete its branch if it is merged. --force discards uncommitted changes,
+deletes the branch even if unmerged, and allows removing the worktree of an
+interrupted session, which can then no longer be resumed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWorktreesRemove,
+}
+
+var (
+	worktreesProject string
+	worktreesJSON    bool
+	worktreesDryRun  bool
+	worktreesForce   bool
+)
+
+func init() {
+	worktreesCmd.PersistentFlags().StringVarP(&worktreesProject, "project", "p", ".", "project directory")
+	worktreesListCmd.Flags().BoolVar(&worktreesJSON, "json", false, "output as JSON")
+	worktreesPruneCmd.Flags().BoolVar(&worktreesDryRun, "dry-run", false, "report what would be removed without changing anything")
+	worktreesRemoveCmd.Flags().BoolVar(&worktreesForce, "force", false, "discard uncommitted changes and delete the branch even if unmerged")
+
+	worktreesCmd.AddCommand(worktreesListCmd)
+	worktreesCmd.AddCommand(worktreesPruneCmd)
+	worktreesCmd.AddCommand(worktreesRemoveCmd)
+}
+
+// loadWorktrees opens the project's store and lists its session worktrees.
+// The caller closes the store.
+func loadWorktrees(ctx context.Context) (*store.Store, *workflow.GitWorkflow, []supervisor.SessionWorktree, error) {
+	s, err := openProjectStore(worktreesProject)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	wf := workflow.NewGitWorkflow("", worktreesProject, logger)
+	wts, err := supervisor.ListSessionWorktrees(ctx, s, wf)
+	if err != nil {
+		s.Close()
+		return nil, nil, nil, err
+	}
+	return s, wf, wts, nil
+}
+
+func runWorktreesList(cmd *cobra.Command, args []string) error {
+	s, _, wts, err := loadWorktrees(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if worktreesJSON {
+		return writeJSON(os.Stdout, wts)
+	}
+	if len(wts) == 0 {
+		fmt.Println("No worktrees found.")
+		return nil
+	}
+	printWorktreeList(os.Stdout, wts)
+	return nil
+}
+
+func printWorktreeList(w io.Writer, wts []supervisor.SessionWorktree) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tBRANCH\tSESSION\tSTATUS\tPR")
+	for _, wt := range wts {
+		session, status, pr := "-", "-", "-"
+		if wt.Session != nil {
+			session = "#" + strconv.FormatInt(wt.Session.ID, 10)
+			status = wt.Session.Status
+			if wt.Session.PRNumber > 0 {
+				pr = "#" + strconv.Itoa(wt.Session.PRNumber)
+			}
+		}
+		path := wt.Path
+		if wt.Missing {
+			path += " (missing)"
+		}
+		branch := wt.Branch
+		if branch == "" {
+			branch = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", path, branch, session, status, pr)
+	}
+	tw.Flush()
+}
+
+func runWorktreesPrune(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	s, wf, wts, err := loadWorktrees(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	removed := 0
+	for _, wt := range wts {
+		if !wt.Ended() && !(wt.Missing && wt.Session == nil) {
+			continue
+		}
+		if worktreesDryRun {
+			fmt.Printf("would remove %s\n", describeWorktree(wt))
+			continue
+		}
+		branchDeleted, err := supervisor.RemoveSessionWorktree(ctx, s, wf, wt, false)
+		if err != nil {
+			fmt.Printf("kept %s: %v\n", describeWorktree(wt), err)
+			continue
+		}
+		removed++
+		fmt.Printf("removed %s%s\n", describeWorktree(wt), branchNote(wt, branchDeleted))
+	}
+	if !worktreesDryRun {
+		if err := wf.PruneWorktrees(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d worktrees.\n", removed)
+	}
+	return nil
+}
+
+func runWorktreesRemove(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	s, wf, wts, err := loadWorktrees(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	wt, err := findWorktree(wts, args[0])
+	if err != nil {
+		return err
+	}
+	if wt.Session != nil {
+		switch wt.Session.Status {
+		case "running":
+			return fmt.Errorf("session #%d is still running in %s", wt.Session.ID, wt.Path)
+		case "interrupted":
+			if !worktreesForce {
+				return fmt.Errorf("session #%d is interrupted and can be resumed from %s; use --force to remove it anyway", wt.Session.ID, wt.Path)
+			}
+		}
+	}
+
+	branchDeleted, err := supervisor.RemoveSessionWorktree(ctx, s, wf, wt, worktreesForce)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("removed %s%s\n", describeWorktree(wt), branchNote(wt, branchDeleted))
+	return nil
+}
+
+// findWorktree picks the worktree named by a session ID or a path.
+func findWorktree(wts []supervisor.SessionWorktree, arg string) (supervisor.SessionWorktree, error) {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		for _, wt := range wts {
+			if wt.Session != nil && wt.Session.ID == id {
+				return wt, nil
+			}
+		}
+		return supervisor.SessionWorktree{}, fmt.Errorf("no worktree found for session #%d", id)
+	}
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return supervisor.SessionWorktree{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	for _, wt := range wts {
+		if wt.Path == abs {
+			return wt, nil
+		}
+	}
+	return supervisor.SessionWorktree{}, fmt.Errorf("%s is not a worktree of this repository", arg)
+}
+
+func describeWorktree(wt supervisor.SessionWorktree) string {
+	if wt.Session == nil {
+		return wt.Path
+	}
+	return fmt.Sprintf("%s (session #%d, %s)", wt.Path, wt.Session.ID, wt.Session.Status)
+}
+
+func branchNote(wt supervisor.SessionWorktree, deleted bool) string {
+	switch {
+	case wt.Branch == "":
+		return ""
+	case deleted:
+		return ", deleted branch " + wt.Branch
+	default:
+		return ", kept unmerged branch " + wt.Branch
+	}
+}
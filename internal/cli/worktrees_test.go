@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/supervisor"
+)
+
+func testWorktrees() []supervisor.SessionWorktree {
+	return []supervisor.SessionWorktree{
+		{Path: "/src/agentbox-done", Branch: "agentbox/done", Session: &store.Session{ID: 3, Status: "completed", PRNumber: 12}},
+		{Path: "/src/agentbox-gone", Branch: "agentbox/gone", Session: &store.Session{ID: 4, Status: "failed"}, Missing: true},
+		{Path: "/src/scratch"},
+	}
+}
+
+func TestPrintWorktreeList(t *testing.T) {
+	var buf bytes.Buffer
+	printWorktreeList(&buf, testWorktrees())
+	out := buf.String()
+	for _, want := range []string{"PATH", "SESSION", "/src/agentbox-done", "agentbox/done", "#3", "completed", "#12", "/src/agentbox-gone (missing)", "/src/scratch"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("list output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFindWorktree(t *testing.T) {
+	wts := testWorktrees()
+	if wt, err := findWorktree(wts, "4"); err != nil || wt.Path != "/src/agentbox-gone" {
+		t.Errorf("findWorktree by session = %+v, %v", wt, err)
+	}
+	if wt, err := findWorktree(wts, "/src/scratch"); err != nil || wt.Session != nil {
+		t.Errorf("findWorktree by path = %+v, %v", wt, err)
+	}
+	if _, err := findWorktree(wts, "9"); err == nil {
+		t.Error("findWorktree found a session without a worktree")
+	}
+	if _, err := findWorktree(wts, "/elsewhere"); err == nil {
+		t.Error("findWorktree found an unknown path")
+	}
+}
+
+func TestBranchNote(t *testing.T) {
+	wt := testWorktrees()[0]
+	if got := branchNote(wt, true); got != ", deleted branch agentbox/done" {
+		t.Errorf("branchNote(deleted) = %q", got)
+	}
+	if got := branchNote(wt, false); got != ", kept unmerged branch agentbox/done" {
+		t.Errorf("branchNote(kept) = %q", got)
+	}
+	if got := branchNote(testWorktrees()[2], false); got != "" {
+		t.Errorf("branchNote without a branch = %q", got)
+	}
+}
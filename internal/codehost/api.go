@@ -0,0 +1,188 @@
+package codehost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// APIError is a non-2xx response from a code host API.
+type APIError struct {
+	Method  string
+	URL     string
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.Status, e.Message)
+}
+
+// apiClient sends JSON requests to one API base URL.
+type apiClient struct {
+	base   string
+	auth   func(*http.Request)
+	client *http.Client
+}
+
+func newAPIClient(base string, auth func(*http.Request)) *apiClient {
+	return &apiClient{
+		base:   strings.TrimSuffix(base, "/"),
+		auth:   auth,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends in as the JSON body (when not nil) and decodes the response
+// into out (when not nil). path is appended to the base URL as given, so
+// callers escape path segments themselves.
+func (c *apiClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	return c.doURL(ctx, method, c.base+path, in, out)
+}
+
+func (c *apiClient) doURL(ctx context.Context, method, url string, in, out interface{}) error {
+	data, _, err := c.send(ctx, method, url, in)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, url, err)
+	}
+	return nil
+}
+
+// text GETs path and returns the response body as text, for endpoints such
+// as job logs that do not return JSON. Redirects to log storage are
+// followed.
+func (c *apiClient) text(ctx context.Context, path string) (string, error) {
+	data, _, err := c.send(ctx, http.MethodGet, c.base+path, nil)
+	return string(data), err
+}
+
+// maxPages bounds how many pages list follows, in case a server keeps
+// linking to more.
+const maxPages = 50
+
+// list GETs path, then each page the Link header of the previous response
+// names as rel="next", passing every page's body to page in order. GitHub,
+// GitLab and Gitea all paginate lists this way.
+func (c *apiClient) list(ctx context.Context, path string, page func(data []byte) error) error {
+	url := c.base + path
+	for n := 0; url != "" && n < maxPages; n++ {
+		data, header, err := c.send(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if err := page(data); err != nil {
+			return fmt.Errorf("decoding GET %s response: %w", url, err)
+		}
+		url = nextLink(header.Get("Link"))
+		// Requests carry the token, so only follow links to the same API.
+		if !strings.HasPrefix(url, c.base+"/") {
+			url = ""
+		}
+	}
+	return nil
+}
+
+// listAll GETs every page of a JSON array, as list does, and appends the
+// elements to the slice out points to.
+func (c *apiClient) listAll(ctx context.Context, path string, out interface{}) error {
+	dst := reflect.ValueOf(out).Elem()
+	return c.list(ctx, path, func(data []byte) error {
+		page := reflect.New(dst.Type())
+		if err := json.Unmarshal(data, page.Interface()); err != nil {
+			return err
+		}
+		dst.Set(reflect.AppendSlice(dst, page.Elem()))
+		return nil
+	})
+}
+
+// nextLink returns the rel="next" URL of a Link header, or "".
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// send makes a request and returns the body and headers of a 2xx response.
+func (c *apiClient) send(ctx context.Context, method, url string, in interface{}) ([]byte, http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.auth(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &APIError{Method: method, URL: url, Status: resp.StatusCode, Message: errorMessage(data)}
+	}
+	return data, resp.Header, nil
+}
+
+// errorMessage pulls the message out of an error response body. GitHub and
+// Gitea use "message"; GitLab uses "message" or "error".
+func errorMessage(data []byte) string {
+	var body struct {
+		Message interface{} `json:"message"`
+		Error   string      `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Message != nil {
+			if s, ok := body.Message.(string); ok {
+				return s
+			}
+			// GitLab validation errors are an object or list.
+			msg, _ := json.Marshal(body.Message)
+			return string(msg)
+		}
+		if body.Error != "" {
+			return body.Error
+		}
+	}
+	msg := strings.TrimSpace(string(data))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	return msg
+}
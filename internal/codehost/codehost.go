@@ -0,0 +1,348 @@
+// Package codehost talks to the service hosting a repository's pull
+// requests, issues and CI: GitHub, GitLab or Gitea/Forgejo.
+package codehost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+// CodeHost is the API of a code hosting service, scoped to one repository.
+// "PR" covers GitLab merge requests too; numbers are the per-repository
+// number shown in the UI (the IID on GitLab).
+type CodeHost interface {
+	// Name returns the provider name: github, gitlab, gitea or fake.
+	Name() string
+
+	// OpenPR opens a pull request from opts.Head. An empty opts.Base targets
+	// the repository's default branch.
+	OpenPR(ctx context.Context, opts PROptions) (*PullRequest, error)
+	// UpdatePR changes the fields set in upd and returns the result.
+	UpdatePR(ctx context.Context, number int, upd PRUpdate) (*PullRequest, error)
+	// GetPR loads a pull request.
+	GetPR(ctx context.Context, number int) (*PullRequest, error)
+	// AddLabels adds labels to a pull request, keeping existing ones.
+	AddLabels(ctx context.Context, number int, labels []string) error
+	// RequestReviewers asks the given users to review a pull request.
+	RequestReviewers(ctx context.Context, number int, reviewers []string) error
+	// Comment adds a comment to a pull request's conversation.
+	Comment(ctx context.Context, number int, body string) error
+
+	// ReviewThreads returns the unresolved review comment threads on a pull
+	// request.
+	ReviewThreads(ctx context.Context, number int) ([]ReviewThread, error)
+	// ReplyToThread adds a reply to a review thread.
+	ReplyToThread(ctx context.Context, number int, threadID, body string) error
+	// ResolveThread marks a review thread resolved.
+	ResolveThread(ctx context.Context, number int, threadID string) error
+
+	// CreateIssue opens an issue.
+	CreateIssue(ctx context.Context, opts IssueOptions) (*Issue, error)
+
+	// CIStatus reports the combined CI result for a commit or branch.
+	CIStatus(ctx context.Context, ref string) (*CIStatus, error)
+	// CheckLog returns the log of a CI job reported by CIStatus. Checks
+	// without a log, such as plain commit statuses, return ErrNotSupported.
+	CheckLog(ctx context.Context, check Check) (string, error)
+}
+
+// PROptions describes a new pull request.
+type PROptions struct {
+	Title     string
+	Body      string
+	Head      string // branch with the changes
+	Base      string // branch to merge into; empty for the default branch
+	Draft     bool
+	Labels    []string
+	Reviewers []string
+}
+
+// PRUpdate lists the pull request fields to change. Nil fields are left
+// alone.
+type PRUpdate struct {
+	Title *string
+	Body  *string
+	Draft *bool
+}
+
+// PullRequest is a pull request (or merge request) as the host reports it.
+type PullRequest struct {
+	Number    int      `json:"number"`
+	URL       string   `json:"url"`
+	Title     string   `json:"title"`
+	Body      string   `json:"body"`
+	Head      string   `json:"head"`
+	Base      string   `json:"base"`
+	Draft     bool     `json:"draft"`
+	State     string   `json:"state"` // open, closed or merged
+	Labels    []string `json:"labels,omitempty"`
+	Reviewers []string `json:"reviewers,omitempty"`
+}
+
+// ReviewThread is a review comment on a line of a pull request's diff,
+// with the replies to it.
+type ReviewThread struct {
+	ID       string          `json:"id"` // provider's ID, for replying and resolving
+	Path     string          `json:"path"`
+	Line     int             `json:"line,omitempty"` // 0 for comments on a whole file
+	Comments []ReviewComment `json:"comments"`
+}
+
+// ReviewComment is one comment in a review thread.
+type ReviewComment struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// ErrNotSupported is returned for operations a provider's API lacks.
+var ErrNotSupported = errors.New("not supported by this code host")
+
+// IssueOptions describes a new issue.
+type IssueOptions struct {
+	Title  string
+	Body   string
+	Labels []string
+}
+
+// Issue is a created issue.
+type Issue struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// CIState is the result of a CI check, or of all of them combined.
+type CIState string
+
+// CI states.
+const (
+	CIPending CIState = "pending"
+	CISuccess CIState = "success"
+	CIFailure CIState = "failure"
+	CINone    CIState = "none" // no checks reported
+)
+
+// CIStatus is the CI result for one commit.
+type CIStatus struct {
+	State  CIState `json:"state"`
+	Checks []Check `json:"checks,omitempty"`
+}
+
+// Check is one CI job or status.
+type Check struct {
+	ID    string  `json:"id,omitempty"` // job ID for fetching the log; empty when there is none
+	Name  string  `json:"name"`
+	State CIState `json:"state"`
+	URL   string  `json:"url,omitempty"`
+}
+
+// combineChecks sets the overall state: failure if any check failed, else
+// pending if any is still running, else success.
+func combineChecks(checks []Check) *CIStatus {
+	st := &CIStatus{State: CINone, Checks: checks}
+	for _, c := range checks {
+		switch {
+		case c.State == CIFailure:
+			st.State = CIFailure
+		case c.State == CIPending && st.State != CIFailure:
+			st.State = CIPending
+		case st.State == CINone:
+			st.State = CISuccess
+		}
+	}
+	return st
+}
+
+// draftPrefixes are the title prefixes GitLab and Gitea treat as draft
+// markers.
+var draftPrefixes = []string{"Draft:", "[Draft]", "(Draft)", "WIP:", "[WIP]"}
+
+// splitDraftTitle removes a draft marker from title and reports whether
+// there was one.
+func splitDraftTitle(title string) (string, bool) {
+	for _, p := range draftPrefixes {
+		if len(title) >= len(p) && strings.EqualFold(title[:len(p)], p) {
+			return strings.TrimSpace(title[len(p):]), true
+		}
+	}
+	return title, false
+}
+
+// draftTitle marks or unmarks title as a draft with prefix.
+func draftTitle(title, prefix string, draft bool) string {
+	title, _ = splitDraftTitle(title)
+	if draft {
+		return prefix + " " + title
+	}
+	return title
+}
+
+// Remote is a parsed git remote URL.
+type Remote struct {
+	Scheme string // https, http or ssh
+	Host   string // with the port for http(s) remotes
+	Path   string // owner/repo; GitLab subgroups add more segments
+}
+
+// Owner returns everything before the repository name.
+func (r Remote) Owner() string {
+	i := strings.LastIndex(r.Path, "/")
+	return r.Path[:i]
+}
+
+// Repo returns the repository name.
+func (r Remote) Repo() string {
+	return r.Path[strings.LastIndex(r.Path, "/")+1:]
+}
+
+// webBase is the host's web root, which the API URLs hang off.
+func (r Remote) webBase() string {
+	if r.Scheme == "http" {
+		return "http://" + r.Host
+	}
+	return "https://" + r.Host
+}
+
+// ParseRemote parses https, ssh and scp-style (git@host:owner/repo.git)
+// remote URLs.
+func ParseRemote(remote string) (Remote, error) {
+	remote = strings.TrimSpace(remote)
+	var r Remote
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		r.Scheme = u.Scheme
+		r.Host = u.Host
+		if u.Scheme != "http" && u.Scheme != "https" {
+			// An ssh port says nothing about where the API listens.
+			r.Scheme, r.Host = "ssh", u.Hostname()
+		}
+		r.Path = u.Path
+	} else if at, path, ok := strings.Cut(remote, ":"); ok && !strings.Contains(at, "/") {
+		r.Scheme = "ssh"
+		r.Host = at[strings.LastIndex(at, "@")+1:]
+		r.Path = path
+	} else {
+		return Remote{}, fmt.Errorf("cannot parse git remote %q", remote)
+	}
+
+	r.Path = strings.TrimSuffix(strings.Trim(r.Path, "/"), ".git")
+	if r.Host == "" || !strings.Contains(r.Path, "/") {
+		return Remote{}, fmt.Errorf("git remote %q does not name an owner and repository", remote)
+	}
+	return r, nil
+}
+
+// DetectProvider guesses the provider from a remote's host name.
+func DetectProvider(host string) (string, error) {
+	h := strings.ToLower(host)
+	switch {
+	case strings.Contains(h, "github"):
+		return "github", nil
+	case strings.Contains(h, "gitlab"):
+		return "gitlab", nil
+	case strings.Contains(h, "gitea"), strings.Contains(h, "forgejo"), h == "codeberg.org":
+		return "gitea", nil
+	}
+	return "", fmt.Errorf("cannot tell which code host %s is; set code_host.provider in agentbox.yaml", host)
+}
+
+// New returns the code host for a git remote URL. The provider, API URL
+// and token come from cfg when set, and otherwise from the remote's host
+// name and the provider's usual token variable.
+func New(remote string, cfg config.CodeHostConfig) (CodeHost, error) {
+	r, err := ParseRemote(remote)
+	if err != nil {
+		return nil, err
+	}
+	provider := cfg.Provider
+	if provider == "" {
+		if provider, err = DetectProvider(r.Host); err != nil {
+			return nil, err
+		}
+	}
+
+	switch provider {
+	case "github":
+		api := cfg.APIURL
+		if api == "" {
+			api = r.webBase() + "/api/v3"
+			if strings.EqualFold(r.Host, "github.com") {
+				api = "https://api.github.com"
+			}
+		}
+		token, err := lookupToken(cfg.TokenEnv, "GITHUB_TOKEN", "GH_TOKEN")
+		if err != nil {
+			if token = ghAuthToken(r.Host); token == "" {
+				return nil, fmt.Errorf("no GitHub token: %w (or log in with gh auth login)", err)
+			}
+		}
+		return NewGitHub(api, token, r.Owner(), r.Repo()), nil
+
+	case "gitlab":
+		api := cfg.APIURL
+		if api == "" {
+			api = r.webBase() + "/api/v4"
+		}
+		token, err := lookupToken(cfg.TokenEnv, "GITLAB_TOKEN")
+		if err != nil {
+			return nil, fmt.Errorf("no GitLab token: %w", err)
+		}
+		return NewGitLab(api, token, r.Path), nil
+
+	case "gitea":
+		api := cfg.APIURL
+		if api == "" {
+			api = r.webBase() + "/api/v1"
+		}
+		token, err := lookupToken(cfg.TokenEnv, "GITEA_TOKEN", "FORGEJO_TOKEN")
+		if err != nil {
+			return nil, fmt.Errorf("no Gitea token: %w", err)
+		}
+		return NewGitea(api, token, r.Owner(), r.Repo()), nil
+	}
+	return nil, fmt.Errorf("unknown code host provider %q", provider)
+}
+
+// ForRepo returns the code host for the origin remote of the repository at
+// dir.
+func ForRepo(ctx context.Context, dir string, cfg config.CodeHostConfig) (CodeHost, error) {
+	cmd := exec.CommandContext(ctx, "git", "remote", "get-url", "origin")
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("reading origin remote: %s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+	return New(stdout.String(), cfg)
+}
+
+// lookupToken reads the token from the configured variable, or else the
+// first of the defaults that is set.
+func lookupToken(configured string, defaults ...string) (string, error) {
+	vars := defaults
+	if configured != "" {
+		vars = []string{configured}
+	}
+	for _, v := range vars {
+		if token := os.Getenv(v); token != "" {
+			return token, nil
+		}
+	}
+	return "", fmt.Errorf("set %s", strings.Join(vars, " or "))
+}
+
+// ghAuthToken returns the token the gh CLI is logged in with, if any.
+func ghAuthToken(host string) string {
+	out, err := exec.Command("gh", "auth", "token", "--hostname", host).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
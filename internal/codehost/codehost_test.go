@@ -0,0 +1,290 @@
+package codehost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+// apiCall is one request received by a fake API server.
+type apiCall struct {
+	Method string
+	Path   string // escaped path plus query
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// fakePage is a route response with a Link header naming Next, a path on
+// the fake server, as the next page.
+type fakePage struct {
+	Next string
+	Body interface{}
+}
+
+// fakeAPI serves canned JSON keyed by "METHOD /escaped/path?query" and
+// records every request. A route can also be a fakePage, or a
+// func(apiCall) interface{} that builds the response from the request.
+// Unknown routes get a 404.
+func fakeAPI(t *testing.T, routes map[string]interface{}) (*httptest.Server, func() []apiCall) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []apiCall
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		if r.URL.RawQuery != "" {
+			path += "?" + r.URL.RawQuery
+		}
+		call := apiCall{Method: r.Method, Path: path, Header: r.Header.Clone()}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &call.Body); err != nil {
+				t.Errorf("%s %s: body is not a JSON object: %s", r.Method, path, data)
+			}
+		}
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+
+		resp, ok := routes[r.Method+" "+path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+			return
+		}
+		if fn, ok := resp.(func(apiCall) interface{}); ok {
+			resp = fn(call)
+		}
+		if page, ok := resp.(fakePage); ok {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next", <http://%s/last>; rel="last"`, r.Host, page.Next, r.Host))
+			resp = page.Body
+		}
+		if raw, ok := resp.([]byte); ok {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write(raw)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []apiCall {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]apiCall(nil), calls...)
+	}
+}
+
+// findCall returns the first call to method and path.
+func findCall(t *testing.T, calls []apiCall, method, path string) apiCall {
+	t.Helper()
+	for _, c := range calls {
+		if c.Method == method && c.Path == path {
+			return c
+		}
+	}
+	t.Fatalf("no %s %s among %d calls", method, path, len(calls))
+	return apiCall{}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   Remote
+	}{
+		{"https://github.com/org/repo.git", Remote{"https", "github.com", "org/repo"}},
+		{"https://github.com/org/repo", Remote{"https", "github.com", "org/repo"}},
+		{"git@github.com:org/repo.git\n", Remote{"ssh", "github.com", "org/repo"}},
+		{"ssh://git@gitlab.example.com:2222/group/sub/repo.git", Remote{"ssh", "gitlab.example.com", "group/sub/repo"}},
+		{"http://gitea.local:3000/me/tool.git", Remote{"http", "gitea.local:3000", "me/tool"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRemote(tt.remote)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRemote(%q) = %+v, %v; want %+v", tt.remote, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "/srv/git/repo.git", "https://github.com/repo"} {
+		if _, err := ParseRemote(bad); err == nil {
+			t.Errorf("ParseRemote(%q) succeeded", bad)
+		}
+	}
+
+	r, _ := ParseRemote("git@gitlab.com:group/sub/repo.git")
+	if r.Owner() != "group/sub" || r.Repo() != "repo" {
+		t.Errorf("Owner, Repo = %q, %q", r.Owner(), r.Repo())
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "gh-token")
+	t.Setenv("GITLAB_TOKEN", "gl-token")
+	t.Setenv("GITEA_TOKEN", "")
+	t.Setenv("FORGEJO_TOKEN", "")
+	t.Setenv("MY_GITEA_TOKEN", "tea-token")
+
+	tests := []struct {
+		remote  string
+		cfg     config.CodeHostConfig
+		name    string
+		apiBase string
+	}{
+		{"git@github.com:org/repo.git", config.CodeHostConfig{}, "github", "https://api.github.com"},
+		{"https://github.example.com/org/repo", config.CodeHostConfig{}, "github", "https://github.example.com/api/v3"},
+		{"https://gitlab.com/group/sub/repo.git", config.CodeHostConfig{}, "gitlab", "https://gitlab.com/api/v4"},
+		{"https://codeberg.org/me/tool.git", config.CodeHostConfig{TokenEnv: "MY_GITEA_TOKEN"}, "gitea", "https://codeberg.org/api/v1"},
+		{"https://git.example.com/me/tool.git", config.CodeHostConfig{Provider: "gitlab", APIURL: "https://api.example.com/v4"}, "gitlab", "https://api.example.com/v4"},
+	}
+	for _, tt := range tests {
+		host, err := New(tt.remote, tt.cfg)
+		if err != nil {
+			t.Errorf("New(%q): %v", tt.remote, err)
+			continue
+		}
+		if host.Name() != tt.name {
+			t.Errorf("New(%q) = %s, want %s", tt.remote, host.Name(), tt.name)
+		}
+		var base string
+		switch h := host.(type) {
+		case *GitHub:
+			base = h.api.base
+		case *GitLab:
+			base = h.api.base
+		case *Gitea:
+			base = h.api.base
+		}
+		if base != tt.apiBase {
+			t.Errorf("New(%q) API base = %s, want %s", tt.remote, base, tt.apiBase)
+		}
+	}
+
+	if _, err := New("https://git.example.com/me/tool.git", config.CodeHostConfig{}); err == nil {
+		t.Error("New guessed a provider for an unknown host")
+	}
+	if _, err := New("https://gitea.example.com/me/tool.git", config.CodeHostConfig{}); err == nil {
+		t.Error("New succeeded without a Gitea token")
+	}
+}
+
+func TestCombineChecks(t *testing.T) {
+	tests := []struct {
+		states []CIState
+		want   CIState
+	}{
+		{nil, CINone},
+		{[]CIState{CISuccess, CISuccess}, CISuccess},
+		{[]CIState{CISuccess, CIPending}, CIPending},
+		{[]CIState{CIPending, CIFailure, CISuccess}, CIFailure},
+	}
+	for _, tt := range tests {
+		var checks []Check
+		for _, s := range tt.states {
+			checks = append(checks, Check{Name: string(s), State: s})
+		}
+		if got := combineChecks(checks).State; got != tt.want {
+			t.Errorf("combineChecks(%v) = %s, want %s", tt.states, got, tt.want)
+		}
+	}
+}
+
+func TestDraftTitle(t *testing.T) {
+	if got := draftTitle("Add login", "Draft:", true); got != "Draft: Add login" {
+		t.Errorf("draftTitle = %q", got)
+	}
+	if got := draftTitle("WIP: Add login", "Draft:", true); got != "Draft: Add login" {
+		t.Errorf("draftTitle replacing a marker = %q", got)
+	}
+	if got := draftTitle("[Draft] Add login", "WIP:", false); got != "Add login" {
+		t.Errorf("draftTitle removing a marker = %q", got)
+	}
+}
+
+func TestFake(t *testing.T) {
+	var host CodeHost = NewFake()
+	f := host.(*Fake)
+	ctx := context.Background()
+
+	pr, err := host.OpenPR(ctx, PROptions{Title: "t", Head: "feat/x", Draft: true, Labels: []string{"agentbox"}})
+	if err != nil || pr.Number != 1 || pr.Base != "main" || !pr.Draft {
+		t.Fatalf("OpenPR = %+v, %v", pr, err)
+	}
+	ready := false
+	if pr, _ = host.UpdatePR(ctx, 1, PRUpdate{Draft: &ready}); pr.Draft {
+		t.Error("UpdatePR did not clear draft")
+	}
+	_ = host.AddLabels(ctx, 1, []string{"agentbox", "ready"})
+	_ = host.Comment(ctx, 1, "sprint 1 done")
+	if pr, _ = host.GetPR(ctx, 1); len(pr.Labels) != 2 {
+		t.Errorf("labels = %v", pr.Labels)
+	}
+	if got := f.Comments(1); len(got) != 1 || got[0] != "sprint 1 done" {
+		t.Errorf("comments = %v", got)
+	}
+	if err := host.Comment(ctx, 9, "x"); err == nil {
+		t.Error("comment on a missing pull request succeeded")
+	}
+
+	id := f.AddReviewThread(1, "auth.go", 12, ReviewComment{Author: "alice", Body: "handle nil"})
+	_ = host.ReplyToThread(ctx, 1, id, "done")
+	_ = host.ResolveThread(ctx, 1, id)
+	if threads, _ := host.ReviewThreads(ctx, 1); len(threads) != 0 {
+		t.Errorf("resolved thread still listed: %+v", threads)
+	}
+	if th, resolved := f.Thread(id); !resolved || len(th.Comments) != 2 || th.Comments[1].Body != "done" {
+		t.Errorf("thread = %+v, resolved %v", th, resolved)
+	}
+
+	f.SetCIStatus("feat/x", Check{Name: "test", State: CIFailure})
+	if st, _ := host.CIStatus(ctx, "feat/x"); st.State != CIFailure {
+		t.Errorf("CI state = %s", st.State)
+	}
+	if st, _ := host.CIStatus(ctx, "other"); st.State != CINone {
+		t.Errorf("CI state without checks = %s", st.State)
+	}
+	f.SetCheckLog("test", "FAIL: TestLogin")
+	if log, err := host.CheckLog(ctx, Check{Name: "test"}); err != nil || log != "FAIL: TestLogin" {
+		t.Errorf("CheckLog = %q, %v", log, err)
+	}
+	if _, err := host.CheckLog(ctx, Check{Name: "lint"}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("CheckLog without a log: %v", err)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct{ header, want string }{
+		{"", ""},
+		{`<https://api.github.com/x?page=2>; rel="next", <https://api.github.com/x?page=5>; rel="last"`, "https://api.github.com/x?page=2"},
+		{`<https://gitlab.com/api/v4/x?page=1>; rel="prev", <https://gitlab.com/api/v4/x?page=3>; rel="next"`, "https://gitlab.com/api/v4/x?page=3"},
+		{`<https://api.github.com/x?page=5>; rel="last"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextLink(tt.header); got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestListStaysOnAPIHost(t *testing.T) {
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		w.Header().Set("Link", `<https://elsewhere.example.com/steal?page=2>; rel="next"`)
+		_, _ = w.Write([]byte("[1]"))
+	}))
+	t.Cleanup(srv.Close)
+
+	var got []int
+	if err := newAPIClient(srv.URL, func(*http.Request) {}).listAll(context.Background(), "/items", &got); err != nil {
+		t.Fatalf("listAll: %v", err)
+	}
+	if pages != 1 || len(got) != 1 {
+		t.Errorf("fetched %d pages (%v), want only the first", pages, got)
+	}
+}
@@ -0,0 +1,340 @@
+package codehost
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake is an in-memory CodeHost for tests. It records what was done to it
+// and serves CI results set with SetCIStatus.
+type Fake struct {
+	mu       sync.Mutex
+	next     int
+	prs      map[int]*PullRequest
+	comments map[int][]string
+	threads  []*fakeThread
+	issues   []IssueOptions
+	ci       map[string]*CIStatus
+	logs     map[string]string
+	err      error
+}
+
+type fakeThread struct {
+	number   int
+	thread   ReviewThread
+	resolved bool
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{prs: map[int]*PullRequest{}, comments: map[int][]string{}, ci: map[string]*CIStatus{}, logs: map[string]string{}}
+}
+
+// Name implements CodeHost.
+func (f *Fake) Name() string { return "fake" }
+
+// FailWith makes every call return err until it is called with nil.
+func (f *Fake) FailWith(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *Fake) pr(number int) (*PullRequest, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	pr, ok := f.prs[number]
+	if !ok {
+		return nil, fmt.Errorf("no pull request #%d", number)
+	}
+	return pr, nil
+}
+
+// OpenPR implements CodeHost.
+func (f *Fake) OpenPR(_ context.Context, opts PROptions) (*PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.next++
+	base := opts.Base
+	if base == "" {
+		base = "main"
+	}
+	pr := &PullRequest{
+		Number: f.next, URL: fmt.Sprintf("https://example.com/pulls/%d", f.next),
+		Title: opts.Title, Body: opts.Body, Head: opts.Head, Base: base,
+		Draft: opts.Draft, State: "open",
+		Labels:    append([]string(nil), opts.Labels...),
+		Reviewers: append([]string(nil), opts.Reviewers...),
+	}
+	f.prs[pr.Number] = pr
+	return copyPR(pr), nil
+}
+
+// UpdatePR implements CodeHost.
+func (f *Fake) UpdatePR(_ context.Context, number int, upd PRUpdate) (*PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, err := f.pr(number)
+	if err != nil {
+		return nil, err
+	}
+	if upd.Title != nil {
+		pr.Title = *upd.Title
+	}
+	if upd.Body != nil {
+		pr.Body = *upd.Body
+	}
+	if upd.Draft != nil {
+		pr.Draft = *upd.Draft
+	}
+	return copyPR(pr), nil
+}
+
+// GetPR implements CodeHost.
+func (f *Fake) GetPR(_ context.Context, number int) (*PullRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, err := f.pr(number)
+	if err != nil {
+		return nil, err
+	}
+	return copyPR(pr), nil
+}
+
+// AddLabels implements CodeHost.
+func (f *Fake) AddLabels(_ context.Context, number int, labels []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, err := f.pr(number)
+	if err != nil {
+		return err
+	}
+	pr.Labels = appendNew(pr.Labels, labels)
+	return nil
+}
+
+// RequestReviewers implements CodeHost.
+func (f *Fake) RequestReviewers(_ context.Context, number int, reviewers []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pr, err := f.pr(number)
+	if err != nil {
+		return err
+	}
+	pr.Reviewers = appendNew(pr.Reviewers, reviewers)
+	return nil
+}
+
+// Comment implements CodeHost.
+func (f *Fake) Comment(_ context.Context, number int, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.pr(number); err != nil {
+		return err
+	}
+	f.comments[number] = append(f.comments[number], body)
+	return nil
+}
+
+// AddReviewThread adds an unresolved review thread to a pull request and
+// returns its ID.
+func (f *Fake) AddReviewThread(number int, path string, line int, comments ...ReviewComment) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := fmt.Sprintf("thread-%d", len(f.threads)+1)
+	f.threads = append(f.threads, &fakeThread{
+		number: number,
+		thread: ReviewThread{ID: id, Path: path, Line: line, Comments: append([]ReviewComment(nil), comments...)},
+	})
+	return id
+}
+
+// Thread returns a review thread, with replies, and whether it is resolved.
+func (f *Fake) Thread(id string) (ReviewThread, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.threads {
+		if t.thread.ID == id {
+			cp := t.thread
+			cp.Comments = append([]ReviewComment(nil), t.thread.Comments...)
+			return cp, t.resolved
+		}
+	}
+	return ReviewThread{}, false
+}
+
+func (f *Fake) findThread(number int, id string) (*fakeThread, error) {
+	if _, err := f.pr(number); err != nil {
+		return nil, err
+	}
+	for _, t := range f.threads {
+		if t.number == number && t.thread.ID == id {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no review thread %s on #%d", id, number)
+}
+
+// ReviewThreads implements CodeHost.
+func (f *Fake) ReviewThreads(_ context.Context, number int) ([]ReviewThread, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.pr(number); err != nil {
+		return nil, err
+	}
+	var threads []ReviewThread
+	for _, t := range f.threads {
+		if t.number == number && !t.resolved {
+			cp := t.thread
+			cp.Comments = append([]ReviewComment(nil), t.thread.Comments...)
+			threads = append(threads, cp)
+		}
+	}
+	return threads, nil
+}
+
+// ReplyToThread implements CodeHost. Replies are written as "agentbox".
+func (f *Fake) ReplyToThread(_ context.Context, number int, threadID, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.findThread(number, threadID)
+	if err != nil {
+		return err
+	}
+	t.thread.Comments = append(t.thread.Comments, ReviewComment{Author: "agentbox", Body: body})
+	return nil
+}
+
+// ResolveThread implements CodeHost.
+func (f *Fake) ResolveThread(_ context.Context, number int, threadID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.findThread(number, threadID)
+	if err != nil {
+		return err
+	}
+	t.resolved = true
+	return nil
+}
+
+// CreateIssue implements CodeHost.
+func (f *Fake) CreateIssue(_ context.Context, opts IssueOptions) (*Issue, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.next++
+	f.issues = append(f.issues, opts)
+	return &Issue{Number: f.next, URL: fmt.Sprintf("https://example.com/issues/%d", f.next)}, nil
+}
+
+// CIStatus implements CodeHost. Refs without a status set report CINone.
+func (f *Fake) CIStatus(_ context.Context, ref string) (*CIStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	st, ok := f.ci[ref]
+	if !ok {
+		return &CIStatus{State: CINone}, nil
+	}
+	cp := *st
+	cp.Checks = append([]Check(nil), st.Checks...)
+	return &cp, nil
+}
+
+// SetCIStatus sets the result CIStatus reports for ref. The overall state
+// is worked out from the checks.
+func (f *Fake) SetCIStatus(ref string, checks ...Check) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ci[ref] = combineChecks(checks)
+}
+
+// SetPRState sets the state of a pull request, such as "merged".
+func (f *Fake) SetPRState(number int, state string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if pr, ok := f.prs[number]; ok {
+		pr.State = state
+	}
+}
+
+// CheckLog implements CodeHost. It returns the log set with SetCheckLog for
+// the check's name, or ErrNotSupported when there is none.
+func (f *Fake) CheckLog(_ context.Context, check Check) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	log, ok := f.logs[check.Name]
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return log, nil
+}
+
+// SetCheckLog sets the log CheckLog returns for checks named name.
+func (f *Fake) SetCheckLog(name, log string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs[name] = log
+}
+
+// PRs returns every pull request opened, in order.
+func (f *Fake) PRs() []*PullRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var prs []*PullRequest
+	for n := 1; n <= f.next; n++ {
+		if pr, ok := f.prs[n]; ok {
+			prs = append(prs, copyPR(pr))
+		}
+	}
+	return prs
+}
+
+// Comments returns the comments on a pull request, oldest first.
+func (f *Fake) Comments(number int) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.comments[number]...)
+}
+
+// Issues returns every issue created, in order.
+func (f *Fake) Issues() []IssueOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]IssueOptions(nil), f.issues...)
+}
+
+func copyPR(pr *PullRequest) *PullRequest {
+	cp := *pr
+	cp.Labels = append([]string(nil), pr.Labels...)
+	cp.Reviewers = append([]string(nil), pr.Reviewers...)
+	return &cp
+}
+
+func appendNew(list, add []string) []string {
+	for _, a := range add {
+		found := false
+		for _, l := range list {
+			if l == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, a)
+		}
+	}
+	return list
+}
@@ -0,0 +1,363 @@
+package codehost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Gitea uses the Gitea API, which Forgejo shares. Draft state is the
+// "WIP:" title prefix, and labels are set by ID. The API has no review
+// threads, so comments on the same line are grouped here, replies go to the
+// pull request conversation, and threads cannot be resolved.
+type Gitea struct {
+	api   *apiClient
+	owner string
+	repo  string
+}
+
+// NewGitea returns a client for owner/repo. apiURL is https://HOST/api/v1.
+func NewGitea(apiURL, token, owner, repo string) *Gitea {
+	return &Gitea{
+		api: newAPIClient(apiURL, func(r *http.Request) {
+			r.Header.Set("Authorization", "token "+token)
+		}),
+		owner: owner,
+		repo:  repo,
+	}
+}
+
+// Name implements CodeHost.
+func (g *Gitea) Name() string { return "gitea" }
+
+func (g *Gitea) repoPath() string {
+	return "/repos/" + url.PathEscape(g.owner) + "/" + url.PathEscape(g.repo)
+}
+
+type giteaPR struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	Draft   bool   `json:"draft"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	RequestedReviewers []struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewers"`
+}
+
+func (p *giteaPR) toPR() *PullRequest {
+	_, draft := splitDraftTitle(p.Title)
+	pr := &PullRequest{
+		Number: p.Number, URL: p.HTMLURL, Title: p.Title, Body: p.Body,
+		Head: p.Head.Ref, Base: p.Base.Ref, Draft: p.Draft || draft, State: p.State,
+	}
+	if p.Merged {
+		pr.State = "merged"
+	}
+	for _, l := range p.Labels {
+		pr.Labels = append(pr.Labels, l.Name)
+	}
+	for _, r := range p.RequestedReviewers {
+		pr.Reviewers = append(pr.Reviewers, r.Login)
+	}
+	return pr
+}
+
+func (g *Gitea) defaultBranch(ctx context.Context) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.api.do(ctx, http.MethodGet, g.repoPath(), nil, &repo); err != nil {
+		return "", fmt.Errorf("finding default branch: %w", err)
+	}
+	return repo.DefaultBranch, nil
+}
+
+// labelIDs resolves label names to IDs, creating labels that do not exist
+// yet as GitHub and GitLab do.
+func (g *Gitea) labelIDs(ctx context.Context, names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var existing []struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := g.api.listAll(ctx, g.repoPath()+"/labels?limit=50", &existing); err != nil {
+		return nil, fmt.Errorf("listing labels: %w", err)
+	}
+	byName := map[string]int64{}
+	for _, l := range existing {
+		byName[l.Name] = l.ID
+	}
+
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, ok := byName[name]
+		if !ok {
+			var created struct {
+				ID int64 `json:"id"`
+			}
+			if err := g.api.do(ctx, http.MethodPost, g.repoPath()+"/labels",
+				map[string]string{"name": name, "color": "#ededed"}, &created); err != nil {
+				return nil, fmt.Errorf("creating label %s: %w", name, err)
+			}
+			id = created.ID
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// OpenPR implements CodeHost.
+func (g *Gitea) OpenPR(ctx context.Context, opts PROptions) (*PullRequest, error) {
+	base := opts.Base
+	if base == "" {
+		var err error
+		if base, err = g.defaultBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	fields := map[string]interface{}{
+		"title": draftTitle(opts.Title, "WIP:", opts.Draft),
+		"body":  opts.Body,
+		"head":  opts.Head,
+		"base":  base,
+	}
+	if len(opts.Labels) > 0 {
+		ids, err := g.labelIDs(ctx, opts.Labels)
+		if err != nil {
+			return nil, err
+		}
+		fields["labels"] = ids
+	}
+
+	var created giteaPR
+	if err := g.api.do(ctx, http.MethodPost, g.repoPath()+"/pulls", fields, &created); err != nil {
+		return nil, fmt.Errorf("creating pull request: %w", err)
+	}
+	if len(opts.Reviewers) > 0 {
+		if err := g.RequestReviewers(ctx, created.Number, opts.Reviewers); err != nil {
+			return nil, err
+		}
+		return g.GetPR(ctx, created.Number)
+	}
+	return created.toPR(), nil
+}
+
+// GetPR implements CodeHost.
+func (g *Gitea) GetPR(ctx context.Context, number int) (*PullRequest, error) {
+	pr, err := g.getPR(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return pr.toPR(), nil
+}
+
+func (g *Gitea) getPR(ctx context.Context, number int) (*giteaPR, error) {
+	var pr giteaPR
+	if err := g.api.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d", g.repoPath(), number), nil, &pr); err != nil {
+		return nil, fmt.Errorf("loading pull request #%d: %w", number, err)
+	}
+	return &pr, nil
+}
+
+// UpdatePR implements CodeHost.
+func (g *Gitea) UpdatePR(ctx context.Context, number int, upd PRUpdate) (*PullRequest, error) {
+	fields := map[string]interface{}{}
+	if upd.Body != nil {
+		fields["body"] = *upd.Body
+	}
+	if upd.Title != nil || upd.Draft != nil {
+		current, err := g.getPR(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		title, draft := current.Title, current.toPR().Draft
+		if upd.Title != nil {
+			title = *upd.Title
+		}
+		if upd.Draft != nil {
+			draft = *upd.Draft
+		}
+		fields["title"] = draftTitle(title, "WIP:", draft)
+	}
+	if len(fields) == 0 {
+		return g.GetPR(ctx, number)
+	}
+	var pr giteaPR
+	if err := g.api.do(ctx, http.MethodPatch, fmt.Sprintf("%s/pulls/%d", g.repoPath(), number), fields, &pr); err != nil {
+		return nil, fmt.Errorf("updating pull request #%d: %w", number, err)
+	}
+	return pr.toPR(), nil
+}
+
+// AddLabels implements CodeHost.
+func (g *Gitea) AddLabels(ctx context.Context, number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	ids, err := g.labelIDs(ctx, labels)
+	if err != nil {
+		return err
+	}
+	if err := g.api.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/labels", g.repoPath(), number),
+		map[string]interface{}{"labels": ids}, nil); err != nil {
+		return fmt.Errorf("labeling #%d: %w", number, err)
+	}
+	return nil
+}
+
+// RequestReviewers implements CodeHost.
+func (g *Gitea) RequestReviewers(ctx context.Context, number int, reviewers []string) error {
+	if len(reviewers) == 0 {
+		return nil
+	}
+	if err := g.api.do(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/requested_reviewers", g.repoPath(), number),
+		map[string]interface{}{"reviewers": reviewers}, nil); err != nil {
+		return fmt.Errorf("requesting reviewers on #%d: %w", number, err)
+	}
+	return nil
+}
+
+// Comment implements CodeHost.
+func (g *Gitea) Comment(ctx context.Context, number int, body string) error {
+	if err := g.api.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", g.repoPath(), number),
+		map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("commenting on #%d: %w", number, err)
+	}
+	return nil
+}
+
+// ReviewThreads implements CodeHost. Comments on the same line of the same
+// file form a thread, named after its first comment's ID.
+func (g *Gitea) ReviewThreads(ctx context.Context, number int) ([]ReviewThread, error) {
+	var reviews []struct {
+		ID            int64 `json:"id"`
+		CommentsCount int   `json:"comments_count"`
+	}
+	if err := g.api.listAll(ctx, fmt.Sprintf("%s/pulls/%d/reviews", g.repoPath(), number), &reviews); err != nil {
+		return nil, fmt.Errorf("loading reviews on #%d: %w", number, err)
+	}
+
+	type key struct {
+		path string
+		line int
+	}
+	var threads []ReviewThread
+	index := map[key]int{}
+	for _, r := range reviews {
+		if r.CommentsCount == 0 {
+			continue
+		}
+		var comments []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+			User struct {
+				Login string `json:"login"`
+			} `json:"user"`
+			Path             string      `json:"path"`
+			Position         int         `json:"position"`
+			OriginalPosition int         `json:"original_position"`
+			Resolver         interface{} `json:"resolver"`
+		}
+		if err := g.api.listAll(ctx,
+			fmt.Sprintf("%s/pulls/%d/reviews/%d/comments", g.repoPath(), number, r.ID), &comments); err != nil {
+			return nil, fmt.Errorf("loading review comments on #%d: %w", number, err)
+		}
+		for _, c := range comments {
+			if c.Resolver != nil {
+				continue
+			}
+			line := c.Position
+			if line == 0 {
+				line = c.OriginalPosition
+			}
+			k := key{c.Path, line}
+			i, ok := index[k]
+			if !ok {
+				i = len(threads)
+				index[k] = i
+				threads = append(threads, ReviewThread{ID: strconv.FormatInt(c.ID, 10), Path: c.Path, Line: line})
+			}
+			threads[i].Comments = append(threads[i].Comments, ReviewComment{Author: c.User.Login, Body: c.Body})
+		}
+	}
+	return threads, nil
+}
+
+// ReplyToThread implements CodeHost by commenting on the pull request.
+func (g *Gitea) ReplyToThread(ctx context.Context, number int, threadID, body string) error {
+	return g.Comment(ctx, number, fmt.Sprintf("Re review comment %s:\n\n%s", threadID, body))
+}
+
+// ResolveThread implements CodeHost. Gitea cannot resolve conversations
+// through its API.
+func (g *Gitea) ResolveThread(ctx context.Context, number int, threadID string) error {
+	return fmt.Errorf("resolving review comment %s on #%d: %w", threadID, number, ErrNotSupported)
+}
+
+// CreateIssue implements CodeHost.
+func (g *Gitea) CreateIssue(ctx context.Context, opts IssueOptions) (*Issue, error) {
+	fields := map[string]interface{}{"title": opts.Title, "body": opts.Body}
+	if len(opts.Labels) > 0 {
+		ids, err := g.labelIDs(ctx, opts.Labels)
+		if err != nil {
+			return nil, err
+		}
+		fields["labels"] = ids
+	}
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.api.do(ctx, http.MethodPost, g.repoPath()+"/issues", fields, &created); err != nil {
+		return nil, fmt.Errorf("creating issue: %w", err)
+	}
+	return &Issue{Number: created.Number, URL: created.HTMLURL}, nil
+}
+
+// CIStatus implements CodeHost. Warnings count as passed.
+func (g *Gitea) CIStatus(ctx context.Context, ref string) (*CIStatus, error) {
+	var combined struct {
+		Statuses []struct {
+			Context   string `json:"context"`
+			Status    string `json:"status"`
+			TargetURL string `json:"target_url"`
+		} `json:"statuses"`
+	}
+	if err := g.api.do(ctx, http.MethodGet,
+		g.repoPath()+"/commits/"+url.PathEscape(ref)+"/status", nil, &combined); err != nil {
+		return nil, fmt.Errorf("loading commit statuses for %s: %w", ref, err)
+	}
+	var checks []Check
+	for _, s := range combined.Statuses {
+		state := CIPending
+		switch s.Status {
+		case "success", "warning":
+			state = CISuccess
+		case "failure", "error":
+			state = CIFailure
+		}
+		checks = append(checks, Check{Name: s.Context, State: state, URL: s.TargetURL})
+	}
+	return combineChecks(checks), nil
+}
+
+// CheckLog implements CodeHost. Gitea's API does not serve job logs.
+func (g *Gitea) CheckLog(ctx context.Context, check Check) (string, error) {
+	return "", ErrNotSupported
+}
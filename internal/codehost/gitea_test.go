@@ -0,0 +1,113 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGiteaOpenPR(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET /repos/me/tool/labels?limit=50": []map[string]interface{}{{"id": 3, "name": "agentbox"}},
+		"POST /repos/me/tool/labels":         map[string]interface{}{"id": 9, "name": "new"},
+		"POST /repos/me/tool/pulls": map[string]interface{}{
+			"number": 2, "html_url": "https://gitea.local/me/tool/pulls/2", "title": "WIP: Add login", "state": "open",
+			"head": map[string]string{"ref": "feat/x"}, "base": map[string]string{"ref": "dev"},
+		},
+	})
+	tea := NewGitea(srv.URL, "secret", "me", "tool")
+
+	pr, err := tea.OpenPR(context.Background(), PROptions{
+		Title: "Add login", Head: "feat/x", Base: "dev", Draft: true, Labels: []string{"agentbox", "new"},
+	})
+	if err != nil {
+		t.Fatalf("OpenPR: %v", err)
+	}
+	if pr.Number != 2 || !pr.Draft || pr.Base != "dev" {
+		t.Errorf("OpenPR = %+v", pr)
+	}
+
+	create := findCall(t, calls(), "POST", "/repos/me/tool/pulls")
+	if create.Header.Get("Authorization") != "token secret" {
+		t.Errorf("Authorization = %q", create.Header.Get("Authorization"))
+	}
+	if create.Body["title"] != "WIP: Add login" {
+		t.Errorf("title = %v", create.Body["title"])
+	}
+	if ids, _ := create.Body["labels"].([]interface{}); len(ids) != 2 || ids[0] != float64(3) || ids[1] != float64(9) {
+		t.Errorf("label ids = %v, want the existing label and a created one", create.Body["labels"])
+	}
+}
+
+func TestGiteaUpdateAndComment(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET /repos/me/tool/pulls/2":            map[string]interface{}{"number": 2, "title": "WIP: Add login", "state": "open"},
+		"PATCH /repos/me/tool/pulls/2":          map[string]interface{}{"number": 2, "title": "Add login", "state": "open"},
+		"POST /repos/me/tool/issues/2/comments": map[string]interface{}{"id": 1},
+	})
+	tea := NewGitea(srv.URL, "secret", "me", "tool")
+	ctx := context.Background()
+
+	ready := false
+	pr, err := tea.UpdatePR(ctx, 2, PRUpdate{Draft: &ready})
+	if err != nil || pr.Draft {
+		t.Fatalf("UpdatePR = %+v, %v", pr, err)
+	}
+	if patch := findCall(t, calls(), "PATCH", "/repos/me/tool/pulls/2"); patch.Body["title"] != "Add login" {
+		t.Errorf("patch body = %v", patch.Body)
+	}
+	if err := tea.Comment(ctx, 2, "sprint done"); err != nil {
+		t.Fatalf("Comment: %v", err)
+	}
+}
+
+func TestGiteaCIStatus(t *testing.T) {
+	srv, _ := fakeAPI(t, map[string]interface{}{
+		"GET /repos/me/tool/commits/main/status": map[string]interface{}{
+			"statuses": []map[string]string{{"context": "ci", "status": "success"}, {"context": "lint", "status": "warning"}},
+		},
+	})
+	tea := NewGitea(srv.URL, "secret", "me", "tool")
+
+	st, err := tea.CIStatus(context.Background(), "main")
+	if err != nil || st.State != CISuccess || len(st.Checks) != 2 {
+		t.Errorf("CIStatus = %+v, %v", st, err)
+	}
+}
+
+func TestGiteaReviewThreads(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET /repos/me/tool/pulls/2/reviews": []map[string]interface{}{
+			{"id": 10, "comments_count": 2}, {"id": 11, "comments_count": 0}, {"id": 12, "comments_count": 1},
+		},
+		"GET /repos/me/tool/pulls/2/reviews/10/comments": []map[string]interface{}{
+			{"id": 100, "body": "handle nil", "user": map[string]string{"login": "alice"}, "path": "auth.go", "position": 12},
+			{"id": 101, "body": "old", "path": "main.go", "position": 3, "resolver": map[string]string{"login": "bob"}},
+		},
+		"GET /repos/me/tool/pulls/2/reviews/12/comments": []map[string]interface{}{
+			{"id": 120, "body": "still", "user": map[string]string{"login": "bob"}, "path": "auth.go", "position": 12},
+		},
+		"POST /repos/me/tool/issues/2/comments": map[string]interface{}{"id": 1},
+	})
+	tea := NewGitea(srv.URL, "secret", "me", "tool")
+	ctx := context.Background()
+
+	threads, err := tea.ReviewThreads(ctx, 2)
+	if err != nil {
+		t.Fatalf("ReviewThreads: %v", err)
+	}
+	if len(threads) != 1 || threads[0].ID != "100" || len(threads[0].Comments) != 2 || threads[0].Comments[1].Author != "bob" {
+		t.Fatalf("threads = %+v", threads)
+	}
+
+	if err := tea.ReplyToThread(ctx, 2, "100", "Fixed in abc123."); err != nil {
+		t.Fatalf("ReplyToThread: %v", err)
+	}
+	if c := findCall(t, calls(), "POST", "/repos/me/tool/issues/2/comments"); !strings.Contains(c.Body["body"].(string), "Fixed in abc123.") {
+		t.Errorf("reply comment = %v", c.Body)
+	}
+	if err := tea.ResolveThread(ctx, 2, "100"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ResolveThread = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,497 @@
+package codehost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitHub uses the GitHub REST API, plus GraphQL for draft state and review
+// threads, which REST cannot change or does not group.
+type GitHub struct {
+	api     *apiClient
+	graphQL string
+	owner   string
+	repo    string
+}
+
+// NewGitHub returns a client for owner/repo. apiURL is
+// https://api.github.com, or https://HOST/api/v3 for GitHub Enterprise.
+func NewGitHub(apiURL, token, owner, repo string) *GitHub {
+	base := strings.TrimSuffix(apiURL, "/")
+	return &GitHub{
+		api: newAPIClient(base, func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+			r.Header.Set("Accept", "application/vnd.github+json")
+			r.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		}),
+		graphQL: strings.TrimSuffix(base, "/v3") + "/graphql",
+		owner:   owner,
+		repo:    repo,
+	}
+}
+
+// Name implements CodeHost.
+func (g *GitHub) Name() string { return "github" }
+
+func (g *GitHub) repoPath() string {
+	return "/repos/" + url.PathEscape(g.owner) + "/" + url.PathEscape(g.repo)
+}
+
+type githubPR struct {
+	Number  int    `json:"number"`
+	NodeID  string `json:"node_id"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	Draft   bool   `json:"draft"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	RequestedReviewers []struct {
+		Login string `json:"login"`
+	} `json:"requested_reviewers"`
+}
+
+func (p *githubPR) toPR() *PullRequest {
+	pr := &PullRequest{
+		Number: p.Number, URL: p.HTMLURL, Title: p.Title, Body: p.Body,
+		Head: p.Head.Ref, Base: p.Base.Ref, Draft: p.Draft, State: p.State,
+	}
+	if p.Merged {
+		pr.State = "merged"
+	}
+	for _, l := range p.Labels {
+		pr.Labels = append(pr.Labels, l.Name)
+	}
+	for _, r := range p.RequestedReviewers {
+		pr.Reviewers = append(pr.Reviewers, r.Login)
+	}
+	return pr
+}
+
+func (g *GitHub) defaultBranch(ctx context.Context) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.api.do(ctx, http.MethodGet, g.repoPath(), nil, &repo); err != nil {
+		return "", fmt.Errorf("finding default branch: %w", err)
+	}
+	return repo.DefaultBranch, nil
+}
+
+// OpenPR implements CodeHost.
+func (g *GitHub) OpenPR(ctx context.Context, opts PROptions) (*PullRequest, error) {
+	base := opts.Base
+	if base == "" {
+		var err error
+		if base, err = g.defaultBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var created githubPR
+	if err := g.api.do(ctx, http.MethodPost, g.repoPath()+"/pulls", map[string]interface{}{
+		"title": opts.Title,
+		"body":  opts.Body,
+		"head":  opts.Head,
+		"base":  base,
+		"draft": opts.Draft,
+	}, &created); err != nil {
+		return nil, fmt.Errorf("creating pull request: %w", err)
+	}
+	if err := g.AddLabels(ctx, created.Number, opts.Labels); err != nil {
+		return nil, err
+	}
+	if err := g.RequestReviewers(ctx, created.Number, opts.Reviewers); err != nil {
+		return nil, err
+	}
+	if len(opts.Labels) > 0 || len(opts.Reviewers) > 0 {
+		return g.GetPR(ctx, created.Number)
+	}
+	return created.toPR(), nil
+}
+
+// GetPR implements CodeHost.
+func (g *GitHub) GetPR(ctx context.Context, number int) (*PullRequest, error) {
+	pr, err := g.getPR(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return pr.toPR(), nil
+}
+
+func (g *GitHub) getPR(ctx context.Context, number int) (*githubPR, error) {
+	var pr githubPR
+	if err := g.api.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d", g.repoPath(), number), nil, &pr); err != nil {
+		return nil, fmt.Errorf("loading pull request #%d: %w", number, err)
+	}
+	return &pr, nil
+}
+
+// UpdatePR implements CodeHost.
+func (g *GitHub) UpdatePR(ctx context.Context, number int, upd PRUpdate) (*PullRequest, error) {
+	fields := map[string]interface{}{}
+	if upd.Title != nil {
+		fields["title"] = *upd.Title
+	}
+	if upd.Body != nil {
+		fields["body"] = *upd.Body
+	}
+	if len(fields) > 0 {
+		if err := g.api.do(ctx, http.MethodPatch, fmt.Sprintf("%s/pulls/%d", g.repoPath(), number), fields, nil); err != nil {
+			return nil, fmt.Errorf("updating pull request #%d: %w", number, err)
+		}
+	}
+
+	pr, err := g.getPR(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if upd.Draft != nil && *upd.Draft != pr.Draft {
+		mutation := "markPullRequestReadyForReview"
+		if *upd.Draft {
+			mutation = "convertPullRequestToDraft"
+		}
+		if err := g.graphQLMutation(ctx, mutation, pr.NodeID); err != nil {
+			return nil, fmt.Errorf("changing draft state of #%d: %w", number, err)
+		}
+		pr.Draft = *upd.Draft
+	}
+	return pr.toPR(), nil
+}
+
+func (g *GitHub) graphQLMutation(ctx context.Context, mutation, pullRequestID string) error {
+	query := fmt.Sprintf(`mutation($id: ID!) { %s(input: {pullRequestId: $id}) { clientMutationId } }`, mutation)
+	if err := g.graphQLQuery(ctx, query, map[string]interface{}{"id": pullRequestID}, nil); err != nil {
+		return fmt.Errorf("%s: %w", mutation, err)
+	}
+	return nil
+}
+
+// graphQLQuery runs a GraphQL query or mutation and decodes its data into
+// out (when not nil). GraphQL reports errors in the body of a 200 response.
+func (g *GitHub) graphQLQuery(ctx context.Context, query string, vars map[string]interface{}, out interface{}) error {
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := g.api.doURL(ctx, http.MethodPost, g.graphQL, map[string]interface{}{
+		"query":     query,
+		"variables": vars,
+	}, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("%s", resp.Errors[0].Message)
+	}
+	if out == nil || len(resp.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("decoding GraphQL response: %w", err)
+	}
+	return nil
+}
+
+// AddLabels implements CodeHost. GitHub creates labels that do not exist.
+func (g *GitHub) AddLabels(ctx context.Context, number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if err := g.api.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/labels", g.repoPath(), number),
+		map[string]interface{}{"labels": labels}, nil); err != nil {
+		return fmt.Errorf("labeling #%d: %w", number, err)
+	}
+	return nil
+}
+
+// RequestReviewers implements CodeHost.
+func (g *GitHub) RequestReviewers(ctx context.Context, number int, reviewers []string) error {
+	if len(reviewers) == 0 {
+		return nil
+	}
+	if err := g.api.do(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/requested_reviewers", g.repoPath(), number),
+		map[string]interface{}{"reviewers": reviewers}, nil); err != nil {
+		return fmt.Errorf("requesting reviewers on #%d: %w", number, err)
+	}
+	return nil
+}
+
+// Comment implements CodeHost.
+func (g *GitHub) Comment(ctx context.Context, number int, body string) error {
+	if err := g.api.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", g.repoPath(), number),
+		map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("commenting on #%d: %w", number, err)
+	}
+	return nil
+}
+
+const githubReviewThreadsQuery = `query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          id
+          isResolved
+          path
+          line
+          originalLine
+          comments(first: 100) {
+            pageInfo { hasNextPage endCursor }
+            nodes { author { login } body }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// githubThreadCommentsQuery loads the comments of a review thread with more
+// than fit in githubReviewThreadsQuery.
+const githubThreadCommentsQuery = `query($id: ID!, $cursor: String) {
+  node(id: $id) {
+    ... on PullRequestReviewThread {
+      comments(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { author { login } body }
+      }
+    }
+  }
+}`
+
+type githubPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type githubThreadComments struct {
+	PageInfo githubPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		Author *struct {
+			Login string `json:"login"`
+		} `json:"author"`
+		Body string `json:"body"`
+	} `json:"nodes"`
+}
+
+// ReviewThreads implements CodeHost.
+func (g *GitHub) ReviewThreads(ctx context.Context, number int) ([]ReviewThread, error) {
+	var threads []ReviewThread
+	var cursor interface{} // null for the first page
+	for {
+		var data struct {
+			Repository struct {
+				PullRequest *struct {
+					ReviewThreads struct {
+						PageInfo githubPageInfo `json:"pageInfo"`
+						Nodes    []struct {
+							ID           string               `json:"id"`
+							IsResolved   bool                 `json:"isResolved"`
+							Path         string               `json:"path"`
+							Line         *int                 `json:"line"`
+							OriginalLine *int                 `json:"originalLine"`
+							Comments     githubThreadComments `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		if err := g.graphQLQuery(ctx, githubReviewThreadsQuery, map[string]interface{}{
+			"owner": g.owner, "repo": g.repo, "number": number, "cursor": cursor,
+		}, &data); err != nil {
+			return nil, fmt.Errorf("loading review threads on #%d: %w", number, err)
+		}
+		if data.Repository.PullRequest == nil {
+			return nil, fmt.Errorf("loading review threads on #%d: pull request not found", number)
+		}
+
+		page := data.Repository.PullRequest.ReviewThreads
+		for _, n := range page.Nodes {
+			if n.IsResolved {
+				continue
+			}
+			t := ReviewThread{ID: n.ID, Path: n.Path}
+			// Outdated threads have no line in the current diff.
+			if n.Line != nil {
+				t.Line = *n.Line
+			} else if n.OriginalLine != nil {
+				t.Line = *n.OriginalLine
+			}
+			comments, err := g.threadComments(ctx, n.ID, n.Comments)
+			if err != nil {
+				return nil, fmt.Errorf("loading review threads on #%d: %w", number, err)
+			}
+			t.Comments = comments
+			threads = append(threads, t)
+		}
+		if !page.PageInfo.HasNextPage {
+			return threads, nil
+		}
+		cursor = page.PageInfo.EndCursor
+	}
+}
+
+// threadComments returns the comments of a review thread, starting with the
+// first page, which came with the thread, and loading the rest.
+func (g *GitHub) threadComments(ctx context.Context, threadID string, page githubThreadComments) ([]ReviewComment, error) {
+	var comments []ReviewComment
+	for {
+		for _, c := range page.Nodes {
+			author := "ghost" // GitHub's name for deleted accounts
+			if c.Author != nil {
+				author = c.Author.Login
+			}
+			comments = append(comments, ReviewComment{Author: author, Body: c.Body})
+		}
+		if !page.PageInfo.HasNextPage {
+			return comments, nil
+		}
+		var data struct {
+			Node *struct {
+				Comments githubThreadComments `json:"comments"`
+			} `json:"node"`
+		}
+		if err := g.graphQLQuery(ctx, githubThreadCommentsQuery, map[string]interface{}{
+			"id": threadID, "cursor": page.PageInfo.EndCursor,
+		}, &data); err != nil {
+			return nil, fmt.Errorf("loading comments of thread %s: %w", threadID, err)
+		}
+		if data.Node == nil {
+			return nil, fmt.Errorf("loading comments of thread %s: thread not found", threadID)
+		}
+		page = data.Node.Comments
+	}
+}
+
+// ReplyToThread implements CodeHost.
+func (g *GitHub) ReplyToThread(ctx context.Context, number int, threadID, body string) error {
+	const query = `mutation($id: ID!, $body: String!) {
+  addPullRequestReviewThreadReply(input: {pullRequestReviewThreadId: $id, body: $body}) { clientMutationId }
+}`
+	if err := g.graphQLQuery(ctx, query, map[string]interface{}{"id": threadID, "body": body}, nil); err != nil {
+		return fmt.Errorf("replying to review thread on #%d: %w", number, err)
+	}
+	return nil
+}
+
+// ResolveThread implements CodeHost.
+func (g *GitHub) ResolveThread(ctx context.Context, number int, threadID string) error {
+	const query = `mutation($id: ID!) { resolveReviewThread(input: {threadId: $id}) { clientMutationId } }`
+	if err := g.graphQLQuery(ctx, query, map[string]interface{}{"id": threadID}, nil); err != nil {
+		return fmt.Errorf("resolving review thread on #%d: %w", number, err)
+	}
+	return nil
+}
+
+// CreateIssue implements CodeHost.
+func (g *GitHub) CreateIssue(ctx context.Context, opts IssueOptions) (*Issue, error) {
+	fields := map[string]interface{}{"title": opts.Title, "body": opts.Body}
+	if len(opts.Labels) > 0 {
+		fields["labels"] = opts.Labels
+	}
+	var created struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.api.do(ctx, http.MethodPost, g.repoPath()+"/issues", fields, &created); err != nil {
+		return nil, fmt.Errorf("creating issue: %w", err)
+	}
+	return &Issue{Number: created.Number, URL: created.HTMLURL}, nil
+}
+
+// CIStatus implements CodeHost. It merges check runs (GitHub Actions and
+// other apps) with commit statuses (older integrations).
+func (g *GitHub) CIStatus(ctx context.Context, ref string) (*CIStatus, error) {
+	var checks []Check
+	if err := g.api.list(ctx, g.repoPath()+"/commits/"+url.PathEscape(ref)+"/check-runs?per_page=100", func(data []byte) error {
+		var page struct {
+			CheckRuns []struct {
+				ID         int64  `json:"id"`
+				Name       string `json:"name"`
+				Status     string `json:"status"`
+				Conclusion string `json:"conclusion"`
+				HTMLURL    string `json:"html_url"`
+				App        struct {
+					Slug string `json:"slug"`
+				} `json:"app"`
+			} `json:"check_runs"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, r := range page.CheckRuns {
+			state := CIPending
+			if r.Status == "completed" {
+				switch r.Conclusion {
+				case "success", "neutral", "skipped":
+					state = CISuccess
+				default:
+					state = CIFailure
+				}
+			}
+			check := Check{Name: r.Name, State: state, URL: r.HTMLURL}
+			// Only Actions check runs are jobs with a log to fetch.
+			if r.App.Slug == "github-actions" {
+				check.ID = strconv.FormatInt(r.ID, 10)
+			}
+			checks = append(checks, check)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("loading check runs for %s: %w", ref, err)
+	}
+
+	if err := g.api.list(ctx, g.repoPath()+"/commits/"+url.PathEscape(ref)+"/status?per_page=100", func(data []byte) error {
+		var page struct {
+			Statuses []struct {
+				Context   string `json:"context"`
+				State     string `json:"state"`
+				TargetURL string `json:"target_url"`
+			} `json:"statuses"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, s := range page.Statuses {
+			state := CIPending
+			switch s.State {
+			case "success":
+				state = CISuccess
+			case "failure", "error":
+				state = CIFailure
+			}
+			checks = append(checks, Check{Name: s.Context, State: state, URL: s.TargetURL})
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("loading commit statuses for %s: %w", ref, err)
+	}
+	return combineChecks(checks), nil
+}
+
+// CheckLog implements CodeHost. Only GitHub Actions jobs have logs.
+func (g *GitHub) CheckLog(ctx context.Context, check Check) (string, error) {
+	if check.ID == "" {
+		return "", ErrNotSupported
+	}
+	log, err := g.api.text(ctx, g.repoPath()+"/actions/jobs/"+check.ID+"/logs")
+	if err != nil {
+		return "", fmt.Errorf("loading log for %s: %w", check.Name, err)
+	}
+	return log, nil
+}
@@ -0,0 +1,288 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGitHubOpenPR(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET /repos/org/repo": map[string]string{"default_branch": "trunk"},
+		"POST /repos/org/repo/pulls": map[string]interface{}{
+			"number": 7, "html_url": "https://github.com/org/repo/pull/7", "draft": true, "state": "open",
+		},
+		"POST /repos/org/repo/issues/7/labels":             []interface{}{},
+		"POST /repos/org/repo/pulls/7/requested_reviewers": map[string]interface{}{},
+		"GET /repos/org/repo/pulls/7": map[string]interface{}{
+			"number": 7, "html_url": "https://github.com/org/repo/pull/7", "draft": true, "state": "open",
+			"head": map[string]string{"ref": "feat/x"}, "base": map[string]string{"ref": "trunk"},
+			"labels":              []map[string]string{{"name": "agentbox"}},
+			"requested_reviewers": []map[string]string{{"login": "alice"}},
+		},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+
+	pr, err := gh.OpenPR(context.Background(), PROptions{
+		Title: "Add login", Body: "body", Head: "feat/x", Draft: true,
+		Labels: []string{"agentbox"}, Reviewers: []string{"alice"},
+	})
+	if err != nil {
+		t.Fatalf("OpenPR: %v", err)
+	}
+	if pr.Number != 7 || pr.Base != "trunk" || !pr.Draft || pr.Labels[0] != "agentbox" || pr.Reviewers[0] != "alice" {
+		t.Errorf("OpenPR = %+v", pr)
+	}
+
+	create := findCall(t, calls(), "POST", "/repos/org/repo/pulls")
+	if create.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Authorization = %q", create.Header.Get("Authorization"))
+	}
+	if create.Body["base"] != "trunk" || create.Body["head"] != "feat/x" || create.Body["draft"] != true {
+		t.Errorf("create body = %v", create.Body)
+	}
+	labels := findCall(t, calls(), "POST", "/repos/org/repo/issues/7/labels")
+	if l, _ := labels.Body["labels"].([]interface{}); len(l) != 1 || l[0] != "agentbox" {
+		t.Errorf("labels body = %v", labels.Body)
+	}
+}
+
+func TestGitHubUpdatePRDraft(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"PATCH /repos/org/repo/pulls/7": map[string]interface{}{},
+		"GET /repos/org/repo/pulls/7":   map[string]interface{}{"number": 7, "node_id": "PR_node", "draft": true, "state": "open"},
+		"POST /graphql":                 map[string]interface{}{"data": map[string]interface{}{}},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+
+	title, ready := "Ready now", false
+	pr, err := gh.UpdatePR(context.Background(), 7, PRUpdate{Title: &title, Draft: &ready})
+	if err != nil {
+		t.Fatalf("UpdatePR: %v", err)
+	}
+	if pr.Draft {
+		t.Error("pull request still a draft")
+	}
+	if patch := findCall(t, calls(), "PATCH", "/repos/org/repo/pulls/7"); patch.Body["title"] != "Ready now" {
+		t.Errorf("patch body = %v", patch.Body)
+	}
+	gql := findCall(t, calls(), "POST", "/graphql")
+	if q, _ := gql.Body["query"].(string); q == "" || gql.Body["variables"].(map[string]interface{})["id"] != "PR_node" {
+		t.Errorf("graphql body = %v", gql.Body)
+	}
+}
+
+func TestGitHubCIStatus(t *testing.T) {
+	srv, _ := fakeAPI(t, map[string]interface{}{
+		"GET /repos/org/repo/commits/feat%2Fx/check-runs?per_page=100": map[string]interface{}{
+			"check_runs": []map[string]interface{}{
+				{"id": 41, "name": "build", "status": "completed", "conclusion": "success",
+					"app": map[string]string{"slug": "github-actions"}},
+				{"id": 42, "name": "lint", "status": "in_progress", "app": map[string]string{"slug": "linter-bot"}},
+			},
+		},
+		"GET /repos/org/repo/actions/jobs/41/logs": []byte("2026-01-02T03:04:05Z ok  \tpkg\n"),
+		"GET /repos/org/repo/commits/feat%2Fx/status?per_page=100": map[string]interface{}{
+			"statuses": []map[string]string{{"context": "ci/legacy", "state": "failure", "target_url": "https://ci/1"}},
+		},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+
+	st, err := gh.CIStatus(context.Background(), "feat/x")
+	if err != nil {
+		t.Fatalf("CIStatus: %v", err)
+	}
+	if st.State != CIFailure || len(st.Checks) != 3 {
+		t.Fatalf("CIStatus = %+v", st)
+	}
+	if st.Checks[1].State != CIPending || st.Checks[2].URL != "https://ci/1" {
+		t.Errorf("checks = %+v", st.Checks)
+	}
+	if st.Checks[0].ID != "41" || st.Checks[1].ID != "" || st.Checks[2].ID != "" {
+		t.Errorf("only Actions jobs should carry an ID: %+v", st.Checks)
+	}
+
+	if log, err := gh.CheckLog(context.Background(), st.Checks[0]); err != nil || !strings.Contains(log, "ok  \tpkg") {
+		t.Errorf("CheckLog = %q, %v", log, err)
+	}
+	if _, err := gh.CheckLog(context.Background(), st.Checks[2]); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("CheckLog on a commit status: %v", err)
+	}
+}
+
+func TestGitHubCreateIssueAndErrors(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"POST /repos/org/repo/issues": map[string]interface{}{"number": 3, "html_url": "https://github.com/org/repo/issues/3"},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+
+	issue, err := gh.CreateIssue(context.Background(), IssueOptions{Title: "stuck", Body: "help"})
+	if err != nil || issue.Number != 3 {
+		t.Fatalf("CreateIssue = %+v, %v", issue, err)
+	}
+	if body := findCall(t, calls(), "POST", "/repos/org/repo/issues").Body; body["title"] != "stuck" {
+		t.Errorf("issue body = %v", body)
+	}
+
+	err = gh.Comment(context.Background(), 99, "hello")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 404 || apiErr.Message != "Not Found" {
+		t.Errorf("Comment on a missing route = %v, want a 404 APIError", err)
+	}
+}
+
+func TestGitHubReviewThreads(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"POST /graphql": map[string]interface{}{"data": map[string]interface{}{
+			"repository": map[string]interface{}{"pullRequest": map[string]interface{}{
+				"reviewThreads": map[string]interface{}{"nodes": []interface{}{
+					map[string]interface{}{
+						"id": "T_1", "isResolved": false, "path": "auth.go", "line": 12,
+						"comments": map[string]interface{}{"nodes": []interface{}{
+							map[string]interface{}{"author": map[string]string{"login": "alice"}, "body": "check the error"},
+						}},
+					},
+					map[string]interface{}{"id": "T_2", "isResolved": true, "path": "main.go", "line": 3},
+					map[string]interface{}{
+						"id": "T_3", "isResolved": false, "path": "old.go", "line": nil, "originalLine": 40,
+						"comments": map[string]interface{}{"nodes": []interface{}{
+							map[string]interface{}{"author": nil, "body": "typo"},
+						}},
+					},
+				}},
+			}},
+		}},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+	ctx := context.Background()
+
+	threads, err := gh.ReviewThreads(ctx, 7)
+	if err != nil {
+		t.Fatalf("ReviewThreads: %v", err)
+	}
+	if len(threads) != 2 || threads[0].ID != "T_1" || threads[0].Line != 12 || threads[0].Comments[0].Author != "alice" {
+		t.Fatalf("threads = %+v", threads)
+	}
+	if threads[1].Line != 40 || threads[1].Comments[0].Author != "ghost" {
+		t.Errorf("outdated thread = %+v", threads[1])
+	}
+	if vars := findCall(t, calls(), "POST", "/graphql").Body["variables"].(map[string]interface{}); vars["number"] != float64(7) || vars["owner"] != "org" {
+		t.Errorf("query variables = %v", vars)
+	}
+
+	if err := gh.ReplyToThread(ctx, 7, "T_1", "Fixed in abc123."); err != nil {
+		t.Fatalf("ReplyToThread: %v", err)
+	}
+	if err := gh.ResolveThread(ctx, 7, "T_1"); err != nil {
+		t.Fatalf("ResolveThread: %v", err)
+	}
+	c := calls()
+	reply, resolve := c[len(c)-2], c[len(c)-1]
+	if q, _ := reply.Body["query"].(string); !strings.Contains(q, "addPullRequestReviewThreadReply") ||
+		reply.Body["variables"].(map[string]interface{})["body"] != "Fixed in abc123." {
+		t.Errorf("reply body = %v", reply.Body)
+	}
+	if q, _ := resolve.Body["query"].(string); !strings.Contains(q, "resolveReviewThread") {
+		t.Errorf("resolve body = %v", resolve.Body)
+	}
+}
+
+func TestGitHubCIStatusPaginated(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET /repos/org/repo/commits/main/check-runs?per_page=100": fakePage{
+			Next: "/repos/org/repo/commits/main/check-runs?per_page=100&page=2",
+			Body: map[string]interface{}{"check_runs": []map[string]interface{}{
+				{"id": 1, "name": "build", "status": "completed", "conclusion": "success"},
+			}},
+		},
+		"GET /repos/org/repo/commits/main/check-runs?per_page=100&page=2": map[string]interface{}{
+			"check_runs": []map[string]interface{}{
+				{"id": 2, "name": "test", "status": "completed", "conclusion": "failure"},
+			},
+		},
+		"GET /repos/org/repo/commits/main/status?per_page=100": map[string]interface{}{"statuses": []interface{}{}},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+
+	st, err := gh.CIStatus(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("CIStatus: %v", err)
+	}
+	if len(st.Checks) != 2 || st.Checks[1].Name != "test" || st.State != CIFailure {
+		t.Errorf("CIStatus = %+v, want the checks of both pages", st)
+	}
+	if page2 := findCall(t, calls(), "GET", "/repos/org/repo/commits/main/check-runs?per_page=100&page=2"); page2.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("second page sent without the token")
+	}
+}
+
+func TestGitHubReviewThreadsPaginated(t *testing.T) {
+	comment := func(body string) map[string]interface{} {
+		return map[string]interface{}{"author": map[string]string{"login": "alice"}, "body": body}
+	}
+	pageInfo := func(next bool, cursor string) map[string]interface{} {
+		return map[string]interface{}{"hasNextPage": next, "endCursor": cursor}
+	}
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"POST /graphql": func(call apiCall) interface{} {
+			query, _ := call.Body["query"].(string)
+			vars, _ := call.Body["variables"].(map[string]interface{})
+			switch {
+			case strings.Contains(query, "node(id:"):
+				return map[string]interface{}{"data": map[string]interface{}{"node": map[string]interface{}{
+					"comments": map[string]interface{}{
+						"pageInfo": pageInfo(false, ""),
+						"nodes":    []interface{}{comment("second comment")},
+					},
+				}}}
+			case vars["cursor"] == nil:
+				return map[string]interface{}{"data": map[string]interface{}{"repository": map[string]interface{}{"pullRequest": map[string]interface{}{
+					"reviewThreads": map[string]interface{}{
+						"pageInfo": pageInfo(true, "threads-1"),
+						"nodes": []interface{}{map[string]interface{}{
+							"id": "T_1", "path": "a.go", "line": 1,
+							"comments": map[string]interface{}{
+								"pageInfo": pageInfo(true, "comments-1"),
+								"nodes":    []interface{}{comment("first comment")},
+							},
+						}},
+					},
+				}}}}
+			default:
+				return map[string]interface{}{"data": map[string]interface{}{"repository": map[string]interface{}{"pullRequest": map[string]interface{}{
+					"reviewThreads": map[string]interface{}{
+						"pageInfo": pageInfo(false, ""),
+						"nodes": []interface{}{map[string]interface{}{
+							"id": "T_2", "path": "b.go", "line": 2,
+							"comments": map[string]interface{}{
+								"pageInfo": pageInfo(false, ""),
+								"nodes":    []interface{}{comment("only comment")},
+							},
+						}},
+					},
+				}}}}
+			}
+		},
+	})
+	gh := NewGitHub(srv.URL, "secret", "org", "repo")
+
+	threads, err := gh.ReviewThreads(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("ReviewThreads: %v", err)
+	}
+	if len(threads) != 2 || threads[0].ID != "T_1" || threads[1].ID != "T_2" {
+		t.Fatalf("threads = %+v, want the threads of both pages", threads)
+	}
+	if c := threads[0].Comments; len(c) != 2 || c[0].Body != "first comment" || c[1].Body != "second comment" {
+		t.Errorf("T_1 comments = %+v, want both pages", c)
+	}
+
+	var cursors []interface{}
+	for _, c := range calls() {
+		cursors = append(cursors, c.Body["variables"].(map[string]interface{})["cursor"])
+	}
+	if len(cursors) != 3 || cursors[0] != nil || cursors[1] != "comments-1" || cursors[2] != "threads-1" {
+		t.Errorf("cursors = %v", cursors)
+	}
+}
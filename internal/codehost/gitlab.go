@@ -0,0 +1,361 @@
+package codehost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitLab uses the GitLab v4 API. Pull requests are merge requests, and
+// draft state is the "Draft:" title prefix.
+type GitLab struct {
+	api     *apiClient
+	project string
+}
+
+// NewGitLab returns a client for the project at path (group/name, with any
+// subgroups). apiURL is https://HOST/api/v4.
+func NewGitLab(apiURL, token, path string) *GitLab {
+	return &GitLab{
+		api: newAPIClient(apiURL, func(r *http.Request) {
+			r.Header.Set("PRIVATE-TOKEN", token)
+		}),
+		project: path,
+	}
+}
+
+// Name implements CodeHost.
+func (g *GitLab) Name() string { return "gitlab" }
+
+func (g *GitLab) projectPath() string {
+	return "/projects/" + url.PathEscape(g.project)
+}
+
+func (g *GitLab) mrPath(iid int) string {
+	return fmt.Sprintf("%s/merge_requests/%d", g.projectPath(), iid)
+}
+
+type gitlabMR struct {
+	IID          int      `json:"iid"`
+	WebURL       string   `json:"web_url"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Draft        bool     `json:"draft"`
+	State        string   `json:"state"`
+	SourceBranch string   `json:"source_branch"`
+	TargetBranch string   `json:"target_branch"`
+	Labels       []string `json:"labels"`
+	Reviewers    []struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"reviewers"`
+}
+
+func (m *gitlabMR) toPR() *PullRequest {
+	pr := &PullRequest{
+		Number: m.IID, URL: m.WebURL, Title: m.Title, Body: m.Description,
+		Head: m.SourceBranch, Base: m.TargetBranch, Draft: m.Draft, State: m.State,
+		Labels: m.Labels,
+	}
+	if m.State == "opened" {
+		pr.State = "open"
+	}
+	for _, r := range m.Reviewers {
+		pr.Reviewers = append(pr.Reviewers, r.Username)
+	}
+	return pr
+}
+
+func (g *GitLab) defaultBranch(ctx context.Context) (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.api.do(ctx, http.MethodGet, g.projectPath(), nil, &project); err != nil {
+		return "", fmt.Errorf("finding default branch: %w", err)
+	}
+	return project.DefaultBranch, nil
+}
+
+// userIDs resolves usernames, since merge request reviewers are set by ID.
+func (g *GitLab) userIDs(ctx context.Context, usernames []string) ([]int, error) {
+	ids := make([]int, 0, len(usernames))
+	for _, name := range usernames {
+		var users []struct {
+			ID int `json:"id"`
+		}
+		if err := g.api.do(ctx, http.MethodGet, "/users?username="+url.QueryEscape(name), nil, &users); err != nil {
+			return nil, fmt.Errorf("looking up user %s: %w", name, err)
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("no GitLab user %s", name)
+		}
+		ids = append(ids, users[0].ID)
+	}
+	return ids, nil
+}
+
+// OpenPR implements CodeHost.
+func (g *GitLab) OpenPR(ctx context.Context, opts PROptions) (*PullRequest, error) {
+	base := opts.Base
+	if base == "" {
+		var err error
+		if base, err = g.defaultBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	fields := map[string]interface{}{
+		"source_branch": opts.Head,
+		"target_branch": base,
+		"title":         draftTitle(opts.Title, "Draft:", opts.Draft),
+		"description":   opts.Body,
+	}
+	if len(opts.Labels) > 0 {
+		fields["labels"] = strings.Join(opts.Labels, ",")
+	}
+	if len(opts.Reviewers) > 0 {
+		ids, err := g.userIDs(ctx, opts.Reviewers)
+		if err != nil {
+			return nil, err
+		}
+		fields["reviewer_ids"] = ids
+	}
+
+	var created gitlabMR
+	if err := g.api.do(ctx, http.MethodPost, g.projectPath()+"/merge_requests", fields, &created); err != nil {
+		return nil, fmt.Errorf("creating merge request: %w", err)
+	}
+	return created.toPR(), nil
+}
+
+// GetPR implements CodeHost.
+func (g *GitLab) GetPR(ctx context.Context, number int) (*PullRequest, error) {
+	mr, err := g.getMR(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return mr.toPR(), nil
+}
+
+func (g *GitLab) getMR(ctx context.Context, iid int) (*gitlabMR, error) {
+	var mr gitlabMR
+	if err := g.api.do(ctx, http.MethodGet, g.mrPath(iid), nil, &mr); err != nil {
+		return nil, fmt.Errorf("loading merge request !%d: %w", iid, err)
+	}
+	return &mr, nil
+}
+
+func (g *GitLab) putMR(ctx context.Context, iid int, fields map[string]interface{}) (*gitlabMR, error) {
+	var mr gitlabMR
+	if err := g.api.do(ctx, http.MethodPut, g.mrPath(iid), fields, &mr); err != nil {
+		return nil, fmt.Errorf("updating merge request !%d: %w", iid, err)
+	}
+	return &mr, nil
+}
+
+// UpdatePR implements CodeHost.
+func (g *GitLab) UpdatePR(ctx context.Context, number int, upd PRUpdate) (*PullRequest, error) {
+	fields := map[string]interface{}{}
+	if upd.Body != nil {
+		fields["description"] = *upd.Body
+	}
+	if upd.Title != nil || upd.Draft != nil {
+		current, err := g.getMR(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		title, draft := current.Title, current.Draft
+		if upd.Title != nil {
+			title = *upd.Title
+		}
+		if upd.Draft != nil {
+			draft = *upd.Draft
+		}
+		fields["title"] = draftTitle(title, "Draft:", draft)
+	}
+	if len(fields) == 0 {
+		return g.GetPR(ctx, number)
+	}
+	mr, err := g.putMR(ctx, number, fields)
+	if err != nil {
+		return nil, err
+	}
+	return mr.toPR(), nil
+}
+
+// AddLabels implements CodeHost.
+func (g *GitLab) AddLabels(ctx context.Context, number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	_, err := g.putMR(ctx, number, map[string]interface{}{"add_labels": strings.Join(labels, ",")})
+	return err
+}
+
+// RequestReviewers implements CodeHost. Setting reviewer_ids replaces the
+// list, so existing reviewers are sent along with the new ones.
+func (g *GitLab) RequestReviewers(ctx context.Context, number int, reviewers []string) error {
+	if len(reviewers) == 0 {
+		return nil
+	}
+	mr, err := g.getMR(ctx, number)
+	if err != nil {
+		return err
+	}
+	added, err := g.userIDs(ctx, reviewers)
+	if err != nil {
+		return err
+	}
+	ids := make([]int, 0, len(mr.Reviewers)+len(added))
+	seen := map[int]bool{}
+	for _, r := range mr.Reviewers {
+		ids = append(ids, r.ID)
+		seen[r.ID] = true
+	}
+	for _, id := range added {
+		if !seen[id] {
+			ids = append(ids, id)
+			seen[id] = true
+		}
+	}
+	_, err = g.putMR(ctx, number, map[string]interface{}{"reviewer_ids": ids})
+	return err
+}
+
+// Comment implements CodeHost.
+func (g *GitLab) Comment(ctx context.Context, number int, body string) error {
+	if err := g.api.do(ctx, http.MethodPost, g.mrPath(number)+"/notes", map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("commenting on !%d: %w", number, err)
+	}
+	return nil
+}
+
+// ReviewThreads implements CodeHost. Threads are the resolvable
+// discussions on a merge request; plain comments and system notes are not.
+func (g *GitLab) ReviewThreads(ctx context.Context, number int) ([]ReviewThread, error) {
+	var discussions []struct {
+		ID    string `json:"id"`
+		Notes []struct {
+			Body   string `json:"body"`
+			System bool   `json:"system"`
+			Author struct {
+				Username string `json:"username"`
+			} `json:"author"`
+			Resolvable bool `json:"resolvable"`
+			Resolved   bool `json:"resolved"`
+			Position   *struct {
+				NewPath string `json:"new_path"`
+				OldPath string `json:"old_path"`
+				NewLine *int   `json:"new_line"`
+				OldLine *int   `json:"old_line"`
+			} `json:"position"`
+		} `json:"notes"`
+	}
+	if err := g.api.listAll(ctx, g.mrPath(number)+"/discussions?per_page=100", &discussions); err != nil {
+		return nil, fmt.Errorf("loading discussions on !%d: %w", number, err)
+	}
+
+	var threads []ReviewThread
+	for _, d := range discussions {
+		if len(d.Notes) == 0 || !d.Notes[0].Resolvable || d.Notes[0].Resolved || d.Notes[0].System {
+			continue
+		}
+		t := ReviewThread{ID: d.ID}
+		if p := d.Notes[0].Position; p != nil {
+			t.Path = p.NewPath
+			if t.Path == "" {
+				t.Path = p.OldPath
+			}
+			if p.NewLine != nil {
+				t.Line = *p.NewLine
+			} else if p.OldLine != nil {
+				t.Line = *p.OldLine
+			}
+		}
+		for _, n := range d.Notes {
+			if !n.System {
+				t.Comments = append(t.Comments, ReviewComment{Author: n.Author.Username, Body: n.Body})
+			}
+		}
+		threads = append(threads, t)
+	}
+	return threads, nil
+}
+
+// ReplyToThread implements CodeHost.
+func (g *GitLab) ReplyToThread(ctx context.Context, number int, threadID, body string) error {
+	if err := g.api.do(ctx, http.MethodPost, g.mrPath(number)+"/discussions/"+url.PathEscape(threadID)+"/notes",
+		map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("replying to discussion on !%d: %w", number, err)
+	}
+	return nil
+}
+
+// ResolveThread implements CodeHost.
+func (g *GitLab) ResolveThread(ctx context.Context, number int, threadID string) error {
+	if err := g.api.do(ctx, http.MethodPut, g.mrPath(number)+"/discussions/"+url.PathEscape(threadID)+"?resolved=true",
+		nil, nil); err != nil {
+		return fmt.Errorf("resolving discussion on !%d: %w", number, err)
+	}
+	return nil
+}
+
+// CreateIssue implements CodeHost.
+func (g *GitLab) CreateIssue(ctx context.Context, opts IssueOptions) (*Issue, error) {
+	fields := map[string]interface{}{"title": opts.Title, "description": opts.Body}
+	if len(opts.Labels) > 0 {
+		fields["labels"] = strings.Join(opts.Labels, ",")
+	}
+	var created struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	if err := g.api.do(ctx, http.MethodPost, g.projectPath()+"/issues", fields, &created); err != nil {
+		return nil, fmt.Errorf("creating issue: %w", err)
+	}
+	return &Issue{Number: created.IID, URL: created.WebURL}, nil
+}
+
+// CIStatus implements CodeHost. Jobs allowed to fail count as passed.
+func (g *GitLab) CIStatus(ctx context.Context, ref string) (*CIStatus, error) {
+	var statuses []struct {
+		ID           int64  `json:"id"`
+		Name         string `json:"name"`
+		Status       string `json:"status"`
+		TargetURL    string `json:"target_url"`
+		AllowFailure bool   `json:"allow_failure"`
+	}
+	if err := g.api.listAll(ctx,
+		g.projectPath()+"/repository/commits/"+url.PathEscape(ref)+"/statuses?per_page=100", &statuses); err != nil {
+		return nil, fmt.Errorf("loading commit statuses for %s: %w", ref, err)
+	}
+	var checks []Check
+	for _, s := range statuses {
+		state := CIPending
+		switch s.Status {
+		case "success", "skipped":
+			state = CISuccess
+		case "failed", "canceled":
+			state = CIFailure
+			if s.AllowFailure {
+				state = CISuccess
+			}
+		}
+		checks = append(checks, Check{ID: strconv.FormatInt(s.ID, 10), Name: s.Name, State: state, URL: s.TargetURL})
+	}
+	return combineChecks(checks), nil
+}
+
+// CheckLog implements CodeHost. It returns the job's trace.
+func (g *GitLab) CheckLog(ctx context.Context, check Check) (string, error) {
+	if check.ID == "" {
+		return "", ErrNotSupported
+	}
+	log, err := g.api.text(ctx, g.projectPath()+"/jobs/"+check.ID+"/trace")
+	if err != nil {
+		return "", fmt.Errorf("loading log for %s: %w", check.Name, err)
+	}
+	return log, nil
+}
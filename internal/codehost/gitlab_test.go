@@ -0,0 +1,149 @@
+package codehost
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const gitlabProject = "/projects/group%2Fsub%2Frepo"
+
+func TestGitLabOpenPR(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET " + gitlabProject:      map[string]string{"default_branch": "main"},
+		"GET /users?username=alice": []map[string]int{{"id": 42}},
+		"POST " + gitlabProject + "/merge_requests": map[string]interface{}{
+			"iid": 5, "web_url": "https://gitlab.com/group/sub/repo/-/merge_requests/5",
+			"title": "Draft: Add login", "draft": true, "state": "opened",
+			"source_branch": "feat/x", "target_branch": "main", "labels": []string{"agentbox", "wip"},
+		},
+	})
+	gl := NewGitLab(srv.URL, "secret", "group/sub/repo")
+
+	pr, err := gl.OpenPR(context.Background(), PROptions{
+		Title: "Add login", Head: "feat/x", Draft: true,
+		Labels: []string{"agentbox", "wip"}, Reviewers: []string{"alice"},
+	})
+	if err != nil {
+		t.Fatalf("OpenPR: %v", err)
+	}
+	if pr.Number != 5 || pr.State != "open" || !pr.Draft || pr.Base != "main" {
+		t.Errorf("OpenPR = %+v", pr)
+	}
+
+	create := findCall(t, calls(), "POST", gitlabProject+"/merge_requests")
+	if create.Header.Get("PRIVATE-TOKEN") != "secret" {
+		t.Errorf("PRIVATE-TOKEN = %q", create.Header.Get("PRIVATE-TOKEN"))
+	}
+	if create.Body["title"] != "Draft: Add login" || create.Body["labels"] != "agentbox,wip" {
+		t.Errorf("create body = %v", create.Body)
+	}
+	if ids, _ := create.Body["reviewer_ids"].([]interface{}); len(ids) != 1 || ids[0] != float64(42) {
+		t.Errorf("reviewer_ids = %v", create.Body["reviewer_ids"])
+	}
+}
+
+func TestGitLabUpdatePRAndReviewers(t *testing.T) {
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET " + gitlabProject + "/merge_requests/5": map[string]interface{}{
+			"iid": 5, "title": "Draft: Add login", "draft": true, "state": "opened",
+			"reviewers": []map[string]interface{}{{"id": 7, "username": "bob"}},
+		},
+		"PUT " + gitlabProject + "/merge_requests/5": map[string]interface{}{
+			"iid": 5, "title": "Add login", "draft": false, "state": "opened",
+		},
+		"GET /users?username=alice": []map[string]int{{"id": 42}},
+	})
+	gl := NewGitLab(srv.URL, "secret", "group/sub/repo")
+	ctx := context.Background()
+
+	ready := false
+	pr, err := gl.UpdatePR(ctx, 5, PRUpdate{Draft: &ready})
+	if err != nil || pr.Draft {
+		t.Fatalf("UpdatePR = %+v, %v", pr, err)
+	}
+	if put := findCall(t, calls(), "PUT", gitlabProject+"/merge_requests/5"); put.Body["title"] != "Add login" {
+		t.Errorf("update body = %v", put.Body)
+	}
+
+	if err := gl.RequestReviewers(ctx, 5, []string{"alice"}); err != nil {
+		t.Fatalf("RequestReviewers: %v", err)
+	}
+	all := calls()
+	last := all[len(all)-1]
+	if ids, _ := last.Body["reviewer_ids"].([]interface{}); len(ids) != 2 || ids[0] != float64(7) || ids[1] != float64(42) {
+		t.Errorf("reviewer_ids = %v, want the existing reviewer kept", last.Body["reviewer_ids"])
+	}
+}
+
+func TestGitLabCIStatus(t *testing.T) {
+	srv, _ := fakeAPI(t, map[string]interface{}{
+		"GET " + gitlabProject + "/repository/commits/abc123/statuses?per_page=100": fakePage{
+			Next: gitlabProject + "/repository/commits/abc123/statuses?per_page=100&page=2",
+			Body: []map[string]interface{}{
+				{"name": "test", "status": "success"},
+				{"name": "flaky", "status": "failed", "allow_failure": true},
+			},
+		},
+		"GET " + gitlabProject + "/repository/commits/abc123/statuses?per_page=100&page=2": []map[string]interface{}{
+			{"id": 9, "name": "deploy", "status": "running", "target_url": "https://gitlab.com/job/9"},
+		},
+		"GET " + gitlabProject + "/jobs/9/trace": []byte("$ make deploy\nerror: no credentials\n"),
+	})
+	gl := NewGitLab(srv.URL, "secret", "group/sub/repo")
+
+	st, err := gl.CIStatus(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("CIStatus: %v", err)
+	}
+	if len(st.Checks) != 3 || st.State != CIPending || st.Checks[1].State != CISuccess {
+		t.Errorf("CIStatus = %+v", st)
+	}
+	if log, err := gl.CheckLog(context.Background(), st.Checks[2]); err != nil || !strings.Contains(log, "no credentials") {
+		t.Errorf("CheckLog = %q, %v", log, err)
+	}
+}
+
+func TestGitLabReviewThreads(t *testing.T) {
+	mr := gitlabProject + "/merge_requests/5"
+	srv, calls := fakeAPI(t, map[string]interface{}{
+		"GET " + mr + "/discussions?per_page=100": []interface{}{
+			map[string]interface{}{"id": "d1", "notes": []interface{}{
+				map[string]interface{}{
+					"body": "handle nil", "author": map[string]string{"username": "alice"},
+					"resolvable": true, "resolved": false,
+					"position": map[string]interface{}{"new_path": "auth.go", "new_line": 12},
+				},
+				map[string]interface{}{"body": "agreed", "author": map[string]string{"username": "bob"}, "resolvable": true},
+			}},
+			map[string]interface{}{"id": "d2", "notes": []interface{}{
+				map[string]interface{}{"body": "done", "resolvable": true, "resolved": true},
+			}},
+			map[string]interface{}{"id": "d3", "notes": []interface{}{
+				map[string]interface{}{"body": "nice work", "resolvable": false},
+			}},
+		},
+		"POST " + mr + "/discussions/d1/notes":        map[string]interface{}{"id": 1},
+		"PUT " + mr + "/discussions/d1?resolved=true": map[string]interface{}{"id": "d1"},
+	})
+	gl := NewGitLab(srv.URL, "secret", "group/sub/repo")
+	ctx := context.Background()
+
+	threads, err := gl.ReviewThreads(ctx, 5)
+	if err != nil {
+		t.Fatalf("ReviewThreads: %v", err)
+	}
+	if len(threads) != 1 || threads[0].ID != "d1" || threads[0].Path != "auth.go" || threads[0].Line != 12 || len(threads[0].Comments) != 2 {
+		t.Fatalf("threads = %+v", threads)
+	}
+
+	if err := gl.ReplyToThread(ctx, 5, "d1", "Fixed in abc123."); err != nil {
+		t.Fatalf("ReplyToThread: %v", err)
+	}
+	if reply := findCall(t, calls(), "POST", mr+"/discussions/d1/notes"); reply.Body["body"] != "Fixed in abc123." {
+		t.Errorf("reply body = %v", reply.Body)
+	}
+	if err := gl.ResolveThread(ctx, 5, "d1"); err != nil {
+		t.Fatalf("ResolveThread: %v", err)
+	}
+}
@@ -2,12 +2,20 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/swamp-dev/agentbox/internal/retry"
 )
 
 // Config represents the agentbox.yaml configuration file.
@@ -18,6 +26,71 @@ type Config struct {
 	Docker     DockerConfig     `yaml:"docker"`
 	Ralph      RalphConfig      `yaml:"ralph"`
 	Supervisor SupervisorConfig `yaml:"supervisor,omitempty"`
+	Storage    StorageConfig    `yaml:"storage,omitempty"`
+	CodeHost   CodeHostConfig   `yaml:"code_host,omitempty"`
+	Commit     CommitConfig     `yaml:"commit,omitempty"`
+	// Packages are the subprojects of a monorepo, with their own image,
+	// quality checks, protected paths and allowed endpoints.
+	Packages []PackageConfig `yaml:"packages,omitempty"`
+	// Retry controls backoff for transient agent and Docker failures. Nil
+	// uses retry.DefaultPolicy.
+	Retry *retry.Policy `yaml:"retry,omitempty"`
+}
+
+// CodeHostConfig selects the service that hosts pull requests, issues and
+// CI for the repository. By default it is worked out from the origin remote.
+type CodeHostConfig struct {
+	// Provider is github, gitlab or gitea (also for Forgejo). Needed when
+	// the remote's host name does not say which it is.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+	// APIURL overrides the API base URL, e.g. for GitHub Enterprise.
+	APIURL string `yaml:"api_url,omitempty" json:"api_url,omitempty"`
+	// TokenEnv names the environment variable holding the API token, in
+	// place of GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN.
+	TokenEnv string `yaml:"token_env,omitempty" json:"token_env,omitempty"`
+}
+
+// StorageConfig controls how the session store keeps agent transcripts.
+type StorageConfig struct {
+	// Transcripts is "inline" (zstd-compressed in agentbox.db, the default)
+	// or "blobs" (zstd-compressed files under .agentbox/blobs/).
+	Transcripts string `yaml:"transcripts,omitempty"`
+	// TranscriptRetention prunes transcripts of sessions older than this,
+	// e.g. "30d". Empty or "keep" keeps them.
+	TranscriptRetention string `yaml:"transcript_retention,omitempty"`
+	// MaxTranscriptSize prunes the oldest transcripts once they total more
+	// than this, e.g. "500MB". Empty means no limit.
+	MaxTranscriptSize string `yaml:"max_transcript_size,omitempty"`
+}
+
+// CommitConfig controls how agent work is committed.
+type CommitConfig struct {
+	// Template is a text/template for the commit message; see
+	// workflow.CommitInfo for its fields. Empty uses
+	// "feat({{.TaskID}}): {{.Title}}". Agentbox-* trailers are always
+	// appended.
+	Template string `yaml:"template,omitempty" json:"template,omitempty"`
+	// Sign is "gpg" or "ssh" to sign commits, or empty for unsigned.
+	Sign string `yaml:"sign,omitempty" json:"sign,omitempty"`
+	// SigningKey is the key to sign with. Empty uses git's user.signingkey.
+	SigningKey string `yaml:"signing_key,omitempty" json:"signing_key,omitempty"`
+	// Squash is "iteration" (one commit per successful iteration, the
+	// default), "task" (fold each task's commits, including the agent's
+	// own, into one) or "sprint" (one commit per sprint, before pushing).
+	Squash string `yaml:"squash,omitempty" json:"squash,omitempty"`
+
+	// ProtectedPaths are globs for files agents must not change, such as
+	// ".github/workflows", "agentbox.yaml" or "*.lock". "**" matches any
+	// number of directories and a pattern without a slash matches at any
+	// depth. Staged changes are checked before every commit.
+	ProtectedPaths []string `yaml:"protected_paths,omitempty" json:"protected_paths,omitempty"`
+	// MaxFilesChanged and MaxDiffLines cap the size of one commit; 0 means
+	// no limit.
+	MaxFilesChanged int `yaml:"max_files_changed,omitempty" json:"max_files_changed,omitempty"`
+	MaxDiffLines    int `yaml:"max_diff_lines,omitempty" json:"max_diff_lines,omitempty"`
+	// RevertProtected undoes changes to protected paths when a commit is
+	// refused, keeping the rest of the agent's work.
+	RevertProtected bool `yaml:"revert_protected,omitempty" json:"revert_protected,omitempty"`
 }
 
 // SupervisorConfig controls the autonomous sprint behavior.
@@ -29,9 +102,27 @@ type SupervisorConfig struct {
 	FallbackAgent       string `yaml:"fallback_agent"`
 	ReviewAfter         string `yaml:"review_after"`
 	BudgetDuration      string `yaml:"budget_duration"`
-	JournalEnabled      bool   `yaml:"journal_enabled"`
-	ReviewEnabled       bool   `yaml:"review_enabled"`
 	EscalationMethod    string `yaml:"escalation_method"`
+	CITimeout           string `yaml:"ci_timeout"`
+	SyncBase            string `yaml:"sync_base"`
+
+	// The switches are pointers so that a section setting other keys
+	// leaves them at the supervisor's defaults.
+	JournalEnabled *bool `yaml:"journal_enabled"`
+	ReviewEnabled  *bool `yaml:"review_enabled"`
+	DraftPR        *bool `yaml:"draft_pr"`
+	CIGate         *bool `yaml:"ci_gate"`
+}
+
+// IsSet reports whether any supervisor field has a non-zero value, which is
+// how a configured supervisor section is told apart from an absent one.
+func (s SupervisorConfig) IsSet() bool {
+	return s.SprintSize != 0 || s.MaxSprints != 0 ||
+		s.MaxConsecutiveFails != 0 || s.ReviewAgent != "" ||
+		s.FallbackAgent != "" || s.ReviewAfter != "" ||
+		s.BudgetDuration != "" || s.EscalationMethod != "" ||
+		s.JournalEnabled != nil || s.ReviewEnabled != nil || s.DraftPR != nil ||
+		s.CIGate != nil || s.CITimeout != "" || s.SyncBase != ""
 }
 
 // ProjectConfig holds project-level settings.
@@ -42,7 +133,9 @@ type ProjectConfig struct {
 
 // AgentConfig specifies which AI agent to use.
 type AgentConfig struct {
-	Name string `yaml:"name"` // claude, claude-cli, amp, aider
+	Name    string `yaml:"name"`               // claude, claude-cli, amp, aider, mock
+	BaseURL string `yaml:"base_url,omitempty"` // alternative API endpoint (gateway or local model server)
+	Model   string `yaml:"model,omitempty"`    // model override passed to the agent CLI
 }
 
 // DockerConfig controls container resources and networking.
@@ -51,6 +144,11 @@ type DockerConfig struct {
 	Resources        ResourcesConfig `yaml:"resources"`
 	Network          string          `yaml:"network"`                     // none, bridge, host, restricted
 	AllowedEndpoints []string        `yaml:"allowed_endpoints,omitempty"` // host:port pairs for restricted mode
+	// Isolation is how `agentbox run` gives the agent the project: "bind"
+	// (the default) mounts it writable, "copy" mounts a private copy whose
+	// changes are applied as a patch once they pass the commit policy and
+	// quality checks.
+	Isolation string `yaml:"isolation,omitempty"`
 }
 
 // ResourcesConfig sets container resource limits.
@@ -104,6 +202,15 @@ func DefaultConfig() *Config {
 	}
 }
 
+// RetryPolicy returns the configured retry policy, or the default when the
+// config has none.
+func (c *Config) RetryPolicy() retry.Policy {
+	if c.Retry == nil {
+		return retry.DefaultPolicy()
+	}
+	return *c.Retry
+}
+
 // Load reads and parses the agentbox.yaml config file.
 func Load(path string) (*Config, error) {
 	if path == "" {
@@ -140,14 +247,30 @@ func (c *Config) Save(path string) error {
 	return nil
 }
 
+// validImages are the image types docker.image accepts.
+var validImages = map[string]bool{"node": true, "python": true, "go": true, "rust": true, "full": true}
+
 // Validate checks the configuration for errors.
 func (c *Config) Validate() error {
-	validAgents := map[string]bool{"claude": true, "claude-cli": true, "amp": true, "aider": true}
+	validAgents := map[string]bool{"claude": true, "claude-cli": true, "amp": true, "aider": true, "mock": true}
 	if !validAgents[c.Agent.Name] {
-		return fmt.Errorf("invalid agent: %s (must be claude, claude-cli, amp, or aider)", c.Agent.Name)
+		return fmt.Errorf("invalid agent: %s (must be claude, claude-cli, amp, aider, or mock)", c.Agent.Name)
+	}
+
+	if c.Agent.BaseURL != "" {
+		u, err := url.Parse(c.Agent.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("invalid agent base_url: %s (must be an http or https URL)", c.Agent.BaseURL)
+		}
+		if c.Agent.Name == "amp" || c.Agent.Name == "mock" {
+			return fmt.Errorf("agent %s does not support base_url", c.Agent.Name)
+		}
+	}
+
+	if c.Agent.Model != "" && (c.Agent.Name == "amp" || c.Agent.Name == "mock") {
+		return fmt.Errorf("agent %s does not support model", c.Agent.Name)
 	}
 
-	validImages := map[string]bool{"node": true, "python": true, "go": true, "rust": true, "full": true}
 	if !validImages[c.Docker.Image] {
 		return fmt.Errorf("invalid image: %s", c.Docker.Image)
 	}
@@ -157,20 +280,17 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid network: %s (must be none, bridge, host, or restricted)", c.Docker.Network)
 	}
 
+	if i := c.Docker.Isolation; i != "" && i != "bind" && i != "copy" {
+		return fmt.Errorf("invalid docker isolation: %s (must be bind or copy)", i)
+	}
+
 	if c.Ralph.MaxIterations < 1 {
 		return fmt.Errorf("max_iterations must be at least 1")
 	}
 
 	// Validate supervisor fields when the section is configured.
-	// We detect usage by checking if any field has a non-zero value.
 	sup := c.Supervisor
-	supervisorConfigured := sup.SprintSize != 0 || sup.MaxSprints != 0 ||
-		sup.MaxConsecutiveFails != 0 || sup.ReviewAgent != "" ||
-		sup.FallbackAgent != "" || sup.ReviewAfter != "" ||
-		sup.BudgetDuration != "" || sup.EscalationMethod != "" ||
-		sup.JournalEnabled || sup.ReviewEnabled
-
-	if supervisorConfigured {
+	if sup.IsSet() {
 		if sup.SprintSize < 1 {
 			return fmt.Errorf("sprint_size must be >= 1")
 		}
@@ -197,26 +317,138 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("invalid budget_duration: %w", err)
 		}
 	}
+	if sup.CITimeout != "" {
+		if _, err := time.ParseDuration(sup.CITimeout); err != nil {
+			return fmt.Errorf("invalid ci_timeout: %w", err)
+		}
+	}
+	if sup.SyncBase != "" && sup.SyncBase != "merge" && sup.SyncBase != "rebase" {
+		return fmt.Errorf("invalid sync_base: %s (must be merge, rebase, or empty)", sup.SyncBase)
+	}
 
 	// Supervisor validation: escalation_method must be a known value.
 	if sup.EscalationMethod != "" {
-		validEscalation := map[string]bool{"github_issue": true, "file": true, "none": true}
+		validEscalation := map[string]bool{"issue": true, "github_issue": true, "file": true, "none": true}
 		if !validEscalation[sup.EscalationMethod] {
-			return fmt.Errorf("invalid escalation_method: %s (must be github_issue, file, or none)", sup.EscalationMethod)
+			return fmt.Errorf("invalid escalation_method: %s (must be issue, file, or none)", sup.EscalationMethod)
+		}
+	}
+
+	if st := c.Storage; st.Transcripts != "" && st.Transcripts != "inline" && st.Transcripts != "blobs" {
+		return fmt.Errorf("invalid storage transcripts: %s (must be inline or blobs)", st.Transcripts)
+	}
+	if p := c.CodeHost.Provider; p != "" && p != "github" && p != "gitlab" && p != "gitea" {
+		return fmt.Errorf("invalid code_host provider: %s (must be github, gitlab, or gitea)", p)
+	}
+	if c.CodeHost.APIURL != "" {
+		u, err := url.Parse(c.CodeHost.APIURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("invalid code_host api_url: %s (must be an http or https URL)", c.CodeHost.APIURL)
 		}
 	}
 
+	if _, _, err := c.Storage.RetentionLimits(); err != nil {
+		return err
+	}
+
+	if c.Commit.Template != "" {
+		if _, err := template.New("commit").Parse(c.Commit.Template); err != nil {
+			return fmt.Errorf("invalid commit template: %w", err)
+		}
+	}
+	if s := c.Commit.Sign; s != "" && s != "gpg" && s != "ssh" {
+		return fmt.Errorf("invalid commit sign: %s (must be gpg, ssh, or empty)", s)
+	}
+	if s := c.Commit.Squash; s != "" && s != "iteration" && s != "task" && s != "sprint" {
+		return fmt.Errorf("invalid commit squash: %s (must be iteration, task, or sprint)", s)
+	}
+	for _, p := range c.Commit.ProtectedPaths {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid commit protected_paths pattern %q: %w", p, err)
+		}
+	}
+	if c.Commit.MaxFilesChanged < 0 || c.Commit.MaxDiffLines < 0 {
+		return fmt.Errorf("commit max_files_changed and max_diff_lines must be >= 0")
+	}
+
+	if err := c.validatePackages(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// RetentionLimits parses the transcript retention settings. Zero means no
+// limit.
+func (s StorageConfig) RetentionLimits() (maxAge time.Duration, maxBytes int64, err error) {
+	if r := s.TranscriptRetention; r != "" && r != "keep" {
+		if maxAge, err = ParseAge(r); err != nil {
+			return 0, 0, fmt.Errorf("invalid transcript_retention: %w", err)
+		}
+	}
+	if s.MaxTranscriptSize != "" {
+		if maxBytes, err = ParseSize(s.MaxTranscriptSize); err != nil {
+			return 0, 0, fmt.Errorf("invalid max_transcript_size: %w", err)
+		}
+	}
+	return maxAge, maxBytes, nil
+}
+
+// ParseAge parses a Go duration, also accepting a whole number of days
+// such as "30d".
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d or 72h)", s)
+	}
+	return d, nil
+}
+
+// ParseSize parses byte sizes such as "500MB", "2G" or "1048576".
+func ParseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	upper = strings.TrimSuffix(upper, "B")
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}} {
+		if n, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, mult = n, unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500MB or 2G)", s)
+	}
+	return n * mult, nil
+}
+
 // FindConfigFile searches for agentbox.yaml in current and parent directories.
 func FindConfigFile() (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return "", err
 	}
+	return FindConfigFileFrom(cwd)
+}
+
+// FindConfigFileFrom searches for agentbox.yaml in dir and its parents.
+func FindConfigFileFrom(dir string) (string, error) {
+	start, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
 
-	for dir := cwd; ; dir = filepath.Dir(dir) {
+	for dir := start; ; dir = filepath.Dir(dir) {
 		configPath := filepath.Join(dir, "agentbox.yaml")
 		if _, err := os.Stat(configPath); err == nil {
 			return configPath, nil
@@ -227,5 +459,39 @@ func FindConfigFile() (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("agentbox.yaml not found in %s or parent directories", cwd)
+	return "", fmt.Errorf("agentbox.yaml not found in %s or parent directories", start)
+}
+
+// LoadForProject loads the agentbox.yaml that applies to projectDir, found
+// by walking up from it. It returns the path it loaded, or "" and the
+// defaults when there is none.
+func LoadForProject(projectDir string) (*Config, string, error) {
+	path, err := FindConfigFileFrom(projectDir)
+	if err != nil {
+		return DefaultConfig(), "", nil
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, path, nil
+}
+
+// ApplyOverrides merges overrides, shaped like agentbox.yaml, into the
+// config. Only the keys given are changed; lists replace the existing list.
+// Unknown keys are an error.
+func (c *Config) ApplyOverrides(overrides map[string]interface{}) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("encoding config overrides: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("applying config overrides: %w", err)
+	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -57,12 +58,57 @@ func TestValidate(t *testing.T) {
 			wantErr:         true,
 			wantErrContains: "invalid network",
 		},
+		{
+			name:    "copy isolation",
+			modify:  func(c *Config) { c.Docker.Isolation = "copy" },
+			wantErr: false,
+		},
+		{
+			name:            "invalid isolation",
+			modify:          func(c *Config) { c.Docker.Isolation = "overlay" },
+			wantErr:         true,
+			wantErrContains: "invalid docker isolation",
+		},
 		{
 			name:            "zero max iterations",
 			modify:          func(c *Config) { c.Ralph.MaxIterations = 0 },
 			wantErr:         true,
 			wantErrContains: "max_iterations",
 		},
+		{
+			name:    "valid base url",
+			modify:  func(c *Config) { c.Agent.BaseURL = "http://localhost:11434/v1" },
+			wantErr: false,
+		},
+		{
+			name:            "invalid base url scheme",
+			modify:          func(c *Config) { c.Agent.BaseURL = "localhost:11434" },
+			wantErr:         true,
+			wantErrContains: "base_url",
+		},
+		{
+			name: "base url unsupported by amp",
+			modify: func(c *Config) {
+				c.Agent.Name = "amp"
+				c.Agent.BaseURL = "https://gateway.example.com"
+			},
+			wantErr:         true,
+			wantErrContains: "does not support base_url",
+		},
+		{
+			name:    "valid model",
+			modify:  func(c *Config) { c.Agent.Model = "claude-opus-4" },
+			wantErr: false,
+		},
+		{
+			name: "model unsupported by amp",
+			modify: func(c *Config) {
+				c.Agent.Name = "amp"
+				c.Agent.Model = "gpt-4o"
+			},
+			wantErr:         true,
+			wantErrContains: "does not support model",
+		},
 		{
 			name:    "valid amp agent",
 			modify:  func(c *Config) { c.Agent.Name = "amp" },
@@ -203,6 +249,28 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "invalid ci_timeout",
+			modify: func(c *Config) {
+				c.Supervisor.SprintSize = 5
+				c.Supervisor.MaxSprints = 3
+				c.Supervisor.MaxConsecutiveFails = 2
+				c.Supervisor.CITimeout = "soon"
+			},
+			wantErr:         true,
+			wantErrContains: "ci_timeout",
+		},
+		{
+			name: "invalid sync_base",
+			modify: func(c *Config) {
+				c.Supervisor.SprintSize = 5
+				c.Supervisor.MaxSprints = 3
+				c.Supervisor.MaxConsecutiveFails = 2
+				c.Supervisor.SyncBase = "squash"
+			},
+			wantErr:         true,
+			wantErrContains: "sync_base",
+		},
 		// escalation_method validation
 		{
 			name: "supervisor escalation_method github_issue is valid",
@@ -325,3 +393,188 @@ func TestFindConfigFile(t *testing.T) {
 		t.Errorf("expected %s, got %s", configPath, found)
 	}
 }
+
+func TestFindConfigFileFrom(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "agentbox.yaml")
+	if err := os.WriteFile(configPath, []byte("version: '1.0'"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := FindConfigFileFrom(nested)
+	if err != nil {
+		t.Fatalf("FindConfigFileFrom() error = %v", err)
+	}
+	if found != configPath {
+		t.Errorf("expected %s, got %s", configPath, found)
+	}
+}
+
+func TestLoadForProject(t *testing.T) {
+	dir := t.TempDir()
+	cfg, path, err := LoadForProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another agentbox.yaml further up the real filesystem would be found
+	// too, so only check the defaults when nothing was.
+	if path == "" && cfg.Docker.Image != "full" {
+		t.Errorf("expected defaults without a config file, got image %s", cfg.Docker.Image)
+	}
+
+	configPath := filepath.Join(dir, "agentbox.yaml")
+	if err := os.WriteFile(configPath, []byte("docker:\n  image: go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "pkg")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg, path, err = LoadForProject(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != configPath {
+		t.Errorf("path = %s, want %s", path, configPath)
+	}
+	if cfg.Docker.Image != "go" || cfg.Docker.Resources.Memory != "4g" {
+		t.Errorf("image %s memory %s, want go 4g", cfg.Docker.Image, cfg.Docker.Resources.Memory)
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Ralph.QualityChecks = []QualityCheck{{Name: "test", Command: "make test"}}
+
+	err := cfg.ApplyOverrides(map[string]interface{}{
+		"docker": map[string]interface{}{
+			"resources": map[string]interface{}{"memory": "8g"},
+		},
+		"ralph": map[string]interface{}{
+			"quality_checks": []interface{}{
+				map[string]interface{}{"name": "lint", "command": "make lint"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Docker.Resources.Memory != "8g" || cfg.Docker.Resources.CPUs != "2" || cfg.Docker.Image != "full" {
+		t.Errorf("docker = %+v, want only memory changed", cfg.Docker)
+	}
+	if len(cfg.Ralph.QualityChecks) != 1 || cfg.Ralph.QualityChecks[0].Name != "lint" {
+		t.Errorf("quality checks = %+v, want the override list", cfg.Ralph.QualityChecks)
+	}
+
+	if err := cfg.ApplyOverrides(map[string]interface{}{"dokcer": map[string]interface{}{}}); err == nil {
+		t.Error("unknown key succeeded, want error")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"30d", 30 * 24 * time.Hour, true},
+		{"72h", 72 * time.Hour, true},
+		{"90m", 90 * time.Minute, true},
+		{"d", 0, false},
+		{"-1h", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"1048576", 1 << 20, true},
+		{"500MB", 500 << 20, true},
+		{"2g", 2 << 30, true},
+		{"64K", 64 << 10, true},
+		{"lots", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	tests := []struct {
+		storage StorageConfig
+		ok      bool
+	}{
+		{StorageConfig{}, true},
+		{StorageConfig{Transcripts: "blobs", TranscriptRetention: "30d", MaxTranscriptSize: "500MB"}, true},
+		{StorageConfig{TranscriptRetention: "keep"}, true},
+		{StorageConfig{Transcripts: "tape"}, false},
+		{StorageConfig{TranscriptRetention: "a while"}, false},
+		{StorageConfig{MaxTranscriptSize: "huge"}, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Storage = tt.storage
+		if err := cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.storage, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateCodeHost(t *testing.T) {
+	tests := []struct {
+		host CodeHostConfig
+		ok   bool
+	}{
+		{CodeHostConfig{}, true},
+		{CodeHostConfig{Provider: "gitea", APIURL: "https://git.example.com/api/v1", TokenEnv: "TEA"}, true},
+		{CodeHostConfig{Provider: "bitbucket"}, false},
+		{CodeHostConfig{APIURL: "git.example.com"}, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.CodeHost = tt.host
+		if err := cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.host, err, tt.ok)
+		}
+	}
+}
+
+func TestValidateCommit(t *testing.T) {
+	tests := []struct {
+		commit CommitConfig
+		ok     bool
+	}{
+		{CommitConfig{}, true},
+		{CommitConfig{Template: "{{.Title}} ({{.TaskID}})", Sign: "ssh", SigningKey: "~/.ssh/id.pub", Squash: "sprint"}, true},
+		{CommitConfig{Template: "{{.Title"}, false},
+		{CommitConfig{Sign: "x509"}, false},
+		{CommitConfig{Squash: "session"}, false},
+		{CommitConfig{ProtectedPaths: []string{"go.mod", ".github/**", "*.lock"}, MaxFilesChanged: 20, MaxDiffLines: 800}, true},
+		{CommitConfig{ProtectedPaths: []string{"[migrations"}}, false},
+		{CommitConfig{MaxDiffLines: -1}, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Commit = tt.commit
+		if err := cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.commit, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PackageConfig overrides settings for one subproject of a monorepo. The
+// overrides apply to tasks whose workdir is in the package and, for quality
+// checks and protected paths, to changes under its path.
+type PackageConfig struct {
+	// Path is the subproject's directory relative to the project root,
+	// e.g. "services/api".
+	Path string `yaml:"path" json:"path"`
+	// Image replaces docker.image for the package's tasks.
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+	// QualityChecks run in the package's directory when it changes. Empty
+	// leaves the package to ralph.quality_checks, run from the project root.
+	QualityChecks []QualityCheck `yaml:"quality_checks,omitempty" json:"quality_checks,omitempty"`
+	// ProtectedPaths are commit.protected_paths patterns relative to Path.
+	ProtectedPaths []string `yaml:"protected_paths,omitempty" json:"protected_paths,omitempty"`
+	// AllowedEndpoints are added to docker.allowed_endpoints for the
+	// package's tasks.
+	AllowedEndpoints []string `yaml:"allowed_endpoints,omitempty" json:"allowed_endpoints,omitempty"`
+}
+
+// CheckSet is a group of quality checks and the directory, relative to the
+// project root, they run in.
+type CheckSet struct {
+	Dir    string
+	Checks []QualityCheck
+}
+
+// CleanWorkdir normalizes a directory relative to the project root, such as
+// a task's workdir or a package path, to slash-separated form without a
+// leading "./" or trailing slash. "" and "." become "". Absolute paths and
+// paths leaving the project are an error.
+func CleanWorkdir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if path.IsAbs(dir) || strings.HasPrefix(dir, `\`) {
+		return "", fmt.Errorf("%s must be relative to the project root", dir)
+	}
+	clean := path.Clean(strings.ReplaceAll(dir, `\`, "/"))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%s is outside the project", dir)
+	}
+	if clean == "." {
+		return "", nil
+	}
+	return clean, nil
+}
+
+// Package returns the package containing p, a slash-separated path relative
+// to the project root, or nil when p is in none. Of nested packages the
+// innermost wins.
+func (c *Config) Package(p string) *PackageConfig {
+	var best *PackageConfig
+	bestLen := 0
+	for i := range c.Packages {
+		pkg := &c.Packages[i]
+		dir, err := CleanWorkdir(pkg.Path)
+		if err != nil || dir == "" {
+			continue
+		}
+		if p != dir && !strings.HasPrefix(p, dir+"/") {
+			continue
+		}
+		if len(dir) > bestLen {
+			best, bestLen = pkg, len(dir)
+		}
+	}
+	return best
+}
+
+// ForWorkdir returns the config an agent working in workdir runs with: the
+// image and allowed endpoints of the package containing workdir. It returns
+// c itself when no package applies.
+func (c *Config) ForWorkdir(workdir string) *Config {
+	pkg := c.Package(workdir)
+	if pkg == nil {
+		return c
+	}
+	scoped := *c
+	if pkg.Image != "" {
+		scoped.Docker.Image = pkg.Image
+	}
+	if len(pkg.AllowedEndpoints) > 0 {
+		scoped.Docker.AllowedEndpoints = append(append([]string{}, c.Docker.AllowedEndpoints...), pkg.AllowedEndpoints...)
+	}
+	return &scoped
+}
+
+// CommitPolicy returns the commit policy with each package's protected
+// paths added, anchored at the package's path.
+func (c *Config) CommitPolicy() CommitConfig {
+	policy := c.Commit
+	var protected []string
+	for _, pkg := range c.Packages {
+		dir, err := CleanWorkdir(pkg.Path)
+		if err != nil || dir == "" {
+			continue
+		}
+		for _, p := range pkg.ProtectedPaths {
+			p = strings.Trim(p, "/")
+			if !strings.Contains(p, "/") {
+				// A bare name matches at any depth, within the package.
+				p = "**/" + p
+			}
+			protected = append(protected, dir+"/"+p)
+		}
+	}
+	if len(protected) > 0 {
+		policy.ProtectedPaths = append(append([]string{}, c.Commit.ProtectedPaths...), protected...)
+	}
+	return policy
+}
+
+// CheckSets picks the quality checks to run for a task working in workdir
+// that changed the given paths. Each package that was changed, or that
+// contains workdir, runs its own checks in its directory. Changes outside
+// every package, or in one without checks of its own, run
+// ralph.quality_checks from the project root, as does a cross-cutting task
+// that changed nothing. Without packages, only ralph.quality_checks run.
+func (c *Config) CheckSets(workdir string, changed []string) []CheckSet {
+	root := len(c.Packages) == 0
+	var pkgs []*PackageConfig
+	seen := make(map[*PackageConfig]bool)
+	add := func(p string) {
+		pkg := c.Package(p)
+		switch {
+		case pkg == nil || len(pkg.QualityChecks) == 0:
+			root = true
+		case !seen[pkg]:
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if workdir != "" {
+		add(workdir)
+	}
+	for _, p := range changed {
+		add(p)
+	}
+	if workdir == "" && len(changed) == 0 {
+		root = true
+	}
+
+	var sets []CheckSet
+	if root && len(c.Ralph.QualityChecks) > 0 {
+		sets = append(sets, CheckSet{Checks: c.Ralph.QualityChecks})
+	}
+	for _, pkg := range pkgs {
+		dir, _ := CleanWorkdir(pkg.Path)
+		sets = append(sets, CheckSet{Dir: dir, Checks: pkg.QualityChecks})
+	}
+	return sets
+}
+
+// validatePackages checks the packages section.
+func (c *Config) validatePackages() error {
+	seen := make(map[string]bool)
+	for _, pkg := range c.Packages {
+		dir, err := CleanWorkdir(pkg.Path)
+		if err != nil {
+			return fmt.Errorf("invalid package path: %w", err)
+		}
+		if dir == "" {
+			return fmt.Errorf("package path must name a subdirectory")
+		}
+		if seen[dir] {
+			return fmt.Errorf("package %s is listed twice", dir)
+		}
+		seen[dir] = true
+		if pkg.Image != "" && !validImages[pkg.Image] {
+			return fmt.Errorf("package %s: invalid image: %s", dir, pkg.Image)
+		}
+		for _, p := range pkg.ProtectedPaths {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("package %s: invalid protected_paths pattern %q: %w", dir, p, err)
+			}
+		}
+	}
+	return nil
+}
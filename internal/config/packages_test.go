@@ -0,0 +1,162 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testPackagesConfig() *Config {
+	cfg := DefaultConfig()
+	cfg.Ralph.QualityChecks = []QualityCheck{{Name: "lint", Command: "make lint"}}
+	cfg.Docker.AllowedEndpoints = []string{"api.anthropic.com:443"}
+	cfg.Commit.ProtectedPaths = []string{"go.work"}
+	cfg.Packages = []PackageConfig{
+		{
+			Path:             "services/api",
+			Image:            "go",
+			QualityChecks:    []QualityCheck{{Name: "test", Command: "go test ./..."}},
+			ProtectedPaths:   []string{"migrations/", "*.sum"},
+			AllowedEndpoints: []string{"proxy.golang.org:443"},
+		},
+		{
+			Path:          "./web/",
+			Image:         "node",
+			QualityChecks: []QualityCheck{{Name: "test", Command: "npm test"}},
+		},
+		{Path: "web/legacy"},
+	}
+	return cfg
+}
+
+func TestCleanWorkdir(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "", true},
+		{".", "", true},
+		{"./services/api/", "services/api", true},
+		{"services//api/../web", "services/web", true},
+		{"/srv/api", "", false},
+		{"../other", "", false},
+		{"services/../../other", "", false},
+	}
+	for _, tt := range tests {
+		got, err := CleanWorkdir(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("CleanWorkdir(%q) = %q, %v; want %q, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPackage(t *testing.T) {
+	cfg := testPackagesConfig()
+	tests := map[string]string{
+		"services/api":             "services/api",
+		"services/api/cmd/main.go": "services/api",
+		"services/apigw/main.go":   "",
+		"web/src/app.tsx":          "./web/",
+		"web/legacy/index.html":    "web/legacy",
+		"README.md":                "",
+		"":                         "",
+	}
+	for p, want := range tests {
+		got := ""
+		if pkg := cfg.Package(p); pkg != nil {
+			got = pkg.Path
+		}
+		if got != want {
+			t.Errorf("Package(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestForWorkdir(t *testing.T) {
+	cfg := testPackagesConfig()
+
+	api := cfg.ForWorkdir("services/api/internal")
+	if api.Docker.Image != "go" {
+		t.Errorf("image = %s, want go", api.Docker.Image)
+	}
+	if want := []string{"api.anthropic.com:443", "proxy.golang.org:443"}; !reflect.DeepEqual(api.Docker.AllowedEndpoints, want) {
+		t.Errorf("allowed endpoints = %v, want %v", api.Docker.AllowedEndpoints, want)
+	}
+	if len(cfg.Docker.AllowedEndpoints) != 1 || cfg.Docker.Image != "full" {
+		t.Errorf("ForWorkdir changed the original config: %+v", cfg.Docker)
+	}
+
+	if got := cfg.ForWorkdir(""); got != cfg {
+		t.Error("ForWorkdir(\"\") did not return the config itself")
+	}
+	if got := cfg.ForWorkdir("tools"); got != cfg {
+		t.Error("ForWorkdir outside every package did not return the config itself")
+	}
+}
+
+func TestCommitPolicy(t *testing.T) {
+	cfg := testPackagesConfig()
+	policy := cfg.CommitPolicy()
+	want := []string{"go.work", "services/api/**/migrations", "services/api/**/*.sum"}
+	if !reflect.DeepEqual(policy.ProtectedPaths, want) {
+		t.Errorf("protected paths = %v, want %v", policy.ProtectedPaths, want)
+	}
+	if len(cfg.Commit.ProtectedPaths) != 1 {
+		t.Errorf("CommitPolicy changed the original config: %v", cfg.Commit.ProtectedPaths)
+	}
+}
+
+func TestCheckSets(t *testing.T) {
+	cfg := testPackagesConfig()
+	root := CheckSet{Checks: cfg.Ralph.QualityChecks}
+	api := CheckSet{Dir: "services/api", Checks: cfg.Packages[0].QualityChecks}
+	web := CheckSet{Dir: "web", Checks: cfg.Packages[1].QualityChecks}
+
+	tests := []struct {
+		name    string
+		workdir string
+		changed []string
+		want    []CheckSet
+	}{
+		{"scoped task", "services/api", []string{"services/api/handler.go"}, []CheckSet{api}},
+		{"scoped task without changes", "services/api", nil, []CheckSet{api}},
+		{"scoped task reaching into another package", "web", []string{"web/app.ts", "services/api/openapi.yaml"}, []CheckSet{web, api}},
+		{"cross-cutting task", "", []string{"services/api/a.go", "web/b.ts", "services/api/c.go"}, []CheckSet{api, web}},
+		{"change outside packages", "", []string{"go.work", "web/b.ts"}, []CheckSet{root, web}},
+		{"package without its own checks", "web/legacy", []string{"web/legacy/index.html"}, []CheckSet{root}},
+		{"cross-cutting task without changes", "", nil, []CheckSet{root}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.CheckSets(tt.workdir, tt.changed); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckSets = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	cfg.Packages = nil
+	if got := cfg.CheckSets("services/api", []string{"services/api/a.go"}); !reflect.DeepEqual(got, []CheckSet{root}) {
+		t.Errorf("CheckSets without packages = %+v", got)
+	}
+}
+
+func TestValidatePackages(t *testing.T) {
+	tests := []struct {
+		pkgs []PackageConfig
+		ok   bool
+	}{
+		{testPackagesConfig().Packages, true},
+		{[]PackageConfig{{Path: ""}}, false},
+		{[]PackageConfig{{Path: "."}}, false},
+		{[]PackageConfig{{Path: "../api"}}, false},
+		{[]PackageConfig{{Path: "api"}, {Path: "./api/"}}, false},
+		{[]PackageConfig{{Path: "api", Image: "java"}}, false},
+		{[]PackageConfig{{Path: "api", ProtectedPaths: []string{"[x"}}}, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Packages = tt.pkgs
+		if err := cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.pkgs, err, tt.ok)
+		}
+	}
+}
@@ -19,13 +19,20 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 
+	"github.com/swamp-dev/agentbox/internal/agent"
 	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/retry"
 )
 
+// RetryHook is called before Create retries a transient Docker failure.
+type RetryHook func(n int, delay time.Duration, err error)
+
 // Manager handles Docker container lifecycle.
 type Manager struct {
 	client         *client.Client
 	restrictedNets map[string]*RestrictedNetwork // containerID -> restricted network
+	retryPolicy    retry.Policy
+	onRetry        RetryHook
 }
 
 // NewManager creates a new Docker container manager.
@@ -38,9 +45,17 @@ func NewManager() (*Manager, error) {
 	return &Manager{
 		client:         cli,
 		restrictedNets: make(map[string]*RestrictedNetwork),
+		retryPolicy:    retry.DefaultPolicy(),
 	}, nil
 }
 
+// SetRetryPolicy overrides how Create retries transient Docker failures.
+// A policy with MaxRetries of 0 disables retries.
+func (m *Manager) SetRetryPolicy(p retry.Policy, hook RetryHook) {
+	m.retryPolicy = p
+	m.onRetry = hook
+}
+
 // Close cleans up any remaining restricted networks and releases the Docker
 // client resources. This is a safety net for networks not cleaned up by Remove
 // (e.g., if the process was interrupted).
@@ -70,6 +85,8 @@ type ContainerConfig struct {
 	MountSSH          bool
 	MountGit          bool
 	MountClaudeConfig bool
+	MountAgentbox     bool // bind-mount the host agentbox binary (used by the mock agent)
+	HostGateway       bool // map host.docker.internal to the Docker host
 	Interactive       bool // allocate TTY and keep stdin open
 }
 
@@ -122,7 +139,20 @@ func shellQuoteForSu(s string) string {
 }
 
 // Create builds and starts a new container with the given configuration.
+// Transient Docker failures (daemon unreachable, connection resets, registry
+// timeouts) are retried with backoff according to the manager's retry policy.
 func (m *Manager) Create(ctx context.Context, cfg *ContainerConfig) (string, error) {
+	var id string
+	err := retry.Do(ctx, m.retryPolicy, func() error {
+		var createErr error
+		id, createErr = m.create(ctx, cfg)
+		return createErr
+	}, m.onRetry)
+	return id, err
+}
+
+// create performs a single attempt at building and starting a container.
+func (m *Manager) create(ctx context.Context, cfg *ContainerConfig) (string, error) {
 	mounts := []mount.Mount{
 		{
 			Type:   mount.TypeBind,
@@ -160,6 +190,19 @@ func (m *Manager) Create(ctx context.Context, cfg *ContainerConfig) (string, err
 		}
 	}
 
+	if cfg.MountAgentbox {
+		agentboxBin, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("finding agentbox binary: %w", err)
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   agentboxBin,
+			Target:   "/usr/local/bin/agentbox",
+			ReadOnly: true,
+		})
+	}
+
 	// NOTE: claude-cli credentials (~/.claude/.credentials.json) are injected
 	// via CopyToContainer after ContainerCreate — not as a bind mount — so the
 	// file is readable by the container's agent user regardless of host
@@ -178,11 +221,18 @@ func (m *Manager) Create(ctx context.Context, cfg *ContainerConfig) (string, err
 	copy(env, cfg.Env)
 	_, env = appendGitConfig(env, "safe.directory", "/workspace")
 
+	// The project is always mounted at /workspace; WorkDir may start the
+	// agent in a subdirectory of it.
+	workDir := cfg.WorkDir
+	if workDir == "" {
+		workDir = "/workspace"
+	}
+
 	containerCfg := &container.Config{
 		Image:      cfg.Image,
 		Cmd:        wrapCmdForAgent(cfg.Cmd),
 		Env:        env,
-		WorkingDir: "/workspace",
+		WorkingDir: workDir,
 		User:       "root",
 		Tty:        cfg.Interactive,
 		OpenStdin:  cfg.Interactive,
@@ -204,6 +254,10 @@ func (m *Manager) Create(ctx context.Context, cfg *ContainerConfig) (string, err
 		hostCfg.NetworkMode = "none"
 	case "host":
 		hostCfg.NetworkMode = "host"
+	case "bridge", "":
+		if cfg.HostGateway {
+			hostCfg.ExtraHosts = []string{hostGatewayEntry}
+		}
 	case "restricted":
 		var err error
 		rn, err = m.CreateRestrictedNetwork(ctx, cfg.Name, cfg.Image, cfg.AllowedEndpoints)
@@ -602,6 +656,13 @@ func ConfigToContainerConfig(cfg *config.Config, projectPath string, cmd []strin
 		return nil, fmt.Errorf("resolving project path: %w", err)
 	}
 
+	// The agent's base URL is always reachable in restricted mode, even
+	// when the user supplied their own allowlist.
+	endpoints := cfg.Docker.AllowedEndpoints
+	if ep := agent.BaseURLEndpoint(cfg.Agent.BaseURL, cfg.Docker.Network); ep != "" && cfg.Docker.Network == "restricted" && !containsString(endpoints, ep) {
+		endpoints = append(append([]string{}, endpoints...), ep)
+	}
+
 	return &ContainerConfig{
 		Name:              fmt.Sprintf("agentbox-%s", cfg.Project.Name),
 		Image:             ImageName(cfg.Docker.Image),
@@ -610,11 +671,22 @@ func ConfigToContainerConfig(cfg *config.Config, projectPath string, cmd []strin
 		Env:               env,
 		Cmd:               cmd,
 		Network:           cfg.Docker.Network,
-		AllowedEndpoints:  cfg.Docker.AllowedEndpoints,
+		AllowedEndpoints:  endpoints,
 		Memory:            memory,
 		CPUs:              cpus,
 		MountSSH:          true,
 		MountGit:          true,
 		MountClaudeConfig: cfg.Agent.Name == "claude-cli",
+		MountAgentbox:     cfg.Agent.Name == "mock",
+		HostGateway:       agent.UsesHostGateway(cfg.Agent.BaseURL, cfg.Docker.Network),
 	}, nil
 }
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
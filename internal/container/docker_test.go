@@ -223,6 +223,48 @@ func TestConfigToContainerConfigAllowedEndpoints(t *testing.T) {
 	}
 }
 
+func TestConfigToContainerConfigBaseURL(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Docker.Network = "restricted"
+	cfg.Docker.AllowedEndpoints = []string{"pypi.org:443"}
+	cfg.Agent.BaseURL = "http://localhost:11434/v1"
+
+	containerCfg, err := ConfigToContainerConfig(cfg, t.TempDir(), []string{"echo"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"pypi.org:443", "host.docker.internal:11434"}
+	if strings.Join(containerCfg.AllowedEndpoints, ",") != strings.Join(want, ",") {
+		t.Errorf("AllowedEndpoints = %v, want %v", containerCfg.AllowedEndpoints, want)
+	}
+	if !containerCfg.HostGateway {
+		t.Error("expected HostGateway for a localhost base URL")
+	}
+	if len(cfg.Docker.AllowedEndpoints) != 1 {
+		t.Errorf("config allowlist was modified: %v", cfg.Docker.AllowedEndpoints)
+	}
+
+	cfg.Agent.BaseURL = "https://gateway.example.com"
+	containerCfg, err = ConfigToContainerConfig(cfg, t.TempDir(), []string{"echo"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containerCfg.HostGateway {
+		t.Error("unexpected HostGateway for a remote base URL")
+	}
+
+	// On the host network loopback is the host's own.
+	cfg.Docker.Network = "host"
+	cfg.Agent.BaseURL = "http://localhost:11434/v1"
+	containerCfg, err = ConfigToContainerConfig(cfg, t.TempDir(), []string{"echo"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containerCfg.HostGateway {
+		t.Error("unexpected HostGateway on the host network")
+	}
+}
+
 func TestConfigToContainerConfigInteractiveDefault(t *testing.T) {
 	cfg := config.DefaultConfig()
 	cfg.Docker.Image = "full"
@@ -266,6 +308,20 @@ func TestConfigToContainerConfigClaudeCLIMount(t *testing.T) {
 	}
 }
 
+func TestConfigToContainerConfigMockAgentMount(t *testing.T) {
+	for _, agent := range []string{"mock", "claude"} {
+		cfg := config.DefaultConfig()
+		cfg.Agent.Name = agent
+		containerCfg, err := ConfigToContainerConfig(cfg, t.TempDir(), []string{"test"}, nil)
+		if err != nil {
+			t.Fatalf("error = %v", err)
+		}
+		if want := agent == "mock"; containerCfg.MountAgentbox != want {
+			t.Errorf("agent %s: MountAgentbox = %v, want %v", agent, containerCfg.MountAgentbox, want)
+		}
+	}
+}
+
 func TestWrapCmdForAgent(t *testing.T) {
 	tests := []struct {
 		name string
@@ -10,8 +10,14 @@ import (
 	dockercontainer "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+
+	"github.com/swamp-dev/agentbox/internal/agent"
 )
 
+// hostGatewayEntry maps host.docker.internal to the Docker host. Docker Desktop
+// provides this name already; on Linux it needs the special host-gateway value.
+const hostGatewayEntry = agent.HostGateway + ":host-gateway"
+
 // RestrictedNetwork holds the resources for an egress-restricted network setup.
 type RestrictedNetwork struct {
 	NetworkID   string
@@ -69,6 +75,13 @@ func (m *Manager) CreateRestrictedNetwork(ctx context.Context, baseName string,
 		proxyCmd = append(proxyCmd, "--allow", h)
 	}
 
+	// The proxy makes the outbound connections, so it is the container that
+	// needs to resolve host.docker.internal when a host service is allowed.
+	var proxyExtraHosts []string
+	if needsHostGateway(allowedHosts) {
+		proxyExtraHosts = []string{hostGatewayEntry}
+	}
+
 	// Find the agentbox binary on the host to bind-mount into the proxy container.
 	agentboxBin, err := os.Executable()
 	if err != nil {
@@ -88,6 +101,7 @@ func (m *Manager) CreateRestrictedNetwork(ctx context.Context, baseName string,
 			},
 		},
 		&dockercontainer.HostConfig{
+			ExtraHosts: proxyExtraHosts,
 			Mounts: []mount.Mount{
 				{
 					Type:     mount.TypeBind,
@@ -166,8 +180,9 @@ func resolveExtraHosts(allowedHosts []string, proxyName, proxyIP string) ([]stri
 		}
 		seen[host] = true
 
-		// Skip if it's already an IP address.
-		if net.ParseIP(host) != nil {
+		// Skip if it's already an IP address, or the Docker host, which
+		// only the proxy resolves (via host-gateway).
+		if net.ParseIP(host) != nil || host == agent.HostGateway {
 			continue
 		}
 
@@ -189,6 +204,20 @@ func resolveExtraHosts(allowedHosts []string, proxyName, proxyIP string) ([]stri
 	return hosts, nil
 }
 
+// needsHostGateway reports whether any allowed endpoint is on the Docker host.
+func needsHostGateway(allowedHosts []string) bool {
+	for _, hostPort := range allowedHosts {
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			host = hostPort
+		}
+		if host == agent.HostGateway {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveRestrictedNetwork tears down the proxy container and internal network.
 // It attempts all cleanup steps even if individual steps fail.
 func (m *Manager) RemoveRestrictedNetwork(ctx context.Context, rn *RestrictedNetwork) error {
@@ -151,3 +151,22 @@ func TestResolveExtraHosts_Dedup(t *testing.T) {
 		t.Errorf("expected 1 entry for api.anthropic.com, got %d in %v", count, hosts)
 	}
 }
+
+func TestNeedsHostGateway(t *testing.T) {
+	if !needsHostGateway([]string{"api.anthropic.com:443", "host.docker.internal:11434"}) {
+		t.Error("expected host gateway for host.docker.internal endpoint")
+	}
+	if needsHostGateway([]string{"api.anthropic.com:443"}) {
+		t.Error("unexpected host gateway without host.docker.internal")
+	}
+}
+
+func TestResolveExtraHosts_SkipsHostGateway(t *testing.T) {
+	hosts, err := resolveExtraHosts([]string{"host.docker.internal:11434"}, "proxy", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0] != "proxy:10.0.0.2" {
+		t.Errorf("hosts = %v, want only the proxy mapping", hosts)
+	}
+}
@@ -0,0 +1,43 @@
+// Package events carries progress events from a running loop or sprint to
+// whoever started it, such as an MCP client waiting on an async session.
+package events
+
+// Kind identifies what happened.
+type Kind string
+
+const (
+	IterationStart     Kind = "iteration_start"
+	IterationEnd       Kind = "iteration_end"
+	TaskComplete       Kind = "task_complete"
+	QualityCheckFailed Kind = "quality_check_failed"
+	BudgetWarning      Kind = "budget_warning"
+)
+
+// Event is a single progress update.
+type Event struct {
+	Kind      Kind
+	Iteration int
+	TaskID    string
+	Message   string
+
+	// Completed and Total count tasks, so a client can render a progress
+	// bar. Total is zero when unknown.
+	Completed int
+	Total     int
+}
+
+// Warning reports whether the event describes something going wrong.
+func (e Event) Warning() bool {
+	return e.Kind == QualityCheckFailed || e.Kind == BudgetWarning
+}
+
+// Sink receives events. A nil Sink discards them, so emitters can call Emit
+// unconditionally.
+type Sink func(Event)
+
+// Emit delivers e to the sink, if any.
+func (s Sink) Emit(e Event) {
+	if s != nil {
+		s(e)
+	}
+}
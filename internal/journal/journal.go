@@ -20,6 +20,11 @@ const (
 	KindAgentSwitch    EntryKind = "agent_switch"
 	KindReflection     EntryKind = "reflection"
 	KindFinalWrapUp    EntryKind = "final_wrap_up"
+	KindTransientRetry EntryKind = "transient_retry"
+	KindEnsemble       EntryKind = "ensemble_result"
+	KindAgentReport    EntryKind = "agent_report"
+	KindCIFailed       EntryKind = "ci_failed"
+	KindBaseSync       EntryKind = "base_sync"
 )
 
 // Journal is a thin wrapper over store for managing dev diary entries.
@@ -0,0 +1,243 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/swamp-dev/agentbox/internal/pause"
+)
+
+// errAsyncTimeout is the cause of an async session's cancellation when it
+// runs past its timeout.
+var errAsyncTimeout = errors.New("session timed out")
+
+// asyncDeadline cancels an async session once it has run for its timeout.
+// The clock stops while the session is paused.
+type asyncDeadline struct {
+	mu        sync.Mutex
+	timer     *time.Timer // nil while stopped
+	remaining time.Duration
+	started   time.Time
+	expire    func()
+}
+
+func newAsyncDeadline(timeout time.Duration, expire func()) *asyncDeadline {
+	d := &asyncDeadline{remaining: timeout, expire: expire}
+	d.start()
+	return d
+}
+
+// start runs the clock for the time remaining.
+func (d *asyncDeadline) start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil {
+		return
+	}
+	d.started = time.Now()
+	d.timer = time.AfterFunc(max(d.remaining, 0), d.expire)
+}
+
+// stop stops the clock, keeping the time remaining.
+func (d *asyncDeadline) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer == nil {
+		return
+	}
+	d.timer.Stop()
+	d.timer = nil
+	d.remaining -= time.Since(d.started)
+}
+
+// startAsyncSession registers a new running session for projectDir and writes
+// its initial state file for the wait command. The session's context is
+// cancelled by agentbox_cancel, or once the session has run for the async
+// timeout, not counting time paused.
+func (h *ToolHandler) startAsyncSession(projectDir string, caller Caller) *asyncSession {
+	timeout := h.asyncTimeout
+	if timeout <= 0 {
+		timeout = defaultAsyncTimeout
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	deadline := newAsyncDeadline(timeout, func() {
+		cancel(fmt.Errorf("%w after %s", errAsyncTimeout, timeout))
+	})
+	sess := &asyncSession{
+		ID:         uuid.New().String(),
+		ProjectDir: projectDir,
+		Status:     "running",
+		ctx:        ctx,
+		cancel: func() {
+			deadline.stop()
+			cancel(nil)
+		},
+		gate:     pause.NewGate(),
+		deadline: deadline,
+
+		notify:        caller.Notify,
+		progressToken: caller.ProgressToken,
+	}
+
+	h.mu.Lock()
+	h.evictSessions()
+	h.sessions[sess.ID] = sess
+	h.mu.Unlock()
+
+	if err := WriteSessionState(projectDir, sess.ID, "running", ""); err != nil {
+		h.logger.Warn("failed to write session state", "error", err)
+	}
+	return sess
+}
+
+// finishAsyncSession records the outcome of an async run. A run that stops
+// because agentbox_cancel was called is "cancelled"; hitting the async timeout
+// is still a failure.
+func (h *ToolHandler) finishAsyncSession(sess *asyncSession, err error) {
+	sess.deadline.stop()
+	cause := context.Cause(sess.ctx)
+
+	h.mu.Lock()
+	switch {
+	case err == nil:
+		sess.Status = "completed"
+	case sess.Status == "cancelling" && cause == context.Canceled:
+		sess.Status = "cancelled"
+	case errors.Is(cause, errAsyncTimeout):
+		sess.Status = "failed"
+		sess.Error = cause.Error()
+	default:
+		sess.Status = "failed"
+		sess.Error = err.Error()
+	}
+	// Written under the lock so a concurrent control call cannot overwrite
+	// the final state with a stale one.
+	if err := WriteSessionState(sess.ProjectDir, sess.ID, sess.Status, sess.Error); err != nil {
+		h.logger.Warn("failed to write session state", "error", err)
+	}
+	status, errMsg := sess.Status, sess.Error
+	h.mu.Unlock()
+
+	level, message := "info", "Session "+status
+	if errMsg != "" {
+		level, message = "error", fmt.Sprintf("Session %s: %s", status, errMsg)
+	}
+	h.notifySession(sess, level, message, map[string]interface{}{"status": status})
+}
+
+// --- agentbox_cancel / agentbox_pause / agentbox_resume ---
+
+type sessionControlArgs struct {
+	SessionID string `json:"session_id"`
+}
+
+// lookupActiveSession returns the in-memory session for a control tool call.
+// Must be called with h.mu held.
+func (h *ToolHandler) lookupActiveSession(id string) (*asyncSession, error) {
+	if id == "" {
+		return nil, fmt.Errorf("session_id is required")
+	}
+	sess, ok := h.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown session %s (only sessions started by this server can be controlled)", id)
+	}
+	switch sess.Status {
+	case "completed", "failed", "cancelled":
+		return nil, fmt.Errorf("session %s already %s", id, sess.Status)
+	}
+	return sess, nil
+}
+
+func (h *ToolHandler) handleCancel(argsJSON json.RawMessage) *ToolCallResult {
+	var args sessionControlArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+
+	h.mu.Lock()
+	sess, err := h.lookupActiveSession(args.SessionID)
+	if err != nil {
+		h.mu.Unlock()
+		return textError(err.Error())
+	}
+	if sess.Status == "cancelling" {
+		h.mu.Unlock()
+		return textError(fmt.Sprintf("session %s is already being cancelled", sess.ID))
+	}
+	sess.Status = "cancelling"
+	_ = WriteSessionState(sess.ProjectDir, sess.ID, "cancelling", "")
+	h.mu.Unlock()
+
+	// Cancelling the context stops the running container (which removes it
+	// and its network) and wakes a paused loop. The supervisor marks its
+	// store session interrupted so it can be picked up with sprint --resume.
+	sess.cancel()
+
+	return controlResult(sess.ID, "cancelling", "Cancellation requested; the current iteration is being stopped")
+}
+
+func (h *ToolHandler) handlePause(argsJSON json.RawMessage) *ToolCallResult {
+	var args sessionControlArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+
+	h.mu.Lock()
+	sess, err := h.lookupActiveSession(args.SessionID)
+	if err == nil && sess.Status != "running" {
+		err = fmt.Errorf("session %s is %s, not running", sess.ID, sess.Status)
+	}
+	if err != nil {
+		h.mu.Unlock()
+		return textError(err.Error())
+	}
+	sess.gate.Pause()
+	sess.deadline.stop()
+	sess.Status = "paused"
+	_ = WriteSessionState(sess.ProjectDir, sess.ID, "paused", "")
+	h.mu.Unlock()
+
+	return controlResult(sess.ID, "paused", "Session will pause once the current iteration finishes")
+}
+
+func (h *ToolHandler) handleResume(argsJSON json.RawMessage) *ToolCallResult {
+	var args sessionControlArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+
+	h.mu.Lock()
+	sess, err := h.lookupActiveSession(args.SessionID)
+	if err == nil && sess.Status != "paused" {
+		err = fmt.Errorf("session %s is %s, not paused", sess.ID, sess.Status)
+	}
+	if err != nil {
+		h.mu.Unlock()
+		return textError(err.Error())
+	}
+	sess.gate.Resume()
+	sess.deadline.start()
+	sess.Status = "running"
+	_ = WriteSessionState(sess.ProjectDir, sess.ID, "running", "")
+	h.mu.Unlock()
+
+	return controlResult(sess.ID, "running", "Session resumed")
+}
+
+func controlResult(sessionID, status, message string) *ToolCallResult {
+	data, err := json.Marshal(map[string]string{
+		"session_id": sessionID,
+		"status":     status,
+		"message":    message,
+	})
+	if err != nil {
+		return textError(fmt.Sprintf("marshaling result: %v", err))
+	}
+	return textResult(string(data))
+}
@@ -0,0 +1,139 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func controlCall(t *testing.T, h *ToolHandler, tool, sessionID string) *ToolCallResult {
+	t.Helper()
+	args, _ := json.Marshal(map[string]string{"session_id": sessionID})
+	return h.Call(tool, args)
+}
+
+func TestCancelAsyncSession(t *testing.T) {
+	dir := t.TempDir()
+	h := NewToolHandler(nil)
+	sess := h.startAsyncSession(dir, Caller{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-sess.ctx.Done()
+		h.finishAsyncSession(sess, sess.ctx.Err())
+	}()
+
+	if r := controlCall(t, h, "agentbox_cancel", sess.ID); r.IsError {
+		t.Fatalf("cancel: %s", r.Content[0].Text)
+	}
+	<-done
+
+	state, err := ReadSessionState(dir, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != "cancelled" || state.Error != "" {
+		t.Errorf("state = %+v, want cancelled without error", state)
+	}
+	if r := controlCall(t, h, "agentbox_cancel", sess.ID); !r.IsError {
+		t.Error("expected error cancelling a finished session")
+	}
+}
+
+func TestFinishAsyncSessionFailureIsNotCancelled(t *testing.T) {
+	dir := t.TempDir()
+	h := NewToolHandler(nil)
+	sess := h.startAsyncSession(dir, Caller{})
+	h.finishAsyncSession(sess, errors.New("container crashed"))
+
+	state, err := ReadSessionState(dir, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != "failed" || state.Error != "container crashed" {
+		t.Errorf("state = %+v, want failed", state)
+	}
+}
+
+func TestPauseResumeAsyncSession(t *testing.T) {
+	dir := t.TempDir()
+	h := NewToolHandler(nil)
+	sess := h.startAsyncSession(dir, Caller{})
+	defer sess.cancel()
+
+	if r := controlCall(t, h, "agentbox_resume", sess.ID); !r.IsError {
+		t.Error("expected error resuming a running session")
+	}
+	if r := controlCall(t, h, "agentbox_pause", sess.ID); r.IsError {
+		t.Fatalf("pause: %s", r.Content[0].Text)
+	}
+	if !sess.gate.Paused() {
+		t.Error("gate should be paused")
+	}
+	if state, _ := ReadSessionState(dir, sess.ID); state == nil || state.Status != "paused" {
+		t.Errorf("state = %+v, want paused", state)
+	}
+	if r := controlCall(t, h, "agentbox_pause", sess.ID); !r.IsError {
+		t.Error("expected error pausing a paused session")
+	}
+
+	if r := controlCall(t, h, "agentbox_resume", sess.ID); r.IsError {
+		t.Fatalf("resume: %s", r.Content[0].Text)
+	}
+	if sess.gate.Paused() {
+		t.Error("gate should be resumed")
+	}
+	if state, _ := ReadSessionState(dir, sess.ID); state == nil || state.Status != "running" {
+		t.Errorf("state = %+v, want running", state)
+	}
+}
+
+func TestSessionControlUnknownSession(t *testing.T) {
+	h := NewToolHandler(nil)
+	for _, tool := range []string{"agentbox_cancel", "agentbox_pause", "agentbox_resume"} {
+		if r := controlCall(t, h, tool, "nope"); !r.IsError {
+			t.Errorf("%s: expected error for unknown session", tool)
+		}
+		if r := controlCall(t, h, tool, ""); !r.IsError {
+			t.Errorf("%s: expected error for missing session_id", tool)
+		}
+	}
+}
+
+func TestPausedSessionDoesNotTimeOut(t *testing.T) {
+	dir := t.TempDir()
+	h := NewToolHandler(nil)
+	h.asyncTimeout = 100 * time.Millisecond
+	sess := h.startAsyncSession(dir, Caller{})
+	defer sess.cancel()
+
+	if r := controlCall(t, h, "agentbox_pause", sess.ID); r.IsError {
+		t.Fatalf("pause: %s", r.Content[0].Text)
+	}
+	time.Sleep(3 * h.asyncTimeout)
+	if err := sess.ctx.Err(); err != nil {
+		t.Fatalf("paused session was stopped: %v", context.Cause(sess.ctx))
+	}
+
+	if r := controlCall(t, h, "agentbox_resume", sess.ID); r.IsError {
+		t.Fatalf("resume: %s", r.Content[0].Text)
+	}
+	select {
+	case <-sess.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed session never timed out")
+	}
+	h.finishAsyncSession(sess, sess.ctx.Err())
+
+	state, err := ReadSessionState(dir, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Status != "failed" || !strings.Contains(state.Error, "timed out") {
+		t.Errorf("state = %+v, want failed with a timeout", state)
+	}
+}
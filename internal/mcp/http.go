@@ -0,0 +1,321 @@
+package mcp
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// HTTPPath is the endpoint the streamable HTTP transport is served on.
+	HTTPPath = "/mcp"
+
+	// sessionHeader carries the MCP session ID assigned at initialize.
+	sessionHeader = "Mcp-Session-Id"
+
+	// notificationBuffer is how many notifications are queued for a client
+	// before new ones are dropped. Clients that never open the SSE stream
+	// must not block the sessions reporting to them.
+	notificationBuffer = 256
+
+	// sessionIdleTimeout is how long a session with no requests and no open
+	// stream is kept before it is removed, for clients that never send
+	// DELETE.
+	sessionIdleTimeout = 30 * time.Minute
+)
+
+// HTTPServer serves MCP over the streamable HTTP transport. Clients POST
+// JSON-RPC messages and open a GET server-sent-events stream to receive
+// notifications. All clients share one ToolHandler, so an async session
+// started by one client can be watched, paused or cancelled by another.
+type HTTPServer struct {
+	handler *ToolHandler
+	token   string
+	logger  *slog.Logger
+
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is one client's MCP session on the HTTP transport.
+type httpSession struct {
+	client  *client
+	pending chan []byte   // encoded notifications waiting for the SSE stream
+	done    chan struct{} // closed when the session is deleted
+
+	// Guarded by HTTPServer.mu.
+	lastUsed time.Time
+	streams  int // open SSE streams
+}
+
+// NewHTTPServer creates an HTTP transport that requires every request to
+// carry "Authorization: Bearer <token>". The token must not be empty.
+func NewHTTPServer(token string, logger *slog.Logger) (*HTTPServer, error) {
+	if token == "" {
+		return nil, fmt.Errorf("bearer token is required for the HTTP transport")
+	}
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	}
+	return &HTTPServer{
+		handler:     NewToolHandler(logger),
+		token:       token,
+		logger:      logger,
+		idleTimeout: sessionIdleTimeout,
+		sessions:    make(map[string]*httpSession),
+	}, nil
+}
+
+// SetProjectDir sets the project directory resources and prompts read from.
+func (s *HTTPServer) SetProjectDir(dir string) {
+	s.handler.SetProjectDir(dir)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="agentbox"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleStream(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *HTTPServer) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// handlePost handles a JSON-RPC message or batch. Responses are returned as
+// application/json; notifications from async sessions go to the SSE stream.
+func (s *HTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	var reqs []JSONRPCRequest
+	if batch {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		var req JSONRPCRequest
+		err = json.Unmarshal(body, &req)
+		reqs = []JSONRPCRequest{req}
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse(nil, -32700, "Parse error"))
+		return
+	}
+
+	sessionID, sess := s.lookup(r)
+	if sess == nil {
+		if !containsInitialize(reqs) {
+			if sessionID == "" {
+				http.Error(w, "missing "+sessionHeader+" header", http.StatusBadRequest)
+			} else {
+				http.Error(w, "unknown session", http.StatusNotFound)
+			}
+			return
+		}
+		sessionID, sess = s.newSession()
+	}
+	w.Header().Set(sessionHeader, sessionID)
+
+	var resps []JSONRPCResponse
+	for _, req := range reqs {
+		if req.isNotification() || req.Method == "" {
+			// Notifications and responses to server requests need no reply.
+			continue
+		}
+		resps = append(resps, dispatch(s.handler, sess.client, req))
+	}
+
+	switch {
+	case len(resps) == 0:
+		w.WriteHeader(http.StatusAccepted)
+	case batch:
+		writeJSON(w, http.StatusOK, resps)
+	default:
+		writeJSON(w, http.StatusOK, resps[0])
+	}
+}
+
+// handleStream opens a server-sent-events stream carrying the session's
+// notifications until the client disconnects or the session is deleted.
+func (s *HTTPServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	_, sess := s.lookup(r)
+	if sess == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// A session with a client listening is not idle.
+	s.mu.Lock()
+	sess.streams++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		sess.streams--
+		sess.lastUsed = time.Now()
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sess.done:
+			return
+		case data := <-sess.pending:
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleDelete ends the client's MCP session. Async sessions it started keep
+// running; they just stop sending it notifications.
+func (s *HTTPServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess := s.remove(r.Header.Get(sessionHeader))
+	if sess == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	sess.client.close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup returns the session named by the request header, if it exists,
+// and marks it used.
+func (s *HTTPServer) lookup(r *http.Request) (string, *httpSession) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		return "", nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[id]
+	if sess != nil {
+		sess.lastUsed = time.Now()
+	}
+	return id, sess
+}
+
+// remove deletes a session and ends its streams. It returns nil when there
+// is no such session, so of concurrent removals only one gets it.
+func (s *HTTPServer) remove(id string) *httpSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.sessions[id]
+	if sess == nil {
+		return nil
+	}
+	delete(s.sessions, id)
+	close(sess.done)
+	return sess
+}
+
+// expireIdle removes sessions unused for longer than the idle timeout that
+// have no stream open.
+func (s *HTTPServer) expireIdle(now time.Time) {
+	var expired []*httpSession
+	s.mu.Lock()
+	for id, sess := range s.sessions {
+		if sess.streams == 0 && now.Sub(sess.lastUsed) > s.idleTimeout {
+			delete(s.sessions, id)
+			close(sess.done)
+			expired = append(expired, sess)
+			s.logger.Debug("expiring idle MCP session", "session", id)
+		}
+	}
+	s.mu.Unlock()
+	for _, sess := range expired {
+		sess.client.close()
+	}
+}
+
+func (s *HTTPServer) newSession() (string, *httpSession) {
+	id := uuid.New().String()
+	sess := &httpSession{
+		pending: make(chan []byte, notificationBuffer),
+		done:    make(chan struct{}),
+	}
+	sess.client = newClient(func(msg interface{}) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			s.logger.Error("failed to marshal notification", "error", err)
+			return
+		}
+		select {
+		case <-sess.done:
+		case sess.pending <- data:
+		default:
+			s.logger.Debug("dropping notification for slow client", "session", id)
+		}
+	})
+
+	// Sessions only accumulate through initialize, so sweeping here keeps
+	// abandoned ones bounded.
+	s.expireIdle(time.Now())
+	s.mu.Lock()
+	sess.lastUsed = time.Now()
+	s.sessions[id] = sess
+	s.mu.Unlock()
+	return id, sess
+}
+
+func containsInitialize(reqs []JSONRPCRequest) bool {
+	for _, req := range reqs {
+		if req.Method == "initialize" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,248 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testToken = "s3cret"
+
+func newTestHTTPServer(t *testing.T) (*HTTPServer, *httptest.Server) {
+	t.Helper()
+	h, err := NewHTTPServer(testToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return h, ts
+}
+
+func postMCP(t *testing.T, url, sessionID, token, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if sessionID != "" {
+		req.Header.Set(sessionHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func initializeHTTP(t *testing.T, url string) string {
+	t.Helper()
+	resp := postMCP(t, url, "", testToken, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d", resp.StatusCode)
+	}
+	id := resp.Header.Get(sessionHeader)
+	if id == "" {
+		t.Fatal("initialize did not assign a session ID")
+	}
+	return id
+}
+
+func TestNewHTTPServerRequiresToken(t *testing.T) {
+	if _, err := NewHTTPServer("", nil); err == nil {
+		t.Error("expected error for empty token")
+	}
+}
+
+func TestHTTPServerRejectsBadToken(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	for _, token := range []string{"", "wrong"} {
+		resp := postMCP(t, ts.URL, "", token, `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, resp.StatusCode)
+		}
+	}
+}
+
+func TestHTTPServerRequestFlow(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	id := initializeHTTP(t, ts.URL)
+
+	resp := postMCP(t, ts.URL, id, testToken, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("tools/list status = %d", resp.StatusCode)
+	}
+	var list struct {
+		Result struct {
+			Tools []ToolDefinition `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Result.Tools) != len(AllTools()) {
+		t.Errorf("got %d tools, want %d", len(list.Result.Tools), len(AllTools()))
+	}
+
+	resp = postMCP(t, ts.URL, id, testToken, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", resp.StatusCode)
+	}
+
+	resp = postMCP(t, ts.URL, id, testToken, `[{"jsonrpc":"2.0","id":3,"method":"ping"},{"jsonrpc":"2.0","id":4,"method":"ping"}]`)
+	var batch []JSONRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 {
+		t.Errorf("batch returned %d responses, want 2", len(batch))
+	}
+}
+
+func TestHTTPServerSessionErrors(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+
+	resp := postMCP(t, ts.URL, "", testToken, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("missing session: status = %d, want 400", resp.StatusCode)
+	}
+	resp = postMCP(t, ts.URL, "nope", testToken, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want 404", resp.StatusCode)
+	}
+	resp = postMCP(t, ts.URL, "", testToken, `{not json`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("parse error: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestHTTPServerStreamsNotifications(t *testing.T) {
+	h, ts := newTestHTTPServer(t)
+	id := initializeHTTP(t, ts.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set(sessionHeader, id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	_, sess := h.lookup(req)
+	sess.client.notify("notifications/message", map[string]interface{}{"level": "info", "data": "hello"})
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if !strings.Contains(data, `"notifications/message"`) || !strings.Contains(data, "hello") {
+				t.Errorf("unexpected event data %s", data)
+			}
+			return
+		}
+	}
+	t.Fatalf("stream ended without an event: %v", scanner.Err())
+}
+
+func TestHTTPServerDeleteSession(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	id := initializeHTTP(t, ts.URL)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set(sessionHeader, id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want 204", resp.StatusCode)
+	}
+
+	resp = postMCP(t, ts.URL, id, testToken, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleted session: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPServerConcurrentDelete(t *testing.T) {
+	_, ts := newTestHTTPServer(t)
+	id := initializeHTTP(t, ts.URL)
+
+	const n = 8
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			req.Header.Set(sessionHeader, id)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	deleted := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusNoContent:
+			deleted++
+		case http.StatusNotFound:
+		default:
+			t.Errorf("delete status = %d", status)
+		}
+	}
+	if deleted != 1 {
+		t.Errorf("%d deletes succeeded, want 1", deleted)
+	}
+}
+
+func TestHTTPServerExpiresIdleSessions(t *testing.T) {
+	h, ts := newTestHTTPServer(t)
+	idle := initializeHTTP(t, ts.URL)
+	streaming := initializeHTTP(t, ts.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set(sessionHeader, streaming)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	h.expireIdle(time.Now().Add(sessionIdleTimeout + time.Minute))
+
+	if resp := postMCP(t, ts.URL, idle, testToken, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("idle session: status = %d, want 404", resp.StatusCode)
+	}
+	if resp := postMCP(t, ts.URL, streaming, testToken, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("session with an open stream: status = %d, want 200", resp.StatusCode)
+	}
+}
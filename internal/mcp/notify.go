@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/swamp-dev/agentbox/internal/events"
+)
+
+// Notifier sends a JSON-RPC notification to a client. It must be safe to
+// call from multiple goroutines.
+type Notifier func(method string, params interface{})
+
+// Caller identifies the client a tool call came from, so async sessions it
+// starts can report back to that client.
+type Caller struct {
+	// ProgressToken is the request's _meta.progressToken, if any.
+	ProgressToken json.RawMessage
+
+	// Notify sends notifications to the caller. Nil disables them.
+	Notify Notifier
+}
+
+// logLevels are the MCP logging levels in increasing severity.
+var logLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// logLevelIndex returns the severity of an MCP log level, or -1 if unknown.
+func logLevelIndex(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// client is one connected MCP client: where its notifications go and the
+// minimum log level it asked for, plus the resources it subscribed to.
+type client struct {
+	send func(msg interface{})
+
+	mu       sync.Mutex
+	logLevel int
+
+	subs subscriptions
+}
+
+// newClient returns a client that writes notifications with send, which must
+// be safe to call from multiple goroutines.
+func newClient(send func(msg interface{})) *client {
+	return &client{send: send, logLevel: logLevelIndex("info")}
+}
+
+func (c *client) setLogLevel(level string) error {
+	idx := logLevelIndex(level)
+	if idx < 0 {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logLevel = idx
+	return nil
+}
+
+// notify sends a notification to the client, dropping log messages below
+// its log level.
+func (c *client) notify(method string, params interface{}) {
+	if method == "notifications/message" {
+		if p, ok := params.(map[string]interface{}); ok {
+			level, _ := p["level"].(string)
+			c.mu.Lock()
+			below := logLevelIndex(level) < c.logLevel
+			c.mu.Unlock()
+			if below {
+				return
+			}
+		}
+	}
+	c.send(JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// eventSink returns a sink that forwards loop and sprint events for sess to
+// the client that started it, or nil if there is no client to tell.
+func (h *ToolHandler) eventSink(sess *asyncSession) events.Sink {
+	if sess.notify == nil {
+		return nil
+	}
+
+	return func(e events.Event) {
+		level := "info"
+		if e.Warning() {
+			level = "warning"
+		}
+		message := e.Message
+		if e.Total > 0 {
+			message = fmt.Sprintf("%s (%d/%d tasks completed)", message, e.Completed, e.Total)
+		}
+		h.notifySession(sess, level, message, map[string]interface{}{
+			"event":     string(e.Kind),
+			"iteration": e.Iteration,
+			"task_id":   e.TaskID,
+			"completed": e.Completed,
+			"total":     e.Total,
+		})
+	}
+}
+
+// notifySession sends a log notification for sess and, if the tool call
+// that started it carried a progress token, a progress notification.
+func (h *ToolHandler) notifySession(sess *asyncSession, level, message string, data map[string]interface{}) {
+	if sess.notify == nil {
+		return
+	}
+	h.mu.Lock()
+	sess.progress++
+	progress := sess.progress
+	h.mu.Unlock()
+
+	logData := map[string]interface{}{
+		"session_id": sess.ID,
+		"message":    message,
+	}
+	for k, v := range data {
+		logData[k] = v
+	}
+	sess.notify("notifications/message", map[string]interface{}{
+		"level":  level,
+		"logger": "agentbox",
+		"data":   logData,
+	})
+
+	// Progress must increase with every notification, so it counts events
+	// rather than tasks; the task counts are in the message.
+	if len(sess.progressToken) > 0 {
+		sess.notify("notifications/progress", map[string]interface{}{
+			"progressToken": sess.progressToken,
+			"progress":      progress,
+			"message":       message,
+		})
+	}
+}
+
+// progressToken extracts _meta.progressToken from tools/call params.
+func progressToken(params json.RawMessage) json.RawMessage {
+	var p struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	if string(p.Meta.ProgressToken) == "null" {
+		return nil
+	}
+	return p.Meta.ProgressToken
+}
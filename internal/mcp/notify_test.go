@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/events"
+)
+
+type notification struct {
+	Method string
+	Params map[string]interface{}
+}
+
+// recordNotifier returns a notifier that records what it is sent.
+func recordNotifier() (Notifier, func() []notification) {
+	var mu sync.Mutex
+	var got []notification
+	n := func(method string, params interface{}) {
+		data, _ := json.Marshal(params)
+		var p map[string]interface{}
+		_ = json.Unmarshal(data, &p)
+		mu.Lock()
+		got = append(got, notification{Method: method, Params: p})
+		mu.Unlock()
+	}
+	return n, func() []notification {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]notification(nil), got...)
+	}
+}
+
+func TestEventSinkSendsProgressAndLogs(t *testing.T) {
+	h := NewToolHandler(nil)
+	notify, got := recordNotifier()
+
+	sess := h.startAsyncSession(t.TempDir(), Caller{ProgressToken: json.RawMessage(`"tok-1"`), Notify: notify})
+	defer sess.cancel()
+	sink := h.eventSink(sess)
+	sink.Emit(events.Event{Kind: events.IterationStart, Iteration: 1, TaskID: "t-1", Message: "Iteration 1: Task", Total: 2})
+	sink.Emit(events.Event{Kind: events.QualityCheckFailed, Iteration: 1, TaskID: "t-1", Message: "Quality check failed"})
+	h.finishAsyncSession(sess, nil)
+
+	var progress []float64
+	var levels []string
+	for _, n := range got() {
+		switch n.Method {
+		case "notifications/progress":
+			if n.Params["progressToken"] != "tok-1" {
+				t.Errorf("progressToken = %v, want tok-1", n.Params["progressToken"])
+			}
+			progress = append(progress, n.Params["progress"].(float64))
+		case "notifications/message":
+			levels = append(levels, n.Params["level"].(string))
+		default:
+			t.Errorf("unexpected method %s", n.Method)
+		}
+	}
+	if len(progress) != 3 || progress[0] >= progress[1] || progress[1] >= progress[2] {
+		t.Errorf("progress = %v, want 3 increasing values", progress)
+	}
+	want := []string{"info", "warning", "info"}
+	if strings.Join(levels, ",") != strings.Join(want, ",") {
+		t.Errorf("levels = %v, want %v", levels, want)
+	}
+}
+
+func TestClientRespectsLogLevel(t *testing.T) {
+	var sent []JSONRPCNotification
+	c := newClient(func(msg interface{}) { sent = append(sent, msg.(JSONRPCNotification)) })
+	if err := c.setLogLevel("warning"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewToolHandler(nil)
+	sess := h.startAsyncSession(t.TempDir(), Caller{Notify: c.notify})
+	defer sess.cancel()
+	sink := h.eventSink(sess)
+	sink.Emit(events.Event{Kind: events.IterationStart, Message: "start"})
+	sink.Emit(events.Event{Kind: events.BudgetWarning, Message: "80% of token budget used"})
+
+	if len(sent) != 1 || sent[0].Params.(map[string]interface{})["level"] != "warning" {
+		t.Errorf("notifications = %+v, want only the warning and no progress without a token", sent)
+	}
+	if err := c.setLogLevel("loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestEventSinkNilWithoutNotifier(t *testing.T) {
+	h := NewToolHandler(nil)
+	sess := h.startAsyncSession(t.TempDir(), Caller{})
+	defer sess.cancel()
+	if h.eventSink(sess) != nil {
+		t.Error("expected nil sink when no notifier is set")
+	}
+}
+
+func TestProgressToken(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"name":"x","_meta":{"progressToken":"abc"}}`, `"abc"`},
+		{`{"name":"x","_meta":{"progressToken":7}}`, `7`},
+		{`{"name":"x"}`, ``},
+		{`{"name":"x","_meta":{"progressToken":null}}`, ``},
+	}
+	for _, tt := range tests {
+		if got := string(progressToken(json.RawMessage(tt.params))); got != tt.want {
+			t.Errorf("progressToken(%s) = %q, want %q", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestServerSetLevel(t *testing.T) {
+	input := `{"jsonrpc":"2.0","id":1,"method":"logging/setLevel","params":{"level":"error"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"logging/setLevel","params":{"level":"nope"}}` + "\n"
+	var stdout bytes.Buffer
+	srv := NewServer(strings.NewReader(input), &stdout, nil)
+	for i := 0; i < 2; i++ {
+		if err := srv.processOne(); err != nil {
+			t.Fatalf("processOne() error = %v", err)
+		}
+	}
+
+	dec := json.NewDecoder(&stdout)
+	var ok, bad JSONRPCResponse
+	if err := dec.Decode(&ok); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&bad); err != nil {
+		t.Fatal(err)
+	}
+	if ok.Error != nil {
+		t.Errorf("setLevel error: %+v", ok.Error)
+	}
+	if bad.Error == nil || bad.Error.Code != -32602 {
+		t.Errorf("expected invalid params for unknown level, got %+v", bad.Error)
+	}
+	if srv.client.logLevel != logLevelIndex("error") {
+		t.Errorf("logLevel = %d, want error", srv.client.logLevel)
+	}
+}
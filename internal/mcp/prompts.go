@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/swamp-dev/agentbox/internal/ralph"
+)
+
+// Prompt describes a prompt template in prompts/list.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes one argument a prompt template accepts.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage is a single message in a prompts/get result.
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
+// PromptResult is the MCP result format for prompts/get.
+type PromptResult struct {
+	Description string          `json:"description"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// AllPrompts returns the prompt templates the server offers.
+func AllPrompts() []Prompt {
+	return []Prompt{
+		{
+			Name:        "write_prd",
+			Description: "Write an agentbox prd.json for a feature",
+			Arguments: []PromptArgument{
+				{Name: "feature", Description: "What the feature should do", Required: true},
+			},
+		},
+		{
+			Name:        "triage_failed_tasks",
+			Description: "Diagnose tasks that failed or stalled in a sprint session",
+			Arguments: []PromptArgument{
+				{Name: "session_id", Description: "Store session ID (default: latest session)"},
+			},
+		},
+	}
+}
+
+// GetPrompt renders a prompt template with args.
+func (h *ToolHandler) GetPrompt(name string, args map[string]string) (*PromptResult, error) {
+	switch name {
+	case "write_prd":
+		return h.promptWritePRD(args)
+	case "triage_failed_tasks":
+		return h.promptTriage(args)
+	default:
+		return nil, fmt.Errorf("unknown prompt: %s", name)
+	}
+}
+
+func userPrompt(description, text string) *PromptResult {
+	return &PromptResult{
+		Description: description,
+		Messages: []PromptMessage{{
+			Role:    "user",
+			Content: ContentBlock{Type: "text", Text: text},
+		}},
+	}
+}
+
+func (h *ToolHandler) promptWritePRD(args map[string]string) (*PromptResult, error) {
+	feature := strings.TrimSpace(args["feature"])
+	if feature == "" {
+		return nil, fmt.Errorf("feature is required")
+	}
+
+	example, _ := json.MarshalIndent(ralph.PRD{
+		Name:        "Feature name",
+		Description: "One paragraph on what the feature does and why",
+		Tasks: []ralph.Task{
+			{ID: "task-1", Title: "Short imperative title", Description: "What to change and how to verify it", Status: "pending", Priority: 1, Complexity: 2},
+			{ID: "task-2", Title: "Follow-up task", Description: "Builds on task-1", Status: "pending", Priority: 2, Complexity: 3, DependsOn: []string{"task-1"}},
+		},
+	}, "", "  ")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Write an agentbox prd.json for this feature:\n\n%s\n\n", feature)
+	sb.WriteString("Break the work into tasks an AI agent can finish in one iteration each. For every task:\n")
+	sb.WriteString("- give it a unique ID and a short imperative title\n")
+	sb.WriteString("- describe the change and how to verify it (tests to add, commands that must pass)\n")
+	sb.WriteString("- set priority (1 is most urgent) and complexity (1-5)\n")
+	sb.WriteString("- list the IDs of tasks it builds on in depends_on\n")
+	sb.WriteString("- set status to \"pending\"\n\n")
+	fmt.Fprintf(&sb, "Use this format:\n\n```json\n%s\n```\n\n", example)
+	sb.WriteString("Reply with only the JSON document.")
+
+	return userPrompt("Write a PRD for a feature", sb.String()), nil
+}
+
+func (h *ToolHandler) promptTriage(args map[string]string) (*PromptResult, error) {
+	dir := h.resourceDir()
+	s, err := openProjectStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	var sessionID int64
+	if raw := args["session_id"]; raw != "" {
+		sessionID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session_id %q", raw)
+		}
+	} else {
+		latest, err := s.LatestSession()
+		if err != nil {
+			return nil, fmt.Errorf("finding latest session: %w", err)
+		}
+		sessionID = latest.ID
+	}
+
+	tasks, err := s.ListTasks(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("listing tasks: %w", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "These tasks in agentbox session %d did not complete. ", sessionID)
+	sb.WriteString("For each one, explain the likely root cause from the attempt errors and recommend whether to retry as-is, rewrite the task, split it into smaller tasks, or drop it.\n")
+
+	failed := 0
+	for _, t := range tasks {
+		if t.Status == "completed" {
+			continue
+		}
+		failed++
+		fmt.Fprintf(&sb, "\n## %s: %s (%s)\n\n", t.ID, t.Title, t.Status)
+		if t.Description != "" {
+			fmt.Fprintf(&sb, "%s\n\n", t.Description)
+		}
+		attempts, err := s.GetAttempts(t.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing attempts for %s: %w", t.ID, err)
+		}
+		if len(attempts) == 0 {
+			sb.WriteString("No attempts recorded.\n")
+			continue
+		}
+		for _, a := range attempts {
+			msg := a.ErrorMsg
+			if msg == "" {
+				msg = "(no error recorded)"
+			}
+			fmt.Fprintf(&sb, "- Attempt %d (%s, transcript %ssession/%d/transcript/%d): %s\n",
+				a.Number, a.AgentName, resourceScheme, sessionID, a.ID, msg)
+		}
+	}
+	if failed == 0 {
+		sb.WriteString("\nNo failed or incomplete tasks were found; confirm the session finished cleanly.\n")
+	}
+	if _, err := ralph.LoadPRD(projectFilePath(dir, prdResource)); err == nil {
+		fmt.Fprintf(&sb, "\nThe current PRD is available as %sproject/prd.json.\n", resourceScheme)
+	}
+
+	return userPrompt(fmt.Sprintf("Triage failed tasks in session %d", sessionID), sb.String()), nil
+}
@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGetPrompt_WritePRD(t *testing.T) {
+	h := NewToolHandler(nil)
+
+	got, err := h.GetPrompt("write_prd", map[string]string{"feature": "export reports as CSV"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" {
+		t.Fatalf("messages = %+v, want one user message", got.Messages)
+	}
+	text := got.Messages[0].Content.Text
+	for _, want := range []string{"export reports as CSV", `"depends_on"`, `"status": "pending"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("prompt missing %q:\n%s", want, text)
+		}
+	}
+
+	if _, err := h.GetPrompt("write_prd", nil); err == nil {
+		t.Error("write_prd without feature succeeded, want error")
+	}
+	if _, err := h.GetPrompt("nope", nil); err == nil {
+		t.Error("unknown prompt succeeded, want error")
+	}
+}
+
+func TestGetPrompt_TriageFailedTasks(t *testing.T) {
+	dir, sessionID, _ := seedProject(t)
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	for _, args := range []map[string]string{nil, {"session_id": strconv.FormatInt(sessionID, 10)}} {
+		got, err := h.GetPrompt("triage_failed_tasks", args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := got.Messages[0].Content.Text
+		for _, want := range []string{"t-1: Add login (failed)", "tests failed", "/transcript/"} {
+			if !strings.Contains(text, want) {
+				t.Errorf("args %v: prompt missing %q:\n%s", args, want, text)
+			}
+		}
+	}
+
+	if _, err := h.GetPrompt("triage_failed_tasks", map[string]string{"session_id": "x"}); err == nil {
+		t.Error("invalid session_id succeeded, want error")
+	}
+
+	empty := NewToolHandler(nil)
+	empty.SetProjectDir(t.TempDir())
+	if _, err := empty.GetPrompt("triage_failed_tasks", nil); err == nil {
+		t.Error("triage without a store succeeded, want error")
+	}
+}
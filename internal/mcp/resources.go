@@ -0,0 +1,410 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+const (
+	// resourceScheme prefixes every agentbox resource URI.
+	resourceScheme = "agentbox://"
+
+	// maxListedSessions caps how many store sessions resources/list expands
+	// into concrete journal, transcript and retro resources.
+	maxListedSessions = 20
+
+	// resourcePollInterval is how often subscribed resources are re-read.
+	// Sprints in other processes write to the store, so there is no
+	// in-process event to hook instead.
+	resourcePollInterval = 2 * time.Second
+)
+
+// Resource describes a readable resource in resources/list.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes a family of resources by URI template.
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the body of a resource returned by resources/read.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// projectFile is a project-directory file exposed as a resource. Its URI
+// uses name; configured gives the file the project's config names instead.
+type projectFile struct {
+	name, mimeType, description string
+	configured                  func(cfg *config.Config) string
+}
+
+var (
+	prdResource = projectFile{"prd.json", "application/json", "Product requirements document with task status",
+		func(cfg *config.Config) string { return cfg.Ralph.PRDFile }}
+	progressResource = projectFile{"progress.txt", "text/plain", "Iteration progress log and learnings",
+		func(cfg *config.Config) string { return cfg.Ralph.ProgressFile }}
+)
+
+// projectFiles are the project-directory files exposed as resources.
+var projectFiles = []projectFile{prdResource, progressResource}
+
+// projectFilePath returns the file resource f reads for the project in dir:
+// the one its agentbox.yaml names, as agentbox ralph uses, or f's default
+// name when there is no config.
+func projectFilePath(dir string, f projectFile) string {
+	name := f.name
+	if cfg, _, err := config.LoadForProject(dir); err == nil && f.configured(cfg) != "" {
+		name = f.configured(cfg)
+	}
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// ResourceTemplates returns the URI templates for agentbox resources.
+func ResourceTemplates() []ResourceTemplate {
+	return []ResourceTemplate{
+		{
+			URITemplate: resourceScheme + "session/{id}/journal",
+			Name:        "Session journal",
+			Description: "Dev diary for a sprint session, as markdown",
+			MimeType:    "text/markdown",
+		},
+		{
+			URITemplate: resourceScheme + "session/{id}/transcript/{attempt}",
+			Name:        "Attempt transcript",
+			Description: "Full agent output for one task attempt",
+			MimeType:    "text/plain",
+		},
+		{
+			URITemplate: resourceScheme + "session/{id}/retro/{sprint}",
+			Name:        "Sprint retrospective",
+			Description: "Retro report for one sprint, as markdown",
+			MimeType:    "text/markdown",
+		},
+	}
+}
+
+// SetProjectDir sets the project directory resources and prompts read from.
+func (h *ToolHandler) SetProjectDir(dir string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.projectDir = dir
+}
+
+func (h *ToolHandler) resourceDir() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.projectDir == "" {
+		return "."
+	}
+	return h.projectDir
+}
+
+// openProjectStore opens the project's store for reading, without creating
+// or migrating it: resources and prompts must not change a store that a
+// sprint may be using.
+func openProjectStore(projectDir string) (*store.Store, error) {
+	dbPath := filepath.Join(projectDir, ".agentbox", "agentbox.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no agentbox store in %s", projectDir)
+	}
+	return store.OpenReadOnly(dbPath)
+}
+
+// openWritableProjectStore opens the project's store for the tools that
+// change it, migrating it if needed, without creating one.
+func openWritableProjectStore(projectDir string) (*store.Store, error) {
+	dbPath := filepath.Join(projectDir, ".agentbox", "agentbox.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no agentbox store in %s", projectDir)
+	}
+	return store.Open(dbPath)
+}
+
+// ListResources returns the project files that exist plus the journal,
+// transcripts and retros of recent sessions.
+func (h *ToolHandler) ListResources() ([]Resource, error) {
+	dir := h.resourceDir()
+	var resources []Resource
+	for _, f := range projectFiles {
+		if _, err := os.Stat(projectFilePath(dir, f)); err == nil {
+			resources = append(resources, Resource{
+				URI:         resourceScheme + "project/" + f.name,
+				Name:        f.name,
+				Description: f.description,
+				MimeType:    f.mimeType,
+			})
+		}
+	}
+
+	s, err := openProjectStore(dir)
+	if err != nil {
+		// No store yet: only project files are available.
+		return resources, nil
+	}
+	defer s.Close()
+
+	sessions, err := s.ListSessions(maxListedSessions)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	for _, sess := range sessions {
+		prefix := fmt.Sprintf("%ssession/%d/", resourceScheme, sess.ID)
+		resources = append(resources, Resource{
+			URI:      prefix + "journal",
+			Name:     fmt.Sprintf("Session %d journal", sess.ID),
+			MimeType: "text/markdown",
+		})
+
+		reports, err := s.SprintReports(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing sprint reports: %w", err)
+		}
+		for _, r := range reports {
+			resources = append(resources, Resource{
+				URI:      fmt.Sprintf("%sretro/%d", prefix, r.SprintNumber),
+				Name:     fmt.Sprintf("Session %d sprint %d retro", sess.ID, r.SprintNumber),
+				MimeType: "text/markdown",
+			})
+		}
+
+		attempts, err := s.SessionAttempts(sess.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing attempts: %w", err)
+		}
+		for _, a := range attempts {
+			resources = append(resources, Resource{
+				URI:      fmt.Sprintf("%stranscript/%d", prefix, a.ID),
+				Name:     fmt.Sprintf("Session %d %s attempt %d transcript", sess.ID, a.TaskID, a.Number),
+				MimeType: "text/plain",
+			})
+		}
+	}
+	return resources, nil
+}
+
+// ReadResource returns the contents of an agentbox:// resource.
+func (h *ToolHandler) ReadResource(uri string) (*ResourceContents, error) {
+	path, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return nil, fmt.Errorf("unsupported resource URI %q", uri)
+	}
+	dir := h.resourceDir()
+	parts := strings.Split(path, "/")
+
+	if len(parts) == 2 && parts[0] == "project" {
+		for _, f := range projectFiles {
+			if f.name != parts[1] {
+				continue
+			}
+			data, err := os.ReadFile(projectFilePath(dir, f))
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", f.name, err)
+			}
+			return &ResourceContents{URI: uri, MimeType: f.mimeType, Text: string(data)}, nil
+		}
+		return nil, fmt.Errorf("unknown project resource %q", parts[1])
+	}
+
+	if len(parts) < 3 || parts[0] != "session" {
+		return nil, fmt.Errorf("unknown resource %q", uri)
+	}
+	sessionID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session ID %q", parts[1])
+	}
+
+	s, err := openProjectStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if _, err := s.GetSession(sessionID); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(parts) == 3 && parts[2] == "journal":
+		md, err := s.ExportJournalMarkdown(sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("reading journal: %w", err)
+		}
+		return &ResourceContents{URI: uri, MimeType: "text/markdown", Text: md}, nil
+
+	case len(parts) == 4 && parts[2] == "transcript":
+		attemptID, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attempt ID %q", parts[3])
+		}
+		attempt, err := s.GetAttempt(attemptID)
+		if err != nil {
+			return nil, err
+		}
+		if attempt.SessionID != sessionID {
+			return nil, fmt.Errorf("attempt %d is not in session %d", attemptID, sessionID)
+		}
+		transcript, err := s.GetTranscript(attemptID)
+		if err != nil {
+			return nil, fmt.Errorf("reading transcript: %w", err)
+		}
+		return &ResourceContents{URI: uri, MimeType: "text/plain", Text: transcript}, nil
+
+	case len(parts) == 4 && parts[2] == "retro":
+		sprint, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid sprint number %q", parts[3])
+		}
+		reports, err := s.SprintReports(sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("reading sprint reports: %w", err)
+		}
+		for _, r := range reports {
+			if r.SprintNumber == sprint {
+				return &ResourceContents{URI: uri, MimeType: "text/markdown", Text: formatRetro(r)}, nil
+			}
+		}
+		return nil, fmt.Errorf("no retro for sprint %d in session %d", sprint, sessionID)
+	}
+	return nil, fmt.Errorf("unknown resource %q", uri)
+}
+
+// formatRetro renders a sprint report as markdown.
+func formatRetro(r *store.SprintReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Sprint %d Retrospective\n\n", r.SprintNumber)
+	fmt.Fprintf(&sb, "- Iterations: %d-%d\n", r.StartIteration, r.EndIteration)
+	fmt.Fprintf(&sb, "- Tasks: %d attempted, %d completed, %d failed\n", r.TasksAttempted, r.TasksCompleted, r.TasksFailed)
+	fmt.Fprintf(&sb, "- Velocity: %.2f\n", r.Velocity)
+	if r.QualityTrend != "" {
+		fmt.Fprintf(&sb, "- Quality trend: %s\n", r.QualityTrend)
+	}
+	fmt.Fprintf(&sb, "- Test pass rate: %.1f%%\n", r.TestPassRate*100)
+	fmt.Fprintf(&sb, "- Tokens: %d\n", r.TotalTokens)
+	fmt.Fprintf(&sb, "- Duration: %s\n", (time.Duration(r.DurationMs) * time.Millisecond).Round(time.Second))
+
+	writeJSONSection := func(heading, raw string) {
+		if raw == "" || raw == "null" || raw == "[]" {
+			return
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return
+		}
+		pretty, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Fprintf(&sb, "\n## %s\n\n```json\n%s\n```\n", heading, pretty)
+	}
+	writeJSONSection("Patterns", r.PatternsJSON)
+	writeJSONSection("Recommendations", r.RecommendationsJSON)
+	return sb.String()
+}
+
+// subscriptions polls the resources a client subscribed to and notifies it
+// when their contents change.
+type subscriptions struct {
+	mu     sync.Mutex
+	hashes map[string][sha256.Size]byte
+	stop   chan struct{}
+}
+
+// subscribe starts watching uri for c, starting the poller on first use.
+func (c *client) subscribe(h *ToolHandler, uri string) error {
+	contents, err := h.ReadResource(uri)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(contents.Text))
+
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+	if c.subs.hashes == nil {
+		c.subs.hashes = make(map[string][sha256.Size]byte)
+		c.subs.stop = make(chan struct{})
+		go c.pollResources(h, c.subs.stop)
+	}
+	c.subs.hashes[uri] = sum
+	return nil
+}
+
+func (c *client) unsubscribe(uri string) {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+	delete(c.subs.hashes, uri)
+}
+
+// close stops the resource poller, if running.
+func (c *client) close() {
+	c.subs.mu.Lock()
+	defer c.subs.mu.Unlock()
+	if c.subs.stop != nil {
+		close(c.subs.stop)
+		c.subs.stop = nil
+		c.subs.hashes = nil
+	}
+}
+
+func (c *client) pollResources(h *ToolHandler, stop <-chan struct{}) {
+	ticker := time.NewTicker(resourcePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.checkResources(h)
+		}
+	}
+}
+
+// checkResources re-reads every subscribed resource and sends
+// notifications/resources/updated for those whose contents changed.
+func (c *client) checkResources(h *ToolHandler) {
+	c.subs.mu.Lock()
+	uris := make([]string, 0, len(c.subs.hashes))
+	for uri := range c.subs.hashes {
+		uris = append(uris, uri)
+	}
+	c.subs.mu.Unlock()
+
+	for _, uri := range uris {
+		contents, err := h.ReadResource(uri)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256([]byte(contents.Text))
+
+		c.subs.mu.Lock()
+		old, ok := c.subs.hashes[uri]
+		changed := ok && old != sum
+		if changed {
+			c.subs.hashes[uri] = sum
+		}
+		c.subs.mu.Unlock()
+
+		if changed {
+			c.notify("notifications/resources/updated", map[string]interface{}{"uri": uri})
+		}
+	}
+}
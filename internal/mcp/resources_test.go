@@ -0,0 +1,326 @@
+package mcp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+// seedProject creates a project directory with a PRD, progress log and a
+// store holding one session with a failed task, its attempt and a retro.
+func seedProject(t *testing.T) (dir string, sessionID, attemptID int64) {
+	t.Helper()
+	dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "prd.json"), []byte(`{"name":"demo","tasks":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "progress.txt"), []byte("iteration 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".agentbox"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.Open(filepath.Join(dir, ".agentbox", "agentbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	sessionID, err = s.CreateSession("", "main", "{}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Add login", Status: "failed", MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	fail := false
+	attemptID, err = s.RecordAttempt(&store.Attempt{TaskID: "t-1", SessionID: sessionID, Number: 1, AgentName: "claude", Success: &fail, ErrorMsg: "tests failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveTranscript(attemptID, "agent output here"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddJournalEntry(&store.JournalEntry{SessionID: sessionID, Kind: "reflection", Iteration: 1, Summary: "Login is harder than expected"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSprintReport(&store.SprintReport{SessionID: sessionID, SprintNumber: 1, StartIteration: 1, EndIteration: 3, TasksAttempted: 1, TasksFailed: 1, QualityTrend: "stable"}); err != nil {
+		t.Fatal(err)
+	}
+	return dir, sessionID, attemptID
+}
+
+func TestListResources(t *testing.T) {
+	dir, sessionID, attemptID := seedProject(t)
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	resources, err := h.ListResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uris := make(map[string]bool)
+	for _, r := range resources {
+		uris[r.URI] = true
+	}
+	for _, want := range []string{
+		"agentbox://project/prd.json",
+		"agentbox://project/progress.txt",
+		fmt.Sprintf("agentbox://session/%d/journal", sessionID),
+		fmt.Sprintf("agentbox://session/%d/transcript/%d", sessionID, attemptID),
+		fmt.Sprintf("agentbox://session/%d/retro/1", sessionID),
+	} {
+		if !uris[want] {
+			t.Errorf("missing resource %s in %v", want, uris)
+		}
+	}
+}
+
+func TestListResources_NoStore(t *testing.T) {
+	dir := t.TempDir()
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	resources, err := h.ListResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 0 {
+		t.Errorf("resources = %v, want none", resources)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".agentbox")); !os.IsNotExist(err) {
+		t.Error("listing resources should not create a store")
+	}
+}
+
+func TestReadResource_ConfiguredPRDFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "agentbox.yaml"), []byte("ralph:\n  prd_file: tasks/feature.json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "tasks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tasks", "feature.json"), []byte(`{"name":"configured"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	resources, err := h.ListResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 1 || resources[0].URI != "agentbox://project/prd.json" {
+		t.Errorf("resources = %v, want the configured PRD", resources)
+	}
+	got, err := h.ReadResource("agentbox://project/prd.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got.Text, `"name":"configured"`) {
+		t.Errorf("prd.json resource = %q, want the configured file", got.Text)
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	dir, sessionID, attemptID := seedProject(t)
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	tests := []struct {
+		uri      string
+		mimeType string
+		contains string
+	}{
+		{"agentbox://project/prd.json", "application/json", `"name":"demo"`},
+		{"agentbox://project/progress.txt", "text/plain", "iteration 1"},
+		{fmt.Sprintf("agentbox://session/%d/journal", sessionID), "text/markdown", "Login is harder than expected"},
+		{fmt.Sprintf("agentbox://session/%d/transcript/%d", sessionID, attemptID), "text/plain", "agent output here"},
+		{fmt.Sprintf("agentbox://session/%d/retro/1", sessionID), "text/markdown", "# Sprint 1 Retrospective"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := h.ReadResource(tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.URI != tt.uri || got.MimeType != tt.mimeType {
+				t.Errorf("got uri=%s mime=%s, want %s %s", got.URI, got.MimeType, tt.uri, tt.mimeType)
+			}
+			if !strings.Contains(got.Text, tt.contains) {
+				t.Errorf("text = %q, want it to contain %q", got.Text, tt.contains)
+			}
+		})
+	}
+}
+
+func TestReadResource_Errors(t *testing.T) {
+	dir, sessionID, attemptID := seedProject(t)
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	for _, uri := range []string{
+		"file:///etc/passwd",
+		"agentbox://project/../secret",
+		"agentbox://session/abc/journal",
+		"agentbox://session/999/journal",
+		fmt.Sprintf("agentbox://session/%d/transcript/999", sessionID),
+		fmt.Sprintf("agentbox://session/%d/retro/7", sessionID),
+		fmt.Sprintf("agentbox://session/%d/unknown", sessionID),
+	} {
+		if _, err := h.ReadResource(uri); err == nil {
+			t.Errorf("ReadResource(%q) succeeded, want error", uri)
+		}
+	}
+
+	// An attempt must belong to the session named in the URI.
+	s, err := store.Open(filepath.Join(dir, ".agentbox", "agentbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.CreateSession("", "main", "{}")
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.ReadResource(fmt.Sprintf("agentbox://session/%d/transcript/%d", other, attemptID)); err == nil {
+		t.Error("reading another session's transcript succeeded, want error")
+	}
+}
+
+func TestSubscribeNotifiesOnChange(t *testing.T) {
+	dir, _, _ := seedProject(t)
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+
+	var sent []JSONRPCNotification
+	c := newClient(func(msg interface{}) { sent = append(sent, msg.(JSONRPCNotification)) })
+	defer c.close()
+
+	const uri = "agentbox://project/progress.txt"
+	if err := c.subscribe(h, uri); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.subscribe(h, "agentbox://project/missing.txt"); err == nil {
+		t.Error("subscribing to an unknown resource succeeded, want error")
+	}
+
+	c.checkResources(h)
+	if len(sent) != 0 {
+		t.Fatalf("got %d notifications for unchanged resource", len(sent))
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "progress.txt"), []byte("iteration 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.checkResources(h)
+	if len(sent) != 1 || sent[0].Method != "notifications/resources/updated" {
+		t.Fatalf("notifications = %+v, want one resources/updated", sent)
+	}
+	if params, _ := json.Marshal(sent[0].Params); !strings.Contains(string(params), uri) {
+		t.Errorf("params = %s, want uri %s", params, uri)
+	}
+
+	c.unsubscribe(uri)
+	if err := os.WriteFile(filepath.Join(dir, "progress.txt"), []byte("iteration 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.checkResources(h)
+	if len(sent) != 1 {
+		t.Errorf("got %d notifications after unsubscribe, want 1", len(sent))
+	}
+}
+
+func TestServerResourcesAndPrompts(t *testing.T) {
+	dir, _, _ := seedProject(t)
+	input := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"resources/list"}` + "\n" +
+		`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"agentbox://project/prd.json"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":4,"method":"resources/templates/list"}` + "\n" +
+		`{"jsonrpc":"2.0","id":5,"method":"prompts/list"}` + "\n" +
+		`{"jsonrpc":"2.0","id":6,"method":"prompts/get","params":{"name":"write_prd","arguments":{"feature":"dark mode"}}}` + "\n" +
+		`{"jsonrpc":"2.0","id":7,"method":"resources/read","params":{"uri":"agentbox://nope"}}` + "\n"
+
+	var out strings.Builder
+	srv := NewServer(strings.NewReader(input), &out, nil)
+	srv.SetProjectDir(dir)
+	if err := srv.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("got %d responses, want 7:\n%s", len(lines), out.String())
+	}
+	var resps []map[string]interface{}
+	for _, line := range lines {
+		var r map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		resps = append(resps, r)
+	}
+
+	caps := resps[0]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	if res, ok := caps["resources"].(map[string]interface{}); !ok || res["subscribe"] != true {
+		t.Errorf("resources capability = %v, want subscribe", caps["resources"])
+	}
+	if _, ok := caps["prompts"]; !ok {
+		t.Error("missing prompts capability")
+	}
+	if list := resps[1]["result"].(map[string]interface{})["resources"].([]interface{}); len(list) < 5 {
+		t.Errorf("resources/list returned %d resources, want at least 5", len(list))
+	}
+	contents := resps[2]["result"].(map[string]interface{})["contents"].([]interface{})
+	if text := contents[0].(map[string]interface{})["text"].(string); !strings.Contains(text, "demo") {
+		t.Errorf("prd.json contents = %q", text)
+	}
+	if tmpl := resps[3]["result"].(map[string]interface{})["resourceTemplates"].([]interface{}); len(tmpl) != 3 {
+		t.Errorf("got %d resource templates, want 3", len(tmpl))
+	}
+	if prompts := resps[4]["result"].(map[string]interface{})["prompts"].([]interface{}); len(prompts) != 2 {
+		t.Errorf("got %d prompts, want 2", len(prompts))
+	}
+	msgs := resps[5]["result"].(map[string]interface{})["messages"].([]interface{})
+	if len(msgs) != 1 || !strings.Contains(fmt.Sprint(msgs[0]), "dark mode") {
+		t.Errorf("write_prd messages = %v", msgs)
+	}
+	if resps[6]["error"] == nil {
+		t.Error("reading an unknown resource should return an error")
+	}
+}
+
+func TestReadResource_OldSchemaNotMigrated(t *testing.T) {
+	dir, sessionID, _ := seedProject(t)
+	dbPath := filepath.Join(dir, ".agentbox", "agentbox.db")
+	// Pretend the store predates the latest migration.
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("DELETE FROM schema_version WHERE version = (SELECT MAX(version) FROM schema_version)")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewToolHandler(nil)
+	h.SetProjectDir(dir)
+	_, err = h.ReadResource(fmt.Sprintf("agentbox://session/%d/journal", sessionID))
+	if err == nil || !strings.Contains(err.Error(), "agentbox db migrate") {
+		t.Errorf("ReadResource on an old store = %v, want a migrate error", err)
+	}
+	if st, err := store.InspectSchema(dbPath); err != nil || st.Version != store.LatestSchemaVersion()-1 {
+		t.Errorf("schema after reading = %+v, %v; want it left unmigrated", st, err)
+	}
+	if backups, _ := filepath.Glob(dbPath + ".v*.bak"); len(backups) != 0 {
+		t.Errorf("reading a resource backed up the store: %v", backups)
+	}
+}
@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+// --- agentbox_search ---
+
+type searchArgs struct {
+	Query      string   `json:"query"`
+	Session    int64    `json:"session,omitempty"`
+	TaskID     string   `json:"task_id,omitempty"`
+	Kinds      []string `json:"kinds,omitempty"`
+	Limit      int      `json:"limit,omitempty"`
+	ProjectDir string   `json:"project_dir,omitempty"`
+}
+
+// searchHit is a store search result plus the resource URI to read the
+// full transcript, when there is one.
+type searchHit struct {
+	*store.SearchResult
+	URI string `json:"uri,omitempty"`
+}
+
+func (h *ToolHandler) handleSearch(argsJSON json.RawMessage) *ToolCallResult {
+	var args searchArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+	if args.Query == "" {
+		return textError("query is required")
+	}
+
+	projectDir := args.ProjectDir
+	if projectDir == "" {
+		projectDir = h.resourceDir()
+	}
+	s, err := openProjectStore(projectDir)
+	if err != nil {
+		return textError(err.Error())
+	}
+	defer s.Close()
+
+	results, err := s.Search(store.SearchQuery{
+		Text:      args.Query,
+		SessionID: args.Session,
+		TaskID:    args.TaskID,
+		Kinds:     args.Kinds,
+		Limit:     args.Limit,
+	})
+	if err != nil {
+		return textError(err.Error())
+	}
+
+	hits := make([]searchHit, len(results))
+	for i, r := range results {
+		hits[i] = searchHit{SearchResult: r}
+		if r.Kind == "transcript" {
+			hits[i].URI = fmt.Sprintf("%ssession/%d/transcript/%d", resourceScheme, r.SessionID, r.AttemptID)
+		}
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"query":   args.Query,
+		"count":   len(hits),
+		"results": hits,
+	}, "", "  ")
+	if err != nil {
+		return textError(fmt.Sprintf("marshaling results: %v", err))
+	}
+	return textResult(string(data))
+}
@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestHandleSearch(t *testing.T) {
+	dir, sessionID, attemptID := seedProject(t)
+	h := NewToolHandler(nil)
+
+	args, _ := json.Marshal(map[string]interface{}{"query": "agent output", "project_dir": dir})
+	result := h.Call("agentbox_search", args)
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.Content[0].Text)
+	}
+	var resp struct {
+		Count   int `json:"count"`
+		Results []struct {
+			Kind      string `json:"kind"`
+			SessionID int64  `json:"session_id"`
+			TaskID    string `json:"task_id"`
+			AttemptID int64  `json:"attempt_id"`
+			Snippet   string `json:"snippet"`
+			URI       string `json:"uri"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 {
+		t.Fatalf("count = %d, want 1: %s", resp.Count, result.Content[0].Text)
+	}
+	hit := resp.Results[0]
+	wantURI := fmt.Sprintf("agentbox://session/%d/transcript/%d", sessionID, attemptID)
+	if hit.Kind != "transcript" || hit.TaskID != "t-1" || hit.AttemptID != attemptID || hit.URI != wantURI {
+		t.Errorf("hit = %+v, want transcript of attempt %d at %s", hit, attemptID, wantURI)
+	}
+
+	// The server's project directory is the default.
+	h.SetProjectDir(dir)
+	args, _ = json.Marshal(map[string]interface{}{"query": "login", "kinds": []string{"journal"}})
+	result = h.Call("agentbox_search", args)
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.Content[0].Text)
+	}
+	resp.Results = nil
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 || resp.Results[0].Kind != "journal" || resp.Results[0].URI != "" {
+		t.Errorf("journal search = %+v", resp.Results)
+	}
+
+	if result := h.Call("agentbox_search", json.RawMessage(`{}`)); !result.IsError {
+		t.Error("search without a query succeeded, want error")
+	}
+}
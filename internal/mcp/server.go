@@ -31,6 +31,14 @@ type JSONRPCResponse struct {
 	Error   *JSONRPCError   `json:"error,omitempty"`
 }
 
+// JSONRPCNotification represents an outgoing JSON-RPC 2.0 notification,
+// which has no ID and expects no response.
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // JSONRPCError represents a JSON-RPC 2.0 error.
 type JSONRPCError struct {
 	Code    int         `json:"code"`
@@ -57,13 +65,19 @@ type ContentBlock struct {
 	Text string `json:"text,omitempty"`
 }
 
-// Server is the MCP JSON-RPC server.
+// Server is the MCP JSON-RPC server for the stdio transport.
 type Server struct {
 	scanner *bufio.Scanner
 	writer  io.Writer
 	logger  *slog.Logger
 	mu      sync.Mutex
 	handler *ToolHandler
+	client  *client
+}
+
+// SetProjectDir sets the project directory resources and prompts read from.
+func (s *Server) SetProjectDir(dir string) {
+	s.handler.SetProjectDir(dir)
 }
 
 // NewServer creates a new MCP server reading from r and writing to w.
@@ -75,17 +89,22 @@ func NewServer(r io.Reader, w io.Writer, logger *slog.Logger) *Server {
 	scanner := bufio.NewScanner(r)
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, maxMessageSize)
-	return &Server{
+	srv := &Server{
 		scanner: scanner,
 		writer:  w,
 		logger:  logger,
 		handler: NewToolHandler(logger),
 	}
+	// Async sessions report progress from their own goroutines; writes are
+	// serialized by s.mu.
+	srv.client = newClient(srv.writeMessage)
+	return srv
 }
 
 // Run starts the server message loop, processing requests until EOF or error.
 func (s *Server) Run() error {
 	s.logger.Info("agentbox MCP server starting")
+	defer s.client.close()
 	for {
 		err := s.processOne()
 		if err == io.EOF {
@@ -112,96 +131,190 @@ func (s *Server) processOne() error {
 	var req JSONRPCRequest
 	if err := json.Unmarshal(line, &req); err != nil {
 		// If we can't parse the request, send a parse error
-		s.sendError(nil, -32700, "Parse error")
+		s.writeMessage(errorResponse(nil, -32700, "Parse error"))
 		return nil
 	}
 
 	// If ID is nil/missing, this is a notification — no response needed.
-	if len(req.ID) == 0 || string(req.ID) == "null" {
+	if req.isNotification() {
 		s.logger.Debug("received notification", "method", req.Method)
 		return nil
 	}
 
+	s.writeMessage(dispatch(s.handler, s.client, req))
+	return nil
+}
+
+// isNotification reports whether the message has no ID and so expects no
+// response.
+func (req JSONRPCRequest) isNotification() bool {
+	return len(req.ID) == 0 || string(req.ID) == "null"
+}
+
+// dispatch handles a JSON-RPC request from c and returns the response. It is
+// shared by the stdio and HTTP transports.
+func dispatch(h *ToolHandler, c *client, req JSONRPCRequest) JSONRPCResponse {
 	switch req.Method {
 	case "initialize":
-		s.handleInitialize(req)
+		return handleInitialize(req)
 	case "tools/list":
-		s.handleToolsList(req)
+		return resultResponse(req.ID, map[string]interface{}{"tools": AllTools()})
 	case "tools/call":
-		s.handleToolsCall(req)
+		return handleToolsCall(h, c, req)
+	case "logging/setLevel":
+		return handleSetLevel(c, req)
+	case "resources/list":
+		return handleResourcesList(h, req)
+	case "resources/templates/list":
+		return resultResponse(req.ID, map[string]interface{}{"resourceTemplates": ResourceTemplates()})
+	case "resources/read":
+		return handleResourcesRead(h, req)
+	case "resources/subscribe", "resources/unsubscribe":
+		return handleSubscribe(h, c, req)
+	case "prompts/list":
+		return resultResponse(req.ID, map[string]interface{}{"prompts": AllPrompts()})
+	case "prompts/get":
+		return handlePromptsGet(h, req)
 	case "ping":
-		s.sendResult(req.ID, map[string]interface{}{})
+		return resultResponse(req.ID, map[string]interface{}{})
 	default:
-		s.sendError(req.ID, -32601, fmt.Sprintf("Method not found: %s", req.Method))
+		return errorResponse(req.ID, -32601, fmt.Sprintf("Method not found: %s", req.Method))
 	}
-
-	return nil
 }
 
 // handleInitialize responds to the initialize handshake.
-func (s *Server) handleInitialize(req JSONRPCRequest) {
+func handleInitialize(req JSONRPCRequest) JSONRPCResponse {
 	result := map[string]interface{}{
 		"protocolVersion": "2024-11-05",
 		"capabilities": map[string]interface{}{
-			"tools": map[string]interface{}{},
+			"tools":     map[string]interface{}{},
+			"logging":   map[string]interface{}{},
+			"resources": map[string]interface{}{"subscribe": true},
+			"prompts":   map[string]interface{}{},
 		},
 		"serverInfo": map[string]interface{}{
 			"name":    "agentbox",
 			"version": "0.1.0",
 		},
 	}
-	s.sendResult(req.ID, result)
-}
-
-// handleToolsList returns all available tool definitions.
-func (s *Server) handleToolsList(req JSONRPCRequest) {
-	tools := AllTools()
-	result := map[string]interface{}{
-		"tools": tools,
-	}
-	s.sendResult(req.ID, result)
+	return resultResponse(req.ID, result)
 }
 
 // handleToolsCall dispatches a tool call to the appropriate handler.
-func (s *Server) handleToolsCall(req JSONRPCRequest) {
+func handleToolsCall(h *ToolHandler, c *client, req JSONRPCRequest) JSONRPCResponse {
 	var params struct {
 		Name      string          `json:"name"`
 		Arguments json.RawMessage `json:"arguments"`
 	}
 	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendError(req.ID, -32602, "Invalid params")
-		return
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+
+	result := h.CallFrom(params.Name, params.Arguments, Caller{
+		ProgressToken: progressToken(req.Params),
+		Notify:        c.notify,
+	})
+	return resultResponse(req.ID, result)
+}
+
+// handleSetLevel sets the minimum level of log notifications sent to c.
+func handleSetLevel(c *client, req JSONRPCRequest) JSONRPCResponse {
+	var params struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+	if err := c.setLogLevel(params.Level); err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+	return resultResponse(req.ID, map[string]interface{}{})
+}
+
+// handleResourcesList lists the project's readable resources.
+func handleResourcesList(h *ToolHandler, req JSONRPCRequest) JSONRPCResponse {
+	resources, err := h.ListResources()
+	if err != nil {
+		return errorResponse(req.ID, -32603, err.Error())
+	}
+	if resources == nil {
+		resources = []Resource{}
 	}
+	return resultResponse(req.ID, map[string]interface{}{"resources": resources})
+}
 
-	result := s.handler.Call(params.Name, params.Arguments)
-	s.sendResult(req.ID, result)
+// handleResourcesRead returns the contents of one resource.
+func handleResourcesRead(h *ToolHandler, req JSONRPCRequest) JSONRPCResponse {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+	contents, err := h.ReadResource(params.URI)
+	if err != nil {
+		return errorResponse(req.ID, -32002, err.Error())
+	}
+	return resultResponse(req.ID, map[string]interface{}{"contents": []*ResourceContents{contents}})
+}
+
+// handleSubscribe starts or stops change notifications for a resource.
+func handleSubscribe(h *ToolHandler, c *client, req JSONRPCRequest) JSONRPCResponse {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+	if req.Method == "resources/unsubscribe" {
+		c.unsubscribe(params.URI)
+		return resultResponse(req.ID, map[string]interface{}{})
+	}
+	if err := c.subscribe(h, params.URI); err != nil {
+		return errorResponse(req.ID, -32002, err.Error())
+	}
+	return resultResponse(req.ID, map[string]interface{}{})
+}
+
+// handlePromptsGet renders a prompt template.
+func handlePromptsGet(h *ToolHandler, req JSONRPCRequest) JSONRPCResponse {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+	result, err := h.GetPrompt(params.Name, params.Arguments)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+	return resultResponse(req.ID, result)
 }
 
-// sendResult sends a successful JSON-RPC response.
-func (s *Server) sendResult(id json.RawMessage, result interface{}) {
-	resp := JSONRPCResponse{
+// resultResponse builds a successful JSON-RPC response.
+func resultResponse(id json.RawMessage, result interface{}) JSONRPCResponse {
+	return JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Result:  result,
 	}
-	s.writeResponse(resp)
 }
 
-// sendError sends a JSON-RPC error response.
-func (s *Server) sendError(id json.RawMessage, code int, message string) {
-	resp := JSONRPCResponse{
+// errorResponse builds a JSON-RPC error response.
+func errorResponse(id json.RawMessage, code int, message string) JSONRPCResponse {
+	return JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Error:   &JSONRPCError{Code: code, Message: message},
 	}
-	s.writeResponse(resp)
 }
 
-func (s *Server) writeResponse(resp JSONRPCResponse) {
+func (s *Server) writeMessage(msg interface{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	data, err := json.Marshal(resp)
+	data, err := json.Marshal(msg)
 	if err != nil {
 		s.logger.Error("failed to marshal response", "error", err)
 		return
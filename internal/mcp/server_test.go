@@ -189,6 +189,14 @@ func TestServerToolsList(t *testing.T) {
 		"agentbox_journal",
 		"agentbox_task_list",
 		"agentbox_sprint_status",
+		"agentbox_cancel",
+		"agentbox_pause",
+		"agentbox_resume",
+		"agentbox_task_add",
+		"agentbox_task_update",
+		"agentbox_task_split",
+		"agentbox_task_depend",
+		"agentbox_search",
 	}
 
 	if len(listResult.Tools) != len(expectedTools) {
@@ -11,7 +11,7 @@ import (
 // cross-process communication between the MCP server and the wait command.
 type SessionState struct {
 	SessionID string `json:"session_id"`
-	Status    string `json:"status"` // "running", "completed", "failed"
+	Status    string `json:"status"` // "running", "paused", "cancelling", "cancelled", "completed", "failed"
 	Error     string `json:"error,omitempty"`
 }
 
@@ -0,0 +1,250 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/swamp-dev/agentbox/internal/supervisor"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+)
+
+// taskTarget selects the session whose tasks a task tool changes.
+type taskTarget struct {
+	SessionID  string `json:"session_id,omitempty"`
+	ProjectDir string `json:"project_dir,omitempty"`
+}
+
+// editTasks runs edit against the target session's tasks. A sprint running
+// in this server is edited live, so its next iteration sees the change;
+// otherwise the change is written to the project store and picked up by
+// `agentbox sprint --resume`.
+func (h *ToolHandler) editTasks(target taskTarget, edit func(*supervisor.TaskEditor) (interface{}, error)) *ToolCallResult {
+	if e, ok := h.liveTaskEditor(target.SessionID); ok {
+		result, err := edit(e)
+		if err == nil {
+			return taskResult("running session", result)
+		}
+		if !errors.Is(err, supervisor.ErrSessionEnded) {
+			return textError(err.Error())
+		}
+		// The sprint finished between lookup and edit; its store is closed,
+		// so fall back to editing the persisted rows.
+	}
+
+	projectDir := target.ProjectDir
+	var storeSessionID int64
+	h.mu.Lock()
+	sess, known := h.sessions[target.SessionID]
+	if known && projectDir == "" {
+		projectDir = sess.ProjectDir
+	}
+	h.mu.Unlock()
+	if !known && target.SessionID != "" {
+		id, err := strconv.ParseInt(target.SessionID, 10, 64)
+		if err != nil {
+			return textError(fmt.Sprintf("unknown session %s", target.SessionID))
+		}
+		storeSessionID = id
+	}
+	if projectDir == "" {
+		projectDir = "."
+	}
+
+	s, err := openWritableProjectStore(projectDir)
+	if err != nil {
+		return textError(err.Error())
+	}
+	defer s.Close()
+
+	if storeSessionID == 0 {
+		latest, err := s.LatestSession()
+		if err != nil {
+			return textError("no sessions found in store")
+		}
+		storeSessionID = latest.ID
+	} else if _, err := s.GetSession(storeSessionID); err != nil {
+		return textError(err.Error())
+	}
+
+	e, err := supervisor.NewTaskEditor(s, storeSessionID)
+	if err != nil {
+		return textError(fmt.Sprintf("loading tasks: %v", err))
+	}
+	result, err := edit(e)
+	if err != nil {
+		return textError(err.Error())
+	}
+	return taskResult(fmt.Sprintf("store session %d", storeSessionID), result)
+}
+
+// liveTaskEditor returns the task editor of a sprint running in this server.
+func (h *ToolHandler) liveTaskEditor(sessionID string) (*supervisor.TaskEditor, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sess, ok := h.sessions[sessionID]
+	if !ok || sess.tasks == nil {
+		return nil, false
+	}
+	switch sess.Status {
+	case "completed", "failed", "cancelled":
+		return nil, false
+	}
+	return sess.tasks, true
+}
+
+func taskResult(appliedTo string, result interface{}) *ToolCallResult {
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"applied_to": appliedTo,
+		"result":     result,
+	}, "", "  ")
+	if err != nil {
+		return textError(fmt.Sprintf("marshaling result: %v", err))
+	}
+	return textResult(string(data))
+}
+
+// --- agentbox_task_add ---
+
+type taskSpec struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description,omitempty"`
+	Priority     int      `json:"priority,omitempty"`
+	Complexity   int      `json:"complexity,omitempty"`
+	DependsOn    []string `json:"depends_on,omitempty"`
+	ContextNotes string   `json:"context_notes,omitempty"`
+}
+
+func (t taskSpec) task() *taskdb.Task {
+	return &taskdb.Task{
+		ID:           t.ID,
+		Title:        t.Title,
+		Description:  t.Description,
+		Status:       taskdb.StatusPending,
+		Priority:     t.Priority,
+		Complexity:   t.Complexity,
+		DependsOn:    t.DependsOn,
+		ContextNotes: t.ContextNotes,
+	}
+}
+
+type taskAddArgs struct {
+	taskTarget
+	taskSpec
+}
+
+func (h *ToolHandler) handleTaskAdd(argsJSON json.RawMessage) *ToolCallResult {
+	var args taskAddArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+	if args.ID == "" || args.Title == "" {
+		return textError("id and title are required")
+	}
+
+	return h.editTasks(args.taskTarget, func(e *supervisor.TaskEditor) (interface{}, error) {
+		task := args.task()
+		if err := e.Add(task); err != nil {
+			return nil, err
+		}
+		return task, nil
+	})
+}
+
+// --- agentbox_task_update ---
+
+type taskUpdateArgs struct {
+	taskTarget
+	TaskID       string  `json:"task_id"`
+	Title        *string `json:"title,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	Status       *string `json:"status,omitempty"`
+	Priority     *int    `json:"priority,omitempty"`
+	ContextNotes *string `json:"context_notes,omitempty"`
+}
+
+func (h *ToolHandler) handleTaskUpdate(argsJSON json.RawMessage) *ToolCallResult {
+	var args taskUpdateArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+	if args.TaskID == "" {
+		return textError("task_id is required")
+	}
+	if args.Title == nil && args.Description == nil && args.Status == nil && args.Priority == nil && args.ContextNotes == nil {
+		return textError("nothing to update: set title, description, status, priority or context_notes")
+	}
+
+	return h.editTasks(args.taskTarget, func(e *supervisor.TaskEditor) (interface{}, error) {
+		return e.Update(args.TaskID, supervisor.TaskUpdate{
+			Title:        args.Title,
+			Description:  args.Description,
+			Status:       args.Status,
+			Priority:     args.Priority,
+			ContextNotes: args.ContextNotes,
+		})
+	})
+}
+
+// --- agentbox_task_split ---
+
+type taskSplitArgs struct {
+	taskTarget
+	TaskID   string     `json:"task_id"`
+	Subtasks []taskSpec `json:"subtasks"`
+}
+
+func (h *ToolHandler) handleTaskSplit(argsJSON json.RawMessage) *ToolCallResult {
+	var args taskSplitArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+	if args.TaskID == "" || len(args.Subtasks) == 0 {
+		return textError("task_id and at least one subtask are required")
+	}
+
+	return h.editTasks(args.taskTarget, func(e *supervisor.TaskEditor) (interface{}, error) {
+		subtasks := make([]*taskdb.Task, len(args.Subtasks))
+		for i, spec := range args.Subtasks {
+			subtasks[i] = spec.task()
+		}
+		ids, err := e.Split(args.TaskID, subtasks)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"deferred": args.TaskID,
+			"subtasks": ids,
+		}, nil
+	})
+}
+
+// --- agentbox_task_depend ---
+
+type taskDependArgs struct {
+	taskTarget
+	TaskID    string `json:"task_id"`
+	DependsOn string `json:"depends_on"`
+}
+
+func (h *ToolHandler) handleTaskDepend(argsJSON json.RawMessage) *ToolCallResult {
+	var args taskDependArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return textError(fmt.Sprintf("invalid arguments: %v", err))
+	}
+	if args.TaskID == "" || args.DependsOn == "" {
+		return textError("task_id and depends_on are required")
+	}
+
+	return h.editTasks(args.taskTarget, func(e *supervisor.TaskEditor) (interface{}, error) {
+		if err := e.Depend(args.TaskID, args.DependsOn); err != nil {
+			return nil, err
+		}
+		return map[string]string{
+			"task_id":    args.TaskID,
+			"depends_on": args.DependsOn,
+		}, nil
+	})
+}
@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/supervisor"
+)
+
+func openSeededStore(t *testing.T, dir string) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(dir, ".agentbox", "agentbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestTaskTools_Store(t *testing.T) {
+	dir, sessionID, _ := seedProject(t)
+	h := NewToolHandler(nil)
+
+	call := func(name, args string) *ToolCallResult {
+		t.Helper()
+		return h.Call(name, json.RawMessage(args))
+	}
+
+	res := call("agentbox_task_add", fmt.Sprintf(`{"project_dir":%q,"id":"t-2","title":"Add logout","depends_on":["t-1"]}`, dir))
+	if res.IsError {
+		t.Fatalf("task_add: %s", res.Content[0].Text)
+	}
+	if !strings.Contains(res.Content[0].Text, fmt.Sprintf("store session %d", sessionID)) {
+		t.Errorf("task_add result = %s, want store session", res.Content[0].Text)
+	}
+
+	res = call("agentbox_task_update", fmt.Sprintf(`{"project_dir":%q,"session_id":"%d","task_id":"t-1","status":"pending","priority":5}`, dir, sessionID))
+	if res.IsError {
+		t.Fatalf("task_update: %s", res.Content[0].Text)
+	}
+
+	res = call("agentbox_task_split", fmt.Sprintf(`{"project_dir":%q,"task_id":"t-1","subtasks":[{"id":"t-1a","title":"Login form"},{"id":"t-1b","title":"Login API"}]}`, dir))
+	if res.IsError {
+		t.Fatalf("task_split: %s", res.Content[0].Text)
+	}
+
+	res = call("agentbox_task_depend", fmt.Sprintf(`{"project_dir":%q,"task_id":"t-1b","depends_on":"t-2"}`, dir))
+	if !res.IsError {
+		t.Errorf("cyclic task_depend succeeded: %s", res.Content[0].Text)
+	}
+
+	s := openSeededStore(t, dir)
+	parent, err := s.GetTask("t-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.Status != "deferred" || parent.Priority != 5 {
+		t.Errorf("t-1 = status %s priority %d, want deferred 5", parent.Status, parent.Priority)
+	}
+	deps, err := s.GetDependencies("t-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 1 || deps[0] != "t-1b" {
+		t.Errorf("t-2 depends on %v, want [t-1b]", deps)
+	}
+}
+
+func TestTaskTools_Validation(t *testing.T) {
+	dir, _, _ := seedProject(t)
+	h := NewToolHandler(nil)
+
+	for name, args := range map[string]string{
+		"agentbox_task_add":    `{"title":"no id"}`,
+		"agentbox_task_update": `{"task_id":"t-1"}`,
+		"agentbox_task_split":  `{"task_id":"t-1","subtasks":[]}`,
+		"agentbox_task_depend": `{"task_id":"t-1"}`,
+	} {
+		if res := h.Call(name, json.RawMessage(args)); !res.IsError {
+			t.Errorf("%s(%s) succeeded, want error", name, args)
+		}
+	}
+
+	res := h.Call("agentbox_task_update", json.RawMessage(fmt.Sprintf(`{"project_dir":%q,"session_id":"not-a-session","task_id":"t-1","priority":1}`, dir)))
+	if !res.IsError {
+		t.Error("unknown session succeeded, want error")
+	}
+	res = h.Call("agentbox_task_update", json.RawMessage(fmt.Sprintf(`{"project_dir":%q,"task_id":"t-1","status":"done"}`, dir)))
+	if !res.IsError {
+		t.Error("invalid status succeeded, want error")
+	}
+}
+
+func TestTaskTools_LiveSession(t *testing.T) {
+	dir, sessionID, _ := seedProject(t)
+	s := openSeededStore(t, dir)
+	editor, err := supervisor.NewTaskEditor(s, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewToolHandler(nil)
+	sess := h.startAsyncSession(dir, Caller{})
+	defer sess.cancel()
+	h.mu.Lock()
+	sess.tasks = editor
+	h.mu.Unlock()
+
+	res := h.Call("agentbox_task_add", json.RawMessage(fmt.Sprintf(`{"session_id":%q,"id":"t-live","title":"Live task"}`, sess.ID)))
+	if res.IsError {
+		t.Fatalf("task_add: %s", res.Content[0].Text)
+	}
+	if !strings.Contains(res.Content[0].Text, "running session") {
+		t.Errorf("result = %s, want running session", res.Content[0].Text)
+	}
+	if _, err := s.GetTask("t-live"); err != nil {
+		t.Errorf("live task not persisted: %v", err)
+	}
+
+	// Once the sprint has finished, edits go to the store instead.
+	h.finishAsyncSession(sess, nil)
+	res = h.Call("agentbox_task_update", json.RawMessage(fmt.Sprintf(`{"session_id":%q,"task_id":"t-live","priority":9}`, sess.ID)))
+	if res.IsError {
+		t.Fatalf("task_update: %s", res.Content[0].Text)
+	}
+	if !strings.Contains(res.Content[0].Text, "store session") {
+		t.Errorf("result = %s, want store session", res.Content[0].Text)
+	}
+}
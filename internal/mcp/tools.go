@@ -10,12 +10,13 @@ import (
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
 
 	"github.com/swamp-dev/agentbox/internal/agent"
 	"github.com/swamp-dev/agentbox/internal/config"
 	"github.com/swamp-dev/agentbox/internal/container"
 	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/pause"
 	"github.com/swamp-dev/agentbox/internal/ralph"
 	"github.com/swamp-dev/agentbox/internal/store"
 	"github.com/swamp-dev/agentbox/internal/supervisor"
@@ -27,7 +28,7 @@ const (
 	defaultRunTimeout = 30 * time.Minute
 
 	// defaultAsyncTimeout is the maximum time async operations (ralph, sprint)
-	// can run before being cancelled.
+	// can run before being cancelled. Time spent paused does not count.
 	defaultAsyncTimeout = 4 * time.Hour
 
 	// maxSessions is the maximum number of completed/failed sessions to retain
@@ -40,12 +41,31 @@ type ToolHandler struct {
 	mu       sync.Mutex
 	sessions map[string]*asyncSession
 	logger   *slog.Logger
+
+	// projectDir is where resources and prompts read prd.json,
+	// progress.txt and the store from. Empty means the working directory.
+	projectDir string
+
+	// asyncTimeout limits how long an async session runs, not counting
+	// time paused. Zero means defaultAsyncTimeout.
+	asyncTimeout time.Duration
 }
 
 type asyncSession struct {
-	ID     string
-	Status string // "running", "completed", "failed"
-	Error  string
+	ID         string
+	ProjectDir string
+	Status     string // "running", "paused", "cancelling", "cancelled", "completed", "failed"
+	Error      string
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	gate     *pause.Gate
+	deadline *asyncDeadline
+	tasks    *supervisor.TaskEditor // set once a sprint's supervisor exists
+
+	notify        Notifier        // the client that started the session, if any
+	progressToken json.RawMessage // from the starting tool call, if any
+	progress      int             // notifications sent so far
 }
 
 // NewToolHandler creates a new tool handler with the given logger.
@@ -66,7 +86,7 @@ func (h *ToolHandler) evictSessions() {
 		return
 	}
 	for id, s := range h.sessions {
-		if s.Status == "completed" || s.Status == "failed" {
+		if s.Status == "completed" || s.Status == "failed" || s.Status == "cancelled" {
 			delete(h.sessions, id)
 		}
 		if len(h.sessions) <= maxSessions {
@@ -77,13 +97,19 @@ func (h *ToolHandler) evictSessions() {
 
 // Call dispatches a tool call by name and returns the MCP result.
 func (h *ToolHandler) Call(name string, argsJSON json.RawMessage) *ToolCallResult {
+	return h.CallFrom(name, argsJSON, Caller{})
+}
+
+// CallFrom is Call for a request from a known client. Async sessions started
+// by the call send progress and log notifications to that client.
+func (h *ToolHandler) CallFrom(name string, argsJSON json.RawMessage, caller Caller) *ToolCallResult {
 	switch name {
 	case "agentbox_run":
 		return h.handleRun(argsJSON)
 	case "agentbox_ralph_start":
-		return h.handleRalphStart(argsJSON)
+		return h.handleRalphStart(argsJSON, caller)
 	case "agentbox_sprint_start":
-		return h.handleSprintStart(argsJSON)
+		return h.handleSprintStart(argsJSON, caller)
 	case "agentbox_status":
 		return h.handleStatus(argsJSON)
 	case "agentbox_journal":
@@ -92,6 +118,22 @@ func (h *ToolHandler) Call(name string, argsJSON json.RawMessage) *ToolCallResul
 		return h.handleTaskList(argsJSON)
 	case "agentbox_sprint_status":
 		return h.handleSprintStatus(argsJSON)
+	case "agentbox_cancel":
+		return h.handleCancel(argsJSON)
+	case "agentbox_pause":
+		return h.handlePause(argsJSON)
+	case "agentbox_resume":
+		return h.handleResume(argsJSON)
+	case "agentbox_task_add":
+		return h.handleTaskAdd(argsJSON)
+	case "agentbox_task_update":
+		return h.handleTaskUpdate(argsJSON)
+	case "agentbox_task_split":
+		return h.handleTaskSplit(argsJSON)
+	case "agentbox_task_depend":
+		return h.handleTaskDepend(argsJSON)
+	case "agentbox_search":
+		return h.handleSearch(argsJSON)
 	default:
 		return textError(fmt.Sprintf("unknown tool: %s", name))
 	}
@@ -106,7 +148,10 @@ type runArgs struct {
 	Image            string   `json:"image,omitempty"`
 	Network          string   `json:"network,omitempty"`
 	AllowedEndpoints []string `json:"allowed_endpoints,omitempty"`
+	BaseURL          string   `json:"base_url,omitempty"`
 	Timeout          int      `json:"timeout,omitempty"` // timeout in minutes (default: 30)
+
+	Config map[string]interface{} `json:"config,omitempty"` // agentbox.yaml overrides
 }
 
 func (h *ToolHandler) handleRun(argsJSON json.RawMessage) *ToolCallResult {
@@ -132,17 +177,16 @@ func (h *ToolHandler) handleRun(argsJSON json.RawMessage) *ToolCallResult {
 		return textError(fmt.Sprintf("API key validation failed: %v", err))
 	}
 
-	cfg, err := config.Load("")
+	cfg, cfgFile, err := projectConfig(args.ProjectDir, args.Config)
 	if err != nil {
-		return textError(fmt.Sprintf("loading config: %v", err))
+		return textError(err.Error())
 	}
 
-	ag, err := agent.New(args.Agent)
-	if err != nil {
-		return textError(fmt.Sprintf("creating agent: %v", err))
+	cfg.Agent.Name = args.Agent
+	if args.BaseURL != "" {
+		cfg.Agent.BaseURL = args.BaseURL
 	}
 
-	cfg.Agent.Name = args.Agent
 	if args.Image != "" {
 		cfg.Docker.Image = args.Image
 	}
@@ -156,6 +200,11 @@ func (h *ToolHandler) handleRun(argsJSON json.RawMessage) *ToolCallResult {
 		cfg.Docker.Network = "restricted"
 	}
 
+	ag, err := agent.NewWithOptions(args.Agent, agent.Options{BaseURL: cfg.Agent.BaseURL, Network: cfg.Docker.Network, Model: cfg.Agent.Model})
+	if err != nil {
+		return textError(fmt.Sprintf("creating agent: %v", err))
+	}
+
 	// Set agent-default endpoints for restricted mode.
 	if cfg.Docker.Network == "restricted" && len(cfg.Docker.AllowedEndpoints) == 0 {
 		cfg.Docker.AllowedEndpoints = ag.AllowedEndpoints()
@@ -197,9 +246,11 @@ func (h *ToolHandler) handleRun(argsJSON json.RawMessage) *ToolCallResult {
 	result := ag.ParseOutput(output)
 
 	data, err := json.Marshal(map[string]interface{}{
-		"success":   result.Success,
-		"completed": result.Completed,
-		"output":    output,
+		"success":          result.Success,
+		"completed":        result.Completed,
+		"output":           output,
+		"config_file":      cfgFile,
+		"effective_config": effectiveConfig(cfg),
 	})
 	if err != nil {
 		return textError(fmt.Sprintf("marshaling result: %v", err))
@@ -214,9 +265,11 @@ type ralphStartArgs struct {
 	Agent         string `json:"agent,omitempty"`
 	PRDFile       string `json:"prd_file,omitempty"`
 	MaxIterations int    `json:"max_iterations,omitempty"`
+
+	Config map[string]interface{} `json:"config,omitempty"` // agentbox.yaml overrides
 }
 
-func (h *ToolHandler) handleRalphStart(argsJSON json.RawMessage) *ToolCallResult {
+func (h *ToolHandler) handleRalphStart(argsJSON json.RawMessage, caller Caller) *ToolCallResult {
 	var args ralphStartArgs
 	if err := json.Unmarshal(argsJSON, &args); err != nil {
 		return textError(fmt.Sprintf("invalid arguments: %v", err))
@@ -230,9 +283,9 @@ func (h *ToolHandler) handleRalphStart(argsJSON json.RawMessage) *ToolCallResult
 		return textError(err.Error())
 	}
 
-	cfg, err := config.Load("")
+	cfg, cfgFile, err := projectConfig(args.ProjectDir, args.Config)
 	if err != nil {
-		return textError(fmt.Sprintf("loading config: %v", err))
+		return textError(err.Error())
 	}
 
 	if args.Agent != "" {
@@ -244,54 +297,35 @@ func (h *ToolHandler) handleRalphStart(argsJSON json.RawMessage) *ToolCallResult
 	if args.MaxIterations > 0 {
 		cfg.Ralph.MaxIterations = args.MaxIterations
 	}
-
-	sessionID := uuid.New().String()
-	projectDir := args.ProjectDir
-
-	h.mu.Lock()
-	h.evictSessions()
-	h.sessions[sessionID] = &asyncSession{ID: sessionID, Status: "running"}
-	h.mu.Unlock()
-
-	// Write initial state file for the wait command.
-	if err := WriteSessionState(projectDir, sessionID, "running", ""); err != nil {
-		h.logger.Warn("failed to write session state", "error", err)
+	if err := cfg.Validate(); err != nil {
+		return textError(fmt.Sprintf("invalid configuration: %v", err))
 	}
 
+	sess := h.startAsyncSession(args.ProjectDir, caller)
+	sessionID := sess.ID
+	projectDir := sess.ProjectDir
+
 	go func() {
+		defer sess.cancel()
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 		loop, err := ralph.NewLoop(cfg, projectDir, logger)
 		if err != nil {
-			h.mu.Lock()
-			h.sessions[sessionID].Status = "failed"
-			h.sessions[sessionID].Error = err.Error()
-			h.mu.Unlock()
-			_ = WriteSessionState(projectDir, sessionID, "failed", err.Error())
+			h.finishAsyncSession(sess, err)
 			return
 		}
 		defer loop.Close()
 
-		ctx, cancel := context.WithTimeout(context.Background(), defaultAsyncTimeout)
-		defer cancel()
-		if err := loop.Run(ctx); err != nil {
-			h.mu.Lock()
-			h.sessions[sessionID].Status = "failed"
-			h.sessions[sessionID].Error = err.Error()
-			h.mu.Unlock()
-			_ = WriteSessionState(projectDir, sessionID, "failed", err.Error())
-			return
-		}
-
-		h.mu.Lock()
-		h.sessions[sessionID].Status = "completed"
-		h.mu.Unlock()
-		_ = WriteSessionState(projectDir, sessionID, "completed", "")
+		loop.SetPauseGate(sess.gate)
+		loop.SetEventSink(h.eventSink(sess))
+		h.finishAsyncSession(sess, loop.Run(sess.ctx))
 	}()
 
-	data, err := json.Marshal(map[string]string{
-		"session_id":   sessionID,
-		"message":      "Ralph loop started",
-		"wait_command": fmt.Sprintf("agentbox wait --session %s --project %q", sessionID, projectDir),
+	data, err := json.Marshal(map[string]interface{}{
+		"session_id":       sessionID,
+		"message":          "Ralph loop started",
+		"wait_command":     fmt.Sprintf("agentbox wait --session %s --project %q", sessionID, projectDir),
+		"config_file":      cfgFile,
+		"effective_config": effectiveConfig(cfg),
 	})
 	if err != nil {
 		return textError(fmt.Sprintf("marshaling result: %v", err))
@@ -310,9 +344,12 @@ type sprintStartArgs struct {
 	MaxSprints       int      `json:"max_sprints,omitempty"`
 	Network          string   `json:"network,omitempty"`
 	AllowedEndpoints []string `json:"allowed_endpoints,omitempty"`
+	BaseURL          string   `json:"base_url,omitempty"`
+
+	Config map[string]interface{} `json:"config,omitempty"` // agentbox.yaml overrides
 }
 
-func (h *ToolHandler) handleSprintStart(argsJSON json.RawMessage) *ToolCallResult {
+func (h *ToolHandler) handleSprintStart(argsJSON json.RawMessage, caller Caller) *ToolCallResult {
 	var args sprintStartArgs
 	if err := json.Unmarshal(argsJSON, &args); err != nil {
 		return textError(fmt.Sprintf("invalid arguments: %v", err))
@@ -324,7 +361,20 @@ func (h *ToolHandler) handleSprintStart(argsJSON json.RawMessage) *ToolCallResul
 		}
 	}
 
+	configDir := args.ProjectDir
+	if configDir == "" {
+		configDir = "."
+	}
+	projectCfg, cfgFile, err := projectConfig(configDir, args.Config)
+	if err != nil {
+		return textError(err.Error())
+	}
+	if err := projectCfg.Validate(); err != nil {
+		return textError(fmt.Sprintf("invalid configuration: %v", err))
+	}
+
 	cfg := supervisor.DefaultConfig()
+	cfg.ApplyProjectConfig(projectCfg)
 	if args.ProjectDir != "" {
 		cfg.WorkDir = args.ProjectDir
 	}
@@ -337,6 +387,9 @@ func (h *ToolHandler) handleSprintStart(argsJSON json.RawMessage) *ToolCallResul
 	if args.Agent != "" {
 		cfg.Agent = args.Agent
 	}
+	if args.BaseURL != "" {
+		cfg.AgentBaseURL = args.BaseURL
+	}
 	if args.SprintSize > 0 {
 		cfg.SprintSize = args.SprintSize
 	}
@@ -349,61 +402,46 @@ func (h *ToolHandler) handleSprintStart(argsJSON json.RawMessage) *ToolCallResul
 	if len(args.AllowedEndpoints) > 0 {
 		cfg.DockerAllowedEndpoints = args.AllowedEndpoints
 	}
+	if err := cfg.ParseBudgetDuration(); err != nil {
+		return textError(fmt.Sprintf("invalid budget duration: %v", err))
+	}
 
 	// Set agent-default endpoints for restricted mode.
 	if cfg.DockerNetwork == "restricted" && len(cfg.DockerAllowedEndpoints) == 0 {
-		ag, err := agent.New(cfg.Agent)
+		ag, err := agent.NewWithOptions(cfg.Agent, agent.Options{BaseURL: cfg.AgentBaseURL, Network: cfg.DockerNetwork, Model: cfg.AgentModel})
 		if err != nil {
 			return textError(fmt.Sprintf("creating agent for endpoint defaults: %v", err))
 		}
 		cfg.DockerAllowedEndpoints = ag.AllowedEndpoints()
 	}
 
-	sessionID := uuid.New().String()
-	projectDir := cfg.WorkDir
-
-	h.mu.Lock()
-	h.evictSessions()
-	h.sessions[sessionID] = &asyncSession{ID: sessionID, Status: "running"}
-	h.mu.Unlock()
-
-	if err := WriteSessionState(projectDir, sessionID, "running", ""); err != nil {
-		h.logger.Warn("failed to write session state", "error", err)
-	}
+	sess := h.startAsyncSession(cfg.WorkDir, caller)
+	sessionID := sess.ID
+	projectDir := sess.ProjectDir
 
 	go func() {
+		defer sess.cancel()
 		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 		sup, err := supervisor.New(cfg, logger)
 		if err != nil {
-			h.mu.Lock()
-			h.sessions[sessionID].Status = "failed"
-			h.sessions[sessionID].Error = err.Error()
-			h.mu.Unlock()
-			_ = WriteSessionState(projectDir, sessionID, "failed", err.Error())
-			return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), defaultAsyncTimeout)
-		defer cancel()
-		if err := sup.Run(ctx); err != nil {
-			h.mu.Lock()
-			h.sessions[sessionID].Status = "failed"
-			h.sessions[sessionID].Error = err.Error()
-			h.mu.Unlock()
-			_ = WriteSessionState(projectDir, sessionID, "failed", err.Error())
+			h.finishAsyncSession(sess, err)
 			return
 		}
 
+		sup.SetPauseGate(sess.gate)
+		sup.SetEventSink(h.eventSink(sess))
 		h.mu.Lock()
-		h.sessions[sessionID].Status = "completed"
+		sess.tasks = sup.Tasks()
 		h.mu.Unlock()
-		_ = WriteSessionState(projectDir, sessionID, "completed", "")
+		h.finishAsyncSession(sess, sup.Run(sess.ctx))
 	}()
 
-	data, err := json.Marshal(map[string]string{
-		"session_id":   sessionID,
-		"message":      "Sprint started",
-		"wait_command": fmt.Sprintf("agentbox wait --session %s --project %q", sessionID, projectDir),
+	data, err := json.Marshal(map[string]interface{}{
+		"session_id":       sessionID,
+		"message":          "Sprint started",
+		"wait_command":     fmt.Sprintf("agentbox wait --session %s --project %q", sessionID, projectDir),
+		"config_file":      cfgFile,
+		"effective_config": effectiveConfig(cfg),
 	})
 	if err != nil {
 		return textError(fmt.Sprintf("marshaling result: %v", err))
@@ -726,6 +764,35 @@ func validateProjectDir(path string) error {
 	return nil
 }
 
+// projectConfig loads the agentbox.yaml that applies to projectDir, walking
+// up from it like `agentbox` does from the working directory, and applies
+// the tool call's overrides. It returns the file it loaded, or "" when the
+// defaults were used.
+func projectConfig(projectDir string, overrides map[string]interface{}) (*config.Config, string, error) {
+	cfg, path, err := config.LoadForProject(projectDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading config: %w", err)
+	}
+	if err := cfg.ApplyOverrides(overrides); err != nil {
+		return nil, "", err
+	}
+	return cfg, path, nil
+}
+
+// effectiveConfig renders a config with its YAML field names, so the tool
+// response shows settings the way agentbox.yaml spells them.
+func effectiveConfig(cfg interface{}) map[string]interface{} {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
 func textResult(text string) *ToolCallResult {
 	return &ToolCallResult{
 		Content: []ContentBlock{
@@ -758,8 +825,8 @@ func AllTools() []ToolDefinition {
 					},
 					"agent": map[string]interface{}{
 						"type":        "string",
-						"description": "Agent to use (claude, claude-cli, amp, aider)",
-						"enum":        []string{"claude", "claude-cli", "amp", "aider"},
+						"description": "Agent to use (claude, claude-cli, amp, aider, or mock for scripted offline runs)",
+						"enum":        []string{"claude", "claude-cli", "amp", "aider", "mock"},
 					},
 					"prompt": map[string]interface{}{
 						"type":        "string",
@@ -780,10 +847,15 @@ func AllTools() []ToolDefinition {
 						},
 						"description": "Allowed host:port endpoints for restricted network mode",
 					},
+					"base_url": map[string]interface{}{
+						"type":        "string",
+						"description": "Alternative API endpoint for the agent (LLM gateway or local model server). Added to the restricted allowlist automatically.",
+					},
 					"timeout": map[string]interface{}{
 						"type":        "integer",
 						"description": "Timeout in minutes (default: 30, max: 240). 0 or omitted uses the default. Container is killed if exceeded.",
 					},
+					"config": configOverridesSchema,
 				},
 				"required": []string{"project_dir", "agent", "prompt"},
 			},
@@ -810,6 +882,7 @@ func AllTools() []ToolDefinition {
 						"type":        "integer",
 						"description": "Maximum number of iterations (default: 10)",
 					},
+					"config": configOverridesSchema,
 				},
 				"required": []string{"project_dir"},
 			},
@@ -855,6 +928,11 @@ func AllTools() []ToolDefinition {
 						},
 						"description": "Allowed host:port endpoints for restricted network mode",
 					},
+					"base_url": map[string]interface{}{
+						"type":        "string",
+						"description": "Alternative API endpoint for the agent (LLM gateway or local model server). Added to the restricted allowlist automatically.",
+					},
+					"config": configOverridesSchema,
 				},
 			},
 		},
@@ -932,5 +1010,175 @@ func AllTools() []ToolDefinition {
 				"required": []string{"session_id"},
 			},
 		},
+		{
+			Name:        "agentbox_cancel",
+			Description: "Cancel a ralph or sprint session started by this server. Stops the running container and cleans up its network; an interrupted sprint can be continued later with `agentbox sprint --resume`.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID to cancel (from ralph_start or sprint_start)",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+		},
+		{
+			Name:        "agentbox_pause",
+			Description: "Pause a running ralph or sprint session. The current iteration finishes first; no new iteration starts until agentbox_resume is called.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID to pause",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+		},
+		{
+			Name:        "agentbox_resume",
+			Description: "Resume a session paused with agentbox_pause.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"session_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Session ID to resume",
+					},
+				},
+				"required": []string{"session_id"},
+			},
+		},
+		{
+			Name:        "agentbox_task_add",
+			Description: "Add a pending task to a sprint session. A sprint running in this server picks it up at its next iteration; otherwise the task is added to the store for `agentbox sprint --resume`.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": withTaskTarget(map[string]interface{}{
+					"id":            map[string]interface{}{"type": "string", "description": "Unique task ID"},
+					"title":         map[string]interface{}{"type": "string", "description": "Short task title"},
+					"description":   map[string]interface{}{"type": "string", "description": "What to do and how to verify it"},
+					"priority":      map[string]interface{}{"type": "integer", "description": "Priority (lower runs first, default: 0)"},
+					"complexity":    map[string]interface{}{"type": "integer", "description": "Complexity 1-5 (default: 3)"},
+					"depends_on":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "IDs of tasks that must complete first"},
+					"context_notes": map[string]interface{}{"type": "string", "description": "Extra context passed to the agent"},
+				}),
+				"required": []string{"id", "title"},
+			},
+		},
+		{
+			Name:        "agentbox_task_update",
+			Description: "Change a task's status, priority, title, description or context notes. Only the fields given are changed.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": withTaskTarget(map[string]interface{}{
+					"task_id":       map[string]interface{}{"type": "string", "description": "Task to update"},
+					"title":         map[string]interface{}{"type": "string", "description": "New title"},
+					"description":   map[string]interface{}{"type": "string", "description": "New description"},
+					"status":        map[string]interface{}{"type": "string", "enum": []string{"pending", "in_progress", "completed", "failed", "deferred", "blocked"}, "description": "New status"},
+					"priority":      map[string]interface{}{"type": "integer", "description": "New priority (0-100, lower runs first)"},
+					"context_notes": map[string]interface{}{"type": "string", "description": "New context notes for the agent"},
+				}),
+				"required": []string{"task_id"},
+			},
+		},
+		{
+			Name:        "agentbox_task_split",
+			Description: "Split a task into subtasks. The task is deferred, the subtasks inherit its dependencies, and tasks that depended on it wait for the last subtask.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": withTaskTarget(map[string]interface{}{
+					"task_id": map[string]interface{}{"type": "string", "description": "Task to split"},
+					"subtasks": map[string]interface{}{
+						"type":        "array",
+						"description": "Subtasks in order",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"id":          map[string]interface{}{"type": "string"},
+								"title":       map[string]interface{}{"type": "string"},
+								"description": map[string]interface{}{"type": "string"},
+								"priority":    map[string]interface{}{"type": "integer"},
+								"complexity":  map[string]interface{}{"type": "integer"},
+								"depends_on":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+							},
+							"required": []string{"id", "title"},
+						},
+					},
+				}),
+				"required": []string{"task_id", "subtasks"},
+			},
+		},
+		{
+			Name:        "agentbox_task_depend",
+			Description: "Make a task wait for another task to complete. Dependencies that would create a cycle are rejected.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": withTaskTarget(map[string]interface{}{
+					"task_id":    map[string]interface{}{"type": "string", "description": "Task that should wait"},
+					"depends_on": map[string]interface{}{"type": "string", "description": "Task it should wait for"},
+				}),
+				"required": []string{"task_id", "depends_on"},
+			},
+		},
+		{
+			Name:        "agentbox_search",
+			Description: "Full-text search over stored agent transcripts, journal entries, task descriptions and review findings. Returns ranked snippets with session, task and attempt references; transcript hits include an agentbox:// URI to read the full transcript.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Words to search for; results contain all of them",
+					},
+					"session": map[string]interface{}{
+						"type":        "integer",
+						"description": "Only search this store session number (as in agentbox://session/{id})",
+					},
+					"task_id": map[string]interface{}{
+						"type":        "string",
+						"description": "Only search this task",
+					},
+					"kinds": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string", "enum": []string{"transcript", "journal", "task", "review"}},
+						"description": "Only search these kinds of record",
+					},
+					"limit": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of results (default: 20)",
+					},
+					"project_dir": map[string]interface{}{
+						"type":        "string",
+						"description": "Project directory containing the store database (default: the server's project)",
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+	}
+}
+
+// configOverridesSchema describes the config argument of the tools that run
+// agents.
+var configOverridesSchema = map[string]interface{}{
+	"type":        "object",
+	"description": "agentbox.yaml overrides, e.g. {\"docker\": {\"image\": \"go\"}}. Merged over the project's agentbox.yaml, which is found by walking up from project_dir. The effective config is returned in the response.",
+}
+
+// withTaskTarget adds the session_id and project_dir properties shared by
+// the task tools.
+func withTaskTarget(props map[string]interface{}) map[string]interface{} {
+	props["session_id"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Sprint session ID from agentbox_sprint_start, or a numeric store session ID (default: latest store session)",
+	}
+	props["project_dir"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Project directory for store lookup (default: .)",
 	}
+	return props
 }
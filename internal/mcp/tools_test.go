@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestToolDefinitions(t *testing.T) {
@@ -599,13 +602,35 @@ func TestHandleSprintStartNetworkPassthrough(t *testing.T) {
 		t.Fatalf("unexpected error: %s", result.Content[0].Text)
 	}
 
-	var resp map[string]string
+	var resp struct {
+		SessionID string `json:"session_id"`
+	}
 	if err := json.Unmarshal([]byte(result.Content[0].Text), &resp); err != nil {
 		t.Fatalf("parse response: %v", err)
 	}
-	if resp["session_id"] == "" {
-		t.Error("expected non-empty session_id")
+	if resp.SessionID == "" {
+		t.Fatal("expected non-empty session_id")
 	}
+
+	// The sprint fails quickly without a PRD; wait so it stops writing
+	// session state before the temp directory is removed.
+	waitForAsyncSession(t, h, resp.SessionID)
+}
+
+// waitForAsyncSession blocks until an async session leaves the running state.
+func waitForAsyncSession(t *testing.T, h *ToolHandler, id string) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		status := h.sessions[id].Status
+		h.mu.Unlock()
+		if status != "running" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("session %s still running", id)
 }
 
 func TestHandleRalphStartNonexistentProjectDir(t *testing.T) {
@@ -722,3 +747,59 @@ func checkRequired(t *testing.T, got, want []string) {
 		}
 	}
 }
+
+func TestHandleSprintStartUsesProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	yamlCfg := "docker:\n  image: go\nsupervisor:\n  sprint_size: 2\n  max_sprints: 3\n  max_consecutive_fails: 1\n"
+	if err := os.WriteFile(filepath.Join(root, "agentbox.yaml"), []byte(yamlCfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, "service")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewToolHandler(nil)
+	args, _ := json.Marshal(map[string]interface{}{
+		"project_dir": dir,
+		"config":      map[string]interface{}{"docker": map[string]interface{}{"resources": map[string]interface{}{"memory": "8g"}}},
+	})
+	result := h.Call("agentbox_sprint_start", args)
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.Content[0].Text)
+	}
+
+	var resp struct {
+		SessionID       string                 `json:"session_id"`
+		ConfigFile      string                 `json:"config_file"`
+		EffectiveConfig map[string]interface{} `json:"effective_config"`
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &resp); err != nil {
+		t.Fatalf("parse response: %v", err)
+	}
+	defer waitForAsyncSession(t, h, resp.SessionID)
+
+	if resp.ConfigFile != filepath.Join(root, "agentbox.yaml") {
+		t.Errorf("config_file = %q, want the parent agentbox.yaml", resp.ConfigFile)
+	}
+	eff := resp.EffectiveConfig
+	if eff["docker_image"] != "go" || eff["docker_memory"] != "8g" || eff["sprint_size"] != float64(2) {
+		t.Errorf("effective config = %v", eff)
+	}
+}
+
+func TestHandleRalphStartConfigOverrideErrors(t *testing.T) {
+	h := NewToolHandler(nil)
+	dir := t.TempDir()
+
+	for _, override := range []map[string]interface{}{
+		{"docker": map[string]interface{}{"image": "cobol"}},
+		{"no_such_section": true},
+	} {
+		args, _ := json.Marshal(map[string]interface{}{"project_dir": dir, "config": override})
+		result := h.Call("agentbox_ralph_start", args)
+		if !result.IsError {
+			t.Errorf("override %v succeeded, want error", override)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package mockagent implements a scripted stand-in for an AI coding agent.
+// It replays responses from a fixture file — file edits, shell commands and
+// output including the stop signal — so the full container, proxy, quality
+// check, commit and retro pipeline can be exercised offline without API keys.
+package mockagent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFixture is the fixture path, relative to the workspace, used when
+// none is configured.
+const DefaultFixture = ".agentbox/mock-agent.yaml"
+
+// StopSignal is printed when a response is marked complete.
+const StopSignal = "<promise>COMPLETE</promise>"
+
+// Fixture is a scripted conversation for the mock agent.
+type Fixture struct {
+	Responses []Response `yaml:"responses" json:"responses"`
+}
+
+// Response is one scripted agent turn. Each invocation replays the first
+// unused response whose Match is contained in the prompt.
+type Response struct {
+	// Match is a substring the prompt must contain. Empty matches any prompt.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+
+	// Edits are applied to the workspace before commands run.
+	Edits []Edit `yaml:"edits,omitempty" json:"edits,omitempty"`
+
+	// Commands run via sh -c in the workspace, in order. Their combined
+	// output is echoed.
+	Commands []string `yaml:"commands,omitempty" json:"commands,omitempty"`
+
+	// Output is printed after edits and commands.
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+
+	// Complete appends the stop signal to the output.
+	Complete bool `yaml:"complete,omitempty" json:"complete,omitempty"`
+
+	// ExitCode makes the agent exit with this status, simulating a crash.
+	ExitCode int `yaml:"exit_code,omitempty" json:"exit_code,omitempty"`
+}
+
+// Edit writes, appends to, or deletes a file relative to the workspace.
+type Edit struct {
+	Path    string `yaml:"path" json:"path"`
+	Content string `yaml:"content,omitempty" json:"content,omitempty"`
+	Append  bool   `yaml:"append,omitempty" json:"append,omitempty"`
+	Delete  bool   `yaml:"delete,omitempty" json:"delete,omitempty"`
+}
+
+// state records which responses have been replayed so repeated invocations
+// (one per iteration, each in a fresh container) advance through the script.
+type state struct {
+	Used []int `json:"used"`
+}
+
+// LoadFixture reads a YAML or JSON fixture file.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture: %w", err)
+	}
+	var f Fixture
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing fixture: %w", err)
+	}
+	if len(f.Responses) == 0 {
+		return nil, fmt.Errorf("fixture %s has no responses", path)
+	}
+	return &f, nil
+}
+
+// ExitError reports a scripted non-zero exit.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("mock agent exited with code %d", e.Code)
+}
+
+// Run replays the next matching response from the fixture at fixturePath
+// against workDir, writing the agent's output to w. Progress is stored in
+// a state file next to the fixture.
+func Run(w io.Writer, fixturePath, workDir, prompt string) error {
+	f, err := LoadFixture(fixturePath)
+	if err != nil {
+		return err
+	}
+
+	statePath := fixturePath + ".state"
+	st, err := loadState(statePath)
+	if err != nil {
+		return err
+	}
+
+	idx := next(f, st, prompt)
+	if idx < 0 {
+		return fmt.Errorf("no unused fixture response matches the prompt")
+	}
+	st.Used = append(st.Used, idx)
+	if err := saveState(statePath, st); err != nil {
+		return err
+	}
+
+	resp := f.Responses[idx]
+	for _, e := range resp.Edits {
+		if err := applyEdit(workDir, e); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Edited %s\n", e.Path)
+	}
+
+	for _, c := range resp.Commands {
+		cmd := exec.Command("sh", "-c", c)
+		cmd.Dir = workDir
+		out, err := cmd.CombinedOutput()
+		fmt.Fprintf(w, "$ %s\n%s", c, out)
+		if err != nil {
+			return fmt.Errorf("running %q: %w", c, err)
+		}
+	}
+
+	if resp.Output != "" {
+		fmt.Fprintln(w, strings.TrimRight(resp.Output, "\n"))
+	}
+	if resp.Complete {
+		fmt.Fprintln(w, StopSignal)
+	}
+	if resp.ExitCode != 0 {
+		return &ExitError{Code: resp.ExitCode}
+	}
+	return nil
+}
+
+// next returns the index of the first unused response matching prompt, or -1.
+func next(f *Fixture, st *state, prompt string) int {
+	used := make(map[int]bool, len(st.Used))
+	for _, i := range st.Used {
+		used[i] = true
+	}
+	for i, r := range f.Responses {
+		if !used[i] && strings.Contains(prompt, r.Match) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyEdit performs a single edit, refusing paths that escape workDir.
+func applyEdit(workDir string, e Edit) error {
+	if e.Path == "" || filepath.IsAbs(e.Path) {
+		return fmt.Errorf("invalid edit path %q: must be relative", e.Path)
+	}
+	path := filepath.Join(workDir, e.Path)
+	rel, err := filepath.Rel(workDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid edit path %q: escapes workspace", e.Path)
+	}
+
+	if e.Delete {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("deleting %s: %w", e.Path, err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", e.Path, err)
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if e.Append {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", e.Path, err)
+	}
+	if _, err := file.WriteString(e.Content); err != nil {
+		file.Close()
+		return fmt.Errorf("writing %s: %w", e.Path, err)
+	}
+	return file.Close()
+}
+
+func loadState(path string) (*state, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &state{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state: %w", err)
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parsing state: %w", err)
+	}
+	return &st, nil
+}
+
+func saveState(path string, st *state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	return nil
+}
+
+// Reset deletes the replay state so the fixture starts from the beginning.
+func Reset(fixturePath string) error {
+	if err := os.Remove(fixturePath + ".state"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package mockagent
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFixture(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "fixture.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+const twoTaskFixture = `
+responses:
+  - match: "ID: task-1"
+    edits:
+      - path: src/hello.txt
+        content: "hello\n"
+    commands:
+      - echo ran
+    output: "Created the hello file"
+    complete: true
+  - match: "ID: task-2"
+    edits:
+      - path: src/hello.txt
+        content: "world\n"
+        append: true
+    complete: true
+`
+
+func TestRun_ReplaysMatchingResponse(t *testing.T) {
+	dir := t.TempDir()
+	fixture := writeFixture(t, dir, twoTaskFixture)
+
+	var out bytes.Buffer
+	if err := Run(&out, fixture, dir, "Current task:\nID: task-1\n"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "src", "hello.txt"))
+	if err != nil || string(data) != "hello\n" {
+		t.Errorf("expected edit applied, got %q (err %v)", data, err)
+	}
+	for _, want := range []string{"Edited src/hello.txt", "$ echo ran", "ran", "Created the hello file", StopSignal} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_AdvancesThroughScript(t *testing.T) {
+	dir := t.TempDir()
+	fixture := writeFixture(t, dir, twoTaskFixture)
+
+	// task-2 first: matching is by prompt, not by position.
+	if err := Run(&bytes.Buffer{}, fixture, dir, "ID: task-2"); err != nil {
+		t.Fatalf("Run task-2: %v", err)
+	}
+	if err := Run(&bytes.Buffer{}, fixture, dir, "ID: task-1"); err != nil {
+		t.Fatalf("Run task-1: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "src", "hello.txt"))
+	if string(data) != "hello\n" {
+		t.Errorf("expected task-1 write to replace file, got %q", data)
+	}
+
+	// Both responses are used up.
+	if err := Run(&bytes.Buffer{}, fixture, dir, "ID: task-1"); err == nil {
+		t.Error("expected error once the script is exhausted")
+	}
+
+	if err := Reset(fixture); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if err := Run(&bytes.Buffer{}, fixture, dir, "ID: task-1"); err != nil {
+		t.Errorf("expected replay to restart after Reset, got %v", err)
+	}
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	dir := t.TempDir()
+	fixture := writeFixture(t, dir, `
+responses:
+  - output: "API Error: 529 overloaded"
+    exit_code: 1
+`)
+	var out bytes.Buffer
+	err := Run(&out, fixture, dir, "anything")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected ExitError{1}, got %v", err)
+	}
+	if !strings.Contains(out.String(), "overloaded") {
+		t.Errorf("expected output before exit, got %q", out.String())
+	}
+}
+
+func TestRun_RejectsEscapingPaths(t *testing.T) {
+	for _, path := range []string{"../outside.txt", "/etc/passwd", "a/../../b"} {
+		dir := t.TempDir()
+		fixture := writeFixture(t, dir, "responses:\n  - edits:\n      - path: \""+path+"\"\n        content: x\n")
+		if err := Run(&bytes.Buffer{}, fixture, dir, ""); err == nil {
+			t.Errorf("expected path %q to be rejected", path)
+		}
+	}
+}
+
+func TestRun_DeleteEdit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fixture := writeFixture(t, dir, "responses:\n  - edits:\n      - path: old.txt\n        delete: true\n")
+	if err := Run(&bytes.Buffer{}, fixture, dir, ""); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Error("expected old.txt deleted")
+	}
+}
+
+func TestRun_FailingCommand(t *testing.T) {
+	dir := t.TempDir()
+	fixture := writeFixture(t, dir, "responses:\n  - commands: [\"exit 3\"]\n")
+	if err := Run(&bytes.Buffer{}, fixture, dir, ""); err == nil {
+		t.Error("expected failing command to return an error")
+	}
+}
+
+func TestLoadFixture_Errors(t *testing.T) {
+	if _, err := LoadFixture(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing fixture")
+	}
+	dir := t.TempDir()
+	if _, err := LoadFixture(writeFixture(t, dir, "responses: []\n")); err == nil {
+		t.Error("expected error for empty fixture")
+	}
+	if _, err := LoadFixture(writeFixture(t, dir, "{not yaml")); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestLoadFixture_JSON(t *testing.T) {
+	dir := t.TempDir()
+	f, err := LoadFixture(writeFixture(t, dir, `{"responses":[{"match":"x","complete":true}]}`))
+	if err != nil {
+		t.Fatalf("LoadFixture: %v", err)
+	}
+	if len(f.Responses) != 1 || !f.Responses[0].Complete {
+		t.Errorf("unexpected fixture: %+v", f)
+	}
+}
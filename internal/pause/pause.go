@@ -0,0 +1,77 @@
+// Package pause provides a gate that long-running loops check between
+// iterations, letting another goroutine pause and resume them.
+package pause
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate blocks callers of Wait while paused. A nil *Gate is never paused, so
+// loops can call Wait unconditionally.
+type Gate struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{}
+}
+
+// NewGate returns an open gate.
+func NewGate() *Gate {
+	return &Gate{}
+}
+
+// Pause closes the gate. It reports false if the gate was already paused.
+func (g *Gate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// Resume opens the gate and releases any waiters. It reports false if the
+// gate was not paused.
+func (g *Gate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	close(g.resumed)
+	return true
+}
+
+// Paused reports whether the gate is closed.
+func (g *Gate) Paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait blocks until the gate is open or ctx is done, returning ctx.Err() in
+// the latter case.
+func (g *Gate) Wait(ctx context.Context) error {
+	if g == nil {
+		return ctx.Err()
+	}
+	g.mu.Lock()
+	paused, resumed := g.paused, g.resumed
+	g.mu.Unlock()
+	if !paused {
+		return ctx.Err()
+	}
+
+	select {
+	case <-resumed:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
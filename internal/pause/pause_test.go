@@ -0,0 +1,62 @@
+package pause
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGateNilIsOpen(t *testing.T) {
+	var g *Gate
+	if g.Paused() {
+		t.Error("nil gate should not be paused")
+	}
+	if err := g.Wait(context.Background()); err != nil {
+		t.Errorf("Wait on nil gate: %v", err)
+	}
+}
+
+func TestGatePauseResume(t *testing.T) {
+	g := NewGate()
+	if !g.Pause() {
+		t.Fatal("Pause() = false on open gate")
+	}
+	if g.Pause() {
+		t.Error("Pause() = true on paused gate")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- g.Wait(context.Background()) }()
+
+	select {
+	case <-done:
+		t.Fatal("Wait returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if !g.Resume() {
+		t.Fatal("Resume() = false on paused gate")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Resume")
+	}
+	if g.Resume() {
+		t.Error("Resume() = true on open gate")
+	}
+}
+
+func TestGateWaitCancelled(t *testing.T) {
+	g := NewGate()
+	g.Pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+}
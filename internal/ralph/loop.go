@@ -4,17 +4,24 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/swamp-dev/agentbox/internal/agent"
 	"github.com/swamp-dev/agentbox/internal/config"
 	"github.com/swamp-dev/agentbox/internal/container"
+	"github.com/swamp-dev/agentbox/internal/events"
+	"github.com/swamp-dev/agentbox/internal/pause"
+	"github.com/swamp-dev/agentbox/internal/retry"
 	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/workflow"
 )
 
 // randomSuffix generates a random hex string for unique container names.
@@ -38,6 +45,22 @@ type Loop struct {
 	projectPath string
 	iteration   int
 
+	// workdir is the current task's directory relative to projectPath,
+	// and since the commit it started from. Quality checks pick packages
+	// from the changes since then.
+	workdir string
+	since   string
+
+	// gate pauses the loop between iterations. Nil means never paused.
+	gate *pause.Gate
+
+	// events receives progress updates. Nil discards them.
+	events events.Sink
+
+	// onDockerRetry is told about each retried Docker failure. Nil means
+	// only the log records it.
+	onDockerRetry container.RetryHook
+
 	// runAgentFn executes the agent and returns its output. Defaults to
 	// runAgent. Tests can replace this to avoid Docker/real agent calls.
 	runAgentFn func(ctx context.Context, prompt string) (string, error)
@@ -49,7 +72,7 @@ type Loop struct {
 
 // NewLoop creates a new Ralph loop executor.
 func NewLoop(cfg *config.Config, projectPath string, logger *slog.Logger) (*Loop, error) {
-	ag, err := agent.New(cfg.Agent.Name)
+	ag, err := agent.NewWithOptions(cfg.Agent.Name, agent.Options{BaseURL: cfg.Agent.BaseURL, Network: cfg.Docker.Network, Model: cfg.Agent.Model})
 	if err != nil {
 		return nil, err
 	}
@@ -104,9 +127,48 @@ func NewLoop(cfg *config.Config, projectPath string, logger *slog.Logger) (*Loop
 	}
 	l.runAgentFn = l.runAgent
 	l.runQualityChecksFn = l.runQualityChecks
+	cm.SetRetryPolicy(cfg.RetryPolicy(), l.dockerRetried)
 	return l, nil
 }
 
+// SetPauseGate lets another goroutine pause the loop between iterations.
+func (l *Loop) SetPauseGate(g *pause.Gate) {
+	l.gate = g
+}
+
+// SetEventSink sends progress events (iterations, completed tasks, failed
+// quality checks) to sink.
+func (l *Loop) SetEventSink(sink events.Sink) {
+	l.events = sink
+}
+
+// SetDockerRetryHook sets a function called, after the warning is logged,
+// each time container creation retries a transient Docker failure.
+func (l *Loop) SetDockerRetryHook(hook container.RetryHook) {
+	l.onDockerRetry = hook
+}
+
+// dockerRetried logs a retried Docker failure and passes it to the hook.
+func (l *Loop) dockerRetried(n int, delay time.Duration, err error) {
+	l.logger.Warn("transient docker failure, retrying",
+		"retry", n, "delay", delay.Round(time.Second), "error", err)
+	if l.onDockerRetry != nil {
+		l.onDockerRetry(n, delay, err)
+	}
+}
+
+// emit sends an event stamped with the current iteration and PRD progress.
+func (l *Loop) emit(kind events.Kind, taskID, message string) {
+	l.events.Emit(events.Event{
+		Kind:      kind,
+		Iteration: l.iteration,
+		TaskID:    taskID,
+		Message:   message,
+		Completed: l.prd.Metadata.Completed,
+		Total:     l.prd.Metadata.TotalTasks,
+	})
+}
+
 // Close releases resources.
 func (l *Loop) Close() error {
 	var storeErr error
@@ -129,10 +191,11 @@ func (l *Loop) Run(ctx context.Context) error {
 	)
 
 	for l.iteration = 1; l.iteration <= l.cfg.Ralph.MaxIterations; l.iteration++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		if l.gate.Paused() {
+			l.logger.Info("loop paused", "iteration", l.iteration)
+		}
+		if err := l.gate.Wait(ctx); err != nil {
+			return err
 		}
 
 		if l.prd.IsComplete() {
@@ -157,7 +220,7 @@ func (l *Loop) Run(ctx context.Context) error {
 }
 
 // runIteration executes a single iteration of the Ralph loop.
-func (l *Loop) runIteration(ctx context.Context) error {
+func (l *Loop) runIteration(ctx context.Context) (err error) {
 	task := l.prd.NextTask()
 	if task == nil {
 		return fmt.Errorf("no available tasks")
@@ -168,44 +231,67 @@ func (l *Loop) runIteration(ctx context.Context) error {
 		"task", task.ID,
 		"title", task.Title,
 	)
+	l.emit(events.IterationStart, task.ID, fmt.Sprintf("Iteration %d: %s", l.iteration, task.Title))
+	defer func() {
+		msg := fmt.Sprintf("Iteration %d succeeded", l.iteration)
+		if err != nil {
+			msg = fmt.Sprintf("Iteration %d failed: %s", l.iteration, err)
+		}
+		l.emit(events.IterationEnd, task.ID, msg)
+	}()
 
 	if err := l.prd.MarkTaskInProgress(task.ID); err != nil {
 		return err
 	}
+	l.startTask(ctx, task)
 	l.logProgressErr("RecordStart", l.progress.RecordStart(task.ID, task.Title))
 
 	prompt := l.buildPrompt(task)
+	l.logProgressErr("clear report", clearReport(l.projectPath, l.iteration))
 
 	output, err := l.runAgentFn(ctx, prompt)
+	report := l.loadReport()
 	if err != nil {
 		failMsg := err.Error()
 		if output != "" {
 			l.logger.Warn("agent output before failure", "task", task.ID, "output", truncateString(output, maxLogOutput))
 			failMsg = fmt.Sprintf("%s\n\nAgent output:\n%s", failMsg, truncateString(output, maxFailMsgOutput))
 		}
-		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, failMsg))
+		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(failMsg, report)))
 		return fmt.Errorf("agent execution failed: %w", err)
 	}
 
 	result := l.agent.ParseOutput(output)
 
 	if !result.Success {
-		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, result.Message))
+		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(result.Message, report)))
 		return fmt.Errorf("agent reported failure: %s", result.Message)
 	}
 
 	if err := l.runQualityChecksFn(ctx); err != nil {
-		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, fmt.Sprintf("quality check failed: %s", err)))
+		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(fmt.Sprintf("quality check failed: %s", err), report)))
+		l.emit(events.QualityCheckFailed, task.ID, fmt.Sprintf("Quality check failed for %s: %s", task.ID, err))
 		return fmt.Errorf("quality checks failed: %w", err)
 	}
 
+	if report != nil {
+		l.logProgressErr("AppendConventions", AppendConventions(l.projectPath, report.Conventions))
+	}
+
 	if l.cfg.Ralph.AutoCommit {
 		if err := l.commitChanges(ctx, task); err != nil {
+			// A policy violation is the agent's doing and fails the
+			// iteration; anything else is only worth a warning.
+			var perr *workflow.PolicyError
+			if errors.As(err, &perr) {
+				l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(err.Error(), report)))
+				return err
+			}
 			l.logger.Warn("commit failed", "error", err)
 		}
 	}
 
-	learnings := l.extractLearnings(output)
+	learnings := l.learnings(report, output)
 	if err := l.prd.MarkTaskComplete(task.ID, strings.Join(learnings, "; ")); err != nil {
 		return err
 	}
@@ -215,6 +301,7 @@ func (l *Loop) runIteration(ctx context.Context) error {
 	}
 
 	l.logProgressErr("RecordComplete", l.progress.RecordComplete(task.ID, task.Title, "Task completed successfully", learnings))
+	l.emit(events.TaskComplete, task.ID, fmt.Sprintf("Completed %s: %s", task.ID, task.Title))
 
 	l.logger.Info("iteration completed",
 		"iteration", l.iteration,
@@ -237,6 +324,9 @@ func (l *Loop) buildPrompt(task *Task) string {
 	sb.WriteString(fmt.Sprintf("ID: %s\n", task.ID))
 	sb.WriteString(fmt.Sprintf("Title: %s\n", task.Title))
 	sb.WriteString(fmt.Sprintf("Description: %s\n", task.Description))
+	if dir := task.Dir(); dir != "" {
+		sb.WriteString(fmt.Sprintf("Working directory: %s (you start there; the rest of the repository is under /workspace)\n", dir))
+	}
 	sb.WriteString("\n")
 
 	sb.WriteString("Instructions:\n")
@@ -247,22 +337,46 @@ func (l *Loop) buildPrompt(task *Task) string {
 	sb.WriteString(l.cfg.Ralph.StopSignal)
 	sb.WriteString("\n\n")
 
-	sb.WriteString("Important: Only output the completion signal when the task is truly done.\n")
+	sb.WriteString("Important: Only output the completion signal when the task is truly done.\n\n")
+	sb.WriteString(ReportInstructions(l.iteration))
 
 	return sb.String()
 }
 
-// runAgent executes the agent in a container.
+// startTask records where a task works and the commit it starts from.
+func (l *Loop) startTask(ctx context.Context, task *Task) {
+	l.workdir = task.Dir()
+	l.since = ""
+	if len(l.cfg.Packages) == 0 {
+		return
+	}
+	cmd := exec.CommandContext(ctx, "git", "-c", "safe.directory="+l.projectPath, "rev-parse", "HEAD")
+	cmd.Dir = l.projectPath
+	if out, err := cmd.Output(); err == nil {
+		l.since = strings.TrimSpace(string(out))
+	}
+}
+
+// runAgent executes the agent in a container, started in the task's
+// workdir with its package's image and allowed endpoints.
 func (l *Loop) runAgent(ctx context.Context, prompt string) (string, error) {
 	cmd := l.agent.Command(prompt)
 	env := l.agent.Environment()
 
-	containerCfg, err := container.ConfigToContainerConfig(l.cfg, l.projectPath, cmd, env)
+	if l.workdir != "" {
+		if info, err := os.Stat(filepath.Join(l.projectPath, l.workdir)); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("task workdir %s is not a directory in the project", l.workdir)
+		}
+	}
+	containerCfg, err := container.ConfigToContainerConfig(l.cfg.ForWorkdir(l.workdir), l.projectPath, cmd, env)
 	if err != nil {
 		return "", err
 	}
 
 	containerCfg.Name = fmt.Sprintf("agentbox-%s-iter-%d-%s", l.cfg.Project.Name, l.iteration, randomSuffix())
+	if l.workdir != "" {
+		containerCfg.WorkDir = path.Join(containerCfg.WorkDir, l.workdir)
+	}
 
 	return l.container.Run(ctx, containerCfg)
 }
@@ -276,6 +390,13 @@ var allowedQualityCheckCommands = []string{
 	"eslint", "prettier", "tsc", "jest", "vitest", "mocha",
 }
 
+// ValidateCheckCommand reports whether command is allowed to run as a quality
+// or acceptance check. It applies the same allowlist as configured quality
+// checks.
+func ValidateCheckCommand(command string) error {
+	return validateQualityCheckCommand(command)
+}
+
 // validateQualityCheckCommand ensures the command starts with an allowed prefix.
 func validateQualityCheckCommand(command string) error {
 	parts := strings.Fields(command)
@@ -293,9 +414,42 @@ func validateQualityCheckCommand(command string) error {
 	return fmt.Errorf("command not in allowlist: %s (allowed: %v)", base, allowedQualityCheckCommands)
 }
 
-// runQualityChecks executes all configured quality checks.
+// runQualityChecks executes the quality checks for the current task. With
+// packages configured, those of the task's package and of the packages its
+// changes touch run, each in its own directory.
 func (l *Loop) runQualityChecks(ctx context.Context) error {
-	for _, check := range l.cfg.Ralph.QualityChecks {
+	var changed []string
+	if len(l.cfg.Packages) > 0 {
+		exempt := []string{".agentbox", l.cfg.Ralph.ProgressFile, l.cfg.Ralph.PRDFile}
+		paths, err := workflow.ChangedPaths(ctx, l.projectPath, l.since, exempt, "-c", "safe.directory="+l.projectPath)
+		if err != nil {
+			l.logger.Warn("could not list changed files, running checks for the task's package", "error", err)
+		}
+		changed = paths
+	}
+	return RunCheckSets(ctx, l.projectPath, l.cfg.CheckSets(l.workdir, changed), l.logger)
+}
+
+// RunCheckSets runs each set of checks in its directory under root,
+// stopping at the first failure.
+func RunCheckSets(ctx context.Context, root string, sets []config.CheckSet, logger *slog.Logger) error {
+	for _, set := range sets {
+		if set.Dir != "" {
+			logger.Debug("running package quality checks", "package", set.Dir)
+		}
+		if err := RunQualityChecks(ctx, filepath.Join(root, set.Dir), set.Checks, logger); err != nil {
+			if set.Dir != "" {
+				return fmt.Errorf("%s: %w", set.Dir, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// RunQualityChecks runs checks in dir, stopping at the first failure.
+func RunQualityChecks(ctx context.Context, dir string, checks []config.QualityCheck, logger *slog.Logger) error {
+	for _, check := range checks {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -306,10 +460,10 @@ func (l *Loop) runQualityChecks(ctx context.Context) error {
 			return fmt.Errorf("invalid quality check %s: %w", check.Name, err)
 		}
 
-		l.logger.Debug("running quality check", "name", check.Name)
+		logger.Debug("running quality check", "name", check.Name)
 
 		cmd := exec.CommandContext(ctx, "sh", "-c", check.Command)
-		cmd.Dir = l.projectPath
+		cmd.Dir = dir
 
 		output, err := cmd.CombinedOutput()
 		if err != nil {
@@ -321,6 +475,8 @@ func (l *Loop) runQualityChecks(ctx context.Context) error {
 }
 
 // commitChanges commits the current changes to git, including untracked files.
+// Staged changes the commit policy does not allow are refused with a
+// *workflow.PolicyError.
 func (l *Loop) commitChanges(ctx context.Context, task *Task) error {
 	gitDir := filepath.Join(l.projectPath, ".git")
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
@@ -363,10 +519,25 @@ func (l *Loop) commitChanges(ctx context.Context, task *Task) error {
 	stagedFiles := strings.Split(strings.TrimSpace(string(output)), "\n")
 	l.logger.Debug("staged changes", "files", strings.TrimSpace(string(output)))
 
-	message := fmt.Sprintf("feat: %s\n\nTask ID: %s\nIteration: %d\n\nGenerated by agentbox Ralph loop",
-		task.Title, task.ID, l.iteration)
+	// The PRD, progress file and reports are agentbox's own bookkeeping and
+	// are not held to the commit policy.
+	exempt := []string{".agentbox", l.cfg.Ralph.ProgressFile, l.cfg.Ralph.PRDFile}
+	if err := workflow.EnforcePolicy(ctx, l.projectPath, l.cfg.CommitPolicy(), exempt, "-c", safeCfg); err != nil {
+		return err
+	}
+
+	message, err := workflow.CommitMessage(l.cfg.Commit, workflow.CommitInfo{
+		TaskID:    task.ID,
+		Title:     task.Title,
+		Agent:     l.cfg.Agent.Name,
+		Iteration: l.iteration,
+	})
+	if err != nil {
+		return err
+	}
 
-	commitCmd := exec.CommandContext(ctx, "git", "-c", safeCfg, "commit", "-m", message)
+	args := append([]string{"-c", safeCfg}, workflow.CommitArgs(l.cfg.Commit, message)...)
+	commitCmd := exec.CommandContext(ctx, "git", args...)
 	commitCmd.Dir = l.projectPath
 	var commitStderr strings.Builder
 	commitCmd.Stderr = &commitStderr
@@ -406,7 +577,38 @@ func (l *Loop) logProgressErr(op string, err error) {
 	}
 }
 
-// extractLearnings attempts to extract learnings from the agent output.
+// loadReport reads the agent's report for the current iteration and removes
+// its directory, since the caller takes the report from here. A missing
+// report yields nil; a malformed one is logged and ignored.
+func (l *Loop) loadReport() *Report {
+	report, err := LoadReport(l.projectPath, l.iteration)
+	l.logProgressErr("clear report", clearReport(l.projectPath, l.iteration))
+	if err != nil {
+		l.logger.Warn("ignoring agent report", "iteration", l.iteration, "error", err)
+		return nil
+	}
+	return report
+}
+
+// learnings returns the report's learnings, falling back to scraping the
+// agent output when no report was written.
+func (l *Loop) learnings(report *Report, output string) []string {
+	if report != nil {
+		return report.Learnings
+	}
+	return l.extractLearnings(output)
+}
+
+// withBlockers appends the report's blockers to a failure message.
+func withBlockers(msg string, report *Report) string {
+	if report == nil || len(report.Blockers) == 0 {
+		return msg
+	}
+	return fmt.Sprintf("%s\n\nBlockers:\n- %s", msg, strings.Join(report.Blockers, "\n- "))
+}
+
+// extractLearnings attempts to extract learnings from the agent output. It is
+// only used when the agent did not write a structured report.
 func (l *Loop) extractLearnings(output string) []string {
 	var learnings []string
 
@@ -438,6 +640,16 @@ type IterationResult struct {
 	Error     string
 	Learnings []string
 	QualityOK bool
+
+	// Report is the agent's structured report for the iteration, or nil if
+	// it did not write one.
+	Report *Report
+
+	// Transient is set when the failure looks like a temporary infrastructure
+	// problem (API rate limit, Docker daemon hiccup, network timeout) rather
+	// than a problem with the agent's work. Callers may retry these without
+	// counting them against the task.
+	Transient bool
 }
 
 // RunSingleTask runs a single iteration for a specific task and prompt.
@@ -450,37 +662,48 @@ func (l *Loop) RunSingleTask(ctx context.Context, task *Task, prompt string) *It
 		result.Error = err.Error()
 		return result
 	}
+	l.startTask(ctx, task)
 	l.logProgressErr("RecordStart", l.progress.RecordStart(task.ID, task.Title))
+	l.logProgressErr("clear report", clearReport(l.projectPath, l.iteration))
 
-	output, err := l.runAgentFn(ctx, prompt)
+	output, err := l.runAgentFn(ctx, prompt+"\n"+ReportInstructions(l.iteration))
 	result.Output = output
+	result.Report = l.loadReport()
 	if err != nil {
 		result.Error = fmt.Sprintf("agent execution failed: %s", err)
+		// Only the error and the agent's closing API error say whether the
+		// failure was the infrastructure's; the rest of the output is the
+		// agent's work and may mention network errors of its own.
+		result.Transient = ctx.Err() == nil && (retry.IsTransient(err) || retry.IsTransientMessage(retry.APIErrorLine(output)))
 		failMsg := result.Error
 		if output != "" {
 			l.logger.Warn("agent output before failure", "task", task.ID, "output", truncateString(output, maxLogOutput))
 			failMsg = fmt.Sprintf("%s\n\nAgent output:\n%s", failMsg, truncateString(output, maxFailMsgOutput))
 		}
-		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, failMsg))
+		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(failMsg, result.Report)))
 		return result
 	}
 
 	agentResult := l.agent.ParseOutput(output)
 	if !agentResult.Success {
 		result.Error = fmt.Sprintf("agent reported failure: %s", agentResult.Message)
-		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, result.Error))
+		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(result.Error, result.Report)))
 		return result
 	}
 
 	if err := l.runQualityChecksFn(ctx); err != nil {
 		result.Error = fmt.Sprintf("quality check failed: %s", err)
 		result.QualityOK = false
-		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, result.Error))
+		l.emit(events.QualityCheckFailed, task.ID, fmt.Sprintf("Quality check failed for %s: %s", task.ID, err))
+		l.logProgressErr("RecordFailed", l.progress.RecordFailed(task.ID, task.Title, withBlockers(result.Error, result.Report)))
 		return result
 	}
 	result.QualityOK = true
 
-	result.Learnings = l.extractLearnings(output)
+	if result.Report != nil {
+		l.logProgressErr("AppendConventions", AppendConventions(l.projectPath, result.Report.Conventions))
+	}
+	result.Learnings = l.learnings(result.Report, output)
 	result.Success = true
 
 	if err := l.prd.MarkTaskComplete(task.ID, strings.Join(result.Learnings, "; ")); err != nil {
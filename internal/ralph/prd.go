@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"time"
+
+	"github.com/swamp-dev/agentbox/internal/config"
 )
 
 // PRD represents a Product Requirements Document with tasks.
@@ -33,6 +35,10 @@ type Task struct {
 	Description string    `json:"description"`
 	Status      string    `json:"status"` // pending, in_progress, completed, blocked
 	Priority    int       `json:"priority,omitempty"`
+	Complexity  int       `json:"complexity,omitempty"`
+	Ensemble    bool      `json:"ensemble,omitempty"` // race several agents on this task
+	Workdir     string    `json:"workdir,omitempty"`  // subdirectory the agent works in, e.g. services/api
+	Package     string    `json:"package,omitempty"`  // same as workdir
 	DependsOn   []string  `json:"depends_on,omitempty"`
 	Subtasks    []Task    `json:"subtasks,omitempty"`
 	Learnings   string    `json:"learnings,omitempty"`
@@ -51,10 +57,36 @@ func LoadPRD(path string) (*PRD, error) {
 		return nil, fmt.Errorf("parsing PRD file: %w", err)
 	}
 
+	for _, t := range prd.ExportTasks() {
+		for _, dir := range []string{t.Workdir, t.Package} {
+			if _, err := config.CleanWorkdir(dir); err != nil {
+				return nil, fmt.Errorf("task %s: invalid workdir: %w", t.ID, err)
+			}
+		}
+		if t.Workdir != "" && t.Package != "" && t.Dir() != cleanWorkdir(t.Package) {
+			return nil, fmt.Errorf("task %s: workdir %s and package %s differ", t.ID, t.Workdir, t.Package)
+		}
+	}
+
 	prd.updateMetadata()
 	return &prd, nil
 }
 
+// Dir returns the directory, relative to the project root, the task is
+// scoped to: its workdir, or its package. "" means the whole project.
+func (t *Task) Dir() string {
+	if t.Workdir != "" {
+		return cleanWorkdir(t.Workdir)
+	}
+	return cleanWorkdir(t.Package)
+}
+
+// cleanWorkdir cleans a workdir LoadPRD has already checked.
+func cleanWorkdir(dir string) string {
+	clean, _ := config.CleanWorkdir(dir)
+	return clean
+}
+
 // Save writes the PRD to a JSON file.
 func (p *PRD) Save(path string) error {
 	p.Metadata.UpdatedAt = time.Now()
@@ -234,3 +234,38 @@ func TestExportTasks(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadPRDWorkdir(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prd.json")
+	load := func(tasks string) (*PRD, error) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(`{"name": "mono", "tasks": [`+tasks+`]}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadPRD(path)
+	}
+
+	prd, err := load(`{"id": "api", "status": "pending", "workdir": "./services/api/"},
+		{"id": "web", "status": "pending", "package": "web"},
+		{"id": "both", "status": "pending", "workdir": "web", "package": "web/"},
+		{"id": "all", "status": "pending"}`)
+	if err != nil {
+		t.Fatalf("LoadPRD: %v", err)
+	}
+	for id, want := range map[string]string{"api": "services/api", "web": "web", "both": "web", "all": ""} {
+		if got := prd.GetTask(id).Dir(); got != want {
+			t.Errorf("task %s: Dir() = %q, want %q", id, got, want)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"id": "up", "workdir": "../elsewhere"}`,
+		`{"id": "abs", "package": "/srv/api"}`,
+		`{"id": "mixed", "workdir": "api", "package": "web"}`,
+	} {
+		if _, err := load(bad); err == nil {
+			t.Errorf("LoadPRD accepted %s", bad)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package ralph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReportFile is the name of the structured report an agent writes at the end
+// of an iteration.
+const ReportFile = "report.json"
+
+// Report is the structured summary an agent leaves in
+// .agentbox/iteration/<n>/report.json. It replaces scraping "Learning:" lines
+// from stdout, which picked up stray log output and missed anything the agent
+// only wrote to files.
+type Report struct {
+	// Learnings are insights about this task, recorded in progress.txt.
+	Learnings []string `json:"learnings,omitempty"`
+
+	// Conventions are durable project patterns worth keeping in AGENTS.md.
+	Conventions []string `json:"conventions,omitempty"`
+
+	// Blockers explain what prevented the task from being finished.
+	Blockers []string `json:"blockers,omitempty"`
+
+	// FilesTouched lists the files the agent changed, relative to the repo.
+	FilesTouched []string `json:"files_touched,omitempty"`
+
+	// FollowUps are new tasks the agent discovered but did not do.
+	FollowUps []FollowUp `json:"follow_ups,omitempty"`
+
+	// Confidence is the agent's self-assessment that the task is done
+	// correctly, from 0 to 1.
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// FollowUp is a task proposed by the agent in its report.
+type FollowUp struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Priority    int    `json:"priority,omitempty"`
+}
+
+// ReportDir returns the directory, relative to the project root, in which the
+// agent writes its report for the given iteration.
+func ReportDir(iteration int) string {
+	return filepath.Join(".agentbox", "iteration", strconv.Itoa(iteration))
+}
+
+// ReportPath returns the relative path of the report for an iteration.
+func ReportPath(iteration int) string {
+	return filepath.Join(ReportDir(iteration), ReportFile)
+}
+
+// LoadReport reads the report for an iteration. It returns nil without error
+// if the agent did not write one.
+func LoadReport(projectPath string, iteration int) (*Report, error) {
+	data, err := os.ReadFile(filepath.Join(projectPath, ReportPath(iteration)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing report: %w", err)
+	}
+	if r.Confidence < 0 || r.Confidence > 1 {
+		return nil, fmt.Errorf("report confidence %v out of range [0,1]", r.Confidence)
+	}
+	r.Learnings = compactStrings(r.Learnings)
+	r.Conventions = compactStrings(r.Conventions)
+	r.Blockers = compactStrings(r.Blockers)
+	r.FilesTouched = compactStrings(r.FilesTouched)
+
+	followUps := r.FollowUps[:0]
+	for _, f := range r.FollowUps {
+		f.Title = strings.TrimSpace(f.Title)
+		if f.Title != "" {
+			followUps = append(followUps, f)
+		}
+	}
+	r.FollowUps = followUps
+	return &r, nil
+}
+
+// clearReport removes the report directory for an iteration: before the
+// agent runs, so a stale file from a run that reused the iteration number is
+// never ingested, and again once the report has been read. The shared
+// iteration directory goes too once it is empty.
+func clearReport(projectPath string, iteration int) error {
+	dir := filepath.Join(projectPath, ReportDir(iteration))
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing report directory: %w", err)
+	}
+	// Fails harmlessly while other iterations' directories remain.
+	_ = os.Remove(filepath.Dir(dir))
+	return nil
+}
+
+// ReportInstructions tells the agent how to write its report.
+func ReportInstructions(iteration int) string {
+	var sb strings.Builder
+	sb.WriteString("Before finishing, write a JSON report to ")
+	sb.WriteString(filepath.ToSlash(ReportPath(iteration)))
+	sb.WriteString(" with these optional fields:\n")
+	sb.WriteString(`- "learnings": insights about this task (strings)` + "\n")
+	sb.WriteString(`- "conventions": durable project patterns for AGENTS.md (strings)` + "\n")
+	sb.WriteString(`- "blockers": anything that stopped you finishing (strings)` + "\n")
+	sb.WriteString(`- "files_touched": files you changed (strings)` + "\n")
+	sb.WriteString(`- "follow_ups": new tasks you found, as {"title", "description", "priority"}` + "\n")
+	sb.WriteString(`- "confidence": how sure you are the task is done correctly, 0 to 1` + "\n")
+	return sb.String()
+}
+
+// AppendConventions adds conventions to AGENTS.md in projectPath, skipping
+// any already listed. The file is created if it does not exist.
+func AppendConventions(projectPath string, conventions []string) error {
+	if len(conventions) == 0 {
+		return nil
+	}
+	path := filepath.Join(projectPath, "AGENTS.md")
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading AGENTS.md: %w", err)
+	}
+	content := string(data)
+	if content == "" {
+		content = "# AGENTS.md\n\n## Notes\n"
+	}
+
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "- ") {
+			existing[strings.TrimPrefix(line, "- ")] = true
+		}
+	}
+
+	added := false
+	for _, c := range conventions {
+		if existing[c] {
+			continue
+		}
+		existing[c] = true
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "- " + c + "\n"
+		added = true
+	}
+	if !added {
+		return nil
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing AGENTS.md: %w", err)
+	}
+	return nil
+}
+
+// compactStrings trims entries and drops empty ones.
+func compactStrings(in []string) []string {
+	var out []string
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package ralph
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeReport(t *testing.T, projectPath string, iteration int, content string) {
+	t.Helper()
+	path := filepath.Join(projectPath, ReportPath(iteration))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadReport(t *testing.T) {
+	dir := t.TempDir()
+	writeReport(t, dir, 3, `{
+		"learnings": ["use table tests", "  "],
+		"blockers": ["missing fixture"],
+		"files_touched": ["main.go"],
+		"follow_ups": [{"title": "Add docs", "priority": 2}, {"title": " "}],
+		"confidence": 0.8
+	}`)
+
+	r, err := LoadReport(dir, 3)
+	if err != nil {
+		t.Fatalf("LoadReport: %v", err)
+	}
+	if len(r.Learnings) != 1 || r.Learnings[0] != "use table tests" {
+		t.Errorf("Learnings = %v, want blanks dropped", r.Learnings)
+	}
+	if len(r.FollowUps) != 1 || r.FollowUps[0].Title != "Add docs" || r.FollowUps[0].Priority != 2 {
+		t.Errorf("FollowUps = %+v", r.FollowUps)
+	}
+	if r.Confidence != 0.8 {
+		t.Errorf("Confidence = %v", r.Confidence)
+	}
+}
+
+func TestLoadReportMissing(t *testing.T) {
+	r, err := LoadReport(t.TempDir(), 1)
+	if err != nil || r != nil {
+		t.Errorf("LoadReport() = %v, %v; want nil, nil", r, err)
+	}
+}
+
+func TestLoadReportInvalid(t *testing.T) {
+	dir := t.TempDir()
+	writeReport(t, dir, 1, `not json`)
+	if _, err := LoadReport(dir, 1); err == nil {
+		t.Error("expected error for malformed report")
+	}
+
+	writeReport(t, dir, 2, `{"confidence": 1.5}`)
+	if _, err := LoadReport(dir, 2); err == nil {
+		t.Error("expected error for out-of-range confidence")
+	}
+}
+
+func TestAppendConventions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "AGENTS.md")
+	if err := os.WriteFile(path, []byte("# AGENTS.md\n\n## Notes\n- existing rule"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AppendConventions(dir, []string{"existing rule", "run make lint"}); err != nil {
+		t.Fatalf("AppendConventions: %v", err)
+	}
+	if err := AppendConventions(dir, []string{"run make lint"}); err != nil {
+		t.Fatalf("AppendConventions: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	want := "# AGENTS.md\n\n## Notes\n- existing rule\n- run make lint\n"
+	if string(data) != want {
+		t.Errorf("AGENTS.md = %q, want %q", data, want)
+	}
+}
+
+func TestAppendConventionsCreatesFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := AppendConventions(dir, []string{"prefer errors.Is"}); err != nil {
+		t.Fatalf("AppendConventions: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "AGENTS.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "- prefer errors.Is\n") {
+		t.Errorf("AGENTS.md = %q", data)
+	}
+}
+
+func TestRunSingleTaskIngestsReport(t *testing.T) {
+	tasks := []Task{{ID: "task-1", Title: "Report task", Status: "pending"}}
+	loop := newTestableLoop(t, tasks, 10)
+
+	var gotPrompt string
+	loop.runAgentFn = func(_ context.Context, prompt string) (string, error) {
+		gotPrompt = prompt
+		writeReport(t, loop.projectPath, loop.iteration, `{
+			"learnings": ["the cache is per request"],
+			"conventions": ["wrap errors with %w"],
+			"follow_ups": [{"title": "Cache eviction"}]
+		}`)
+		return "Note: this stdout line is not a learning\ndone", nil
+	}
+
+	result := loop.RunSingleTask(context.Background(), &tasks[0], "do the thing")
+	if !result.Success {
+		t.Fatalf("expected success, got %s", result.Error)
+	}
+	if !strings.Contains(gotPrompt, ".agentbox/iteration/1/report.json") {
+		t.Errorf("prompt missing report instructions:\n%s", gotPrompt)
+	}
+	if result.Report == nil || len(result.Report.FollowUps) != 1 {
+		t.Fatalf("Report = %+v, want one follow-up", result.Report)
+	}
+	if len(result.Learnings) != 1 || result.Learnings[0] != "the cache is per request" {
+		t.Errorf("Learnings = %v, want report learnings instead of scraped output", result.Learnings)
+	}
+	if _, err := os.Stat(filepath.Join(loop.projectPath, ".agentbox", "iteration")); !os.IsNotExist(err) {
+		t.Errorf("expected report directory to be removed once read, stat err = %v", err)
+	}
+
+	agents, err := os.ReadFile(filepath.Join(loop.projectPath, "AGENTS.md"))
+	if err != nil || !strings.Contains(string(agents), "- wrap errors with %w") {
+		t.Errorf("AGENTS.md = %q, %v; want convention appended", agents, err)
+	}
+	progress, _ := os.ReadFile(filepath.Join(loop.projectPath, "progress.txt"))
+	if !strings.Contains(string(progress), "- the cache is per request") {
+		t.Errorf("progress.txt missing report learning:\n%s", progress)
+	}
+}
+
+func TestRunSingleTaskClearsStaleReport(t *testing.T) {
+	tasks := []Task{{ID: "task-1", Title: "Stale", Status: "pending"}}
+	loop := newTestableLoop(t, tasks, 10)
+	writeReport(t, loop.projectPath, 1, `{"learnings": ["stale"]}`)
+
+	loop.runAgentFn = func(_ context.Context, _ string) (string, error) {
+		return "done", nil
+	}
+	result := loop.RunSingleTask(context.Background(), &tasks[0], "prompt")
+	if result.Report != nil {
+		t.Errorf("expected stale report to be cleared, got %+v", result.Report)
+	}
+}
+
+func TestRunIterationRecordsReportBlockers(t *testing.T) {
+	tasks := []Task{{ID: "task-1", Title: "Blocked", Status: "pending"}}
+	loop := newTestableLoop(t, tasks, 10)
+	loop.iteration = 1
+	loop.runAgentFn = func(_ context.Context, _ string) (string, error) {
+		writeReport(t, loop.projectPath, loop.iteration, `{"blockers": ["no database credentials"]}`)
+		return "", fmt.Errorf("exit status 1")
+	}
+
+	if err := loop.runIteration(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	progress, _ := os.ReadFile(filepath.Join(loop.projectPath, "progress.txt"))
+	if !strings.Contains(string(progress), "no database credentials") {
+		t.Errorf("progress.txt missing blocker:\n%s", progress)
+	}
+}
@@ -0,0 +1,174 @@
+// Package retry provides transient-failure classification and jittered
+// exponential backoff for agent and Docker operations.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+)
+
+// Policy controls how transient failures are retried.
+type Policy struct {
+	MaxRetries int           `yaml:"max_retries" json:"max_retries"`
+	BaseDelay  time.Duration `yaml:"base_delay" json:"base_delay"`
+	MaxDelay   time.Duration `yaml:"max_delay" json:"max_delay"`
+}
+
+// DefaultPolicy returns a policy with sensible defaults: up to 3 retries,
+// starting at 5s and capped at 2m.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxRetries: 3,
+		BaseDelay:  5 * time.Second,
+		MaxDelay:   2 * time.Minute,
+	}
+}
+
+// Backoff returns the delay before retry number n (1-based). The delay grows
+// exponentially from BaseDelay, is capped at MaxDelay, and has "equal jitter"
+// applied so the result lies in [d/2, d].
+func (p Policy) Backoff(n int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < n; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			d = p.MaxDelay
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// Wait sleeps for d, returning early with the context's error if it is
+// cancelled.
+func Wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// transientPatterns are lowercase substrings that identify failures worth
+// retrying: model API rate limiting and overload, Docker daemon hiccups, and
+// network timeouts during image pulls or API calls.
+var transientPatterns = []string{
+	"too many requests",
+	"rate limit",
+	"rate_limit",
+	"overloaded",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+	"service unavailable",
+	"cannot connect to the docker daemon",
+	"is the docker daemon running",
+	"error during connect",
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"i/o timeout",
+	"tls handshake timeout",
+	"timeout exceeded while awaiting headers",
+	"temporary failure in name resolution",
+	"net/http: request canceled",
+	"unexpected eof",
+}
+
+// IsTransientMessage reports whether an error message looks like a transient
+// failure. Matching is case-insensitive.
+func IsTransientMessage(msg string) bool {
+	lower := strings.ToLower(msg)
+	for _, p := range transientPatterns {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiErrorMarkers are lowercase substrings of the line an agent CLI prints
+// when it gives up on an error from the model API.
+var apiErrorMarkers = []string{
+	"api error",
+	"apierror",
+	"api_error",
+	"rate_limit_error",
+	"overloaded_error",
+	"ratelimiterror",
+	"serviceunavailableerror",
+	"apiconnectionerror",
+}
+
+// APIErrorLine returns the last non-empty line of an agent's output when it
+// reports an error from the model API, such as "API Error: 529 Overloaded",
+// or "" otherwise. Only that line says why the agent stopped; anything
+// earlier may be output of the code it was working on.
+func APIErrorLine(output string) string {
+	lines := strings.Split(strings.TrimRight(output, " \t\r\n"), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	lower := strings.ToLower(last)
+	for _, m := range apiErrorMarkers {
+		if strings.Contains(lower, m) {
+			return last
+		}
+	}
+	return ""
+}
+
+// IsTransient reports whether err is a transient failure. Context
+// cancellation and deadline errors are always permanent — the caller asked
+// us to stop.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return IsTransientMessage(err.Error())
+}
+
+// Do calls fn until it succeeds, returns a permanent error, or MaxRetries
+// retries have been used. onRetry, if non-nil, is called before each retry
+// with the retry number (1-based), the delay about to be slept, and the
+// error that triggered it.
+func Do(ctx context.Context, p Policy, fn func() error, onRetry func(n int, delay time.Duration, err error)) error {
+	for n := 0; ; n++ {
+		err := fn()
+		if err == nil || !IsTransient(err) || n >= p.MaxRetries {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		delay := p.Backoff(n + 1)
+		if onRetry != nil {
+			onRetry(n+1, delay, err)
+		}
+
+		if waitErr := Wait(ctx, delay); waitErr != nil {
+			return fmt.Errorf("%w (retry aborted: %v)", err, waitErr)
+		}
+	}
+}
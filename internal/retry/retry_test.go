@@ -0,0 +1,159 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsTransientMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"API Error: 429 Too Many Requests", true},
+		{`{"type":"error","error":{"type":"rate_limit_error"}}`, true},
+		{"Overloaded", true},
+		{"Cannot connect to the Docker daemon at unix:///var/run/docker.sock", true},
+		{"dial tcp 1.2.3.4:443: i/o timeout", true},
+		{"read: connection reset by peer", true},
+		{"undefined: foo", false},
+		{"tests failed: 3 errors", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientMessage(tt.msg); got != tt.want {
+			t.Errorf("IsTransientMessage(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestAPIErrorLine(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"Working on it...\nAPI Error: 529 Overloaded\n", "API Error: 529 Overloaded"},
+		{`{"type":"error","error":{"type":"rate_limit_error"}}`, `{"type":"error","error":{"type":"rate_limit_error"}}`},
+		{"litellm.RateLimitError: AnthropicException - 429\n\n", "litellm.RateLimitError: AnthropicException - 429"},
+		{"API Error: 503 service unavailable\nI fixed the handler instead.", ""},
+		{"--- FAIL: TestDial\n    dial tcp: connection refused\nFAIL", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := APIErrorLine(tt.output); got != tt.want {
+			t.Errorf("APIErrorLine(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "deadline" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	if IsTransient(nil) {
+		t.Error("nil error should not be transient")
+	}
+	if IsTransient(context.Canceled) {
+		t.Error("context.Canceled should not be transient")
+	}
+	if IsTransient(fmt.Errorf("wrapped: %w", context.DeadlineExceeded)) {
+		t.Error("context.DeadlineExceeded should not be transient")
+	}
+	if !IsTransient(fmt.Errorf("pulling image: %w", timeoutErr{})) {
+		t.Error("net timeout error should be transient")
+	}
+	if !IsTransient(errors.New("503 Service Unavailable")) {
+		t.Error("503 should be transient")
+	}
+	if IsTransient(errors.New("no such image")) {
+		t.Error("unknown image should not be transient")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 4 * time.Second} {
+		for i := 0; i < 20; i++ {
+			d := p.Backoff(n)
+			if d < want/2 || d > want {
+				t.Fatalf("Backoff(%d) = %v, want in [%v, %v]", n, d, want/2, want)
+			}
+		}
+	}
+
+	if d := (Policy{}).Backoff(1); d != 0 {
+		t.Errorf("zero policy Backoff = %v, want 0", d)
+	}
+}
+
+func TestDo_RetriesTransientThenSucceeds(t *testing.T) {
+	calls := 0
+	var retries []int
+	err := Do(context.Background(), Policy{MaxRetries: 3}, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, func(n int, _ time.Duration, _ error) {
+		retries = append(retries, n)
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+	if len(retries) != 2 || retries[0] != 1 || retries[1] != 2 {
+		t.Errorf("unexpected retry numbers: %v", retries)
+	}
+}
+
+func TestDo_StopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Policy{MaxRetries: 3}, func() error {
+		calls++
+		return errors.New("invalid reference format")
+	}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestDo_ExhaustsRetries(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), Policy{MaxRetries: 2}, func() error {
+		calls++
+		return errors.New("rate limit exceeded")
+	}, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls (1 + 2 retries), got %d", calls)
+	}
+}
+
+func TestDo_ContextCancelledDuringWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Do(ctx, Policy{MaxRetries: 3, BaseDelay: time.Hour}, func() error {
+		calls++
+		return errors.New("overloaded")
+	}, func(int, time.Duration, error) { cancel() })
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
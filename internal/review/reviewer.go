@@ -90,11 +90,25 @@ func NewReviewer(agentName string, cfg *config.Config, cm *container.Manager, lo
 	}
 }
 
+// agentOptions returns the options the review agent is built with. The base
+// URL belongs to the primary agent's provider, so only a review agent of the
+// same kind is pointed at it.
+func (r *Reviewer) agentOptions() agent.Options {
+	if r.cfg == nil {
+		return agent.Options{}
+	}
+	opts := agent.Options{Network: r.cfg.Docker.Network}
+	if r.agentName == r.cfg.Agent.Name {
+		opts.BaseURL = r.cfg.Agent.BaseURL
+	}
+	return opts
+}
+
 // Review runs the review agent on the current diff.
 func (r *Reviewer) Review(ctx context.Context, projectPath, diff string, changedFiles []string, testSummary string) (*ReviewResult, error) {
 	prompt := r.buildPrompt(diff, changedFiles, testSummary)
 
-	ag, err := agent.New(r.agentName)
+	ag, err := agent.NewWithOptions(r.agentName, r.agentOptions())
 	if err != nil {
 		return nil, fmt.Errorf("creating review agent: %w", err)
 	}
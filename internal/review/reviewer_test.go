@@ -4,6 +4,9 @@ import (
 	"context"
 	"strings"
 	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/agent"
+	"github.com/swamp-dev/agentbox/internal/config"
 )
 
 func TestExtractJSON(t *testing.T) {
@@ -318,3 +321,30 @@ func TestParseReviewOutput_BlockersOverrideApproved(t *testing.T) {
 		t.Error("expected approved=false when blockers exist, even if JSON says true")
 	}
 }
+
+func TestReviewerAgentOptions_BaseURL(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agent.Name = "aider"
+	cfg.Agent.BaseURL = "http://localhost:11434/v1"
+
+	tests := []struct {
+		reviewAgent string
+		wantBaseURL string
+	}{
+		{reviewAgent: "aider", wantBaseURL: cfg.Agent.BaseURL},
+		{reviewAgent: "claude", wantBaseURL: ""},
+		{reviewAgent: "amp", wantBaseURL: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reviewAgent, func(t *testing.T) {
+			r := NewReviewer(tt.reviewAgent, cfg, nil, nil)
+			opts := r.agentOptions()
+			if opts.BaseURL != tt.wantBaseURL {
+				t.Errorf("BaseURL = %q, want %q", opts.BaseURL, tt.wantBaseURL)
+			}
+			if _, err := agent.NewWithOptions(tt.reviewAgent, opts); err != nil {
+				t.Errorf("creating review agent: %v", err)
+			}
+		})
+	}
+}
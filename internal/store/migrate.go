@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// baseSchemaVersion is the version produced by schema.sql. Migrations start
+// at the version after it.
+const baseSchemaVersion = 1
+
+// Migration upgrades the store schema from Version-1 to Version.
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	SQL     string `json:"-"`
+}
+
+// migrations is the embedded, ordered list of schema migrations.
+var migrations = mustLoadMigrations(migrationFiles, "migrations")
+
+func mustLoadMigrations(fsys fs.FS, dir string) []Migration {
+	ms, err := loadMigrations(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return ms
+}
+
+// loadMigrations reads NNNN_name.sql files from dir and checks that their
+// versions follow on from the base schema without gaps.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	var ms []Migration
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.sql", e.Name())
+		}
+		version, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", e.Name(), err)
+		}
+		ms = append(ms, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	for i, m := range ms {
+		if want := baseSchemaVersion + 1 + i; m.Version != want {
+			return nil, fmt.Errorf("migration %04d_%s: expected version %d", m.Version, m.Name, want)
+		}
+	}
+	return ms, nil
+}
+
+// LatestSchemaVersion returns the schema version this build migrates to.
+func LatestSchemaVersion() int {
+	return latestVersion(migrations)
+}
+
+func latestVersion(ms []Migration) int {
+	if len(ms) == 0 {
+		return baseSchemaVersion
+	}
+	return ms[len(ms)-1].Version
+}
+
+// pendingMigrations returns the migrations newer than version.
+func pendingMigrations(ms []Migration, version int) []Migration {
+	var pending []Migration
+	for _, m := range ms {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// migrate brings the schema up to date. A fresh database gets the base
+// schema first; an existing one is backed up before any migration runs.
+func (s *Store) migrate() error {
+	return s.migrateWith(migrations)
+}
+
+func (s *Store) migrateWith(ms []Migration) error {
+	version, err := readSchemaVersion(s.db)
+	if err != nil {
+		return err
+	}
+
+	// Another process may open the store at the same time, so every step
+	// below re-checks the version under the write lock before it runs.
+	fresh := false
+	if version == 0 {
+		if fresh, err = s.applyLocked(schemaSQL, baseSchemaVersion, nil); err != nil {
+			return fmt.Errorf("applying schema: %w", err)
+		}
+		if version, err = readSchemaVersion(s.db); err != nil {
+			return err
+		}
+	}
+
+	if latest := latestVersion(ms); version > latest {
+		return fmt.Errorf("schema version %d is newer than this agentbox supports (%d); upgrade agentbox", version, latest)
+	}
+
+	backup := func(current int) error {
+		if fresh || s.path == ":memory:" || s.backupPath != "" {
+			return nil
+		}
+		path, err := s.backup(current)
+		if err != nil {
+			return fmt.Errorf("backing up before migration: %w", err)
+		}
+		s.backupPath = path
+		return nil
+	}
+	for _, m := range pendingMigrations(ms, version) {
+		ran, err := s.applyLocked(m.SQL, m.Version, backup)
+		if err != nil {
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			s.applied = append(s.applied, m)
+		}
+	}
+	return nil
+}
+
+// applyLocked runs a schema script and records version in one transaction
+// that holds the write lock from the start. If the schema is already at
+// version, because another process got there first, nothing runs. Otherwise
+// before, when set, is called with the current version ahead of the script.
+// It reports whether the script ran.
+func (s *Store) applyLocked(script string, version int, before func(current int) error) (bool, error) {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// A deferred BEGIN would let two processes read the same version and
+	// both run the script.
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return false, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	current, err := readSchemaVersion(conn)
+	if err != nil || current >= version {
+		return false, err
+	}
+	if before != nil {
+		// The write lock still lets other connections read, as before does
+		// to take a backup.
+		if err := before(current); err != nil {
+			return false, err
+		}
+	}
+	if _, err := conn.ExecContext(ctx, script); err != nil {
+		return false, err
+	}
+	if _, err := conn.ExecContext(ctx, "INSERT INTO schema_version (version) VALUES (?)", version); err != nil {
+		return false, fmt.Errorf("recording schema version: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, err
+	}
+	committed = true
+	return true, nil
+}
+
+// backup writes a consistent copy of the database next to it and returns
+// its path.
+func (s *Store) backup(version int) (string, error) {
+	dest := fmt.Sprintf("%s.v%d-%s.bak", s.path, version, time.Now().Format("20060102-150405"))
+	if _, err := os.Stat(dest); err == nil {
+		return "", fmt.Errorf("backup %s already exists", dest)
+	}
+	// VACUUM INTO includes pages still in the WAL, unlike a file copy.
+	if _, err := s.db.Exec("VACUUM INTO ?", dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// rowQuerier is a *sql.DB or *sql.Conn.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readSchemaVersion returns the recorded schema version, or 0 for a
+// database with no schema yet.
+func readSchemaVersion(db rowQuerier) (int, error) {
+	ctx := context.Background()
+	var name string
+	err := db.QueryRowContext(ctx,
+		"SELECT name FROM sqlite_master WHERE type='table' AND name='schema_version'",
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("checking schema version: %w", err)
+	}
+
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// SchemaVersion returns the store's current schema version.
+func (s *Store) SchemaVersion() (int, error) {
+	return readSchemaVersion(s.db)
+}
+
+// AppliedMigrations returns the migrations Open applied to this store.
+func (s *Store) AppliedMigrations() []Migration {
+	return s.applied
+}
+
+// BackupPath returns the copy taken before Open migrated the store, or ""
+// when no migration ran.
+func (s *Store) BackupPath() string {
+	return s.backupPath
+}
+
+// SchemaStatus describes how far a store's schema is behind this build.
+type SchemaStatus struct {
+	Path    string      `json:"path"`
+	Version int         `json:"version"`
+	Latest  int         `json:"latest"`
+	Pending []Migration `json:"pending"`
+}
+
+// InspectSchema reports the schema status of the store at path without
+// migrating or otherwise changing it.
+func InspectSchema(path string) (*SchemaStatus, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no store at %s: %w", path, err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	version, err := readSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaStatus{
+		Path:    path,
+		Version: version,
+		Latest:  LatestSchemaVersion(),
+		Pending: pendingMigrations(migrations, version),
+	}, nil
+}
@@ -0,0 +1,259 @@
+package store
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// openUnmigrated opens the database at path without running migrations.
+func openUnmigrated(t *testing.T, path string) *Store {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Store{db: db, path: path}
+}
+
+func testMigrations(t *testing.T, files fstest.MapFS) []Migration {
+	t.Helper()
+	ms, err := loadMigrations(files, "m")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	return ms
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	if _, err := loadMigrations(migrationFiles, "migrations"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadMigrationsRejectsGaps(t *testing.T) {
+	_, err := loadMigrations(fstest.MapFS{
+		"m/0002_a.sql": {Data: []byte("SELECT 1;")},
+		"m/0004_b.sql": {Data: []byte("SELECT 1;")},
+	}, "m")
+	if err == nil || !strings.Contains(err.Error(), "expected version 3") {
+		t.Errorf("err = %v, want a gap error", err)
+	}
+	if _, err := loadMigrations(fstest.MapFS{"m/second.sql": {Data: []byte("SELECT 1;")}}, "m"); err == nil {
+		t.Error("unnumbered migration loaded, want error")
+	}
+}
+
+// openBaseSchema creates a database at path with only the base schema.
+func openBaseSchema(t *testing.T, path string) *Store {
+	t.Helper()
+	s := openUnmigrated(t, path)
+	if err := s.migrateWith(nil); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentbox.db")
+	s := openBaseSchema(t, path)
+	id, err := s.CreateSession("repo", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := testMigrations(t, fstest.MapFS{
+		"m/0002_labels.sql":        {Data: []byte("CREATE TABLE labels (name TEXT PRIMARY KEY);")},
+		"m/0003_session_label.sql": {Data: []byte("ALTER TABLE sessions ADD COLUMN label TEXT;")},
+		"m/README.md":              {Data: []byte("ignored")},
+	})
+
+	if err := s.migrateWith(ms); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if v, _ := s.SchemaVersion(); v != 3 {
+		t.Errorf("version = %d, want 3", v)
+	}
+	if len(s.AppliedMigrations()) != 2 {
+		t.Errorf("applied = %v, want 2 migrations", s.AppliedMigrations())
+	}
+	if _, err := s.db.Exec("UPDATE sessions SET label = 'x' WHERE id = ?", id); err != nil {
+		t.Errorf("migrated column missing: %v", err)
+	}
+
+	backup := s.BackupPath()
+	if backup == "" || !strings.Contains(backup, ".v1-") {
+		t.Fatalf("backup path = %q", backup)
+	}
+	old := openUnmigrated(t, backup)
+	if v, _ := old.SchemaVersion(); v != 1 {
+		t.Errorf("backup version = %d, want 1", v)
+	}
+	var n int
+	if err := old.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = ?", id).Scan(&n); err != nil || n != 1 {
+		t.Errorf("backup is missing session %d: %v", id, err)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	s := openBaseSchema(t, filepath.Join(t.TempDir(), "agentbox.db"))
+	ms := testMigrations(t, fstest.MapFS{
+		"m/0002_ok.sql":     {Data: []byte("CREATE TABLE labels (name TEXT);")},
+		"m/0003_broken.sql": {Data: []byte("CREATE TABLE half (x TEXT); ALTER TABLE nope ADD COLUMN y TEXT;")},
+	})
+
+	err := s.migrateWith(ms)
+	if err == nil || !strings.Contains(err.Error(), "0003_broken") {
+		t.Fatalf("err = %v, want failure naming 0003_broken", err)
+	}
+	if v, _ := s.SchemaVersion(); v != 2 {
+		t.Errorf("version = %d, want 2 after the failed migration", v)
+	}
+	var n int
+	s.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half'").Scan(&n)
+	if n != 0 {
+		t.Error("partial migration was not rolled back")
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	s := openTestStore(t)
+	if _, err := s.db.Exec("INSERT INTO schema_version (version) VALUES (?)", LatestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.migrate(); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("err = %v, want newer-schema error", err)
+	}
+}
+
+func TestFreshDatabaseNeedsNoBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agentbox.db")
+	s := openUnmigrated(t, path)
+	ms := testMigrations(t, fstest.MapFS{"m/0002_labels.sql": {Data: []byte("CREATE TABLE labels (name TEXT);")}})
+	if err := s.migrateWith(ms); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.SchemaVersion(); v != 2 {
+		t.Errorf("version = %d, want 2", v)
+	}
+	if s.BackupPath() != "" {
+		t.Errorf("fresh database was backed up to %s", s.BackupPath())
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.bak"))
+	if len(matches) != 0 {
+		t.Errorf("unexpected backups: %v", matches)
+	}
+}
+
+func TestInspectSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentbox.db")
+	if _, err := InspectSchema(path); err == nil {
+		t.Error("inspecting a missing store succeeded, want error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("InspectSchema created the database")
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	st, err := InspectSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Version != LatestSchemaVersion() || st.Latest != LatestSchemaVersion() || len(st.Pending) != 0 {
+		t.Errorf("status = %+v, want up to date", st)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agentbox.db")
+	if _, err := OpenReadOnly(path); err == nil {
+		t.Error("opening a missing store succeeded, want error")
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.CreateSession("repo", "main", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	if sess, err := ro.GetSession(id); err != nil || sess.RepoURL != "repo" {
+		t.Errorf("GetSession = %+v, %v", sess, err)
+	}
+	if _, err := ro.CreateSession("other", "main", ""); err == nil {
+		t.Error("wrote through a read-only store")
+	}
+	ro.Close()
+
+	// A store behind this build is refused, not migrated.
+	oldPath := filepath.Join(dir, "old.db")
+	openBaseSchema(t, oldPath).db.Close()
+	if _, err := OpenReadOnly(oldPath); err == nil || !strings.Contains(err.Error(), "agentbox db migrate") {
+		t.Errorf("OpenReadOnly on an old schema = %v, want a migrate error", err)
+	}
+	if st, err := InspectSchema(oldPath); err != nil || st.Version != baseSchemaVersion {
+		t.Errorf("old store after OpenReadOnly = %+v, %v; want it left at the base schema", st, err)
+	}
+	backups, _ := filepath.Glob(oldPath + ".v*.bak")
+	if len(backups) != 0 {
+		t.Errorf("OpenReadOnly took backups: %v", backups)
+	}
+}
+
+func TestConcurrentOpenMigratesOnce(t *testing.T) {
+	dir := t.TempDir()
+	fresh := filepath.Join(dir, "fresh.db")
+	old := filepath.Join(dir, "old.db")
+	openBaseSchema(t, old).db.Close()
+
+	for _, path := range []string{fresh, old} {
+		const n = 4
+		stores := make([]*Store, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stores[i], errs[i] = Open(path)
+			}()
+		}
+		wg.Wait()
+
+		applied := 0
+		for i, s := range stores {
+			if errs[i] != nil {
+				t.Errorf("Open %s #%d: %v", filepath.Base(path), i, errs[i])
+				continue
+			}
+			applied += len(s.AppliedMigrations())
+			s.Close()
+		}
+		st, err := InspectSchema(path)
+		if err != nil || st.Version != LatestSchemaVersion() {
+			t.Errorf("%s after concurrent opens = %+v, %v", filepath.Base(path), st, err)
+		}
+		if applied != len(migrations) {
+			t.Errorf("migrations applied %d times in total, want %d", applied, len(migrations))
+		}
+	}
+}
@@ -0,0 +1,2 @@
+-- Record when a session stopped so history listings can show durations.
+ALTER TABLE sessions ADD COLUMN ended_at DATETIME;
@@ -0,0 +1,74 @@
+-- Full-text index over transcripts, journal entries, task descriptions and
+-- review findings. Triggers keep it in sync with the source tables.
+--
+-- kind is transcript, journal, task or review; ref is the source row's id
+-- (the attempt id for transcripts, the task id for tasks).
+CREATE VIRTUAL TABLE search_index USING fts5(
+    kind UNINDEXED,
+    ref UNINDEXED,
+    session_id UNINDEXED,
+    task_id UNINDEXED,
+    body,
+    tokenize = 'unicode61'
+);
+
+CREATE TRIGGER search_attempts_insert AFTER INSERT ON attempts
+WHEN COALESCE(new.transcript, '') != ''
+BEGIN
+    INSERT INTO search_index (kind, ref, session_id, task_id, body)
+    VALUES ('transcript', new.id, new.session_id, new.task_id, new.transcript);
+END;
+
+CREATE TRIGGER search_attempts_update AFTER UPDATE OF transcript ON attempts
+BEGIN
+    DELETE FROM search_index WHERE kind = 'transcript' AND ref = old.id;
+    INSERT INTO search_index (kind, ref, session_id, task_id, body)
+    SELECT 'transcript', new.id, new.session_id, new.task_id, new.transcript
+    WHERE COALESCE(new.transcript, '') != '';
+END;
+
+CREATE TRIGGER search_journal_insert AFTER INSERT ON journal_entries
+BEGIN
+    INSERT INTO search_index (kind, ref, session_id, task_id, body)
+    VALUES ('journal', new.id, new.session_id, COALESCE(new.task_id, ''),
+            new.summary || char(10) || new.reflection);
+END;
+
+CREATE TRIGGER search_tasks_insert AFTER INSERT ON tasks
+BEGIN
+    INSERT INTO search_index (kind, ref, session_id, task_id, body)
+    VALUES ('task', new.id, new.session_id, new.id,
+            new.title || char(10) || COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER search_tasks_update AFTER UPDATE OF title, description ON tasks
+BEGIN
+    DELETE FROM search_index WHERE kind = 'task' AND ref = old.id;
+    INSERT INTO search_index (kind, ref, session_id, task_id, body)
+    VALUES ('task', new.id, new.session_id, new.id,
+            new.title || char(10) || COALESCE(new.description, ''));
+END;
+
+CREATE TRIGGER search_reviews_insert AFTER INSERT ON review_results
+BEGIN
+    INSERT INTO search_index (kind, ref, session_id, task_id, body)
+    VALUES ('review', new.id, new.session_id, '',
+            COALESCE(new.summary, '') || char(10) || COALESCE(new.findings_json, ''));
+END;
+
+-- Index what existing databases already hold.
+INSERT INTO search_index (kind, ref, session_id, task_id, body)
+SELECT 'transcript', id, session_id, task_id, transcript FROM attempts
+WHERE COALESCE(transcript, '') != '';
+
+INSERT INTO search_index (kind, ref, session_id, task_id, body)
+SELECT 'journal', id, session_id, COALESCE(task_id, ''), summary || char(10) || reflection
+FROM journal_entries;
+
+INSERT INTO search_index (kind, ref, session_id, task_id, body)
+SELECT 'task', id, session_id, id, title || char(10) || COALESCE(description, '')
+FROM tasks;
+
+INSERT INTO search_index (kind, ref, session_id, task_id, body)
+SELECT 'review', id, session_id, '', COALESCE(summary, '') || char(10) || COALESCE(findings_json, '')
+FROM review_results;
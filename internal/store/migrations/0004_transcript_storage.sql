@@ -0,0 +1,20 @@
+-- Transcripts move out of the attempts.transcript text column: new ones are
+-- stored zstd-compressed in transcript_zstd, or as a content-addressed file
+-- under .agentbox/blobs/ named by transcript_blob. Older rows keep their
+-- inline text and are read as before.
+ALTER TABLE attempts ADD COLUMN transcript_zstd BLOB;
+ALTER TABLE attempts ADD COLUMN transcript_blob TEXT;
+ALTER TABLE attempts ADD COLUMN transcript_size INTEGER NOT NULL DEFAULT 0;
+
+UPDATE attempts SET transcript_size = LENGTH(CAST(transcript AS BLOB))
+WHERE COALESCE(transcript, '') != '';
+
+-- How long this session's transcripts are kept, in seconds. NULL uses the
+-- project default; 0 keeps them forever.
+ALTER TABLE sessions ADD COLUMN transcript_retention INTEGER;
+
+-- The store indexes transcripts itself now that the text is compressed.
+DROP TRIGGER search_attempts_insert;
+DROP TRIGGER search_attempts_update;
+
+CREATE INDEX IF NOT EXISTS idx_attempts_transcript_blob ON attempts(transcript_blob);
@@ -0,0 +1,4 @@
+-- The pull request a session opened, so a resumed session keeps updating
+-- the same one instead of opening another.
+ALTER TABLE sessions ADD COLUMN pr_number INTEGER;
+ALTER TABLE sessions ADD COLUMN pr_url TEXT;
@@ -0,0 +1,3 @@
+-- Where a session's git worktree lives, so resume and cleanup find it
+-- without guessing from the branch name.
+ALTER TABLE sessions ADD COLUMN worktree_path TEXT;
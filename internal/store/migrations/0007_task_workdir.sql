@@ -0,0 +1,3 @@
+-- The subdirectory of a monorepo a task is scoped to, relative to the
+-- project root. NULL means the whole project.
+ALTER TABLE tasks ADD COLUMN workdir TEXT;
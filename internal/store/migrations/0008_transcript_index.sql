@@ -0,0 +1,15 @@
+-- Transcripts get their own contentless full-text index, so the index no
+-- longer keeps an uncompressed copy of text the attempts table stores
+-- compressed. Its rowid is the attempt id. Search cuts snippets from the
+-- decompressed transcript.
+CREATE VIRTUAL TABLE transcript_index USING fts5(
+    body,
+    content = '',
+    contentless_delete = 1,
+    tokenize = 'unicode61'
+);
+
+INSERT INTO transcript_index (rowid, body)
+SELECT CAST(ref AS INTEGER), body FROM search_index WHERE kind = 'transcript';
+
+DELETE FROM search_index WHERE kind = 'transcript';
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- Full-text search ---
+
+// SearchQuery filters a full-text search.
+type SearchQuery struct {
+	Text      string
+	SessionID int64  // 0 searches every session
+	TaskID    string // "" searches every task
+	Kinds     []string
+	Limit     int // defaults to 20
+}
+
+// SearchResult is one ranked match from Search.
+type SearchResult struct {
+	Kind      string  `json:"kind"` // transcript, journal, task or review
+	Ref       string  `json:"ref"`
+	SessionID int64   `json:"session_id"`
+	TaskID    string  `json:"task_id,omitempty"`
+	AttemptID int64   `json:"attempt_id,omitempty"`
+	Snippet   string  `json:"snippet"`
+	Score     float64 `json:"score"` // higher is better; comparable within one search only
+}
+
+// rrfK is the rank offset in reciprocal rank fusion; 60 is the customary
+// value.
+const rrfK = 60
+
+// Search finds transcripts, journal entries, task descriptions and review
+// findings matching every word of q.Text, best matches first. Words are
+// matched literally, so input such as "auth.ts" needs no FTS5 escaping.
+//
+// Transcripts and the other kinds live in separate FTS5 tables, whose bm25
+// scores depend on each table's size and so cannot be compared. Each table
+// is ranked on its own and the two rankings are merged by reciprocal rank
+// fusion: a result's score is 1/(rrfK+rank) within its table.
+func (s *Store) Search(q SearchQuery) ([]*SearchResult, error) {
+	match := ftsQuery(q.Text)
+	if match == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	var results []*SearchResult
+	var others []string
+	transcripts := len(q.Kinds) == 0
+	for _, k := range q.Kinds {
+		if k == "transcript" {
+			transcripts = true
+		} else {
+			others = append(others, k)
+		}
+	}
+	if transcripts {
+		found, err := s.searchTranscripts(match, q, limit)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fuseRanks(found)...)
+	}
+	if len(q.Kinds) == 0 || len(others) > 0 {
+		found, err := s.searchIndex(match, q, others, limit)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fuseRanks(found)...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// fuseRanks scores results, best first from one table, by their rank.
+func fuseRanks(results []*SearchResult) []*SearchResult {
+	for i, r := range results {
+		r.Score = 1 / float64(rrfK+i+1)
+	}
+	return results
+}
+
+// searchIndex searches journal entries, task descriptions and review
+// findings, of the given kinds or of every kind when kinds is empty.
+func (s *Store) searchIndex(match string, q SearchQuery, kinds []string, limit int) ([]*SearchResult, error) {
+	query := `SELECT kind, ref, session_id, task_id,
+		 snippet(search_index, 4, '[', ']', '…', 16)
+		 FROM search_index WHERE search_index MATCH ?`
+	args := []interface{}{match}
+	if q.SessionID > 0 {
+		query += " AND session_id = ?"
+		args = append(args, q.SessionID)
+	}
+	if q.TaskID != "" {
+		query += " AND task_id = ?"
+		args = append(args, q.TaskID)
+	}
+	if len(kinds) > 0 {
+		query += " AND kind IN (?" + strings.Repeat(", ?", len(kinds)-1) + ")"
+		for _, k := range kinds {
+			args = append(args, k)
+		}
+	}
+	query += " ORDER BY rank LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*SearchResult
+	for rows.Next() {
+		r := &SearchResult{}
+		if err := rows.Scan(&r.Kind, &r.Ref, &r.SessionID, &r.TaskID, &r.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// searchTranscripts searches the contentless transcript index. It holds no
+// text, so snippets are cut from the decompressed transcripts.
+func (s *Store) searchTranscripts(match string, q SearchQuery, limit int) ([]*SearchResult, error) {
+	query := `SELECT a.id, a.session_id, a.task_id
+		 FROM transcript_index JOIN attempts a ON a.id = transcript_index.rowid
+		 WHERE transcript_index MATCH ?`
+	args := []interface{}{match}
+	if q.SessionID > 0 {
+		query += " AND a.session_id = ?"
+		args = append(args, q.SessionID)
+	}
+	if q.TaskID != "" {
+		query += " AND a.task_id = ?"
+		args = append(args, q.TaskID)
+	}
+	query += " ORDER BY rank LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching transcripts: %w", err)
+	}
+	var results []*SearchResult
+	for rows.Next() {
+		r := &SearchResult{Kind: "transcript"}
+		if err := rows.Scan(&r.AttemptID, &r.SessionID, &r.TaskID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.Ref = strconv.FormatInt(r.AttemptID, 10)
+		results = append(results, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		text, err := s.GetTranscript(r.AttemptID)
+		if err != nil {
+			return nil, fmt.Errorf("reading transcript %d: %w", r.AttemptID, err)
+		}
+		r.Snippet = snippet(text, q.Text, 16)
+	}
+	return results, nil
+}
+
+// snippet returns about n words of text around the first word containing
+// one of the query's words, with matching words in brackets, in the style
+// of FTS5's snippet().
+func snippet(text, query string, n int) string {
+	terms := strings.Fields(strings.ToLower(query))
+	words := strings.Fields(text)
+	matches := func(w string) bool {
+		lw := strings.ToLower(w)
+		for _, t := range terms {
+			if strings.Contains(lw, t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first := 0
+	for i, w := range words {
+		if matches(w) {
+			first = i
+			break
+		}
+	}
+	start := max(0, first-n/4)
+	end := min(len(words), start+n)
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i, w := range words[start:end] {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if matches(w) {
+			w = "[" + w + "]"
+		}
+		sb.WriteString(w)
+	}
+	if end < len(words) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+// ftsQuery turns free text into an FTS5 query that matches rows containing
+// every word, quoting each word so punctuation is not parsed as syntax.
+func ftsQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
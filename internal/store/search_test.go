@@ -0,0 +1,140 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	s := openTestStore(t)
+	sess1, _ := s.CreateSession("", "one", "")
+	sess2, _ := s.CreateSession("", "two", "")
+	for _, task := range []*Task{
+		{ID: "t-auth", SessionID: sess1, Title: "Harden login", Description: "Validate tokens in auth.ts", Status: "pending", MaxAttempts: 3},
+		{ID: "t-docs", SessionID: sess2, Title: "Write docs", Status: "pending", MaxAttempts: 3},
+	} {
+		if err := s.InsertTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attemptID, _ := s.RecordAttempt(&Attempt{TaskID: "t-auth", SessionID: sess1, Number: 1, AgentName: "claude", StartedAt: time.Now()})
+	if err := s.SaveTranscript(attemptID, "editing auth.ts\npanic: runtime error: nil pointer dereference"); err != nil {
+		t.Fatal(err)
+	}
+	otherAttempt, _ := s.RecordAttempt(&Attempt{TaskID: "t-docs", SessionID: sess2, Number: 1, AgentName: "claude", StartedAt: time.Now()})
+	_ = s.SaveTranscript(otherAttempt, "README updated, no nil pointer here")
+	_ = s.AddJournalEntry(&JournalEntry{SessionID: sess1, Kind: "reflection", TaskID: "t-auth", Iteration: 1, Summary: "auth fix", Reflection: "the nil pointer came from an unset session"})
+	_ = s.SaveReviewResult(&ReviewResult{SessionID: sess1, Sprint: 1, ReviewAgent: "amp", Summary: "auth.ts lacks tests"})
+
+	results, err := s.Search(SearchQuery{Text: "auth.ts nil pointer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Kind != "transcript" || results[0].AttemptID != attemptID || results[0].TaskID != "t-auth" {
+		t.Fatalf("results = %+v, want only the auth transcript", results)
+	}
+	if results[0].Snippet == "" || results[0].Score <= 0 {
+		t.Errorf("result = %+v, want a snippet and positive score", results[0])
+	}
+
+	results, _ = s.Search(SearchQuery{Text: "nil pointer"})
+	if len(results) != 3 {
+		t.Errorf("'nil pointer' matched %d rows, want 3", len(results))
+	}
+	results, _ = s.Search(SearchQuery{Text: "nil pointer", SessionID: sess2})
+	if len(results) != 1 || results[0].AttemptID != otherAttempt {
+		t.Errorf("session filter = %+v", results)
+	}
+	results, _ = s.Search(SearchQuery{Text: "auth", Kinds: []string{"review"}})
+	if len(results) != 1 || results[0].Kind != "review" {
+		t.Errorf("kind filter = %+v", results)
+	}
+	results, _ = s.Search(SearchQuery{Text: "tokens", TaskID: "t-auth"})
+	if len(results) != 1 || results[0].Kind != "task" || results[0].Ref != "t-auth" {
+		t.Errorf("task filter = %+v", results)
+	}
+
+	// Updating or pruning a transcript updates the index.
+	_ = s.SaveTranscript(attemptID, "all green")
+	if results, _ := s.Search(SearchQuery{Text: "auth.ts", Kinds: []string{"transcript"}}); len(results) != 0 {
+		t.Errorf("stale transcript still indexed: %+v", results)
+	}
+
+	if _, err := s.Search(SearchQuery{Text: "   "}); err == nil {
+		t.Error("empty query succeeded, want error")
+	}
+	if _, err := s.Search(SearchQuery{Text: `"unbalanced AND (`}); err != nil {
+		t.Errorf("query with FTS syntax characters failed: %v", err)
+	}
+}
+
+func TestSearchIndexBackfill(t *testing.T) {
+	s := openBaseSchema(t, t.TempDir()+"/agentbox.db")
+	id, _ := s.CreateSession("", "main", "")
+	if _, err := s.db.Exec(
+		`INSERT INTO tasks (id, session_id, title, description) VALUES ('t-1', ?, 'Old task', 'migrate the widget cache')`, id,
+	); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.db.Exec(
+		`INSERT INTO attempts (task_id, session_id, number, agent_name, started_at, transcript)
+		 VALUES ('t-1', ?, 1, 'claude', CURRENT_TIMESTAMP, 'flushing the widget cache took 3s')`, id,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attemptID, _ := res.LastInsertId()
+
+	if err := s.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Search(SearchQuery{Text: "widget cache"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want the pre-existing task and transcript", results)
+	}
+	for _, r := range results {
+		if r.Kind == "transcript" && (r.AttemptID != attemptID || !strings.Contains(r.Snippet, "[widget]")) {
+			t.Errorf("transcript result = %+v", r)
+		}
+	}
+	var copies int
+	_ = s.db.QueryRow("SELECT COUNT(*) FROM search_index WHERE kind = 'transcript'").Scan(&copies)
+	if copies != 0 {
+		t.Errorf("%d transcripts still stored in search_index", copies)
+	}
+}
+
+func TestSearchInterleavesSources(t *testing.T) {
+	s := openTestStore(t)
+	sess, _ := s.CreateSession("", "one", "")
+	if err := s.InsertTask(&Task{ID: "t-1", SessionID: sess, Title: "Fix sync", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	// Many short transcripts full of the term would outscore everything in
+	// the other table if bm25 scores were compared across tables.
+	for i := range 5 {
+		id, _ := s.RecordAttempt(&Attempt{TaskID: "t-1", SessionID: sess, Number: i + 1, AgentName: "claude", StartedAt: time.Now()})
+		if err := s.SaveTranscript(id, "deadlock deadlock deadlock"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.AddJournalEntry(&JournalEntry{SessionID: sess, Kind: "reflection", TaskID: "t-1", Iteration: 1,
+		Summary: "sync work", Reflection: "the worker pool can deadlock when the queue is full and every worker waits on a reply that is itself queued behind it"})
+
+	results, err := s.Search(SearchQuery{Text: "deadlock", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, r := range results {
+		kinds = append(kinds, r.Kind)
+	}
+	if strings.Join(kinds, ",") != "transcript,journal" {
+		t.Errorf("kinds = %v, want the best of each source", kinds)
+	}
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Session history ---
+
+// SessionSummary aggregates one session for history listings and
+// comparisons.
+type SessionSummary struct {
+	Session            *Session   `json:"session"`
+	DurationMs         int64      `json:"duration_ms"`
+	Tasks              *TaskStats `json:"tasks"`
+	Attempts           int        `json:"attempts"`
+	SuccessfulAttempts int        `json:"successful_attempts"`
+	Tokens             int        `json:"tokens"`
+	ContainerTimeMs    int        `json:"container_time_ms"`
+	Sprints            int        `json:"sprints"`
+	Velocity           float64    `json:"velocity"`
+	TestPassRate       float64    `json:"test_pass_rate"`
+	QualityTrend       string     `json:"quality_trend"`
+}
+
+// SuccessRate returns the fraction of attempts that succeeded.
+func (s *SessionSummary) SuccessRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.SuccessfulAttempts) / float64(s.Attempts)
+}
+
+// SummarizeSession aggregates a session's tasks, attempts, usage and sprint
+// metrics. Velocity is the mean tasks completed per sprint, and the test
+// pass rate covers every quality snapshot in the session.
+func (s *Store) SummarizeSession(id int64) (*SessionSummary, error) {
+	sess, err := s.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.summarize(sess)
+}
+
+// SessionSummaries summarizes up to limit sessions, newest first. A limit
+// of zero or less summarizes every session.
+func (s *Store) SessionSummaries(limit int) ([]*SessionSummary, error) {
+	sessions, err := s.ListSessions(limit)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]*SessionSummary, 0, len(sessions))
+	for _, sess := range sessions {
+		sum, err := s.summarize(sess)
+		if err != nil {
+			return nil, fmt.Errorf("summarizing session %d: %w", sess.ID, err)
+		}
+		summaries = append(summaries, sum)
+	}
+	return summaries, nil
+}
+
+func (s *Store) summarize(sess *Session) (*SessionSummary, error) {
+	sum := &SessionSummary{Session: sess}
+
+	var err error
+	if sum.Tasks, err = s.taskStats(sess.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0)
+		 FROM attempts WHERE session_id = ?`, sess.ID,
+	).Scan(&sum.Attempts, &sum.SuccessfulAttempts); err != nil {
+		return nil, fmt.Errorf("counting attempts: %w", err)
+	}
+
+	usage, err := s.TotalUsage(sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	sum.Tokens = usage.EstimatedTokens
+	sum.ContainerTimeMs = usage.ContainerTimeMs
+
+	reports, err := s.SprintReports(sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	sum.Sprints = len(reports)
+	if len(reports) > 0 {
+		completed := 0
+		for _, r := range reports {
+			completed += r.TasksCompleted
+		}
+		sum.Velocity = float64(completed) / float64(len(reports))
+	}
+
+	// SQLite treats a negative LIMIT as no limit.
+	if sum.TestPassRate, err = s.TestPassRate(sess.ID, -1); err != nil {
+		return nil, err
+	}
+	if sum.QualityTrend, err = s.QualityTrend(sess.ID, 10); err != nil {
+		return nil, err
+	}
+
+	end, err := s.sessionEnd(sess)
+	if err != nil {
+		return nil, err
+	}
+	if end.After(sess.StartedAt) {
+		sum.DurationMs = end.Sub(sess.StartedAt).Milliseconds()
+	}
+	return sum, nil
+}
+
+// sessionEnd returns when a session stopped. Sessions recorded before
+// ended_at existed fall back to the end of their last attempt, and running
+// sessions are measured up to now.
+func (s *Store) sessionEnd(sess *Session) (time.Time, error) {
+	if sess.EndedAt != nil {
+		return *sess.EndedAt, nil
+	}
+	if sess.Status == "running" {
+		return time.Now(), nil
+	}
+
+	var startedAt time.Time
+	var completedAt sql.NullTime
+	var durationMs int
+	err := s.db.QueryRow(
+		`SELECT started_at, completed_at, duration_ms FROM attempts
+		 WHERE session_id = ? ORDER BY id DESC LIMIT 1`, sess.ID,
+	).Scan(&startedAt, &completedAt, &durationMs)
+	if err == sql.ErrNoRows {
+		return sess.StartedAt, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("finding last attempt: %w", err)
+	}
+	if completedAt.Valid {
+		return completedAt.Time, nil
+	}
+	return startedAt.Add(time.Duration(durationMs) * time.Millisecond), nil
+}
+
+// SessionExport is everything the store holds about one session.
+type SessionExport struct {
+	Summary       *SessionSummary     `json:"summary"`
+	Tasks         []*Task             `json:"tasks"`
+	Dependencies  map[string][]string `json:"dependencies"`
+	Attempts      []*Attempt          `json:"attempts"`
+	Quality       []*QualitySnapshot  `json:"quality"`
+	Journal       []*JournalEntry     `json:"journal"`
+	SprintReports []*SprintReport     `json:"sprint_reports"`
+	Reviews       []*ReviewResult     `json:"reviews"`
+}
+
+// ExportSession gathers a session's full history. Transcripts are only
+// included when transcripts is true, since they dominate the size.
+func (s *Store) ExportSession(id int64, transcripts bool) (*SessionExport, error) {
+	sum, err := s.SummarizeSession(id)
+	if err != nil {
+		return nil, err
+	}
+	e := &SessionExport{Summary: sum}
+
+	if e.Tasks, err = s.ListTasks(id); err != nil {
+		return nil, fmt.Errorf("loading tasks: %w", err)
+	}
+	if e.Dependencies, err = s.GetAllDependencies(id); err != nil {
+		return nil, fmt.Errorf("loading dependencies: %w", err)
+	}
+	if e.Attempts, err = s.SessionAttempts(id); err != nil {
+		return nil, fmt.Errorf("loading attempts: %w", err)
+	}
+	if transcripts {
+		for _, a := range e.Attempts {
+			if a.Transcript, err = s.GetTranscript(a.ID); err != nil {
+				return nil, fmt.Errorf("loading transcript for attempt %d: %w", a.ID, err)
+			}
+		}
+	}
+	if e.Quality, err = s.QualitySnapshots(id); err != nil {
+		return nil, fmt.Errorf("loading quality snapshots: %w", err)
+	}
+	if e.Journal, err = s.JournalEntries(id, nil); err != nil {
+		return nil, fmt.Errorf("loading journal: %w", err)
+	}
+	if e.SprintReports, err = s.SprintReports(id); err != nil {
+		return nil, fmt.Errorf("loading sprint reports: %w", err)
+	}
+	if e.Reviews, err = s.ReviewResults(id); err != nil {
+		return nil, fmt.Errorf("loading reviews: %w", err)
+	}
+	return e, nil
+}
+
+// TimelineEvent is one entry in a session's timeline.
+type TimelineEvent struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"` // session, attempt, quality, journal, sprint, review
+	TaskID string    `json:"task_id,omitempty"`
+	Detail string    `json:"detail"`
+}
+
+// Timeline merges the export's records into one chronological list.
+func (e *SessionExport) Timeline() []TimelineEvent {
+	sess := e.Summary.Session
+	events := []TimelineEvent{{Time: sess.StartedAt, Kind: "session", Detail: "started on " + sess.BranchName}}
+
+	for _, a := range e.Attempts {
+		detail := fmt.Sprintf("attempt %d by %s", a.Number, a.AgentName)
+		switch {
+		case a.Success == nil:
+			detail += ": no result"
+		case *a.Success:
+			detail += ": succeeded"
+		default:
+			detail += ": failed"
+			if a.ErrorMsg != "" {
+				detail += " (" + a.ErrorMsg + ")"
+			}
+		}
+		events = append(events, TimelineEvent{Time: a.StartedAt, Kind: "attempt", TaskID: a.TaskID, Detail: detail})
+	}
+	for _, q := range e.Quality {
+		result := "failed"
+		if q.OverallPass {
+			result = "passed"
+		}
+		events = append(events, TimelineEvent{
+			Time: q.Timestamp, Kind: "quality", TaskID: q.TaskID,
+			Detail: fmt.Sprintf("checks %s, %d/%d tests passed", result, q.TestPassed, q.TestTotal),
+		})
+	}
+	for _, j := range e.Journal {
+		events = append(events, TimelineEvent{Time: j.Timestamp, Kind: "journal", TaskID: j.TaskID, Detail: j.Kind + ": " + j.Summary})
+	}
+	for _, r := range e.SprintReports {
+		events = append(events, TimelineEvent{
+			Time: r.Timestamp, Kind: "sprint",
+			Detail: fmt.Sprintf("sprint %d: %d/%d tasks completed, quality %s", r.SprintNumber, r.TasksCompleted, r.TasksAttempted, r.QualityTrend),
+		})
+	}
+	for _, r := range e.Reviews {
+		verdict := "changes requested"
+		if r.Approved {
+			verdict = "approved"
+		}
+		events = append(events, TimelineEvent{
+			Time: r.ReviewedAt, Kind: "review",
+			Detail: fmt.Sprintf("sprint %d review by %s: %s", r.Sprint, r.ReviewAgent, verdict),
+		})
+	}
+	if sess.EndedAt != nil {
+		events = append(events, TimelineEvent{Time: *sess.EndedAt, Kind: "session", Detail: "ended " + sess.Status})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+// QualitySnapshots returns all quality snapshots for a session, oldest first.
+func (s *Store) QualitySnapshots(sessionID int64) ([]*QualitySnapshot, error) {
+	rows, err := s.db.Query(
+		`SELECT id, session_id, attempt_id, iteration, COALESCE(task_id, ''), overall_pass,
+		 COALESCE(checks_json, ''), test_total, test_passed, test_failed, test_skipped,
+		 COALESCE(failed_tests_json, ''), timestamp
+		 FROM quality_snapshots WHERE session_id = ? ORDER BY id ASC`, sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []*QualitySnapshot
+	for rows.Next() {
+		q := &QualitySnapshot{}
+		var attemptID sql.NullInt64
+		var overallPass sql.NullBool
+		if err := rows.Scan(&q.ID, &q.SessionID, &attemptID, &q.Iteration, &q.TaskID,
+			&overallPass, &q.ChecksJSON, &q.TestTotal, &q.TestPassed, &q.TestFailed,
+			&q.TestSkipped, &q.FailedTestsJSON, &q.Timestamp); err != nil {
+			return nil, err
+		}
+		if attemptID.Valid {
+			q.AttemptID = &attemptID.Int64
+		}
+		q.OverallPass = overallPass.Bool
+		snapshots = append(snapshots, q)
+	}
+	return snapshots, rows.Err()
+}
+
+// ReviewResults returns all review results for a session, oldest first.
+func (s *Store) ReviewResults(sessionID int64) ([]*ReviewResult, error) {
+	rows, err := s.db.Query(
+		`SELECT id, session_id, COALESCE(sprint, 0), review_agent, COALESCE(findings_json, ''),
+		 COALESCE(summary, ''), COALESCE(approved, 0), reviewed_at
+		 FROM review_results WHERE session_id = ? ORDER BY id ASC`, sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*ReviewResult
+	for rows.Next() {
+		r := &ReviewResult{}
+		if err := rows.Scan(&r.ID, &r.SessionID, &r.Sprint, &r.ReviewAgent, &r.FindingsJSON,
+			&r.Summary, &r.Approved, &r.ReviewedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,225 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedHistory creates a finished session with two sprints, attempts,
+// quality snapshots, a journal entry and a review.
+func seedHistory(t *testing.T, s *Store, branch string, completed int) int64 {
+	t.Helper()
+	id, err := s.CreateSession("", branch, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	if _, err := s.db.Exec("UPDATE sessions SET started_at = ? WHERE id = ?", start.Add(-time.Minute), id); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		status := "pending"
+		if i < completed {
+			status = "completed"
+		}
+		taskID := branch + "-" + string(rune('a'+i))
+		if err := s.InsertTask(&Task{ID: taskID, SessionID: id, Title: taskID, Status: status, MaxAttempts: 3}); err != nil {
+			t.Fatal(err)
+		}
+		ok := i < completed
+		end := start.Add(time.Duration(i+1) * time.Minute)
+		attemptID, err := s.RecordAttempt(&Attempt{
+			TaskID: taskID, SessionID: id, Number: 1, AgentName: "claude",
+			StartedAt: start.Add(time.Duration(i) * time.Minute), CompletedAt: &end, Success: &ok,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveTranscript(attemptID, strings.Repeat("x", 100)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordQuality(&QualitySnapshot{SessionID: id, Iteration: i + 1, TaskID: taskID, OverallPass: ok, TestTotal: 10, TestPassed: 8}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RecordUsage(&ResourceUsage{SessionID: id, Iteration: i + 1, EstimatedTokens: 1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for sprint := 1; sprint <= 2; sprint++ {
+		if err := s.SaveSprintReport(&SprintReport{SessionID: id, SprintNumber: sprint, TasksCompleted: completed, TasksAttempted: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddJournalEntry(&JournalEntry{SessionID: id, Kind: "reflection", Iteration: 1, Summary: "went fine", Reflection: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveReviewResult(&ReviewResult{SessionID: id, Sprint: 1, ReviewAgent: "amp", Approved: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateSessionStatus(id, "completed"); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestUpdateSessionStatusRecordsEnd(t *testing.T) {
+	s := openTestStore(t)
+	id, _ := s.CreateSession("", "main", "")
+
+	if err := s.UpdateSessionStatus(id, "interrupted"); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := s.GetSession(id)
+	if sess.EndedAt == nil {
+		t.Fatal("ended_at not set for interrupted session")
+	}
+
+	if err := s.UpdateSessionStatus(id, "running"); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ = s.GetSession(id)
+	if sess.EndedAt != nil {
+		t.Errorf("ended_at = %v after resume, want nil", sess.EndedAt)
+	}
+}
+
+func TestSetSessionPR(t *testing.T) {
+	s := openTestStore(t)
+	id, _ := s.CreateSession("", "feat/x", "")
+
+	if sess, _ := s.GetSession(id); sess.PRNumber != 0 || sess.PRURL != "" {
+		t.Fatalf("new session has PR %d %q", sess.PRNumber, sess.PRURL)
+	}
+	if err := s.SetSessionPR(id, 12, "https://example.com/pulls/12"); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := s.GetSession(id)
+	if sess.PRNumber != 12 || sess.PRURL != "https://example.com/pulls/12" {
+		t.Errorf("PR = %d %q", sess.PRNumber, sess.PRURL)
+	}
+}
+
+func TestSetSessionWorktree(t *testing.T) {
+	s := openTestStore(t)
+	id, _ := s.CreateSession("", "", "")
+
+	if err := s.SetSessionWorktree(id, "feat/agentbox-sprint-1", "/src/feat-agentbox-sprint-1"); err != nil {
+		t.Fatal(err)
+	}
+	sess, _ := s.GetSession(id)
+	if sess.BranchName != "feat/agentbox-sprint-1" || sess.WorktreePath != "/src/feat-agentbox-sprint-1" {
+		t.Errorf("branch, worktree = %q, %q", sess.BranchName, sess.WorktreePath)
+	}
+}
+
+func TestSessionSummaries(t *testing.T) {
+	s := openTestStore(t)
+	seedHistory(t, s, "old", 1)
+	newer := seedHistory(t, s, "new", 3)
+
+	sums, err := s.SessionSummaries(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 2 || sums[0].Session.ID != newer {
+		t.Fatalf("summaries = %d, first %d; want 2 newest first", len(sums), sums[0].Session.ID)
+	}
+	sum := sums[0]
+	if sum.Tasks.Total != 3 || sum.Tasks.Completed != 3 {
+		t.Errorf("tasks = %+v", sum.Tasks)
+	}
+	if sum.Attempts != 3 || sum.SuccessfulAttempts != 3 || sum.SuccessRate() != 1 {
+		t.Errorf("attempts = %d/%d", sum.SuccessfulAttempts, sum.Attempts)
+	}
+	if sum.Tokens != 3000 || sum.Sprints != 2 || sum.Velocity != 3 {
+		t.Errorf("tokens %d sprints %d velocity %.1f", sum.Tokens, sum.Sprints, sum.Velocity)
+	}
+	if sum.TestPassRate != 0.8 {
+		t.Errorf("test pass rate = %.2f, want 0.80", sum.TestPassRate)
+	}
+	if sum.DurationMs <= 0 {
+		t.Errorf("duration = %d, want positive", sum.DurationMs)
+	}
+	if sums[1].Velocity != 1 {
+		t.Errorf("old session velocity = %.1f, want 1", sums[1].Velocity)
+	}
+}
+
+func TestExportSessionTimeline(t *testing.T) {
+	s := openTestStore(t)
+	id := seedHistory(t, s, "main", 2)
+
+	e, err := s.ExportSession(id, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Tasks) != 3 || len(e.Attempts) != 3 || len(e.Quality) != 3 || len(e.Reviews) != 1 || len(e.Journal) != 1 {
+		t.Errorf("export counts: tasks %d attempts %d quality %d reviews %d journal %d",
+			len(e.Tasks), len(e.Attempts), len(e.Quality), len(e.Reviews), len(e.Journal))
+	}
+	if e.Attempts[0].Transcript != "" {
+		t.Error("transcript exported without asking for it")
+	}
+	withTranscripts, _ := s.ExportSession(id, true)
+	if len(withTranscripts.Attempts[0].Transcript) != 100 {
+		t.Error("transcript missing from export")
+	}
+
+	events := e.Timeline()
+	if events[0].Kind != "session" || !strings.Contains(events[0].Detail, "started") {
+		t.Errorf("first event = %+v, want session start", events[0])
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Time.Before(events[i-1].Time) {
+			t.Fatalf("timeline out of order at %d: %v before %v", i, events[i].Time, events[i-1].Time)
+		}
+	}
+	kinds := map[string]bool{}
+	for _, ev := range events {
+		kinds[ev.Kind] = true
+	}
+	for _, k := range []string{"session", "attempt", "quality", "journal", "sprint", "review"} {
+		if !kinds[k] {
+			t.Errorf("timeline has no %s events", k)
+		}
+	}
+}
+
+func TestPruneTranscripts(t *testing.T) {
+	s := openTestStore(t)
+	seedHistory(t, s, "old", 1)
+	seedHistory(t, s, "new", 3)
+	running, _ := s.CreateSession("", "live", "")
+	if err := s.InsertTask(&Task{ID: "live-a", SessionID: running, Title: "live", Status: "in_progress", MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	liveAttempt, _ := s.RecordAttempt(&Attempt{TaskID: "live-a", SessionID: running, Number: 1, AgentName: "claude", StartedAt: time.Now()})
+	_ = s.SaveTranscript(liveAttempt, strings.Repeat("y", 1000))
+
+	// Six finished transcripts of 100 bytes plus one running of 1000.
+	dry, err := s.PruneTranscripts(PruneOptions{MaxBytes: 1300, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dry.Transcripts != 3 || dry.Bytes != 300 || dry.Vacuumed {
+		t.Errorf("dry run = %+v, want 3 transcripts / 300 bytes, no vacuum", dry)
+	}
+	if tr, _ := s.GetTranscript(1); tr == "" {
+		t.Error("dry run removed a transcript")
+	}
+
+	res, err := s.PruneTranscripts(PruneOptions{Before: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Transcripts != 6 || !res.Vacuumed {
+		t.Errorf("prune by age = %+v, want 6 transcripts and a vacuum", res)
+	}
+	if tr, _ := s.GetTranscript(liveAttempt); tr == "" {
+		t.Error("running session's transcript was pruned")
+	}
+	if attempts, _ := s.SessionAttempts(1); len(attempts) != 3 {
+		t.Errorf("pruning removed attempts: %d left", len(attempts))
+	}
+}
@@ -17,12 +17,18 @@ import (
 //go:embed schema.sql
 var schemaSQL string
 
-const currentSchemaVersion = 1
-
 // Store is the SQLite-backed persistence layer for agentbox.
 type Store struct {
 	db   *sql.DB
 	path string
+
+	// Set by migrate when Open upgrades an existing database.
+	applied    []Migration
+	backupPath string
+
+	// blobs stores new transcripts as files under blobDir instead of in
+	// the database. See SetTranscriptStorage.
+	blobs bool
 }
 
 // Open opens or creates a SQLite database at path and runs migrations.
@@ -40,7 +46,14 @@ func Open(path string) (*Store, error) {
 		}
 	}
 
-	db, err := sql.Open("sqlite", path)
+	dsn := path
+	if path != ":memory:" {
+		// Writers from other goroutines, such as a task editor while a
+		// sprint runs, wait for the lock instead of failing with
+		// SQLITE_BUSY. In the DSN the pragma applies to every connection.
+		dsn = "file:" + path + "?_pragma=busy_timeout(5000)"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -64,54 +77,66 @@ func Open(path string) (*Store, error) {
 	return s, nil
 }
 
-// Close closes the database connection.
-func (s *Store) Close() error {
-	return s.db.Close()
-}
-
-// migrate applies the schema if not already at the current version.
-func (s *Store) migrate() error {
-	// Check if schema_version table exists.
-	var name string
-	err := s.db.QueryRow(
-		"SELECT name FROM sqlite_master WHERE type='table' AND name='schema_version'",
-	).Scan(&name)
-
-	if err == sql.ErrNoRows {
-		// Fresh database — apply full schema.
-		if _, err := s.db.Exec(schemaSQL); err != nil {
-			return fmt.Errorf("applying schema: %w", err)
-		}
-		_, err = s.db.Exec("INSERT INTO schema_version (version) VALUES (?)", currentSchemaVersion)
-		return err
-	}
+// OpenReadOnly opens an existing store for reading. Unlike Open it never
+// creates, migrates or backs up the database, so a store whose schema is
+// not this build's is an error.
+func OpenReadOnly(path string) (*Store, error) {
+	st, err := InspectSchema(path)
 	if err != nil {
-		return fmt.Errorf("checking schema version: %w", err)
+		return nil, err
 	}
-
-	var version int
-	if err := s.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
-		return fmt.Errorf("reading schema version: %w", err)
+	switch {
+	case st.Version < st.Latest:
+		return nil, fmt.Errorf("store %s is at schema version %d, behind this agentbox (%d); run agentbox db migrate", path, st.Version, st.Latest)
+	case st.Version > st.Latest:
+		return nil, fmt.Errorf("schema version %d is newer than this agentbox supports (%d); upgrade agentbox", st.Version, st.Latest)
 	}
 
-	if version < currentSchemaVersion {
-		// Future: apply incremental migrations here.
-		return fmt.Errorf("schema version %d is older than %d — migration not yet implemented", version, currentSchemaVersion)
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
 	}
+	return &Store{db: db, path: path}, nil
+}
 
-	return nil
+// Close closes the database connection.
+func (s *Store) Close() error {
+	return s.db.Close()
 }
 
 // --- Session management ---
 
 // Session represents a supervisor session.
 type Session struct {
-	ID         int64     `json:"id"`
-	StartedAt  time.Time `json:"started_at"`
-	RepoURL    string    `json:"repo_url"`
-	BranchName string    `json:"branch_name"`
-	Status     string    `json:"status"`
-	ConfigJSON string    `json:"config_json,omitempty"`
+	ID         int64      `json:"id"`
+	StartedAt  time.Time  `json:"started_at"`
+	RepoURL    string     `json:"repo_url"`
+	BranchName string     `json:"branch_name"`
+	Status     string     `json:"status"`
+	ConfigJSON string     `json:"config_json,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	PRNumber   int        `json:"pr_number,omitempty"`
+	PRURL      string     `json:"pr_url,omitempty"`
+	// WorktreePath is the git worktree the session worked in, if any.
+	WorktreePath string `json:"worktree_path,omitempty"`
+}
+
+// sessionColumns are the session fields read by scanSession.
+const sessionColumns = "id, started_at, repo_url, branch_name, status, COALESCE(config_json, ''), ended_at, " +
+	"COALESCE(pr_number, 0), COALESCE(pr_url, ''), COALESCE(worktree_path, '')"
+
+// scanSession reads a row selected with sessionColumns.
+func scanSession(row interface{ Scan(...interface{}) error }) (*Session, error) {
+	sess := &Session{}
+	var endedAt sql.NullTime
+	if err := row.Scan(&sess.ID, &sess.StartedAt, &sess.RepoURL, &sess.BranchName, &sess.Status, &sess.ConfigJSON, &endedAt,
+		&sess.PRNumber, &sess.PRURL, &sess.WorktreePath); err != nil {
+		return nil, err
+	}
+	if endedAt.Valid {
+		sess.EndedAt = &endedAt.Time
+	}
+	return sess, nil
 }
 
 // CreateSession starts a new session and returns its ID.
@@ -126,18 +151,32 @@ func (s *Store) CreateSession(repoURL, branchName, configJSON string) (int64, er
 	return result.LastInsertId()
 }
 
-// UpdateSessionStatus sets the session status.
+// UpdateSessionStatus sets the session status. Any status other than
+// "running" also records the session's end time; going back to "running"
+// on resume clears it.
 func (s *Store) UpdateSessionStatus(id int64, status string) error {
-	_, err := s.db.Exec("UPDATE sessions SET status = ? WHERE id = ?", status, id)
+	_, err := s.db.Exec(
+		`UPDATE sessions SET status = ?,
+		 ended_at = CASE WHEN ? = 'running' THEN NULL ELSE CURRENT_TIMESTAMP END
+		 WHERE id = ?`, status, status, id)
+	return err
+}
+
+// SetSessionPR records the pull request a session opened.
+func (s *Store) SetSessionPR(id int64, number int, url string) error {
+	_, err := s.db.Exec("UPDATE sessions SET pr_number = ?, pr_url = ? WHERE id = ?", number, url, id)
+	return err
+}
+
+// SetSessionWorktree records the branch and worktree a session works in.
+func (s *Store) SetSessionWorktree(id int64, branch, path string) error {
+	_, err := s.db.Exec("UPDATE sessions SET branch_name = ?, worktree_path = ? WHERE id = ?", branch, path, id)
 	return err
 }
 
 // GetSession returns a session by ID.
 func (s *Store) GetSession(id int64) (*Session, error) {
-	sess := &Session{}
-	err := s.db.QueryRow(
-		"SELECT id, started_at, repo_url, branch_name, status, COALESCE(config_json, '') FROM sessions WHERE id = ?", id,
-	).Scan(&sess.ID, &sess.StartedAt, &sess.RepoURL, &sess.BranchName, &sess.Status, &sess.ConfigJSON)
+	sess, err := scanSession(s.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("session %d not found", id)
 	}
@@ -146,27 +185,48 @@ func (s *Store) GetSession(id int64) (*Session, error) {
 
 // LatestSession returns the most recent session.
 func (s *Store) LatestSession() (*Session, error) {
-	sess := &Session{}
-	err := s.db.QueryRow(
-		"SELECT id, started_at, repo_url, branch_name, status, COALESCE(config_json, '') FROM sessions ORDER BY id DESC LIMIT 1",
-	).Scan(&sess.ID, &sess.StartedAt, &sess.RepoURL, &sess.BranchName, &sess.Status, &sess.ConfigJSON)
+	sess, err := scanSession(s.db.QueryRow("SELECT " + sessionColumns + " FROM sessions ORDER BY id DESC LIMIT 1"))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("no sessions found")
 	}
 	return sess, err
 }
 
+// ListSessions returns up to limit sessions, newest first. A limit of zero
+// or less returns all sessions.
+func (s *Store) ListSessions(limit int) ([]*Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions ORDER BY id DESC"
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
 // LatestResumableSession returns the most recent session with status
 // "interrupted". Only interrupted sessions are safe to resume — "running"
 // sessions may still have an active writer and resuming them risks
 // concurrent modifications.
 func (s *Store) LatestResumableSession() (*Session, error) {
-	sess := &Session{}
-	err := s.db.QueryRow(
-		`SELECT id, started_at, repo_url, branch_name, status, COALESCE(config_json, '')
-		 FROM sessions WHERE status = 'interrupted'
+	sess, err := scanSession(s.db.QueryRow(
+		`SELECT ` + sessionColumns + ` FROM sessions WHERE status = 'interrupted'
 		 ORDER BY id DESC LIMIT 1`,
-	).Scan(&sess.ID, &sess.StartedAt, &sess.RepoURL, &sess.BranchName, &sess.Status, &sess.ConfigJSON)
+	))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("no resumable sessions found")
 	}
@@ -246,6 +306,7 @@ type Task struct {
 	ContextNotes           string     `json:"context_notes,omitempty"`
 	AcceptanceCriteriaJSON string     `json:"acceptance_criteria_json,omitempty"`
 	TagsJSON               string     `json:"tags_json,omitempty"`
+	Workdir                string     `json:"workdir,omitempty"` // subdirectory the task is scoped to
 	CreatedAt              time.Time  `json:"created_at"`
 	CompletedAt            *time.Time `json:"completed_at,omitempty"`
 }
@@ -254,10 +315,10 @@ type Task struct {
 func (s *Store) InsertTask(t *Task) error {
 	_, err := s.db.Exec(
 		`INSERT INTO tasks (id, session_id, title, description, status, priority, complexity,
-		 parent_id, max_attempts, context_notes, acceptance_criteria_json, tags_json)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?)`,
+		 parent_id, max_attempts, context_notes, acceptance_criteria_json, tags_json, workdir)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, NULLIF(?, ''))`,
 		t.ID, t.SessionID, t.Title, t.Description, t.Status, t.Priority, t.Complexity,
-		t.ParentID, t.MaxAttempts, t.ContextNotes, t.AcceptanceCriteriaJSON, t.TagsJSON,
+		t.ParentID, t.MaxAttempts, t.ContextNotes, t.AcceptanceCriteriaJSON, t.TagsJSON, t.Workdir,
 	)
 	return err
 }
@@ -270,12 +331,12 @@ func (s *Store) GetTask(id string) (*Task, error) {
 	err := s.db.QueryRow(
 		`SELECT id, session_id, title, description, status, priority, complexity,
 		 parent_id, max_attempts, COALESCE(context_notes, ''),
-		 COALESCE(acceptance_criteria_json, ''), COALESCE(tags_json, ''),
+		 COALESCE(acceptance_criteria_json, ''), COALESCE(tags_json, ''), COALESCE(workdir, ''),
 		 created_at, completed_at
 		 FROM tasks WHERE id = ?`, id,
 	).Scan(&t.ID, &t.SessionID, &t.Title, &t.Description, &t.Status, &t.Priority,
 		&t.Complexity, &parentID, &t.MaxAttempts, &t.ContextNotes,
-		&t.AcceptanceCriteriaJSON, &t.TagsJSON, &t.CreatedAt, &completedAt)
+		&t.AcceptanceCriteriaJSON, &t.TagsJSON, &t.Workdir, &t.CreatedAt, &completedAt)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("task %s not found", id)
 	}
@@ -310,12 +371,31 @@ func (s *Store) UpdateTaskContextNotes(taskID, contextNotes string) error {
 	return err
 }
 
+// UpdateTask writes a task's title, description, status, priority and
+// context notes. completed_at is set when the status becomes completed and
+// cleared otherwise.
+func (s *Store) UpdateTask(t *Task) error {
+	result, err := s.db.Exec(
+		`UPDATE tasks SET title = ?, description = ?, status = ?, priority = ?, context_notes = ?,
+		 completed_at = CASE WHEN ? = 'completed' THEN COALESCE(completed_at, CURRENT_TIMESTAMP) ELSE NULL END
+		 WHERE id = ?`,
+		t.Title, t.Description, t.Status, t.Priority, t.ContextNotes, t.Status, t.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("task %s not found", t.ID)
+	}
+	return nil
+}
+
 // ListTasks returns all tasks for a session.
 func (s *Store) ListTasks(sessionID int64) ([]*Task, error) {
 	rows, err := s.db.Query(
 		`SELECT id, session_id, title, description, status, priority, complexity,
 		 parent_id, max_attempts, COALESCE(context_notes, ''),
-		 COALESCE(acceptance_criteria_json, ''), COALESCE(tags_json, ''),
+		 COALESCE(acceptance_criteria_json, ''), COALESCE(tags_json, ''), COALESCE(workdir, ''),
 		 created_at, completed_at
 		 FROM tasks WHERE session_id = ? ORDER BY priority ASC, created_at ASC`, sessionID,
 	)
@@ -331,7 +411,7 @@ func (s *Store) ListTasks(sessionID int64) ([]*Task, error) {
 		var completedAt sql.NullTime
 		if err := rows.Scan(&t.ID, &t.SessionID, &t.Title, &t.Description, &t.Status,
 			&t.Priority, &t.Complexity, &parentID, &t.MaxAttempts, &t.ContextNotes,
-			&t.AcceptanceCriteriaJSON, &t.TagsJSON, &t.CreatedAt, &completedAt); err != nil {
+			&t.AcceptanceCriteriaJSON, &t.TagsJSON, &t.Workdir, &t.CreatedAt, &completedAt); err != nil {
 			return nil, err
 		}
 		if parentID.Valid {
@@ -354,6 +434,21 @@ func (s *Store) AddDependency(taskID, dependsOn string) error {
 	return err
 }
 
+// ReplaceDependency points every task that depends on oldDep at newDep
+// instead, as when a task is split and its dependents must wait for the
+// last subtask.
+func (s *Store) ReplaceDependency(oldDep, newDep string) error {
+	if _, err := s.db.Exec(
+		"UPDATE OR IGNORE task_dependencies SET depends_on = ? WHERE depends_on = ?",
+		newDep, oldDep,
+	); err != nil {
+		return err
+	}
+	// Rows left behind already had an edge to newDep.
+	_, err := s.db.Exec("DELETE FROM task_dependencies WHERE depends_on = ?", oldDep)
+	return err
+}
+
 // GetDependencies returns the task IDs that a given task depends on.
 func (s *Store) GetDependencies(taskID string) ([]string, error) {
 	rows, err := s.db.Query(
@@ -407,6 +502,7 @@ func (s *Store) NextTask(sessionID int64) (*Task, error) {
 		       COALESCE(t.context_notes, ''),
 		       COALESCE(t.acceptance_criteria_json, ''),
 		       COALESCE(t.tags_json, ''),
+		       COALESCE(t.workdir, ''),
 		       t.created_at, t.completed_at
 		FROM tasks t
 		WHERE t.session_id = ?
@@ -436,7 +532,7 @@ func (s *Store) NextTask(sessionID int64) (*Task, error) {
 	var completedAt sql.NullTime
 	if err := rows.Scan(&t.ID, &t.SessionID, &t.Title, &t.Description, &t.Status,
 		&t.Priority, &t.Complexity, &parentID, &t.MaxAttempts, &t.ContextNotes,
-		&t.AcceptanceCriteriaJSON, &t.TagsJSON, &t.CreatedAt, &completedAt); err != nil {
+		&t.AcceptanceCriteriaJSON, &t.TagsJSON, &t.Workdir, &t.CreatedAt, &completedAt); err != nil {
 		return nil, err
 	}
 	if parentID.Valid {
@@ -473,32 +569,71 @@ type Attempt struct {
 	TokensUsed  int        `json:"tokens_used"`
 	DurationMs  int        `json:"duration_ms"`
 	Transcript  string     `json:"transcript,omitempty"`
+	// TranscriptSize is the uncompressed transcript length in bytes. It is
+	// set when attempts are listed; the transcript itself is only loaded by
+	// GetTranscript.
+	TranscriptSize int64 `json:"transcript_size,omitempty"`
 }
 
-// RecordAttempt inserts an attempt and returns its ID.
+// RecordAttempt inserts an attempt and returns its ID. A transcript set on
+// the attempt is stored as SaveTranscript would.
 func (s *Store) RecordAttempt(a *Attempt) (int64, error) {
 	result, err := s.db.Exec(
 		`INSERT INTO attempts (task_id, session_id, number, agent_name, started_at,
-		 completed_at, success, error_msg, git_commit, git_rollback, tokens_used, duration_ms, transcript)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		 completed_at, success, error_msg, git_commit, git_rollback, tokens_used, duration_ms)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		a.TaskID, a.SessionID, a.Number, a.AgentName, a.StartedAt,
 		a.CompletedAt, a.Success, a.ErrorMsg, a.GitCommit, a.GitRollback,
-		a.TokensUsed, a.DurationMs, a.Transcript,
+		a.TokensUsed, a.DurationMs,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("recording attempt: %w", err)
 	}
-	return result.LastInsertId()
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if a.Transcript != "" {
+		if err := s.SaveTranscript(id, a.Transcript); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
 }
 
+// attemptColumns are the attempt fields read by scanAttempts.
+const attemptColumns = `id, task_id, session_id, number, agent_name, started_at,
+		 completed_at, success, COALESCE(error_msg, ''), COALESCE(git_commit, ''),
+		 COALESCE(git_rollback, ''), tokens_used, duration_ms, transcript_size`
+
 // GetAttempts returns all attempts for a task.
 func (s *Store) GetAttempts(taskID string) ([]*Attempt, error) {
-	rows, err := s.db.Query(
-		`SELECT id, task_id, session_id, number, agent_name, started_at,
-		 completed_at, success, COALESCE(error_msg, ''), COALESCE(git_commit, ''),
-		 COALESCE(git_rollback, ''), tokens_used, duration_ms
-		 FROM attempts WHERE task_id = ? ORDER BY number ASC`, taskID,
+	return s.queryAttempts(
+		`SELECT `+attemptColumns+` FROM attempts WHERE task_id = ? ORDER BY number ASC`, taskID,
+	)
+}
+
+// SessionAttempts returns all attempts in a session, oldest first.
+func (s *Store) SessionAttempts(sessionID int64) ([]*Attempt, error) {
+	return s.queryAttempts(
+		`SELECT `+attemptColumns+` FROM attempts WHERE session_id = ? ORDER BY id ASC`, sessionID,
 	)
+}
+
+// GetAttempt returns an attempt by ID, without its transcript.
+func (s *Store) GetAttempt(id int64) (*Attempt, error) {
+	attempts, err := s.queryAttempts(`SELECT `+attemptColumns+` FROM attempts WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, fmt.Errorf("attempt %d not found", id)
+	}
+	return attempts[0], nil
+}
+
+func (s *Store) queryAttempts(query string, args ...interface{}) ([]*Attempt, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -511,7 +646,7 @@ func (s *Store) GetAttempts(taskID string) ([]*Attempt, error) {
 		var success sql.NullBool
 		if err := rows.Scan(&a.ID, &a.TaskID, &a.SessionID, &a.Number, &a.AgentName,
 			&a.StartedAt, &completedAt, &success, &a.ErrorMsg, &a.GitCommit,
-			&a.GitRollback, &a.TokensUsed, &a.DurationMs); err != nil {
+			&a.GitRollback, &a.TokensUsed, &a.DurationMs, &a.TranscriptSize); err != nil {
 			return nil, err
 		}
 		if completedAt.Valid {
@@ -525,25 +660,6 @@ func (s *Store) GetAttempts(taskID string) ([]*Attempt, error) {
 	return attempts, rows.Err()
 }
 
-// SaveTranscript stores the full agent transcript for an attempt.
-func (s *Store) SaveTranscript(attemptID int64, transcript string) error {
-	_, err := s.db.Exec("UPDATE attempts SET transcript = ? WHERE id = ?", transcript, attemptID)
-	return err
-}
-
-// GetTranscript retrieves the transcript for an attempt.
-func (s *Store) GetTranscript(attemptID int64) (string, error) {
-	var transcript sql.NullString
-	err := s.db.QueryRow("SELECT transcript FROM attempts WHERE id = ?", attemptID).Scan(&transcript)
-	if err != nil {
-		return "", err
-	}
-	if transcript.Valid {
-		return transcript.String, nil
-	}
-	return "", nil
-}
-
 // --- Quality Snapshots ---
 
 // QualitySnapshot represents a point-in-time quality measurement.
@@ -945,36 +1061,10 @@ func (s *Store) ExportDashboardData(sessionID int64) (*DashboardData, error) {
 		return nil, err
 	}
 
-	// Task stats
-	stats := &TaskStats{}
-	rows, err := s.db.Query(
-		"SELECT status, COUNT(*) FROM tasks WHERE session_id = ? GROUP BY status", sessionID,
-	)
+	stats, err := s.taskStats(sessionID)
 	if err != nil {
 		return nil, err
 	}
-	for rows.Next() {
-		var status string
-		var count int
-		if err := rows.Scan(&status, &count); err != nil {
-			rows.Close()
-			return nil, err
-		}
-		stats.Total += count
-		switch status {
-		case "pending":
-			stats.Pending = count
-		case "in_progress":
-			stats.InProgress = count
-		case "completed":
-			stats.Completed = count
-		case "failed":
-			stats.Failed = count
-		case "deferred":
-			stats.Deferred = count
-		}
-	}
-	rows.Close()
 
 	usage, err := s.TotalUsage(sessionID)
 	if err != nil {
@@ -1011,3 +1101,37 @@ func (s *Store) ExportDashboardData(sessionID int64) (*DashboardData, error) {
 		RecentJournal: journal,
 	}, nil
 }
+
+// taskStats counts a session's tasks by status.
+func (s *Store) taskStats(sessionID int64) (*TaskStats, error) {
+	stats := &TaskStats{}
+	rows, err := s.db.Query(
+		"SELECT status, COUNT(*) FROM tasks WHERE session_id = ? GROUP BY status", sessionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		stats.Total += count
+		switch status {
+		case "pending":
+			stats.Pending = count
+		case "in_progress":
+			stats.InProgress = count
+		case "completed":
+			stats.Completed = count
+		case "failed":
+			stats.Failed = count
+		case "deferred":
+			stats.Deferred = count
+		}
+	}
+	return stats, rows.Err()
+}
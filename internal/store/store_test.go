@@ -46,8 +46,8 @@ func TestSchemaVersion(t *testing.T) {
 	if err != nil {
 		t.Fatalf("querying schema version: %v", err)
 	}
-	if version != currentSchemaVersion {
-		t.Errorf("expected schema version %d, got %d", currentSchemaVersion, version)
+	if version != LatestSchemaVersion() {
+		t.Errorf("expected schema version %d, got %d", LatestSchemaVersion(), version)
 	}
 }
 
@@ -107,6 +107,7 @@ func TestTaskCRUD(t *testing.T) {
 		Priority:    1,
 		Complexity:  2,
 		MaxAttempts: 3,
+		Workdir:     "services/api",
 	}
 
 	// Insert
@@ -125,6 +126,9 @@ func TestTaskCRUD(t *testing.T) {
 	if got.Status != "pending" {
 		t.Errorf("expected status 'pending', got %q", got.Status)
 	}
+	if got.Workdir != "services/api" {
+		t.Errorf("expected workdir 'services/api', got %q", got.Workdir)
+	}
 
 	// Update status
 	if err := s.UpdateTaskStatus("task-1", "completed"); err != nil {
@@ -273,6 +277,59 @@ func TestAttemptCRUD(t *testing.T) {
 	}
 }
 
+func TestSessionAttemptsAndGetAttempt(t *testing.T) {
+	s := openTestStore(t)
+	sess1, _ := s.CreateSession("", "a", "")
+	sess2, _ := s.CreateSession("", "b", "")
+	for _, task := range []*Task{
+		{ID: "t-1", SessionID: sess1, Title: "One", Status: "pending", MaxAttempts: 3},
+		{ID: "t-2", SessionID: sess2, Title: "Two", Status: "pending", MaxAttempts: 3},
+	} {
+		if err := s.InsertTask(task); err != nil {
+			t.Fatalf("InsertTask: %v", err)
+		}
+	}
+	id1, _ := s.RecordAttempt(&Attempt{TaskID: "t-1", SessionID: sess1, Number: 1, StartedAt: time.Now()})
+	_, _ = s.RecordAttempt(&Attempt{TaskID: "t-2", SessionID: sess2, Number: 1, StartedAt: time.Now()})
+
+	attempts, err := s.SessionAttempts(sess1)
+	if err != nil {
+		t.Fatalf("SessionAttempts: %v", err)
+	}
+	if len(attempts) != 1 || attempts[0].ID != id1 {
+		t.Errorf("SessionAttempts = %+v, want only attempt %d", attempts, id1)
+	}
+
+	a, err := s.GetAttempt(id1)
+	if err != nil || a.SessionID != sess1 || a.TaskID != "t-1" {
+		t.Errorf("GetAttempt = %+v, %v", a, err)
+	}
+	if _, err := s.GetAttempt(999); err == nil {
+		t.Error("expected error for missing attempt")
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	s := openTestStore(t)
+	for _, branch := range []string{"a", "b", "c"} {
+		if _, err := s.CreateSession("", branch, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := s.ListSessions(0)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(all) != 3 || all[0].BranchName != "c" {
+		t.Errorf("ListSessions(0) = %d sessions, first %q; want 3 newest first", len(all), all[0].BranchName)
+	}
+	limited, _ := s.ListSessions(2)
+	if len(limited) != 2 {
+		t.Errorf("ListSessions(2) returned %d sessions", len(limited))
+	}
+}
+
 func TestTranscript(t *testing.T) {
 	s := openTestStore(t)
 	sessionID, _ := s.CreateSession("", "main", "")
@@ -0,0 +1,357 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// --- Transcript storage ---
+
+// Transcript storage modes for SetTranscriptStorage.
+const (
+	TranscriptsInline = "inline" // zstd-compressed in the database
+	TranscriptsBlobs  = "blobs"  // zstd-compressed files under .agentbox/blobs/
+)
+
+// The encoder and decoder are safe for concurrent EncodeAll/DecodeAll calls.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// SetTranscriptStorage selects where SaveTranscript puts new transcripts.
+// Either way they are zstd-compressed. Blobs are content-addressed files
+// in the blobs directory next to the database, so identical transcripts are
+// stored once. Transcripts already saved are read from wherever they are.
+func (s *Store) SetTranscriptStorage(mode string) error {
+	switch mode {
+	case "", TranscriptsInline:
+		s.blobs = false
+	case TranscriptsBlobs:
+		if s.path == ":memory:" {
+			return fmt.Errorf("blob transcript storage needs an on-disk store")
+		}
+		s.blobs = true
+	default:
+		return fmt.Errorf("invalid transcript storage %q (must be inline or blobs)", mode)
+	}
+	return nil
+}
+
+// blobDir is where blob transcripts live: .agentbox/blobs/ for the usual
+// .agentbox/agentbox.db.
+func (s *Store) blobDir() string {
+	return filepath.Join(filepath.Dir(s.path), "blobs")
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.blobDir(), hash[:2], hash+".zst")
+}
+
+// SaveTranscript stores the full agent transcript for an attempt, replacing
+// any earlier one, and indexes it for search. An empty transcript clears it.
+func (s *Store) SaveTranscript(attemptID int64, transcript string) error {
+	var oldBlob string
+	err := s.db.QueryRow(
+		"SELECT COALESCE(transcript_blob, '') FROM attempts WHERE id = ?", attemptID,
+	).Scan(&oldBlob)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("attempt %d not found", attemptID)
+	}
+	if err != nil {
+		return err
+	}
+
+	var inline []byte
+	var blob sql.NullString
+	if transcript != "" {
+		compressed := zstdEncoder.EncodeAll([]byte(transcript), nil)
+		if s.blobs {
+			hash, err := s.writeBlob(transcript, compressed)
+			if err != nil {
+				return fmt.Errorf("writing transcript blob: %w", err)
+			}
+			blob = sql.NullString{String: hash, Valid: true}
+		} else {
+			inline = compressed
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE attempts SET transcript = NULL, transcript_zstd = ?, transcript_blob = ?,
+		 transcript_size = ? WHERE id = ?`,
+		inline, blob, len(transcript), attemptID,
+	); err != nil {
+		return fmt.Errorf("saving transcript: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM transcript_index WHERE rowid = ?", attemptID); err != nil {
+		return fmt.Errorf("unindexing transcript: %w", err)
+	}
+	if transcript != "" {
+		if _, err := tx.Exec(
+			"INSERT INTO transcript_index (rowid, body) VALUES (?, ?)", attemptID, transcript,
+		); err != nil {
+			return fmt.Errorf("indexing transcript: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if oldBlob != "" && oldBlob != blob.String {
+		return s.removeUnusedBlobs([]string{oldBlob})
+	}
+	return nil
+}
+
+// GetTranscript loads and decompresses the transcript for an attempt. It
+// returns "" when the attempt has none.
+func (s *Store) GetTranscript(attemptID int64) (string, error) {
+	var text sql.NullString
+	var compressed []byte
+	var blob string
+	err := s.db.QueryRow(
+		"SELECT transcript, transcript_zstd, COALESCE(transcript_blob, '') FROM attempts WHERE id = ?", attemptID,
+	).Scan(&text, &compressed, &blob)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case blob != "":
+		data, err := os.ReadFile(s.blobPath(blob))
+		if err != nil {
+			return "", fmt.Errorf("reading transcript blob: %w", err)
+		}
+		compressed = data
+	case len(compressed) == 0:
+		// Saved before compression, or never saved.
+		return text.String, nil
+	}
+
+	raw, err := zstdDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return "", fmt.Errorf("decompressing transcript: %w", err)
+	}
+	return string(raw), nil
+}
+
+// writeBlob stores compressed under the hash of the uncompressed transcript
+// and returns the hash. An existing blob with that hash is reused.
+func (s *Store) writeBlob(transcript string, compressed []byte) (string, error) {
+	sum := sha256.Sum256([]byte(transcript))
+	hash := hex.EncodeToString(sum[:])
+	path := s.blobPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(compressed); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return hash, nil
+}
+
+// removeUnusedBlobs deletes the given blobs unless an attempt still uses
+// them.
+func (s *Store) removeUnusedBlobs(hashes []string) error {
+	for _, hash := range hashes {
+		var refs int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM attempts WHERE transcript_blob = ?", hash).Scan(&refs); err != nil {
+			return err
+		}
+		if refs > 0 {
+			continue
+		}
+		if err := os.Remove(s.blobPath(hash)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing transcript blob: %w", err)
+		}
+	}
+	return nil
+}
+
+// --- Retention ---
+
+// SetSessionRetention sets how long a session's transcripts are kept,
+// overriding the policy passed to ApplyRetention. Zero keeps them forever,
+// and also protects them from PruneTranscripts.
+func (s *Store) SetSessionRetention(sessionID int64, maxAge time.Duration) error {
+	_, err := s.db.Exec("UPDATE sessions SET transcript_retention = ? WHERE id = ?",
+		int64(maxAge/time.Second), sessionID)
+	return err
+}
+
+// RetentionPolicy is the project-wide transcript retention.
+type RetentionPolicy struct {
+	// MaxAge prunes transcripts of sessions started longer ago than this.
+	// Zero keeps them regardless of age.
+	MaxAge time.Duration
+	// MaxBytes prunes the oldest transcripts until the rest total at most
+	// this many uncompressed bytes. Zero means no limit.
+	MaxBytes int64
+}
+
+// ApplyRetention prunes transcripts under the policy. Sessions with their
+// own retention use it in place of p.MaxAge.
+func (s *Store) ApplyRetention(p RetentionPolicy, dryRun bool) (*PruneResult, error) {
+	now := time.Now()
+	return s.prune(func(t storedTranscript) bool {
+		maxAge := p.MaxAge
+		if t.retention.Valid {
+			maxAge = time.Duration(t.retention.Int64) * time.Second
+		}
+		return maxAge > 0 && t.started.Before(now.Add(-maxAge))
+	}, p.MaxBytes, dryRun)
+}
+
+// PruneOptions selects which transcripts PruneTranscripts removes.
+type PruneOptions struct {
+	// Before removes transcripts of sessions started before this time.
+	Before time.Time
+	// MaxBytes removes the oldest remaining transcripts until the total
+	// transcript size is at most this many bytes. Zero means no limit.
+	MaxBytes int64
+	// DryRun reports what would be removed without changing anything.
+	DryRun bool
+}
+
+// PruneResult reports what was pruned. Bytes counts uncompressed
+// transcript text.
+type PruneResult struct {
+	Transcripts int   `json:"transcripts"`
+	Bytes       int64 `json:"bytes"`
+	Vacuumed    bool  `json:"vacuumed"`
+}
+
+// PruneTranscripts clears stored transcripts by age and total size, then
+// vacuums the database to return the space to the filesystem. Attempts and
+// every other record are kept. Running sessions and sessions set to keep
+// their transcripts forever are never touched.
+func (s *Store) PruneTranscripts(opts PruneOptions) (*PruneResult, error) {
+	return s.prune(func(t storedTranscript) bool {
+		return !opts.Before.IsZero() && t.started.Before(opts.Before)
+	}, opts.MaxBytes, opts.DryRun)
+}
+
+type storedTranscript struct {
+	attemptID int64
+	size      int64
+	blob      string
+	started   time.Time
+	running   bool
+	retention sql.NullInt64
+}
+
+// prune clears transcripts that expired says to, then the oldest others
+// while the total exceeds maxBytes.
+func (s *Store) prune(expired func(storedTranscript) bool, maxBytes int64, dryRun bool) (*PruneResult, error) {
+	rows, err := s.db.Query(
+		`SELECT a.id, a.transcript_size, COALESCE(a.transcript_blob, ''), s.started_at,
+		 s.status, s.transcript_retention
+		 FROM attempts a JOIN sessions s ON s.id = a.session_id
+		 WHERE a.transcript_size > 0
+		 ORDER BY a.id ASC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing transcripts: %w", err)
+	}
+	var all []storedTranscript
+	var total int64
+	for rows.Next() {
+		var t storedTranscript
+		var status string
+		if err := rows.Scan(&t.attemptID, &t.size, &t.blob, &t.started, &status, &t.retention); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.running = status == "running"
+		total += t.size
+		all = append(all, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &PruneResult{}
+	var pruned []storedTranscript
+	for _, t := range all {
+		keepForever := t.retention.Valid && t.retention.Int64 == 0
+		if t.running || keepForever {
+			continue
+		}
+		if !expired(t) && !(maxBytes > 0 && total > maxBytes) {
+			continue
+		}
+		pruned = append(pruned, t)
+		total -= t.size
+		result.Transcripts++
+		result.Bytes += t.size
+	}
+	if dryRun || len(pruned) == 0 {
+		return result, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var blobs []string
+	for _, t := range pruned {
+		if _, err := tx.Exec(
+			`UPDATE attempts SET transcript = NULL, transcript_zstd = NULL, transcript_blob = NULL,
+			 transcript_size = 0 WHERE id = ?`, t.attemptID,
+		); err != nil {
+			return nil, fmt.Errorf("clearing transcript %d: %w", t.attemptID, err)
+		}
+		if _, err := tx.Exec("DELETE FROM transcript_index WHERE rowid = ?", t.attemptID); err != nil {
+			return nil, fmt.Errorf("unindexing transcript %d: %w", t.attemptID, err)
+		}
+		if t.blob != "" {
+			blobs = append(blobs, t.blob)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := s.removeUnusedBlobs(blobs); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return nil, fmt.Errorf("vacuuming database: %w", err)
+	}
+	result.Vacuumed = true
+	return result, nil
+}
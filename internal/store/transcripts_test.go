@@ -0,0 +1,221 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newAttempt(t *testing.T, s *Store, sessionID int64, taskID string) int64 {
+	t.Helper()
+	if _, err := s.GetTask(taskID); err != nil {
+		if err := s.InsertTask(&Task{ID: taskID, SessionID: sessionID, Title: taskID, Status: "pending", MaxAttempts: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, err := s.RecordAttempt(&Attempt{TaskID: taskID, SessionID: sessionID, Number: 1, AgentName: "claude", StartedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestTranscriptCompressedInline(t *testing.T) {
+	s := openTestStore(t)
+	sessionID, _ := s.CreateSession("", "main", "")
+	id := newAttempt(t, s, sessionID, "t-1")
+
+	transcript := strings.Repeat("running go test ./...\nok\n", 2000)
+	if err := s.SaveTranscript(id, transcript); err != nil {
+		t.Fatal(err)
+	}
+
+	var stored []byte
+	var text *string
+	if err := s.db.QueryRow("SELECT transcript_zstd, transcript FROM attempts WHERE id = ?", id).Scan(&stored, &text); err != nil {
+		t.Fatal(err)
+	}
+	if text != nil {
+		t.Error("transcript text stored uncompressed")
+	}
+	if len(stored) == 0 || len(stored) >= len(transcript)/10 {
+		t.Errorf("compressed size %d for %d bytes of text", len(stored), len(transcript))
+	}
+
+	got, err := s.GetTranscript(id)
+	if err != nil || got != transcript {
+		t.Fatalf("GetTranscript = %d bytes, %v; want the original", len(got), err)
+	}
+	a, _ := s.GetAttempt(id)
+	if a.TranscriptSize != int64(len(transcript)) || a.Transcript != "" {
+		t.Errorf("attempt size %d transcript %d bytes; want size only", a.TranscriptSize, len(a.Transcript))
+	}
+}
+
+func TestTranscriptLegacyInlineText(t *testing.T) {
+	s := openTestStore(t)
+	sessionID, _ := s.CreateSession("", "main", "")
+	id := newAttempt(t, s, sessionID, "t-1")
+	if _, err := s.db.Exec("UPDATE attempts SET transcript = 'old style' WHERE id = ?", id); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetTranscript(id); err != nil || got != "old style" {
+		t.Errorf("GetTranscript = %q, %v", got, err)
+	}
+}
+
+func TestTranscriptBlobs(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "agentbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SetTranscriptStorage("tape"); err == nil {
+		t.Error("unknown storage mode accepted")
+	}
+	if err := s.SetTranscriptStorage(TranscriptsBlobs); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, _ := s.CreateSession("", "main", "")
+	first := newAttempt(t, s, sessionID, "t-1")
+	second := newAttempt(t, s, sessionID, "t-2")
+	for _, id := range []int64{first, second} {
+		if err := s.SaveTranscript(id, "same output"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	blobs, _ := filepath.Glob(filepath.Join(dir, "blobs", "*", "*.zst"))
+	if len(blobs) != 1 {
+		t.Fatalf("blobs = %v, want one shared blob", blobs)
+	}
+	if got, err := s.GetTranscript(second); err != nil || got != "same output" {
+		t.Errorf("GetTranscript = %q, %v", got, err)
+	}
+	if results, _ := s.Search(SearchQuery{Text: "same output"}); len(results) != 2 {
+		t.Errorf("search found %d blob transcripts, want 2", len(results))
+	}
+
+	// The blob survives while another attempt uses it.
+	if err := s.SaveTranscript(first, "different"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blobs[0]); err != nil {
+		t.Errorf("shared blob removed while still in use: %v", err)
+	}
+	if err := s.SaveTranscript(second, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blobs[0]); !os.IsNotExist(err) {
+		t.Error("unused blob was not removed")
+	}
+
+	// Switching back to inline still reads existing blobs.
+	_ = s.SetTranscriptStorage(TranscriptsInline)
+	if got, _ := s.GetTranscript(first); got != "different" {
+		t.Errorf("GetTranscript after switching modes = %q", got)
+	}
+}
+
+func TestSetTranscriptStorageInMemory(t *testing.T) {
+	s := openTestStore(t)
+	if err := s.SetTranscriptStorage(TranscriptsBlobs); err == nil {
+		t.Error("blob storage accepted for an in-memory store")
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	s := openTestStore(t)
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	sessions := map[string]int64{}
+	for _, name := range []string{"default", "keep", "short", "recent"} {
+		id, _ := s.CreateSession("", name, "")
+		sessions[name] = id
+		if name != "recent" {
+			if _, err := s.db.Exec("UPDATE sessions SET started_at = ? WHERE id = ?", old, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = s.UpdateSessionStatus(id, "completed")
+		if err := s.SaveTranscript(newAttempt(t, s, id, name+"-t"), "transcript of "+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetSessionRetention(sessions["keep"], 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSessionRetention(sessions["short"], 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	kept := func(name string) bool {
+		attempts, _ := s.SessionAttempts(sessions[name])
+		got, _ := s.GetTranscript(attempts[0].ID)
+		return got != ""
+	}
+
+	// Only the session with its own one day retention is past it.
+	res, err := s.ApplyRetention(RetentionPolicy{MaxAge: 30 * 24 * time.Hour}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Transcripts != 1 || kept("short") {
+		t.Errorf("30 day policy pruned %d transcripts, short kept = %v", res.Transcripts, kept("short"))
+	}
+
+	res, err = s.ApplyRetention(RetentionPolicy{MaxAge: 7 * 24 * time.Hour}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Transcripts != 1 || kept("default") {
+		t.Errorf("7 day policy pruned %d transcripts, default kept = %v", res.Transcripts, kept("default"))
+	}
+	if !kept("keep") || !kept("recent") {
+		t.Error("7 day policy pruned a keep-forever or recent session")
+	}
+
+	// An explicit prune also leaves keep-forever sessions alone.
+	res, _ = s.PruneTranscripts(PruneOptions{Before: time.Now().Add(time.Hour)})
+	if res.Transcripts != 1 || kept("recent") || !kept("keep") {
+		t.Errorf("prune removed %d transcripts, want only the recent one", res.Transcripts)
+	}
+}
+
+func TestTranscriptIndexKeepsNoCopy(t *testing.T) {
+	s := openTestStore(t)
+	sessionID, _ := s.CreateSession("", "main", "")
+	id := newAttempt(t, s, sessionID, "t-1")
+
+	pageBytes := func() int64 {
+		var count, size int64
+		if err := s.db.QueryRow("SELECT page_count, page_size FROM pragma_page_count(), pragma_page_size()").Scan(&count, &size); err != nil {
+			t.Fatal(err)
+		}
+		return count * size
+	}
+	before := pageBytes()
+
+	var sb strings.Builder
+	for i := 0; sb.Len() < 4<<20; i++ {
+		sb.WriteString("=== RUN   TestHandler\n--- PASS: TestHandler (0.00s)\nok  \tgithub.com/example/app/internal/api\t0.012s\n")
+		if i%1000 == 0 {
+			sb.WriteString("panic: runtime error: nil pointer dereference\n")
+		}
+	}
+	transcript := sb.String()
+	if err := s.SaveTranscript(id, transcript); err != nil {
+		t.Fatal(err)
+	}
+
+	if grew := pageBytes() - before; grew > int64(len(transcript))/4 {
+		t.Errorf("database grew %d bytes for a %d byte transcript; the index keeps a copy", grew, len(transcript))
+	}
+	results, err := s.Search(SearchQuery{Text: "nil pointer"})
+	if err != nil || len(results) != 1 || !strings.Contains(results[0].Snippet, "[nil]") {
+		t.Errorf("Search = %+v, %v", results, err)
+	}
+}
@@ -1,45 +1,21 @@
 package supervisor
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/swamp-dev/agentbox/internal/codehost"
 	"github.com/swamp-dev/agentbox/internal/journal"
 	"github.com/swamp-dev/agentbox/internal/retro"
 	"github.com/swamp-dev/agentbox/internal/store"
 	"github.com/swamp-dev/agentbox/internal/taskdb"
 )
 
-// CommandExecutor abstracts command execution for testing.
-type CommandExecutor interface {
-	// Execute runs a command and returns its combined stdout, or an error.
-	Execute(ctx context.Context, dir string, name string, args ...string) (string, error)
-}
-
-// execCommandExecutor is the real implementation that runs exec.CommandContext.
-type execCommandExecutor struct{}
-
-func (e *execCommandExecutor) Execute(ctx context.Context, dir string, name string, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, name, args...)
-	if dir != "" {
-		cmd.Dir = dir
-	}
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("%s: %s: %w", name, stderr.String(), err)
-	}
-	return stdout.String(), nil
-}
-
 // AdaptiveController applies retrospective recommendations.
 type AdaptiveController struct {
 	store     *store.Store
@@ -54,8 +30,8 @@ type AdaptiveController struct {
 	journal           *journal.Journal
 
 	// Escalation settings.
-	escalationMethod string          // "github_issue", "file", "none"
-	cmdExecutor      CommandExecutor // injectable for testing gh CLI calls
+	escalationMethod string // "issue", "file", "none"
+	codeHost         func(context.Context) (codehost.CodeHost, error)
 }
 
 // NewAdaptiveController creates a new adaptive controller.
@@ -75,14 +51,15 @@ func (ac *AdaptiveController) SetJournal(j *journal.Journal) {
 }
 
 // SetEscalationMethod configures how escalations are delivered.
-// Valid values: "github_issue", "file", "none". Default is "file".
+// Valid values: "issue" (or "github_issue"), "file", "none". Default is
+// "file".
 func (ac *AdaptiveController) SetEscalationMethod(method string) {
 	ac.escalationMethod = method
 }
 
-// SetCommandExecutor overrides the command executor (used for testing).
-func (ac *AdaptiveController) SetCommandExecutor(executor CommandExecutor) {
-	ac.cmdExecutor = executor
+// SetCodeHost sets where escalation issues are opened.
+func (ac *AdaptiveController) SetCodeHost(h codehost.CodeHost) {
+	ac.codeHost = func(context.Context) (codehost.CodeHost, error) { return h, nil }
 }
 
 // SwitchRecommended returns whether an agent switch was recommended and the
@@ -232,7 +209,6 @@ func (ac *AdaptiveController) generateSubtasks(parent *taskdb.Task, description
 }
 
 // WriteEscalation routes an escalation message based on the configured method.
-// It returns an optional result string (e.g., the issue URL for github_issue).
 func (ac *AdaptiveController) WriteEscalation(ctx context.Context, workDir, message string) error {
 	method := ac.escalationMethod
 	if method == "" {
@@ -244,12 +220,12 @@ func (ac *AdaptiveController) WriteEscalation(ctx context.Context, workDir, mess
 		ac.logger.Warn("escalation (log only)", "message", message)
 		return nil
 
-	case "github_issue":
-		url, err := ac.createGitHubIssue(ctx, workDir, message)
+	case "issue", "github_issue":
+		url, err := ac.createIssue(ctx, message)
 		if err != nil {
-			return fmt.Errorf("creating GitHub issue: %w", err)
+			return fmt.Errorf("creating escalation issue: %w", err)
 		}
-		ac.logger.Info("escalation created as GitHub issue", "url", url)
+		ac.logger.Info("escalation created as issue", "url", url)
 		return nil
 
 	default: // "file"
@@ -275,11 +251,14 @@ func (ac *AdaptiveController) writeEscalationFile(workDir, message string) error
 	return err
 }
 
-// createGitHubIssue creates a GitHub issue with escalation details via gh CLI.
-func (ac *AdaptiveController) createGitHubIssue(ctx context.Context, workDir, message string) (string, error) {
-	executor := ac.cmdExecutor
-	if executor == nil {
-		executor = &execCommandExecutor{}
+// createIssue opens an issue with escalation details on the code host.
+func (ac *AdaptiveController) createIssue(ctx context.Context, message string) (string, error) {
+	if ac.codeHost == nil {
+		return "", fmt.Errorf("no code host configured")
+	}
+	host, err := ac.codeHost(ctx)
+	if err != nil {
+		return "", err
 	}
 
 	title := "agentbox escalation: " + truncate(message, 60)
@@ -289,14 +268,11 @@ func (ac *AdaptiveController) createGitHubIssue(ctx context.Context, workDir, me
 	execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	out, err := executor.Execute(execCtx, workDir, "gh", "issue", "create",
-		"--title", title,
-		"--body", body,
-	)
+	issue, err := host.CreateIssue(execCtx, codehost.IssueOptions{Title: title, Body: body})
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(out), nil
+	return issue.URL, nil
 }
 
 // truncate shortens a string to maxLen runes, adding "..." if truncated.
@@ -8,29 +8,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/swamp-dev/agentbox/internal/codehost"
 	"github.com/swamp-dev/agentbox/internal/retro"
 	"github.com/swamp-dev/agentbox/internal/store"
 	"github.com/swamp-dev/agentbox/internal/taskdb"
 )
 
-// mockCommandExecutor records calls and returns configured responses.
-type mockCommandExecutor struct {
-	calls  []mockCall
-	output string
-	err    error
-}
-
-type mockCall struct {
-	Dir  string
-	Name string
-	Args []string
-}
-
-func (m *mockCommandExecutor) Execute(_ context.Context, dir string, name string, args ...string) (string, error) {
-	m.calls = append(m.calls, mockCall{Dir: dir, Name: name, Args: args})
-	return m.output, m.err
-}
-
 func TestApply_SimpleActions(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -443,64 +426,64 @@ func TestWriteEscalation_NoneMethod(t *testing.T) {
 	}
 }
 
-func TestWriteEscalation_GitHubIssueMethod(t *testing.T) {
-	s := openTestStore(t)
-	sessionID, _ := s.CreateSession("", "main", "")
-	logger := testLogger()
-	ac := NewAdaptiveController(s, sessionID, nil, logger)
-	ac.SetEscalationMethod("github_issue")
-
-	mock := &mockCommandExecutor{output: "https://github.com/org/repo/issues/42\n"}
-	ac.SetCommandExecutor(mock)
+func TestWriteEscalation_IssueMethod(t *testing.T) {
+	for _, method := range []string{"issue", "github_issue"} {
+		t.Run(method, func(t *testing.T) {
+			s := openTestStore(t)
+			sessionID, _ := s.CreateSession("", "main", "")
+			logger := testLogger()
+			ac := NewAdaptiveController(s, sessionID, nil, logger)
+			ac.SetEscalationMethod(method)
 
-	dir := t.TempDir()
-	if err := ac.WriteEscalation(context.Background(), dir, "task T-1 failed 3 times"); err != nil {
-		t.Fatalf("WriteEscalation: %v", err)
-	}
+			host := codehost.NewFake()
+			ac.SetCodeHost(host)
 
-	// Verify gh was called correctly.
-	if len(mock.calls) != 1 {
-		t.Fatalf("expected 1 command call, got %d", len(mock.calls))
-	}
-	call := mock.calls[0]
-	if call.Name != "gh" {
-		t.Errorf("expected command 'gh', got %q", call.Name)
-	}
-	if call.Dir != dir {
-		t.Errorf("expected dir %q, got %q", dir, call.Dir)
-	}
+			dir := t.TempDir()
+			if err := ac.WriteEscalation(context.Background(), dir, "task T-1 failed 3 times"); err != nil {
+				t.Fatalf("WriteEscalation: %v", err)
+			}
 
-	// Check args contain expected values.
-	args := strings.Join(call.Args, " ")
-	if !strings.Contains(args, "issue") || !strings.Contains(args, "create") {
-		t.Errorf("expected 'issue create' in args, got %q", args)
-	}
-	if !strings.Contains(args, "task T-1 failed 3 times") {
-		t.Errorf("expected escalation message in title args, got %q", args)
-	}
+			issues := host.Issues()
+			if len(issues) != 1 {
+				t.Fatalf("expected 1 issue, got %d", len(issues))
+			}
+			if !strings.Contains(issues[0].Title, "task T-1 failed 3 times") {
+				t.Errorf("expected escalation message in title, got %q", issues[0].Title)
+			}
+			if !strings.Contains(issues[0].Body, "task T-1 failed 3 times") {
+				t.Errorf("expected escalation message in body, got %q", issues[0].Body)
+			}
 
-	// No file should be created.
-	_, err := os.Stat(filepath.Join(dir, ".agentbox", "escalations.md"))
-	if err == nil {
-		t.Error("expected no local file for github_issue method")
+			// No file should be created.
+			_, err := os.Stat(filepath.Join(dir, ".agentbox", "escalations.md"))
+			if err == nil {
+				t.Error("expected no local file for issue method")
+			}
+		})
 	}
 }
 
-func TestWriteEscalation_GitHubIssueMethod_Error(t *testing.T) {
+func TestWriteEscalation_IssueMethod_Error(t *testing.T) {
 	s := openTestStore(t)
 	sessionID, _ := s.CreateSession("", "main", "")
 	logger := testLogger()
 	ac := NewAdaptiveController(s, sessionID, nil, logger)
-	ac.SetEscalationMethod("github_issue")
+	ac.SetEscalationMethod("issue")
 
-	mock := &mockCommandExecutor{err: fmt.Errorf("gh: not authenticated")}
-	ac.SetCommandExecutor(mock)
+	// Without a code host the escalation cannot be delivered.
+	if err := ac.WriteEscalation(context.Background(), t.TempDir(), "no host"); err == nil {
+		t.Fatal("expected error without a code host")
+	}
+
+	host := codehost.NewFake()
+	host.FailWith(fmt.Errorf("401 Bad credentials"))
+	ac.SetCodeHost(host)
 
 	err := ac.WriteEscalation(context.Background(), t.TempDir(), "failing escalation")
 	if err == nil {
-		t.Fatal("expected error when gh fails")
+		t.Fatal("expected error when the code host fails")
 	}
-	if !strings.Contains(err.Error(), "creating GitHub issue") {
+	if !strings.Contains(err.Error(), "creating escalation issue") {
 		t.Errorf("expected wrapped error, got %q", err.Error())
 	}
 }
@@ -526,25 +509,6 @@ func TestWriteEscalation_DefaultMethodIsFile(t *testing.T) {
 	}
 }
 
-func TestExecCommandExecutor_Success(t *testing.T) {
-	exec := &execCommandExecutor{}
-	out, err := exec.Execute(context.Background(), "", "git", "version")
-	if err != nil {
-		t.Fatalf("Execute(git version): %v", err)
-	}
-	if !strings.Contains(out, "git version") {
-		t.Errorf("expected 'git version' in output, got %q", out)
-	}
-}
-
-func TestExecCommandExecutor_Failure(t *testing.T) {
-	exec := &execCommandExecutor{}
-	_, err := exec.Execute(context.Background(), "", "git", "invalid-subcommand-xyz")
-	if err == nil {
-		t.Error("expected error for invalid git subcommand")
-	}
-}
-
 func TestTruncate(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,284 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// TagReviewThread marks tasks created from a pull request review thread.
+const TagReviewThread = "review-thread"
+
+// reviewTask pairs a review thread with the task addressing it.
+type reviewTask struct {
+	thread codehost.ReviewThread
+	taskID string
+}
+
+// AddressReview works through the unresolved review threads on pull request
+// cfg.AddressReview. Each thread becomes a task, one sprint runs on the pull
+// request's branch, the fixes are pushed, and each addressed thread gets a
+// reply naming the fixing commit and is resolved.
+func (s *Supervisor) AddressReview(ctx context.Context) error {
+	defer s.closeStore()
+
+	number := s.cfg.AddressReview
+	if number <= 0 {
+		_ = s.store.UpdateSessionStatus(s.sessionID, "failed")
+		return fmt.Errorf("no pull request to address")
+	}
+	s.logger.Info("addressing review comments", "pr", number)
+
+	tasks, err := s.prepareReview(ctx, number)
+	if err == nil && len(tasks) > 0 && !s.cfg.DryRun {
+		err = s.runReviewSprint(ctx, number, tasks)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		_ = s.store.UpdateSessionStatus(s.sessionID, "interrupted")
+		return ctx.Err()
+	case err != nil:
+		_ = s.store.UpdateSessionStatus(s.sessionID, "failed")
+		return err
+	}
+	_ = s.store.UpdateSessionStatus(s.sessionID, "completed")
+	return nil
+}
+
+// prepareReview checks out the pull request's branch and turns its
+// unresolved review threads into tasks. In dry-run mode it only lists them.
+func (s *Supervisor) prepareReview(ctx context.Context, number int) ([]reviewTask, error) {
+	if err := s.workflow.CloneOrOpen(ctx); err != nil {
+		return nil, fmt.Errorf("opening repository: %w", err)
+	}
+	host, err := s.workflow.CodeHost(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("finding code host: %w", err)
+	}
+	pr, err := host.GetPR(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if pr.State != "open" {
+		return nil, fmt.Errorf("pull request #%d is %s", number, pr.State)
+	}
+	threads, err := host.ReviewThreads(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	// Threads an earlier run already took on keep their task, and the
+	// store's task IDs are unique across sessions, so they are skipped.
+	fresh := threads[:0]
+	for _, t := range threads {
+		if s.reviewTaskExists(reviewTaskID(number, t)) {
+			s.logger.Info("review thread already has a task", "pr", number, "location", threadLocation(t))
+			continue
+		}
+		fresh = append(fresh, t)
+	}
+	threads = fresh
+	if len(threads) == 0 {
+		s.logger.Info("no review threads to address", "pr", number)
+		return nil, nil
+	}
+
+	if s.cfg.DryRun {
+		for _, t := range threads {
+			s.logger.Info("would address review thread", "pr", number, "location", threadLocation(t))
+		}
+		return nil, nil
+	}
+
+	if err := s.workflow.CheckoutWorktree(ctx, pr.Head, pr.Base); err != nil {
+		return nil, fmt.Errorf("checking out %s: %w", pr.Head, err)
+	}
+	s.recordWorktree()
+	s.prNumber = pr.Number
+	if err := s.store.SetSessionPR(s.sessionID, pr.Number, pr.URL); err != nil {
+		s.logger.Warn("could not record PR", "error", err)
+	}
+
+	tasks := make([]reviewTask, 0, len(threads))
+	for _, t := range threads {
+		task := &taskdb.Task{
+			ID:          reviewTaskID(number, t),
+			Title:       "Address review comment on " + threadLocation(t),
+			Description: reviewTaskDescription(number, t),
+			Priority:    0,
+			Complexity:  2,
+			MaxAttempts: 2,
+			Tags:        []string{TagReviewThread},
+		}
+		if err := s.tasks.Add(task); err != nil {
+			return nil, fmt.Errorf("adding task for %s: %w", threadLocation(t), err)
+		}
+		tasks = append(tasks, reviewTask{thread: t, taskID: task.ID})
+	}
+
+	if s.cfg.JournalEnabled {
+		_ = s.journal.Add(&store.JournalEntry{
+			Kind:       string(journal.KindReviewReceived),
+			Summary:    fmt.Sprintf("Human review on PR #%d: %d unresolved threads", number, len(threads)),
+			Reflection: fmt.Sprintf("Addressing review comments on %s.", pr.URL),
+		})
+	}
+	return tasks, nil
+}
+
+// reviewTaskID is the ID of the task addressing thread t on pull request
+// number. It is the same on every run, so a thread gets one task.
+func reviewTaskID(number int, t codehost.ReviewThread) string {
+	return fmt.Sprintf("review-%d-%s", number, t.ID)
+}
+
+// reviewTaskExists reports whether a task with id is in this session's task
+// DB or was stored by any earlier session.
+func (s *Supervisor) reviewTaskExists(id string) bool {
+	if _, ok := s.taskDB.Get(id); ok {
+		return true
+	}
+	_, err := s.store.GetTask(id)
+	return err == nil
+}
+
+// runReviewSprint runs one sprint over the review tasks, pushes the result
+// and answers the threads.
+func (s *Supervisor) runReviewSprint(ctx context.Context, number int, tasks []reviewTask) error {
+	loop, err := ralph.NewLoop(s.cfg.ToRalphConfig(), s.workflow.WorktreePath(), s.logger)
+	if err != nil {
+		return fmt.Errorf("creating ralph loop: %w", err)
+	}
+	defer func() {
+		if closeErr := loop.Close(); closeErr != nil {
+			s.logger.Warn("failed to close ralph loop", "error", closeErr)
+		}
+	}()
+	loop.SetEventSink(s.events)
+	loop.SetDockerRetryHook(s.journalDockerRetry)
+
+	return s.fixReview(ctx, number, tasks, NewRalphAgentRunner(loop))
+}
+
+// fixReview runs the sprint with runner, pushes, and replies to and
+// resolves the threads whose tasks completed.
+func (s *Supervisor) fixReview(ctx context.Context, number int, tasks []reviewTask, runner AgentRunner) error {
+	// Every thread gets a turn, even when there are more than a sprint holds.
+	cfg := *s.cfg
+	if cfg.SprintSize < len(tasks) {
+		cfg.SprintSize = len(tasks)
+	}
+	// Replies name each thread's own commit, so keep one per task.
+	if cfg.Commit.Squash == workflow.SquashSprint {
+		cfg.Commit.Squash = workflow.SquashTask
+	}
+	sr := NewSprintRunner(&cfg, s.store, s.sessionID,
+		s.workflow, s.taskDB, s.collector, s.budget, s.journal, runner, s.logger)
+	sr.SetPauseGate(s.gate)
+	sr.SetEventSink(s.events)
+	if _, err := sr.RunSprint(ctx, 1, 1); err != nil {
+		return fmt.Errorf("running sprint: %w", err)
+	}
+
+	if err := s.workflow.Push(ctx); err != nil {
+		return err
+	}
+	host, err := s.workflow.CodeHost(ctx)
+	if err != nil {
+		return fmt.Errorf("finding code host: %w", err)
+	}
+
+	fixes := s.fixCommits(ctx)
+	for _, rt := range tasks {
+		task, ok := s.taskDB.Get(rt.taskID)
+		if !ok || task.Status != taskdb.StatusCompleted {
+			continue
+		}
+		commit := fixes[rt.taskID]
+		reply := "No change made: agentbox found nothing to fix here."
+		if commit != "" {
+			reply = fmt.Sprintf("Addressed in %s.", shortSHA(commit))
+		}
+		if err := host.ReplyToThread(ctx, number, rt.thread.ID, reply); err != nil {
+			s.logger.Warn("could not reply to review thread", "location", threadLocation(rt.thread), "error", err)
+			continue
+		}
+		if commit == "" {
+			continue
+		}
+		if err := host.ResolveThread(ctx, number, rt.thread.ID); err != nil {
+			if errors.Is(err, codehost.ErrNotSupported) {
+				s.logger.Info("code host cannot resolve review threads", "host", host.Name())
+				continue
+			}
+			s.logger.Warn("could not resolve review thread", "location", threadLocation(rt.thread), "error", err)
+		}
+	}
+	return nil
+}
+
+// fixCommits maps each completed task to the commit that finished it. Each
+// attempt records HEAD before it ran, so a successful attempt's work ends
+// where the next attempt began, or at the current HEAD for the last one. A
+// task whose attempt left HEAD unchanged maps to "".
+func (s *Supervisor) fixCommits(ctx context.Context) map[string]string {
+	type run struct {
+		taskID  string
+		attempt taskdb.Attempt
+	}
+	var runs []run
+	for _, t := range s.taskDB.TasksByStatus(taskdb.StatusCompleted, taskdb.StatusFailed,
+		taskdb.StatusPending, taskdb.StatusInProgress, taskdb.StatusDeferred, taskdb.StatusBlocked) {
+		for _, a := range t.Attempts {
+			runs = append(runs, run{taskID: t.ID, attempt: a})
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].attempt.StartedAt.Before(runs[j].attempt.StartedAt) })
+
+	head, _ := s.workflow.CurrentCommit(ctx)
+	fixes := make(map[string]string)
+	for i, r := range runs {
+		if !r.attempt.Success {
+			continue
+		}
+		end := head
+		if i+1 < len(runs) {
+			end = runs[i+1].attempt.GitCommit
+		}
+		if end != "" && end != r.attempt.GitCommit {
+			fixes[r.taskID] = end
+		}
+	}
+	return fixes
+}
+
+// threadLocation names the file and line a review thread is about.
+func threadLocation(t codehost.ReviewThread) string {
+	switch {
+	case t.Path == "":
+		return "the pull request"
+	case t.Line > 0:
+		return fmt.Sprintf("%s:%d", t.Path, t.Line)
+	}
+	return t.Path
+}
+
+// reviewTaskDescription gives the agent the thread's location and comments.
+func reviewTaskDescription(number int, t codehost.ReviewThread) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "A reviewer commented on %s in pull request #%d.\n\n", threadLocation(t), number)
+	for _, c := range t.Comments {
+		fmt.Fprintf(&sb, "%s: %s\n\n", c.Author, strings.TrimSpace(c.Body))
+	}
+	sb.WriteString("Change the code to address the comments. If nothing needs to change, make no changes.")
+	return sb.String()
+}
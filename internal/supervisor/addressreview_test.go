@@ -0,0 +1,143 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+)
+
+// reviewFixRunner succeeds on every task, writing a file for the tasks in
+// edits and leaving the worktree alone for the rest.
+type reviewFixRunner struct {
+	dir   string
+	edits map[string]string // task ID -> file to create
+}
+
+func (r *reviewFixRunner) RunTask(_ context.Context, task *ralph.Task, _ string) *ralph.IterationResult {
+	if name, ok := r.edits[task.ID]; ok {
+		if err := os.WriteFile(filepath.Join(r.dir, name), []byte(task.ID+"\n"), 0644); err != nil {
+			return &ralph.IterationResult{TaskID: task.ID, Error: err.Error()}
+		}
+	}
+	return &ralph.IterationResult{TaskID: task.ID, Success: true}
+}
+
+func TestAddressReview(t *testing.T) {
+	sup, host := newCodeHostSupervisor(t)
+	ctx := context.Background()
+	clone := sup.workflow.RepoDir()
+	if out, err := exec.Command("git", "-C", clone, "push", "origin", "main:feat/login").CombinedOutput(); err != nil {
+		t.Fatalf("push: %v\n%s", err, out)
+	}
+	pr, _ := host.OpenPR(ctx, codehost.PROptions{Title: "Add login", Head: "feat/login", Base: "main"})
+	fixed := host.AddReviewThread(pr.Number, "auth.go", 12, codehost.ReviewComment{Author: "alice", Body: "handle the nil session"})
+	noop := host.AddReviewThread(pr.Number, "README.md", 0, codehost.ReviewComment{Author: "bob", Body: "is this still true?"})
+	sup.cfg.AddressReview = pr.Number
+
+	tasks, err := sup.prepareReview(ctx, pr.Number)
+	if err != nil {
+		t.Fatalf("prepareReview: %v", err)
+	}
+	if len(tasks) != 2 || sup.workflow.BranchName() != "feat/login" {
+		t.Fatalf("tasks = %+v on branch %s", tasks, sup.workflow.BranchName())
+	}
+	task, _ := sup.taskDB.Get(tasks[0].taskID)
+	if !task.HasTag(TagReviewThread) || !strings.Contains(task.Description, "auth.go:12") ||
+		!strings.Contains(task.Description, "alice: handle the nil session") {
+		t.Errorf("task = %+v", task)
+	}
+
+	runner := &reviewFixRunner{dir: sup.workflow.WorktreePath(), edits: map[string]string{tasks[0].taskID: "auth.go"}}
+	if err := sup.fixReview(ctx, pr.Number, tasks, runner); err != nil {
+		t.Fatalf("fixReview: %v", err)
+	}
+
+	head, err := sup.workflow.CurrentCommit(ctx)
+	if err != nil {
+		t.Fatalf("CurrentCommit: %v", err)
+	}
+	out, err := exec.Command("git", "-C", clone, "ls-remote", "origin", "feat/login").Output()
+	if err != nil || !strings.HasPrefix(string(out), head) {
+		t.Errorf("origin feat/login = %q, want %s", out, head)
+	}
+
+	thread, resolved := host.Thread(fixed)
+	if !resolved || len(thread.Comments) != 2 || thread.Comments[1].Body != "Addressed in "+head[:12]+"." {
+		t.Errorf("fixed thread = %+v, resolved %v", thread, resolved)
+	}
+	thread, resolved = host.Thread(noop)
+	if resolved || len(thread.Comments) != 2 || !strings.HasPrefix(thread.Comments[1].Body, "No change made") {
+		t.Errorf("unchanged thread = %+v, resolved %v", thread, resolved)
+	}
+
+	sess, _ := sup.Store().GetSession(sup.SessionID())
+	if sess.PRNumber != pr.Number {
+		t.Errorf("session PR = %d, want %d", sess.PRNumber, pr.Number)
+	}
+}
+
+func TestPrepareReview_SecondRound(t *testing.T) {
+	sup, host := newCodeHostSupervisor(t)
+	ctx := context.Background()
+	clone := sup.workflow.RepoDir()
+	if out, err := exec.Command("git", "-C", clone, "push", "origin", "main:feat/login").CombinedOutput(); err != nil {
+		t.Fatalf("push: %v\n%s", err, out)
+	}
+	pr, _ := host.OpenPR(ctx, codehost.PROptions{Title: "Add login", Head: "feat/login", Base: "main"})
+	first := host.AddReviewThread(pr.Number, "auth.go", 12, codehost.ReviewComment{Author: "alice", Body: "handle the nil session"})
+
+	tasks, err := sup.prepareReview(ctx, pr.Number)
+	if err != nil {
+		t.Fatalf("first prepareReview: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].taskID != fmt.Sprintf("review-%d-%s", pr.Number, first) {
+		t.Fatalf("first round tasks = %+v", tasks)
+	}
+
+	// A later run is a new session with its own task DB on the same store.
+	second := host.AddReviewThread(pr.Number, "auth.go", 40, codehost.ReviewComment{Author: "alice", Body: "and the expired one"})
+	sup.taskDB = taskdb.New()
+	sup.tasks = &TaskEditor{taskDB: sup.taskDB, store: sup.store, sessionID: sup.sessionID}
+
+	tasks, err = sup.prepareReview(ctx, pr.Number)
+	if err != nil {
+		t.Fatalf("second prepareReview: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].taskID != fmt.Sprintf("review-%d-%s", pr.Number, second) {
+		t.Errorf("second round tasks = %+v, want only the new thread", tasks)
+	}
+}
+
+func TestAddressReview_NoThreads(t *testing.T) {
+	sup, host := newCodeHostSupervisor(t)
+	pr, _ := host.OpenPR(context.Background(), codehost.PROptions{Title: "Add login", Head: "feat/login"})
+	sup.cfg.AddressReview = pr.Number
+
+	if err := sup.AddressReview(context.Background()); err != nil {
+		t.Fatalf("AddressReview: %v", err)
+	}
+}
+
+func TestNewForResume_RejectsAddressReviewSession(t *testing.T) {
+	s := openTestStore(t)
+	sessionID, err := s.CreateSession("", "", `{"address_review":7}`)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.UpdateSessionStatus(sessionID, "interrupted"); err != nil {
+		t.Fatalf("UpdateSessionStatus: %v", err)
+	}
+
+	_, err = newForResumeWithStore(s, sessionID, t.TempDir(), testLogger())
+	if err == nil || !strings.Contains(err.Error(), "--address-review 7") {
+		t.Errorf("newForResumeWithStore = %v, want a pointer to --address-review", err)
+	}
+}
@@ -0,0 +1,291 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/events"
+	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+)
+
+// TagCIFix marks tasks created from a failing CI job.
+const TagCIFix = "ci-fix"
+
+// defaultCITimeout bounds the wait for CI when ci_timeout is unset.
+const defaultCITimeout = 30 * time.Minute
+
+// CI excerpt limits: lines kept around each failure, and the overall caps.
+const (
+	ciContextBefore = 3
+	ciContextAfter  = 8
+	maxCIExcerptLen = 60
+	maxCIExcerptB   = 4000
+)
+
+// ciPollInterval is how often the CI gate asks the code host for results,
+// and ciNoneGrace how long it waits for a commit with no checks at all to
+// get some before treating the repository as having no CI. Variables so
+// tests can shorten them.
+var (
+	ciPollInterval = 30 * time.Second
+	ciNoneGrace    = 2 * time.Minute
+)
+
+var (
+	ansiEscape   = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	logTimestamp = regexp.MustCompile(`^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d(\.\d+)?Z ?`)
+	failureLine  = regexp.MustCompile(`(?i)\b(fail(ed|ure)?|error|panic)\b`)
+)
+
+// runCIGate pushes the branch and waits for the code host's CI on the new
+// head. Failing jobs are treated like failed quality checks: a failing
+// snapshot is recorded, a QualityCheckFailed event is emitted, and each
+// failing job becomes a fix task carrying an excerpt of its log. Problems
+// talking to the code host are logged and the session carries on.
+func (s *Supervisor) runCIGate(ctx context.Context, sprint, iteration int) {
+	if !s.cfg.CIGate || s.cfg.DryRun {
+		return
+	}
+	host, err := s.workflow.CodeHost(ctx)
+	if err != nil {
+		s.logger.Warn("CI gate: could not find code host", "error", err)
+		return
+	}
+	if err := s.workflow.Push(ctx); err != nil {
+		s.logger.Warn("CI gate: could not push", "error", err)
+		return
+	}
+	head, err := s.workflow.CurrentCommit(ctx)
+	if err != nil {
+		s.logger.Warn("CI gate: could not read HEAD", "error", err)
+		return
+	}
+
+	s.logger.Info("waiting for CI", "commit", shortSHA(head), "host", host.Name())
+	st, err := s.waitForCI(ctx, host, head)
+	switch {
+	case err != nil:
+		s.logger.Warn("CI gate: could not read CI status", "commit", shortSHA(head), "error", err)
+		return
+	case st.State == codehost.CINone:
+		s.logger.Info("no CI checks reported", "commit", shortSHA(head))
+		return
+	case st.State != codehost.CIFailure:
+		if pendingChecks(st.Checks) > 0 {
+			s.logger.Warn("CI did not finish in time", "commit", shortSHA(head), "pending", pendingChecks(st.Checks))
+			return
+		}
+		s.logger.Info("CI passed", "commit", shortSHA(head))
+		return
+	}
+	s.ciFailed(ctx, host, head, sprint, iteration, st)
+}
+
+// waitForCI polls the CI status of head until every check has finished,
+// the commit still has no checks after ciNoneGrace, or the timeout passes.
+// On timeout it returns the last status seen.
+func (s *Supervisor) waitForCI(ctx context.Context, host codehost.CodeHost, head string) (*codehost.CIStatus, error) {
+	timeout := defaultCITimeout
+	if s.cfg.CITimeout != "" {
+		if d, err := time.ParseDuration(s.cfg.CITimeout); err == nil {
+			timeout = d
+		}
+	}
+	start := time.Now()
+	for {
+		st, err := host.CIStatus(ctx, head)
+		if err != nil {
+			return nil, err
+		}
+		if st.State == codehost.CINone {
+			if time.Since(start) >= ciNoneGrace {
+				return st, nil
+			}
+		} else if pendingChecks(st.Checks) == 0 {
+			return st, nil
+		}
+		if time.Since(start) >= timeout {
+			return st, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(ciPollInterval):
+		}
+	}
+}
+
+// ciFailed records a failing CI run and queues a fix task per failed job.
+func (s *Supervisor) ciFailed(ctx context.Context, host codehost.CodeHost, head string, sprint, iteration int, st *codehost.CIStatus) {
+	var failed []codehost.Check
+	for _, c := range st.Checks {
+		if c.State == codehost.CIFailure {
+			failed = append(failed, c)
+		}
+	}
+	names := make([]string, len(failed))
+	for i, c := range failed {
+		names[i] = c.Name
+	}
+	s.logger.Warn("CI failed", "commit", shortSHA(head), "jobs", strings.Join(names, ", "))
+
+	checksJSON, _ := json.Marshal(st.Checks)
+	if err := s.collector.RecordQuality(&store.QualitySnapshot{
+		Iteration:   iteration,
+		OverallPass: false,
+		ChecksJSON:  string(checksJSON),
+		Timestamp:   time.Now(),
+	}); err != nil {
+		s.logger.Warn("could not record CI result", "error", err)
+	}
+	s.events.Emit(events.Event{
+		Kind:      events.QualityCheckFailed,
+		Iteration: iteration,
+		Message:   fmt.Sprintf("CI failed on %s: %s", shortSHA(head), strings.Join(names, ", ")),
+	})
+
+	// A job that is still failing after a fix attempt should not pile up a
+	// second task while the first is still queued.
+	queued := make(map[string]bool)
+	for _, t := range s.taskDB.TasksByStatus(taskdb.StatusPending, taskdb.StatusInProgress) {
+		queued[t.Title] = true
+	}
+	added := 0
+	for i, c := range failed {
+		title := "Fix failing CI job: " + c.Name
+		if queued[title] {
+			continue
+		}
+		task := &taskdb.Task{
+			ID:           fmt.Sprintf("ci-fix-%s-%d", head[:min(len(head), 7)], i+1),
+			Title:        title,
+			Description:  ciTaskDescription(head, c),
+			Priority:     0,
+			Complexity:   2,
+			MaxAttempts:  2,
+			ContextNotes: s.ciLogNotes(ctx, host, c),
+			Tags:         []string{TagCIFix},
+		}
+		if err := s.tasks.Add(task); err != nil {
+			s.logger.Warn("could not add CI fix task", "job", c.Name, "error", err)
+			continue
+		}
+		added++
+	}
+
+	if s.cfg.JournalEnabled {
+		_ = s.journal.Add(&store.JournalEntry{
+			Kind:       string(journal.KindCIFailed),
+			Sprint:     sprint,
+			Iteration:  iteration,
+			Summary:    fmt.Sprintf("CI failed on %s: %s", shortSHA(head), strings.Join(names, ", ")),
+			Reflection: fmt.Sprintf("Queued %d fix tasks from the failing job logs.", added),
+		})
+	}
+}
+
+// ciLogNotes returns the excerpt of a failed job's log for the fix task's
+// context notes, or a pointer to the job when the log is unavailable.
+func (s *Supervisor) ciLogNotes(ctx context.Context, host codehost.CodeHost, c codehost.Check) string {
+	log, err := host.CheckLog(ctx, c)
+	if err != nil {
+		if !errors.Is(err, codehost.ErrNotSupported) {
+			s.logger.Warn("could not fetch CI log", "job", c.Name, "error", err)
+		}
+		if c.URL != "" {
+			return "The job log could not be fetched; see " + c.URL
+		}
+		return ""
+	}
+	excerpt := ciLogExcerpt(log)
+	if excerpt == "" {
+		return ""
+	}
+	return "Excerpt from the failing CI job log:\n\n```\n" + excerpt + "\n```"
+}
+
+// ciTaskDescription tells the agent which job failed on which commit.
+func ciTaskDescription(head string, c codehost.Check) string {
+	desc := fmt.Sprintf("The CI job %q failed on commit %s. Find the cause and fix it so the job passes.", c.Name, shortSHA(head))
+	if c.URL != "" {
+		desc += "\n\nJob: " + c.URL
+	}
+	return desc
+}
+
+// ciLogExcerpt picks the part of a job log worth showing an agent: the lines
+// around failure markers such as FAIL, error or panic, or the tail of the
+// log when there are none. Colour codes and runner timestamps are stripped.
+func ciLogExcerpt(log string) string {
+	lines := strings.Split(strings.ReplaceAll(log, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		l = ansiEscape.ReplaceAllString(l, "")
+		lines[i] = strings.TrimRight(logTimestamp.ReplaceAllString(l, ""), " \r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	keep := make([]bool, len(lines))
+	matched := false
+	for i, l := range lines {
+		if !failureLine.MatchString(l) {
+			continue
+		}
+		matched = true
+		for j := max(0, i-ciContextBefore); j <= min(len(lines)-1, i+ciContextAfter); j++ {
+			keep[j] = true
+		}
+	}
+
+	var out []string
+	if !matched {
+		out = lines[max(0, len(lines)-maxCIExcerptLen):]
+	} else {
+		for i, l := range lines {
+			switch {
+			case keep[i]:
+				out = append(out, l)
+			case i > 0 && keep[i-1]:
+				out = append(out, "...")
+			}
+		}
+		// The last failures are usually the summary; keep those.
+		if len(out) > maxCIExcerptLen {
+			out = out[len(out)-maxCIExcerptLen:]
+		}
+	}
+
+	excerpt := strings.Join(out, "\n")
+	if len(excerpt) > maxCIExcerptB {
+		excerpt = excerpt[len(excerpt)-maxCIExcerptB:]
+		if i := strings.IndexByte(excerpt, '\n'); i >= 0 {
+			excerpt = excerpt[i+1:]
+		}
+	}
+	return strings.TrimSpace(excerpt)
+}
+
+// pendingChecks counts the checks that have not finished.
+func pendingChecks(checks []codehost.Check) int {
+	n := 0
+	for _, c := range checks {
+		if c.State == codehost.CIPending {
+			n++
+		}
+	}
+	return n
+}
+
+// shortSHA abbreviates a commit hash for logs and messages.
+func shortSHA(sha string) string {
+	return sha[:min(len(sha), 12)]
+}
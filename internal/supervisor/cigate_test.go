@@ -0,0 +1,126 @@
+package supervisor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/events"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+)
+
+// newCIGateSupervisor returns a code host supervisor with ci_gate on,
+// working on a new branch, and the commit CI will be asked about.
+func newCIGateSupervisor(t *testing.T) (*Supervisor, *codehost.Fake, string) {
+	t.Helper()
+	oldPoll, oldGrace := ciPollInterval, ciNoneGrace
+	ciPollInterval, ciNoneGrace = time.Millisecond, 0
+	t.Cleanup(func() { ciPollInterval, ciNoneGrace = oldPoll, oldGrace })
+
+	sup, host := newCodeHostSupervisor(t)
+	sup.cfg.CIGate = true
+	ctx := context.Background()
+	if err := sup.workflow.CreateWorktree(ctx, "agentbox/ci"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	head, err := sup.workflow.CurrentCommit(ctx)
+	if err != nil {
+		t.Fatalf("CurrentCommit: %v", err)
+	}
+	return sup, host, head
+}
+
+func TestRunCIGate_FailingJobsBecomeTasks(t *testing.T) {
+	sup, host, head := newCIGateSupervisor(t)
+	var got []events.Event
+	sup.SetEventSink(func(e events.Event) { got = append(got, e) })
+
+	host.SetCIStatus(head,
+		codehost.Check{Name: "build", State: codehost.CISuccess},
+		codehost.Check{Name: "integration", State: codehost.CIFailure, URL: "https://ci.example.com/jobs/7"},
+	)
+	host.SetCheckLog("integration", "=== RUN TestCheckout\n--- FAIL: TestCheckout (0.2s)\n    cart_test.go:41: total = 9, want 10\nFAIL\n")
+
+	ctx := context.Background()
+	sup.runCIGate(ctx, 1, 5)
+
+	tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending)
+	if len(tasks) != 1 {
+		t.Fatalf("pending tasks = %+v, want one CI fix", tasks)
+	}
+	task := tasks[0]
+	if task.Title != "Fix failing CI job: integration" || task.Priority != 0 || task.Tags[0] != TagCIFix {
+		t.Errorf("task = %+v", task)
+	}
+	if !strings.Contains(task.Description, "https://ci.example.com/jobs/7") {
+		t.Errorf("description = %q", task.Description)
+	}
+	if !strings.Contains(task.ContextNotes, "total = 9, want 10") {
+		t.Errorf("context notes = %q", task.ContextNotes)
+	}
+
+	snaps, err := sup.Store().QualitySnapshots(sup.SessionID())
+	if err != nil || len(snaps) != 1 || snaps[0].OverallPass || !strings.Contains(snaps[0].ChecksJSON, "integration") {
+		t.Errorf("quality snapshots = %+v, %v", snaps, err)
+	}
+	if len(got) != 1 || got[0].Kind != events.QualityCheckFailed || got[0].Iteration != 5 {
+		t.Errorf("events = %+v", got)
+	}
+
+	// Still failing while the fix is queued: no second task.
+	sup.runCIGate(ctx, 2, 6)
+	if tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending); len(tasks) != 1 {
+		t.Errorf("pending tasks after a second failure = %d, want 1", len(tasks))
+	}
+}
+
+func TestRunCIGate_Passing(t *testing.T) {
+	sup, host, head := newCIGateSupervisor(t)
+	host.SetCIStatus(head, codehost.Check{Name: "build", State: codehost.CISuccess})
+
+	sup.runCIGate(context.Background(), 1, 1)
+	if tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending); len(tasks) != 0 {
+		t.Errorf("passing CI added tasks: %+v", tasks)
+	}
+	if snaps, _ := sup.Store().QualitySnapshots(sup.SessionID()); len(snaps) != 0 {
+		t.Errorf("passing CI recorded snapshots: %+v", snaps)
+	}
+}
+
+func TestRunCIGate_Timeout(t *testing.T) {
+	sup, host, head := newCIGateSupervisor(t)
+	sup.cfg.CITimeout = "1ms"
+	host.SetCIStatus(head, codehost.Check{Name: "build", State: codehost.CIPending})
+
+	sup.runCIGate(context.Background(), 1, 1)
+	if tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending); len(tasks) != 0 {
+		t.Errorf("unfinished CI added tasks: %+v", tasks)
+	}
+}
+
+func TestCILogExcerpt(t *testing.T) {
+	var lines []string
+	lines = append(lines, "2026-01-02T03:04:05.1234567Z \x1b[36;1mgo test ./...\x1b[0m")
+	for i := 0; i < 200; i++ {
+		lines = append(lines, "ok  \tpkg/module"+strings.Repeat("x", i%5))
+	}
+	lines = append(lines, "--- FAIL: TestLogin (0.01s)", "    login_test.go:12: got 401", "FAIL\tpkg/auth")
+	excerpt := ciLogExcerpt(strings.Join(lines, "\r\n") + "\r\n")
+
+	if !strings.Contains(excerpt, "--- FAIL: TestLogin") || !strings.Contains(excerpt, "got 401") {
+		t.Errorf("excerpt misses the failure:\n%s", excerpt)
+	}
+	if strings.Contains(excerpt, "go test ./...") || strings.Contains(excerpt, "\r") {
+		t.Errorf("excerpt keeps lines far from the failure:\n%s", excerpt)
+	}
+	if n := strings.Count(excerpt, "\n") + 1; n > maxCIExcerptLen {
+		t.Errorf("excerpt has %d lines", n)
+	}
+
+	tail := ciLogExcerpt("2026-01-02T03:04:05Z \x1b[31mstep one\x1b[0m\nstep two\n")
+	if tail != "step one\nstep two" {
+		t.Errorf("excerpt without failures = %q", tail)
+	}
+}
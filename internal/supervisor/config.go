@@ -7,6 +7,7 @@ import (
 
 	"github.com/swamp-dev/agentbox/internal/config"
 	"github.com/swamp-dev/agentbox/internal/metrics"
+	"github.com/swamp-dev/agentbox/internal/retry"
 )
 
 // Config holds supervisor-specific configuration.
@@ -21,6 +22,13 @@ type Config struct {
 	ReviewAgent   string `yaml:"review_agent" json:"review_agent"`
 	FallbackAgent string `yaml:"fallback_agent" json:"fallback_agent"`
 
+	// AgentBaseURL points the primary agent at an alternative API endpoint
+	// (LLM gateway or a model server on the host).
+	AgentBaseURL string `yaml:"agent_base_url,omitempty" json:"agent_base_url,omitempty"`
+
+	// AgentModel overrides the primary agent's default model.
+	AgentModel string `yaml:"agent_model,omitempty" json:"agent_model,omitempty"`
+
 	// Review settings.
 	ReviewAfter     string `yaml:"review_after" json:"review_after"` // "sprint" or "pr"
 	MaxReviewRounds int    `yaml:"max_review_rounds" json:"max_review_rounds"`
@@ -28,13 +36,52 @@ type Config struct {
 	// Budget.
 	Budget metrics.Budget `yaml:"budget" json:"budget"`
 
+	// Retry controls backoff for transient agent and Docker failures (rate
+	// limits, overloaded APIs, network timeouts). Retries do not count as
+	// attempts.
+	Retry retry.Policy `yaml:"retry" json:"retry"`
+
+	// Ensemble races several agents on hard tasks and keeps the best result.
+	Ensemble EnsembleConfig `yaml:"ensemble,omitempty" json:"ensemble,omitempty"`
+
 	// Features.
 	JournalEnabled bool `yaml:"journal_enabled" json:"journal_enabled"`
 	ReviewEnabled  bool `yaml:"review_enabled" json:"review_enabled"`
 	AutoCommit     bool `yaml:"auto_commit" json:"auto_commit"`
 
 	// Escalation.
-	EscalationMethod string `yaml:"escalation_method" json:"escalation_method"` // "github_issue", "file", "none"
+	EscalationMethod string `yaml:"escalation_method" json:"escalation_method"` // "issue", "file", "none"
+
+	// DraftPR opens a draft pull request after the first sprint that
+	// completes a task and updates it after every sprint; finalize marks it
+	// ready for review.
+	DraftPR bool `yaml:"draft_pr" json:"draft_pr"`
+
+	// CIGate pushes the branch after each sprint and waits for the code
+	// host's CI on the new head; failing jobs become fix tasks. CITimeout
+	// bounds the wait (default 30m).
+	CIGate    bool   `yaml:"ci_gate" json:"ci_gate"`
+	CITimeout string `yaml:"ci_timeout" json:"ci_timeout,omitempty"`
+
+	// SyncBase brings new commits on the base branch into the sprint branch
+	// between sprints: "merge" merges the base branch, "rebase" rebases
+	// onto it. Empty leaves the branch on the base it started from.
+	SyncBase string `yaml:"sync_base" json:"sync_base,omitempty"`
+
+	// AddressReview is the pull request whose review comments the session
+	// addresses (see Supervisor.AddressReview), or 0 for a normal session.
+	AddressReview int `yaml:"-" json:"address_review,omitempty"`
+
+	// CodeHost selects where pull requests and escalation issues go. By
+	// default it is worked out from the origin remote.
+	CodeHost config.CodeHostConfig `yaml:"code_host,omitempty" json:"code_host,omitempty"`
+
+	// Commit sets the commit message template, signing and squashing.
+	Commit config.CommitConfig `yaml:"commit,omitempty" json:"commit,omitempty"`
+
+	// Packages are the monorepo subprojects tasks can be scoped to, with
+	// their own image, quality checks and protected paths.
+	Packages []config.PackageConfig `yaml:"packages,omitempty" json:"packages,omitempty"`
 
 	// Paths.
 	RepoURL    string `yaml:"repo_url" json:"repo_url"`
@@ -55,6 +102,14 @@ type Config struct {
 	// Quality checks run after each iteration.
 	QualityChecks []QualityCheck `yaml:"quality_checks" json:"quality_checks"`
 
+	// Storage controls transcript compression, blob files and the
+	// project-wide retention applied when a session starts.
+	Storage config.StorageConfig `yaml:"storage,omitempty" json:"storage,omitempty"`
+	// TranscriptRetention overrides Storage.TranscriptRetention for this
+	// session's transcripts: a duration such as "7d", or "keep" to keep
+	// them forever.
+	TranscriptRetention string `yaml:"transcript_retention,omitempty" json:"transcript_retention,omitempty"`
+
 	// DryRun mode — use NoopAgentRunner instead of real agent.
 	DryRun bool `yaml:"-" json:"-"`
 }
@@ -76,6 +131,7 @@ func DefaultConfig() *Config {
 		ReviewAfter:         "sprint",
 		MaxReviewRounds:     2,
 		Budget:              metrics.DefaultBudget(),
+		Retry:               retry.DefaultPolicy(),
 		JournalEnabled:      true,
 		ReviewEnabled:       true,
 		AutoCommit:          true,
@@ -94,10 +150,11 @@ func (c *Config) ToRalphConfig() *config.Config {
 	if workDir == "" {
 		workDir = "."
 	}
+	retryPolicy := c.Retry
 	return &config.Config{
 		Version: "1.0",
 		Project: config.ProjectConfig{Name: filepath.Base(workDir)},
-		Agent:   config.AgentConfig{Name: c.Agent},
+		Agent:   config.AgentConfig{Name: c.Agent, BaseURL: c.AgentBaseURL, Model: c.AgentModel},
 		Docker: config.DockerConfig{
 			Image:            c.DockerImage,
 			Resources:        config.ResourcesConfig{Memory: c.DockerMemory, CPUs: c.DockerCPUs},
@@ -112,7 +169,102 @@ func (c *Config) ToRalphConfig() *config.Config {
 			StopSignal:    "<promise>COMPLETE</promise>",
 			QualityChecks: toConfigQualityChecks(c.QualityChecks),
 		},
+		Commit:   c.Commit,
+		Packages: c.Packages,
+		Retry:    &retryPolicy,
+	}
+}
+
+// ApplyProjectConfig copies the settings a sprint uses from a project's
+// agentbox.yaml: agent, Docker, PRD file, quality checks and, when the file
+// has one, the supervisor section.
+func (c *Config) ApplyProjectConfig(pc *config.Config) {
+	c.Agent = pc.Agent.Name
+	c.AgentBaseURL = pc.Agent.BaseURL
+	c.AgentModel = pc.Agent.Model
+	c.DockerImage = pc.Docker.Image
+	c.DockerMemory = pc.Docker.Resources.Memory
+	c.DockerCPUs = pc.Docker.Resources.CPUs
+	c.DockerNetwork = pc.Docker.Network
+	c.DockerAllowedEndpoints = pc.Docker.AllowedEndpoints
+	c.PRDFile = pc.Ralph.PRDFile
+	if len(pc.Ralph.QualityChecks) > 0 {
+		c.QualityChecks = make([]QualityCheck, len(pc.Ralph.QualityChecks))
+		for i, qc := range pc.Ralph.QualityChecks {
+			c.QualityChecks[i] = QualityCheck{Name: qc.Name, Command: qc.Command}
+		}
+	}
+
+	c.Storage = pc.Storage
+	c.CodeHost = pc.CodeHost
+	c.Commit = pc.Commit
+	c.Packages = pc.Packages
+	if pc.Retry != nil {
+		c.Retry = *pc.Retry
+	}
+
+	sup := pc.Supervisor
+	if !sup.IsSet() {
+		return
+	}
+	if sup.SprintSize > 0 {
+		c.SprintSize = sup.SprintSize
+	}
+	if sup.MaxSprints > 0 {
+		c.MaxSprints = sup.MaxSprints
+	}
+	if sup.MaxConsecutiveFails > 0 {
+		c.MaxConsecutiveFails = sup.MaxConsecutiveFails
+	}
+	if sup.ReviewAgent != "" {
+		c.ReviewAgent = sup.ReviewAgent
+	}
+	if sup.FallbackAgent != "" {
+		c.FallbackAgent = sup.FallbackAgent
+	}
+	if sup.ReviewAfter != "" {
+		c.ReviewAfter = sup.ReviewAfter
+	}
+	if sup.BudgetDuration != "" {
+		c.BudgetDuration = sup.BudgetDuration
+	}
+	if sup.EscalationMethod != "" {
+		c.EscalationMethod = sup.EscalationMethod
+	}
+	if sup.JournalEnabled != nil {
+		c.JournalEnabled = *sup.JournalEnabled
+	}
+	if sup.ReviewEnabled != nil {
+		c.ReviewEnabled = *sup.ReviewEnabled
+	}
+	if sup.DraftPR != nil {
+		c.DraftPR = *sup.DraftPR
+	}
+	if sup.CIGate != nil {
+		c.CIGate = *sup.CIGate
+	}
+	if sup.CITimeout != "" {
+		c.CITimeout = sup.CITimeout
+	}
+	if sup.SyncBase != "" {
+		c.SyncBase = sup.SyncBase
+	}
+}
+
+// CommitPolicy returns the commit policy sprint commits follow: the commit
+// section with the packages' protected paths added.
+func (c *Config) CommitPolicy() config.CommitConfig {
+	return c.ToRalphConfig().CommitPolicy()
+}
+
+// bookkeepingPaths are the worktree files agentbox itself writes: the
+// .agentbox directory, the progress file and the PRD.
+func (c *Config) bookkeepingPaths() []string {
+	paths := []string{".agentbox", "progress.txt"}
+	if c.PRDFile != "" && !filepath.IsAbs(c.PRDFile) {
+		paths = append(paths, c.PRDFile)
 	}
+	return paths
 }
 
 // toConfigQualityChecks converts supervisor QualityChecks to config QualityChecks.
@@ -31,6 +31,9 @@ func (cb *ContextBuilder) BuildPrompt(task *taskdb.Task, projectName string) str
 	sb.WriteString(fmt.Sprintf("ID: %s\n", task.ID))
 	sb.WriteString(fmt.Sprintf("Title: %s\n", task.Title))
 	sb.WriteString(fmt.Sprintf("Description: %s\n", task.Description))
+	if task.Workdir != "" {
+		sb.WriteString(fmt.Sprintf("Working directory: %s (you start there; the rest of the repository is under /workspace)\n", task.Workdir))
+	}
 	sb.WriteString("\n")
 
 	// Acceptance criteria.
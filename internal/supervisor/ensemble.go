@@ -0,0 +1,422 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/review"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// TagEnsemble marks a task that should always run in ensemble mode. It is
+// set from the PRD's per-task "ensemble": true flag.
+const TagEnsemble = "ensemble"
+
+// EnsembleAgent is one ensemble member: an agent and, optionally, the model
+// it runs with.
+type EnsembleAgent struct {
+	Agent string `yaml:"agent" json:"agent"`
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+}
+
+// ParseEnsembleAgent parses an "agent" or "agent:model" member. Everything
+// after the first colon is the model, so model tags may contain colons.
+func ParseEnsembleAgent(s string) EnsembleAgent {
+	name, model, _ := strings.Cut(strings.TrimSpace(s), ":")
+	return EnsembleAgent{Agent: name, Model: model}
+}
+
+// String returns the member in "agent" or "agent:model" form.
+func (a EnsembleAgent) String() string {
+	if a.Model == "" {
+		return a.Agent
+	}
+	return a.Agent + ":" + a.Model
+}
+
+// EnsembleConfig controls multi-agent ensemble mode, where several agents
+// race on the same task in separate worktrees and the best result is kept.
+type EnsembleConfig struct {
+	// Agents lists the competing agents. Repeat an agent with different
+	// models, or with none, to race it against itself. Ensemble mode needs
+	// at least two entries.
+	Agents []EnsembleAgent `yaml:"agents,omitempty" json:"agents,omitempty"`
+
+	// MinComplexity sends every task at or above this complexity through the
+	// ensemble. 0 means only tasks flagged in the PRD use it.
+	MinComplexity int `yaml:"min_complexity,omitempty" json:"min_complexity,omitempty"`
+
+	// Judge asks the review agent to pick among passing candidates instead of
+	// simply taking the smallest diff.
+	Judge bool `yaml:"judge,omitempty" json:"judge,omitempty"`
+}
+
+// Enabled reports whether enough agents are configured to form an ensemble.
+func (e EnsembleConfig) Enabled() bool {
+	return len(e.Agents) >= 2
+}
+
+// Members returns the configured members in "agent" or "agent:model" form.
+func (e EnsembleConfig) Members() []string {
+	members := make([]string, len(e.Agents))
+	for i, m := range e.Agents {
+		members[i] = m.String()
+	}
+	return members
+}
+
+// Applies reports whether task should run in ensemble mode.
+func (e EnsembleConfig) Applies(task *taskdb.Task) bool {
+	if !e.Enabled() {
+		return false
+	}
+	if task.HasTag(TagEnsemble) {
+		return true
+	}
+	return e.MinComplexity > 0 && task.Complexity >= e.MinComplexity
+}
+
+// RunnerFactory builds an AgentRunner for member working in worktreePath.
+// The returned close function releases its resources.
+type RunnerFactory func(member EnsembleAgent, worktreePath string) (AgentRunner, func() error, error)
+
+// TaskRunFunc runs one attempt at task with runner. The sprint runner passes
+// its retrying runner so candidates get the same transient-failure handling
+// as any other attempt.
+type TaskRunFunc func(ctx context.Context, runner AgentRunner, task *ralph.Task, prompt string) *ralph.IterationResult
+
+// Candidate is one ensemble member's result.
+type Candidate struct {
+	Agent        string
+	Model        string
+	Path         string
+	Result       *ralph.IterationResult
+	AcceptanceOK bool
+	Patch        string
+	DiffLines    int
+	Err          error
+}
+
+// member returns the ensemble member the candidate ran as.
+func (c *Candidate) member() EnsembleAgent {
+	return EnsembleAgent{Agent: c.Agent, Model: c.Model}
+}
+
+// passed reports whether the candidate is eligible to win.
+func (c *Candidate) passed() bool {
+	return c.Err == nil && c.Result != nil && c.Result.Success && c.AcceptanceOK && c.Patch != ""
+}
+
+// Judge picks the best of several passing candidates, returning its index.
+type Judge interface {
+	Pick(ctx context.Context, task *taskdb.Task, candidates []*Candidate) (int, error)
+}
+
+// EnsembleResult is the outcome of an ensemble run.
+type EnsembleResult struct {
+	// Result is the winner's iteration result, or an aggregated failure if
+	// no candidate passed.
+	Result     *ralph.IterationResult
+	Winner     *Candidate
+	Candidates []*Candidate
+}
+
+// EnsembleRunner races several agents on one task and applies the best
+// result to the sprint worktree.
+type EnsembleRunner struct {
+	cfg       EnsembleConfig
+	workflow  *workflow.GitWorkflow
+	newRunner RunnerFactory
+	judge     Judge
+	exclude   []string
+	logger    *slog.Logger
+}
+
+// NewEnsembleRunner creates an EnsembleRunner. judge may be nil, in which
+// case the smallest passing diff wins. Paths in exclude (the PRD and
+// per-worktree bookkeeping) are left out of candidate patches because the
+// sprint worktree tracks them on its own.
+func NewEnsembleRunner(cfg EnsembleConfig, wf *workflow.GitWorkflow, newRunner RunnerFactory, judge Judge, exclude []string, logger *slog.Logger) *EnsembleRunner {
+	return &EnsembleRunner{
+		cfg:       cfg,
+		workflow:  wf,
+		newRunner: newRunner,
+		judge:     judge,
+		exclude:   exclude,
+		logger:    logger,
+	}
+}
+
+// Run executes task with every configured agent in parallel, each in its own
+// detached worktree, using run for each candidate's attempt. The winning
+// candidate's changes are applied (and staged) in the sprint worktree; all
+// candidate worktrees are removed afterwards.
+func (e *EnsembleRunner) Run(ctx context.Context, task *taskdb.Task, ralphTask *ralph.Task, prompt string, run TaskRunFunc) *EnsembleResult {
+	base := e.workflow.WorktreePath()
+	candidates := make([]*Candidate, len(e.cfg.Agents))
+	for i, m := range e.cfg.Agents {
+		candidates[i] = &Candidate{
+			Agent: m.Agent,
+			Model: m.Model,
+			Path: fmt.Sprintf("%s-ensemble-%s-%d-%s", base, pathSafe.Replace(task.ID), i+1,
+				pathSafe.Replace(m.String())),
+		}
+	}
+
+	e.logger.Info("running ensemble", "task", task.ID, "agents", e.cfg.Members())
+
+	// Worktrees are created up front: concurrent "git worktree add" calls
+	// race on the repository's lock files.
+	for _, c := range candidates {
+		if err := e.workflow.AddDetachedWorktree(ctx, c.Path); err != nil {
+			c.Err = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range candidates {
+		if c.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(c *Candidate) {
+			defer wg.Done()
+			e.runCandidate(ctx, task, ralphTask, prompt, run, c)
+		}(c)
+	}
+	wg.Wait()
+
+	// Candidate worktrees are scratch space; the winner's patch is already
+	// captured in memory.
+	defer func() {
+		for _, c := range candidates {
+			if err := e.workflow.RemoveWorktree(context.WithoutCancel(ctx), c.Path); err != nil {
+				e.logger.Warn("failed to remove ensemble worktree", "path", c.Path, "error", err)
+			}
+		}
+	}()
+
+	out := &EnsembleResult{Candidates: candidates}
+	winner := e.pick(ctx, task, candidates)
+	if winner == nil {
+		out.Result = aggregateFailure(ralphTask.ID, candidates)
+		return out
+	}
+
+	if err := e.workflow.ApplyPatch(ctx, winner.Patch); err != nil {
+		out.Result = &ralph.IterationResult{
+			TaskID: ralphTask.ID,
+			Output: winner.Result.Output,
+			Error:  fmt.Sprintf("applying ensemble winner (%s): %s", winner.member(), err),
+		}
+		return out
+	}
+
+	e.logger.Info("ensemble winner applied",
+		"task", task.ID,
+		"agent", winner.member().String(),
+		"diff_lines", winner.DiffLines,
+	)
+	out.Winner = winner
+	out.Result = winner.Result
+	return out
+}
+
+// runCandidate runs the agent in c's worktree and collects the acceptance
+// result and patch.
+func (e *EnsembleRunner) runCandidate(ctx context.Context, task *taskdb.Task, ralphTask *ralph.Task, prompt string, run TaskRunFunc, c *Candidate) {
+	runner, closeRunner, err := e.newRunner(c.member(), c.Path)
+	if err != nil {
+		c.Err = fmt.Errorf("creating runner for %s: %w", c.member(), err)
+		return
+	}
+	defer func() {
+		if closeErr := closeRunner(); closeErr != nil {
+			e.logger.Warn("failed to close ensemble runner", "agent", c.member().String(), "error", closeErr)
+		}
+	}()
+
+	// Each candidate gets its own copy so concurrent runners don't share state.
+	t := *ralphTask
+	c.Result = run(ctx, runner, &t, prompt)
+	if !c.Result.Success {
+		return
+	}
+
+	if err := runAcceptanceChecks(ctx, c.Path, task.AcceptanceCriteria); err != nil {
+		c.Result.Error = fmt.Sprintf("acceptance check failed: %s", err)
+		return
+	}
+	c.AcceptanceOK = true
+
+	patch, err := e.workflow.WorkingPatch(ctx, c.Path, e.exclude)
+	if err != nil {
+		c.Err = fmt.Errorf("collecting patch from %s: %w", c.member(), err)
+		return
+	}
+	c.Patch = patch
+	c.DiffLines = countDiffLines(patch)
+}
+
+// pick returns the winning candidate, or nil if none passed.
+func (e *EnsembleRunner) pick(ctx context.Context, task *taskdb.Task, candidates []*Candidate) *Candidate {
+	var passing []*Candidate
+	for _, c := range candidates {
+		if c.passed() {
+			passing = append(passing, c)
+		}
+	}
+	if len(passing) == 0 {
+		return nil
+	}
+
+	// Smallest diff first; the stable sort keeps configuration order on ties.
+	sort.SliceStable(passing, func(i, j int) bool {
+		return passing[i].DiffLines < passing[j].DiffLines
+	})
+
+	if e.judge != nil && len(passing) > 1 {
+		idx, err := e.judge.Pick(ctx, task, passing)
+		if err == nil && idx >= 0 && idx < len(passing) {
+			return passing[idx]
+		}
+		e.logger.Warn("ensemble judge failed, falling back to smallest diff", "error", err)
+	}
+	return passing[0]
+}
+
+// aggregateFailure builds a failed result describing why every candidate lost.
+func aggregateFailure(taskID string, candidates []*Candidate) *ralph.IterationResult {
+	result := &ralph.IterationResult{TaskID: taskID}
+	var reasons []string
+	for _, c := range candidates {
+		reason := "no changes"
+		switch {
+		case c.Err != nil:
+			reason = c.Err.Error()
+		case c.Result != nil && c.Result.Error != "":
+			reason = c.Result.Error
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", c.member(), reason))
+		if result.Output == "" && c.Result != nil {
+			result.Output = c.Result.Output
+		}
+	}
+	result.Error = "no ensemble candidate passed (" + strings.Join(reasons, "; ") + ")"
+	return result
+}
+
+// pathSafe turns task IDs and ensemble members into worktree path components.
+var pathSafe = strings.NewReplacer("/", "-", ":", "-")
+
+// runAcceptanceChecks runs each criterion's command in dir. Criteria without
+// a command are left to the review step.
+func runAcceptanceChecks(ctx context.Context, dir string, criteria []taskdb.AcceptanceCriteria) error {
+	for _, ac := range criteria {
+		if ac.Command == "" {
+			continue
+		}
+		if err := ralph.ValidateCheckCommand(ac.Command); err != nil {
+			return fmt.Errorf("%s: %w", ac.Description, err)
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", ac.Command)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %s", ac.Description, truncate(string(out), 500))
+		}
+	}
+	return nil
+}
+
+// countDiffLines counts added and removed lines in a unified diff.
+func countDiffLines(patch string) int {
+	n := 0
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			n++
+		}
+	}
+	return n
+}
+
+// ReviewJudge picks the candidate the review agent likes best: approved
+// over not approved, then fewest blocking findings, then fewest findings.
+// Candidates arrive sorted by diff size, so ties go to the smaller diff.
+type ReviewJudge struct {
+	reviewer *review.Reviewer
+}
+
+// NewReviewJudge creates a Judge backed by a review agent.
+func NewReviewJudge(r *review.Reviewer) *ReviewJudge {
+	return &ReviewJudge{reviewer: r}
+}
+
+// Pick reviews each candidate's patch and returns the index of the best one.
+func (j *ReviewJudge) Pick(ctx context.Context, _ *taskdb.Task, candidates []*Candidate) (int, error) {
+	best, bestScore := -1, 0
+	for i, c := range candidates {
+		res, err := j.reviewer.Review(ctx, c.Path, c.Patch, patchFiles(c.Patch), "")
+		if err != nil {
+			continue
+		}
+		score := len(res.BlockerFindings())*100 + len(res.Findings)
+		if !res.Approved {
+			score += 10000
+		}
+		if best < 0 || score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return -1, fmt.Errorf("review failed for all %d candidates", len(candidates))
+	}
+	return best, nil
+}
+
+// patchFiles extracts the changed file paths from a git diff.
+func patchFiles(patch string) []string {
+	var files []string
+	for _, line := range strings.Split(patch, "\n") {
+		if rest, ok := strings.CutPrefix(line, "diff --git a/"); ok {
+			if i := strings.Index(rest, " b/"); i >= 0 {
+				files = append(files, rest[:i])
+			}
+		}
+	}
+	return files
+}
+
+// newRalphRunnerFactory returns a RunnerFactory that builds a ralph loop per
+// candidate from the supervisor config with the member's agent and model
+// swapped in.
+func newRalphRunnerFactory(cfg *Config, logger *slog.Logger) RunnerFactory {
+	return func(member EnsembleAgent, worktreePath string) (AgentRunner, func() error, error) {
+		c := *cfg
+		if member.Agent != cfg.Agent {
+			// The base URL and model are specific to the primary agent's
+			// provider.
+			c.AgentBaseURL = ""
+			c.AgentModel = ""
+		}
+		if member.Model != "" {
+			c.AgentModel = member.Model
+		}
+		c.Agent = member.Agent
+		c.WorkDir = worktreePath
+		loop, err := ralph.NewLoop(c.ToRalphConfig(), worktreePath, logger.With("ensemble_agent", member.String()))
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewRalphAgentRunner(loop), loop.Close, nil
+	}
+}
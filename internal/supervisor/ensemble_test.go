@@ -0,0 +1,410 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/retry"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// editingRunner writes files into its worktree to simulate an agent's edits.
+type editingRunner struct {
+	dir     string
+	files   map[string]string
+	success bool
+}
+
+func (r *editingRunner) RunTask(_ context.Context, task *ralph.Task, _ string) *ralph.IterationResult {
+	for name, content := range r.files {
+		if err := os.WriteFile(filepath.Join(r.dir, name), []byte(content), 0644); err != nil {
+			return &ralph.IterationResult{TaskID: task.ID, Error: err.Error()}
+		}
+	}
+	if !r.success {
+		return &ralph.IterationResult{TaskID: task.ID, Error: "agent reported failure: gave up"}
+	}
+	return &ralph.IterationResult{TaskID: task.ID, Success: true, QualityOK: true, Output: "done"}
+}
+
+// fakeFactory returns a RunnerFactory whose runners apply the given edits
+// per ensemble member ("agent" or "agent:model").
+func fakeFactory(edits map[string]map[string]string, failing map[string]bool) RunnerFactory {
+	return func(member EnsembleAgent, worktreePath string) (AgentRunner, func() error, error) {
+		name := member.String()
+		if _, ok := edits[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown agent %q", name)
+		}
+		r := &editingRunner{dir: worktreePath, files: edits[name], success: !failing[name]}
+		return r, func() error { return nil }, nil
+	}
+}
+
+// members parses ensemble members from their "agent[:model]" form.
+func members(specs ...string) []EnsembleAgent {
+	out := make([]EnsembleAgent, len(specs))
+	for i, s := range specs {
+		out[i] = ParseEnsembleAgent(s)
+	}
+	return out
+}
+
+// runOnce runs a candidate without retries.
+func runOnce(ctx context.Context, r AgentRunner, task *ralph.Task, prompt string) *ralph.IterationResult {
+	return r.RunTask(ctx, task, prompt)
+}
+
+type fixedJudge struct {
+	pick  int
+	calls int
+}
+
+func (j *fixedJudge) Pick(_ context.Context, _ *taskdb.Task, _ []*Candidate) (int, error) {
+	j.calls++
+	return j.pick, nil
+}
+
+func setupEnsembleRepo(t *testing.T) *workflow.GitWorkflow {
+	t.Helper()
+	repoDir := initGitRepo(t, map[string]string{"main.go": "package main\n"})
+	wf := workflow.NewGitWorkflow("", repoDir, testLogger())
+	wf.SetWorktreePath(repoDir, "main")
+	return wf
+}
+
+func TestEnsembleConfig_Applies(t *testing.T) {
+	cfg := EnsembleConfig{Agents: members("claude", "aider"), MinComplexity: 4}
+
+	if !cfg.Applies(&taskdb.Task{Complexity: 4}) {
+		t.Error("expected complexity 4 to meet threshold 4")
+	}
+	if cfg.Applies(&taskdb.Task{Complexity: 3}) {
+		t.Error("expected complexity 3 to miss threshold 4")
+	}
+	if !cfg.Applies(&taskdb.Task{Complexity: 1, Tags: []string{TagEnsemble}}) {
+		t.Error("expected ensemble tag to opt in regardless of complexity")
+	}
+
+	single := EnsembleConfig{Agents: members("claude"), MinComplexity: 1}
+	if single.Applies(&taskdb.Task{Complexity: 5, Tags: []string{TagEnsemble}}) {
+		t.Error("expected ensemble with one agent to be disabled")
+	}
+
+	optIn := EnsembleConfig{Agents: members("claude", "aider")}
+	if optIn.Applies(&taskdb.Task{Complexity: 5}) {
+		t.Error("expected MinComplexity 0 to require PRD opt-in")
+	}
+}
+
+func TestParseEnsembleAgent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want EnsembleAgent
+	}{
+		{"claude", EnsembleAgent{Agent: "claude"}},
+		{" claude:opus ", EnsembleAgent{Agent: "claude", Model: "opus"}},
+		{"aider:ollama/qwen2.5-coder:7b", EnsembleAgent{Agent: "aider", Model: "ollama/qwen2.5-coder:7b"}},
+	}
+	for _, tt := range tests {
+		got := ParseEnsembleAgent(tt.in)
+		if got != tt.want {
+			t.Errorf("ParseEnsembleAgent(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if back := ParseEnsembleAgent(got.String()); back != got {
+			t.Errorf("String() of %+v does not round-trip: %q", got, got.String())
+		}
+	}
+}
+
+func TestEnsembleRunner_SmallestDiffWins(t *testing.T) {
+	wf := setupEnsembleRepo(t)
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\nfunc c() {}\n"},
+		"aider":  {"main.go": "package main\n\nfunc a() {}\n"},
+	}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude", "aider")}, wf, fakeFactory(edits, nil), nil, nil, testLogger())
+
+	task := &taskdb.Task{ID: "t-1", Title: "Task"}
+	res := e.Run(context.Background(), task, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+
+	if !res.Result.Success {
+		t.Fatalf("expected success, got error %q", res.Result.Error)
+	}
+	if res.Winner == nil || res.Winner.Agent != "aider" {
+		t.Fatalf("expected aider (smaller diff) to win, got %+v", res.Winner)
+	}
+
+	data, err := os.ReadFile(filepath.Join(wf.WorktreePath(), "main.go"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != edits["aider"]["main.go"] {
+		t.Errorf("winner patch not applied, main.go = %q", data)
+	}
+
+	for _, c := range res.Candidates {
+		if _, err := os.Stat(c.Path); !os.IsNotExist(err) {
+			t.Errorf("expected candidate worktree %s to be removed", c.Path)
+		}
+	}
+}
+
+func TestEnsembleRunner_FailedCandidateCannotWin(t *testing.T) {
+	wf := setupEnsembleRepo(t)
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\n"},
+		"aider":  {"main.go": "package main\n\n"},
+	}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude", "aider")}, wf,
+		fakeFactory(edits, map[string]bool{"aider": true}), nil, nil, testLogger())
+
+	res := e.Run(context.Background(), &taskdb.Task{ID: "t-1"}, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+	if res.Winner == nil || res.Winner.Agent != "claude" {
+		t.Fatalf("expected claude to win, got %+v", res.Winner)
+	}
+}
+
+func TestEnsembleRunner_AcceptanceCriteriaGate(t *testing.T) {
+	repoDir := initGitRepo(t, map[string]string{
+		"main.go":  "package main\n",
+		"Makefile": "done:\n\ttest -f DONE\n",
+	})
+	wf := workflow.NewGitWorkflow("", repoDir, testLogger())
+	wf.SetWorktreePath(repoDir, "main")
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\n", "DONE": "yes"},
+		"aider":  {"main.go": "package main\n\nfunc a() {}\n"},
+	}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude", "aider")}, wf, fakeFactory(edits, nil), nil, nil, testLogger())
+
+	task := &taskdb.Task{
+		ID: "t-1",
+		AcceptanceCriteria: []taskdb.AcceptanceCriteria{
+			{Description: "marker file exists", Command: "make done"},
+		},
+	}
+	res := e.Run(context.Background(), task, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+	if res.Winner == nil || res.Winner.Agent != "claude" {
+		t.Fatalf("expected claude (only one meeting acceptance criteria) to win, got %+v", res.Winner)
+	}
+}
+
+func TestEnsembleRunner_NoCandidatePasses(t *testing.T) {
+	wf := setupEnsembleRepo(t)
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "broken"},
+		"aider":  {"main.go": "also broken"},
+	}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude", "aider")}, wf,
+		fakeFactory(edits, map[string]bool{"claude": true, "aider": true}), nil, nil, testLogger())
+
+	res := e.Run(context.Background(), &taskdb.Task{ID: "t-1"}, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+	if res.Result.Success {
+		t.Fatal("expected failure when no candidate passes")
+	}
+	if res.Winner != nil {
+		t.Errorf("expected no winner, got %s", res.Winner.Agent)
+	}
+	if !strings.Contains(res.Result.Error, "claude") || !strings.Contains(res.Result.Error, "aider") {
+		t.Errorf("expected error to mention both agents, got %q", res.Result.Error)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(wf.WorktreePath(), "main.go"))
+	if string(data) != "package main\n" {
+		t.Errorf("expected sprint worktree untouched, main.go = %q", data)
+	}
+}
+
+func TestEnsembleRunner_SameAgentDifferentModels(t *testing.T) {
+	wf := setupEnsembleRepo(t)
+	edits := map[string]map[string]string{
+		"claude:opus":   {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\n"},
+		"claude:sonnet": {"main.go": "package main\n\nfunc a() {}\n"},
+	}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude:opus", "claude:sonnet")}, wf, fakeFactory(edits, nil), nil, nil, testLogger())
+
+	res := e.Run(context.Background(), &taskdb.Task{ID: "t-1"}, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+	if res.Winner == nil || res.Winner.Agent != "claude" || res.Winner.Model != "sonnet" {
+		t.Fatalf("expected claude:sonnet (smaller diff) to win, got %+v", res.Winner)
+	}
+	if res.Candidates[0].Path == res.Candidates[1].Path {
+		t.Errorf("expected distinct worktrees, both at %s", res.Candidates[0].Path)
+	}
+	for _, c := range res.Candidates {
+		if strings.Contains(filepath.Base(c.Path), ":") {
+			t.Errorf("expected model separator to be kept out of worktree path %s", c.Path)
+		}
+	}
+}
+
+func TestEnsembleRunner_JudgeOverridesDiffSize(t *testing.T) {
+	wf := setupEnsembleRepo(t)
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\n"},
+		"aider":  {"main.go": "package main\n\nfunc a() {}\n"},
+	}
+	// Candidates reach the judge sorted by diff size: [aider, claude].
+	judge := &fixedJudge{pick: 1}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude", "aider"), Judge: true}, wf, fakeFactory(edits, nil), judge, nil, testLogger())
+
+	res := e.Run(context.Background(), &taskdb.Task{ID: "t-1"}, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+	if judge.calls != 1 {
+		t.Errorf("expected judge to be consulted once, got %d", judge.calls)
+	}
+	if res.Winner == nil || res.Winner.Agent != "claude" {
+		t.Fatalf("expected judge's pick (claude) to win, got %+v", res.Winner)
+	}
+}
+
+func TestEnsembleRunner_ExcludesPaths(t *testing.T) {
+	wf := setupEnsembleRepo(t)
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\n", "progress.txt": "noise"},
+		"aider":  {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\n"},
+	}
+	e := NewEnsembleRunner(EnsembleConfig{Agents: members("claude", "aider")}, wf, fakeFactory(edits, nil), nil, []string{"progress.txt"}, testLogger())
+
+	res := e.Run(context.Background(), &taskdb.Task{ID: "t-1"}, &ralph.Task{ID: "t-1"}, "prompt", runOnce)
+	if res.Winner == nil {
+		t.Fatalf("expected a winner, got error %q", res.Result.Error)
+	}
+	if strings.Contains(res.Winner.Patch, "progress.txt") {
+		t.Error("expected excluded path to be left out of the patch")
+	}
+	if _, err := os.Stat(filepath.Join(wf.WorktreePath(), "progress.txt")); !os.IsNotExist(err) {
+		t.Error("expected excluded file not to be applied to the sprint worktree")
+	}
+}
+
+func TestSprintRunner_RunIteration_Ensemble(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	wf := setupEnsembleRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.JournalEnabled = true
+	cfg.AutoCommit = true
+	cfg.Ensemble = EnsembleConfig{Agents: members("claude", "aider")}
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Hard task", Status: taskdb.StatusPending, MaxAttempts: 3, Tags: []string{TagEnsemble}}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Hard task", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\n"},
+		"aider":  {"main.go": "package main\n\nfunc a() {}\nfunc b() {}\n"},
+	}
+	// The primary runner must not be used for ensemble tasks.
+	primary := &MockAgentRunner{}
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, primary, logger)
+	sr.SetEnsemble(NewEnsembleRunner(cfg.Ensemble, wf, fakeFactory(edits, nil), nil, nil, logger))
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if !sr.runIteration(context.Background(), task) {
+		t.Fatal("expected ensemble iteration to succeed")
+	}
+	if primary.idx != 0 {
+		t.Errorf("expected primary runner unused, got %d calls", primary.idx)
+	}
+	if got := task.LastAttempt().AgentName; got != "claude" {
+		t.Errorf("expected attempt credited to claude, got %q", got)
+	}
+
+	entries, err := j.Entries(&store.JournalQuery{Kind: string(journal.KindEnsemble)})
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].Summary, "claude won") {
+		t.Errorf("expected one ensemble journal entry naming the winner, got %+v", entries)
+	}
+
+	// Auto-commit should have committed the applied winner patch.
+	out, err := wf.DiffFiles(context.Background(), "HEAD~1")
+	if err != nil {
+		t.Fatalf("DiffFiles: %v", err)
+	}
+	if len(out) != 1 || out[0] != "main.go" {
+		t.Errorf("expected winner commit to touch main.go, got %v", out)
+	}
+}
+
+// transientOnceRunner fails with a transient error on its first run, then
+// behaves like the wrapped runner.
+type transientOnceRunner struct {
+	AgentRunner
+	calls int
+}
+
+func (r *transientOnceRunner) RunTask(ctx context.Context, task *ralph.Task, prompt string) *ralph.IterationResult {
+	r.calls++
+	if r.calls == 1 {
+		return &ralph.IterationResult{TaskID: task.ID, Transient: true, Error: "agent reported failure: 529 overloaded"}
+	}
+	return r.AgentRunner.RunTask(ctx, task, prompt)
+}
+
+func TestSprintRunner_RunIteration_EnsembleRetriesTransient(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	wf := setupEnsembleRepo(t)
+
+	cfg := DefaultConfig()
+	cfg.AutoCommit = false
+	cfg.Retry = retry.Policy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	cfg.Ensemble = EnsembleConfig{Agents: members("claude", "aider")}
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Hard task", Status: taskdb.StatusPending, MaxAttempts: 3, Tags: []string{TagEnsemble}}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Hard task", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	edits := map[string]map[string]string{
+		"claude": {"main.go": "package main\n\nfunc a() {}\n"},
+		"aider":  {"main.go": "package main\n\nfunc a() {}\n"},
+	}
+	var mu sync.Mutex
+	var flaky []*transientOnceRunner
+	factory := func(member EnsembleAgent, worktreePath string) (AgentRunner, func() error, error) {
+		inner, closeFn, err := fakeFactory(edits, nil)(member, worktreePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		r := &transientOnceRunner{AgentRunner: inner}
+		mu.Lock()
+		flaky = append(flaky, r)
+		mu.Unlock()
+		return r, closeFn, nil
+	}
+
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, &MockAgentRunner{}, logger)
+	sr.SetEnsemble(NewEnsembleRunner(cfg.Ensemble, wf, factory, nil, nil, logger))
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if !sr.runIteration(context.Background(), task) {
+		t.Fatal("expected candidates to be retried past the transient failure")
+	}
+	for _, r := range flaky {
+		if r.calls != 2 {
+			t.Errorf("expected each candidate to run twice, got %d", r.calls)
+		}
+	}
+}
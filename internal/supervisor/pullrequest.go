@@ -0,0 +1,164 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/journal"
+)
+
+// maxPRHighlights caps the journal entries quoted in a PR body.
+const maxPRHighlights = 5
+
+// syncDraftPR keeps the session's draft pull request current. The draft is
+// opened after the first sprint that completes a task; after that every
+// sprint pushes the branch and regenerates the title and body. Failures are
+// logged and the session carries on.
+func (s *Supervisor) syncDraftPR(ctx context.Context, result *SprintResult) {
+	if !s.cfg.DraftPR || s.cfg.DryRun {
+		return
+	}
+	if s.prNumber == 0 && result.TasksCompleted == 0 {
+		return
+	}
+
+	title, body := s.prContent()
+	if s.prNumber == 0 {
+		s.openPR(ctx, title, body, true)
+		return
+	}
+	if err := s.updatePR(ctx, title, body, nil); err != nil {
+		s.logger.Warn("could not update draft PR", "number", s.prNumber, "error", err)
+	}
+}
+
+// publishPR opens the session's pull request, or marks the draft opened by
+// syncDraftPR ready for review with a final title and body.
+func (s *Supervisor) publishPR(ctx context.Context) {
+	title, body := s.prContent()
+	if s.prNumber == 0 {
+		s.openPR(ctx, title, body, false)
+		return
+	}
+	ready := false
+	if err := s.updatePR(ctx, title, body, &ready); err != nil {
+		s.logger.Warn("could not mark PR ready for review", "number", s.prNumber, "error", err)
+		return
+	}
+	s.logger.Info("pull request ready for review", "number", s.prNumber)
+}
+
+// openPR opens a pull request and records it on the session, so a resumed
+// session updates it instead of opening another.
+func (s *Supervisor) openPR(ctx context.Context, title, body string, draft bool) {
+	pr, err := s.workflow.OpenPR(ctx, title, body, draft)
+	if err != nil {
+		s.logger.Warn("could not create PR", "error", err)
+		return
+	}
+	s.prNumber = pr.Number
+	if err := s.store.SetSessionPR(s.sessionID, pr.Number, pr.URL); err != nil {
+		s.logger.Warn("could not record PR", "error", err)
+	}
+}
+
+// updatePR pushes the branch and rewrites the session's pull request. A nil
+// draft leaves the draft state alone.
+func (s *Supervisor) updatePR(ctx context.Context, title, body string, draft *bool) error {
+	host, err := s.workflow.CodeHost(ctx)
+	if err != nil {
+		return fmt.Errorf("finding code host: %w", err)
+	}
+	if err := s.workflow.Push(ctx); err != nil {
+		return err
+	}
+	if _, err := host.UpdatePR(ctx, s.prNumber, codehost.PRUpdate{Title: &title, Body: &body, Draft: draft}); err != nil {
+		return fmt.Errorf("updating PR #%d: %w", s.prNumber, err)
+	}
+	return nil
+}
+
+// prContent returns the pull request title and body for the session so far.
+func (s *Supervisor) prContent() (string, string) {
+	body, err := s.generatePRBody()
+	if err != nil {
+		s.logger.Warn("could not generate PR body", "error", err)
+		body = "Automated PR by agentbox"
+	}
+	total, completed, _, _, _ := s.taskDB.Stats()
+	return fmt.Sprintf("agentbox: %d/%d tasks completed", completed, total), body
+}
+
+// sprintRetroSection summarizes each finished sprint's retrospective.
+func (s *Supervisor) sprintRetroSection() string {
+	reports, err := s.store.SprintReports(s.sessionID)
+	if err != nil || len(reports) == 0 {
+		return ""
+	}
+	section := "## Sprint Retros\n\n"
+	for _, r := range reports {
+		section += fmt.Sprintf("- Sprint %d: %d/%d tasks (%.0f%% velocity), quality %s, pass rate %.1f%%\n",
+			r.SprintNumber, r.TasksCompleted, r.TasksAttempted, r.Velocity*100, r.QualityTrend, r.TestPassRate*100)
+	}
+	return section + "\n"
+}
+
+// journalHighlightsSection lists the latest agent switches, reviews,
+// ensemble results, CI failures and syncs with the base branch from the
+// journal.
+func (s *Supervisor) journalHighlightsSection() string {
+	entries, err := s.journal.Entries(nil)
+	if err != nil {
+		return ""
+	}
+	var highlights []string
+	for _, e := range entries {
+		switch journal.EntryKind(e.Kind) {
+		case journal.KindAgentSwitch, journal.KindReviewReceived, journal.KindEnsemble, journal.KindCIFailed, journal.KindBaseSync:
+			line := "- " + e.Summary
+			if e.Sprint > 0 {
+				line = fmt.Sprintf("- Sprint %d: %s", e.Sprint, e.Summary)
+			}
+			highlights = append(highlights, line)
+		}
+	}
+	if len(highlights) == 0 {
+		return ""
+	}
+	if len(highlights) > maxPRHighlights {
+		highlights = highlights[len(highlights)-maxPRHighlights:]
+	}
+	section := "## Journal Highlights\n\n"
+	for _, h := range highlights {
+		section += h + "\n"
+	}
+	return section + "\n"
+}
+
+// costSection reports tokens and time spent against the budget.
+func (s *Supervisor) costSection() string {
+	usage, err := s.collector.TotalUsage()
+	if err != nil {
+		return ""
+	}
+	budget := s.cfg.Budget
+
+	section := "## Cost\n\n"
+	tokens := fmt.Sprintf("- Tokens: %d", usage.EstimatedTokens)
+	if budget.MaxTokens > 0 {
+		tokens += fmt.Sprintf(" of %d", budget.MaxTokens)
+	}
+	section += tokens + "\n"
+	section += fmt.Sprintf("- Container time: %s\n",
+		(time.Duration(usage.ContainerTimeMs) * time.Millisecond).Round(time.Second))
+	if sess, err := s.store.GetSession(s.sessionID); err == nil {
+		elapsed := fmt.Sprintf("- Elapsed: %s", time.Since(sess.StartedAt).Round(time.Minute))
+		if budget.MaxDuration > 0 {
+			elapsed += fmt.Sprintf(" of %s", budget.MaxDuration)
+		}
+		section += elapsed + "\n"
+	}
+	return section + "\n"
+}
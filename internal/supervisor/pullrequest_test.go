@@ -0,0 +1,117 @@
+package supervisor
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// newCodeHostSupervisor returns a supervisor on a clone with a local bare
+// origin, talking to a fake code host.
+func newCodeHostSupervisor(t *testing.T) (*Supervisor, *codehost.Fake) {
+	t.Helper()
+	repoDir := initGitRepo(t, nil)
+	bareDir := filepath.Join(t.TempDir(), "origin.git")
+	cloneDir := filepath.Join(t.TempDir(), "clone")
+	for _, args := range [][]string{
+		{"git", "clone", "--bare", repoDir, bareDir},
+		{"git", "clone", bareDir, cloneDir},
+		{"git", "-C", cloneDir, "config", "user.email", "test@test.com"},
+		{"git", "-C", cloneDir, "config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.WorkDir = t.TempDir()
+	sup, err := New(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { sup.Store().Close() })
+
+	host := codehost.NewFake()
+	sup.workflow = workflow.NewGitWorkflow("", cloneDir, testLogger())
+	sup.workflow.SetCodeHost(host)
+	return sup, host
+}
+
+// newDraftPRSupervisor returns a code host supervisor with draft_pr on,
+// working on a new branch.
+func newDraftPRSupervisor(t *testing.T) (*Supervisor, *codehost.Fake) {
+	t.Helper()
+	sup, host := newCodeHostSupervisor(t)
+	sup.cfg.DraftPR = true
+	if err := sup.workflow.CreateWorktree(context.Background(), "agentbox/draft"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	return sup, host
+}
+
+func TestSyncDraftPR(t *testing.T) {
+	sup, host := newDraftPRSupervisor(t)
+	ctx := context.Background()
+
+	sup.syncDraftPR(ctx, &SprintResult{SprintNumber: 1, TasksAttempted: 1})
+	if prs := host.PRs(); len(prs) != 0 {
+		t.Fatalf("opened a PR before any task completed: %+v", prs)
+	}
+
+	if err := sup.taskDB.Add(&taskdb.Task{ID: "t-1", Title: "Setup auth", Status: taskdb.StatusCompleted}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	sup.syncDraftPR(ctx, &SprintResult{SprintNumber: 2, TasksAttempted: 1, TasksCompleted: 1})
+	prs := host.PRs()
+	if len(prs) != 1 || !prs[0].Draft || prs[0].Head != "agentbox/draft" || prs[0].Base != "main" {
+		t.Fatalf("PRs after first successful sprint = %+v", prs)
+	}
+	sess, err := sup.Store().GetSession(sup.SessionID())
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if sess.PRNumber != prs[0].Number || sess.PRURL != prs[0].URL {
+		t.Errorf("session PR = #%d %s, want #%d %s", sess.PRNumber, sess.PRURL, prs[0].Number, prs[0].URL)
+	}
+
+	if err := sup.taskDB.Add(&taskdb.Task{ID: "t-2", Title: "Add login", Status: taskdb.StatusCompleted}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	sup.syncDraftPR(ctx, &SprintResult{SprintNumber: 3})
+	prs = host.PRs()
+	if len(prs) != 1 {
+		t.Fatalf("later sprint opened another PR: %+v", prs)
+	}
+	if !prs[0].Draft || !strings.Contains(prs[0].Body, "Add login") || !strings.Contains(prs[0].Title, "2/2") {
+		t.Errorf("PR not updated: %+v", prs[0])
+	}
+
+	sup.publishPR(ctx)
+	prs = host.PRs()
+	if len(prs) != 1 || prs[0].Draft {
+		t.Errorf("PRs after finalize = %+v, want one ready for review", prs)
+	}
+}
+
+func TestSyncDraftPR_Disabled(t *testing.T) {
+	sup, host := newDraftPRSupervisor(t)
+	sup.cfg.DraftPR = false
+	ctx := context.Background()
+
+	sup.syncDraftPR(ctx, &SprintResult{SprintNumber: 1, TasksCompleted: 1})
+	if prs := host.PRs(); len(prs) != 0 {
+		t.Fatalf("opened a draft PR with draft_pr off: %+v", prs)
+	}
+
+	sup.publishPR(ctx)
+	if prs := host.PRs(); len(prs) != 1 || prs[0].Draft {
+		t.Errorf("PRs after finalize = %+v, want one ready for review", prs)
+	}
+}
@@ -0,0 +1,61 @@
+package supervisor
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/store"
+)
+
+// ParseRetention parses a transcript retention setting: a duration such as
+// "30d" or "72h", or "keep" to keep transcripts forever, which is returned
+// as zero.
+func ParseRetention(s string) (time.Duration, error) {
+	if s == "keep" {
+		return 0, nil
+	}
+	d, err := config.ParseAge(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("invalid retention %q (use e.g. 30d, or keep)", s)
+	}
+	return d, nil
+}
+
+// applyTranscriptRetention records the session's own retention, then prunes
+// transcripts that the project-wide policy says have expired.
+func applyTranscriptRetention(s *store.Store, sessionID int64, cfg *Config, logger *slog.Logger) error {
+	if cfg.TranscriptRetention != "" {
+		d, err := ParseRetention(cfg.TranscriptRetention)
+		if err != nil {
+			return fmt.Errorf("transcript_retention: %w", err)
+		}
+		if err := s.SetSessionRetention(sessionID, d); err != nil {
+			return fmt.Errorf("setting transcript retention: %w", err)
+		}
+	}
+
+	maxAge, maxBytes, err := cfg.Storage.RetentionLimits()
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	policy := store.RetentionPolicy{MaxAge: maxAge, MaxBytes: maxBytes}
+	if policy.MaxAge == 0 && policy.MaxBytes == 0 {
+		return nil
+	}
+
+	res, err := s.ApplyRetention(policy, false)
+	if err != nil {
+		// Pruning is housekeeping; a failure should not stop the session.
+		logger.Warn("failed to apply transcript retention", "error", err)
+		return nil
+	}
+	if res.Transcripts > 0 {
+		logger.Info("pruned expired transcripts", "transcripts", res.Transcripts, "bytes", res.Bytes)
+	}
+	return nil
+}
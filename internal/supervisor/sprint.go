@@ -2,14 +2,20 @@ package supervisor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/events"
 	"github.com/swamp-dev/agentbox/internal/journal"
 	"github.com/swamp-dev/agentbox/internal/metrics"
+	"github.com/swamp-dev/agentbox/internal/pause"
 	"github.com/swamp-dev/agentbox/internal/ralph"
 	"github.com/swamp-dev/agentbox/internal/retro"
+	"github.com/swamp-dev/agentbox/internal/retry"
 	"github.com/swamp-dev/agentbox/internal/store"
 	"github.com/swamp-dev/agentbox/internal/taskdb"
 	"github.com/swamp-dev/agentbox/internal/workflow"
@@ -28,11 +34,20 @@ type SprintRunner struct {
 	ctxBuilder *ContextBuilder
 	adaptive   *AdaptiveController
 	runner     AgentRunner
+	ensemble   *EnsembleRunner
+	gate       *pause.Gate
+	events     events.Sink
 	logger     *slog.Logger
 
 	sprintNum        int
 	iteration        int
 	consecutiveFails int
+
+	// sprintBase is HEAD when the sprint started, set only when the
+	// sprint is squashed into one commit; committed lists the tasks the
+	// sprint committed.
+	sprintBase string
+	committed  []workflow.CommitInfo
 }
 
 // SprintResult captures the outcome of a sprint.
@@ -72,6 +87,9 @@ func NewSprintRunner(
 	if cfg.EscalationMethod != "" {
 		adaptive.SetEscalationMethod(cfg.EscalationMethod)
 	}
+	if wf != nil {
+		adaptive.codeHost = wf.CodeHost
+	}
 	if j != nil {
 		adaptive.SetJournal(j)
 	}
@@ -92,11 +110,47 @@ func NewSprintRunner(
 	}
 }
 
+// SetPauseGate lets another goroutine pause the sprint between iterations.
+func (sr *SprintRunner) SetPauseGate(g *pause.Gate) {
+	sr.gate = g
+}
+
+// SetEventSink sends progress events for this sprint to sink.
+func (sr *SprintRunner) SetEventSink(sink events.Sink) {
+	sr.events = sink
+}
+
+// emit sends an event stamped with the current iteration and task counts.
+func (sr *SprintRunner) emit(kind events.Kind, taskID, message string) {
+	if sr.events == nil {
+		return
+	}
+	total, completed, _, _, _ := sr.taskDB.Stats()
+	sr.events.Emit(events.Event{
+		Kind:      kind,
+		Iteration: sr.iteration,
+		TaskID:    taskID,
+		Message:   message,
+		Completed: completed,
+		Total:     total,
+	})
+}
+
+// SetEnsemble enables ensemble mode for tasks matching cfg.Ensemble.
+func (sr *SprintRunner) SetEnsemble(e *EnsembleRunner) {
+	sr.ensemble = e
+}
+
 // RunSprint executes a single sprint of N iterations.
 func (sr *SprintRunner) RunSprint(ctx context.Context, sprintNum, startIter int) (*SprintResult, error) {
 	sr.sprintNum = sprintNum
 	sr.iteration = startIter
 	sr.consecutiveFails = 0
+	sr.committed = nil
+	sr.sprintBase = ""
+	if sr.cfg.Commit.Squash == workflow.SquashSprint && sr.cfg.AutoCommit && sr.workflow != nil {
+		sr.sprintBase, _ = sr.workflow.CurrentCommit(ctx)
+	}
 
 	result := &SprintResult{SprintNumber: sprintNum}
 	sprintStart := time.Now()
@@ -108,12 +162,13 @@ func (sr *SprintRunner) RunSprint(ctx context.Context, sprintNum, startIter int)
 	)
 
 	for i := 0; i < sr.cfg.SprintSize; i++ {
-		select {
-		case <-ctx.Done():
+		if sr.gate.Paused() {
+			sr.logger.Info("sprint paused", "sprint", sprintNum, "iteration", sr.iteration)
+		}
+		if err := sr.gate.Wait(ctx); err != nil {
 			result.AbortedEarly = true
 			result.AbortReason = "context cancelled"
-			return result, ctx.Err()
-		default:
+			return result, err
 		}
 
 		// Check budget.
@@ -128,6 +183,7 @@ func (sr *SprintRunner) RunSprint(ctx context.Context, sprintNum, startIter int)
 		}
 		if budgetStatus.Warning {
 			sr.logger.Warn("budget warning", "reason", budgetStatus.Reason)
+			sr.emit(events.BudgetWarning, "", fmt.Sprintf("Budget warning: %s", budgetStatus.Reason))
 		}
 
 		// Check consecutive failures.
@@ -139,26 +195,31 @@ func (sr *SprintRunner) RunSprint(ctx context.Context, sprintNum, startIter int)
 		}
 
 		// Get next task.
-		task := sr.taskDB.NextTask()
+		task := sr.nextTask(ctx)
 		if task == nil {
 			sr.logger.Info("no more tasks available")
 			break
 		}
 
 		// Run the iteration.
+		sr.emit(events.IterationStart, task.ID, fmt.Sprintf("Iteration %d: %s", sr.iteration, task.Title))
 		success := sr.runIteration(ctx, task)
 		result.TasksAttempted++
 		if success {
 			result.TasksCompleted++
 			sr.consecutiveFails = 0
+			sr.emit(events.IterationEnd, task.ID, fmt.Sprintf("Iteration %d succeeded", sr.iteration))
 		} else {
 			result.TasksFailed++
 			sr.consecutiveFails++
+			sr.emit(events.IterationEnd, task.ID, fmt.Sprintf("Iteration %d failed", sr.iteration))
 		}
 
 		sr.iteration++
 	}
 
+	sr.squashSprint(ctx)
+
 	// Run retrospective.
 	analyzer := retro.NewAnalyzer(sr.store, sr.sessionID)
 	report, err := analyzer.Analyze(sprintNum, startIter, sr.iteration)
@@ -183,7 +244,42 @@ func (sr *SprintRunner) RunSprint(ctx context.Context, sprintNum, startIter int)
 	return result, nil
 }
 
-// runIteration executes a single task iteration.
+// nextTask returns a snapshot of the task to run next; the task editor may
+// change the task in the DB while it runs. While a merge of the base branch
+// waits for its conflicts to be resolved only the conflict task runs, since
+// other tasks could not commit on top of the conflict markers. Once that
+// task has given up the merge is abandoned before anything else runs.
+func (sr *SprintRunner) nextTask(ctx context.Context) *taskdb.Task {
+	var next *taskdb.Task
+	if sr.cfg.SyncBase != "" && sr.workflow != nil && sr.workflow.MergeInProgress(ctx) {
+		if next = queuedConflictTask(sr.taskDB); next == nil {
+			sr.abortMerge(ctx)
+		}
+	}
+	if next == nil {
+		next = sr.taskDB.NextTask()
+	}
+	if next == nil {
+		return nil
+	}
+	task, ok := sr.taskDB.Snapshot(next.ID)
+	if !ok {
+		return nil
+	}
+	return &task
+}
+
+// abortMerge abandons a merge of the base branch whose conflicts were not
+// resolved, leaving the branch on its old base.
+func (sr *SprintRunner) abortMerge(ctx context.Context) {
+	sr.logger.Warn("abandoning merge of the base branch with unresolved conflicts")
+	if err := sr.workflow.AbortMerge(ctx); err != nil {
+		sr.logger.Warn("could not abort merge", "error", err)
+	}
+}
+
+// runIteration executes a single task iteration. task is a snapshot;
+// the attempt and its outcome are written back to the task DB.
 func (sr *SprintRunner) runIteration(ctx context.Context, task *taskdb.Task) bool {
 	sr.logger.Info("starting iteration",
 		"iteration", sr.iteration,
@@ -235,17 +331,56 @@ func (sr *SprintRunner) runIteration(ctx context.Context, task *taskdb.Task) boo
 		ID:          task.ID,
 		Title:       task.Title,
 		Description: task.Description,
+		Workdir:     task.Workdir,
+	}
+	agentName := sr.cfg.Agent
+	var agentResult *ralph.IterationResult
+	if sr.ensemble != nil && sr.cfg.Ensemble.Applies(task) {
+		er := sr.ensemble.Run(ctx, task, ralphTask, prompt, sr.runTaskWithRetry)
+		agentResult = er.Result
+		if er.Winner != nil {
+			agentName = er.Winner.Agent
+		}
+		sr.writeEnsembleEntry(task, er)
+	} else {
+		agentResult = sr.runTaskWithRetry(ctx, sr.runner, ralphTask, prompt)
 	}
-	agentResult := sr.runner.RunTask(ctx, ralphTask, prompt)
 	success := agentResult.Success
 
+	// Auto-commit on success. Work the commit policy refuses, or that
+	// leaves merge conflicts unresolved, fails the attempt, and the agent
+	// sees why on its next one.
+	var refused *workflow.PolicyError
+	if success && sr.cfg.AutoCommit {
+		err := sr.commitTask(ctx, beforeSHA, workflow.CommitInfo{
+			TaskID:    task.ID,
+			Title:     task.Title,
+			Attempt:   attemptNum,
+			Agent:     agentName,
+			Tokens:    (len(prompt) + len(agentResult.Output)) / 4,
+			Iteration: sr.iteration,
+			Sprint:    sr.sprintNum,
+			SessionID: sr.sessionID,
+		})
+		if err != nil {
+			sr.logger.Warn("commit refused", "task", task.ID, "error", err)
+			success = false
+			agentResult.Error = err.Error()
+			errors.As(err, &refused)
+		}
+	}
+
+	if agentResult.Report != nil {
+		sr.ingestReport(task, agentResult.Report, success)
+	}
+
 	duration := time.Since(iterStart)
 
 	// Record resource usage.
 	_ = sr.collector.RecordUsage(&store.ResourceUsage{
 		Iteration:       sr.iteration,
 		TaskID:          task.ID,
-		AgentName:       sr.cfg.Agent,
+		AgentName:       agentName,
 		ContainerTimeMs: int(duration.Milliseconds()),
 	})
 
@@ -264,30 +399,35 @@ func (sr *SprintRunner) runIteration(ctx context.Context, task *taskdb.Task) boo
 	}
 	_ = sr.store.SaveTranscript(attemptID, transcript)
 
-	// Record attempt on the taskdb task.
-	task.Attempts = append(task.Attempts, taskdb.Attempt{
-		Number:    attemptNum,
-		AgentName: sr.cfg.Agent,
-		Success:   success,
-		ErrorMsg:  agentResult.Error,
-		StartedAt: iterStart,
-		GitCommit: beforeSHA,
-	})
-
-	// Auto-commit on success.
-	if success && sr.cfg.AutoCommit {
-		msg := fmt.Sprintf("feat(%s): %s", task.ID, task.Title)
-		if err := sr.workflow.Commit(ctx, msg, nil); err != nil {
-			sr.logger.Warn("commit failed", "error", err)
+	// Record the attempt and the task status in the task DB.
+	now := time.Now()
+	updated, err := sr.taskDB.Update(task.ID, func(t *taskdb.Task) {
+		t.Attempts = append(t.Attempts, taskdb.Attempt{
+			Number:    attemptNum,
+			AgentName: agentName,
+			Success:   success,
+			ErrorMsg:  agentResult.Error,
+			StartedAt: iterStart,
+			GitCommit: beforeSHA,
+		})
+		if success {
+			t.Status = taskdb.StatusCompleted
+			t.CompletedAt = &now
 		}
+	})
+	if err != nil {
+		sr.logger.Warn("could not record attempt on task", "task", task.ID, "error", err)
+	} else {
+		*task = updated
 	}
 
-	// Update task status.
 	if success {
-		task.Status = taskdb.StatusCompleted
-		now := time.Now()
-		task.CompletedAt = &now
 		_ = sr.store.UpdateTaskStatus(task.ID, "completed")
+		sr.emit(events.TaskComplete, task.ID, fmt.Sprintf("Completed %s: %s", task.ID, task.Title))
+	} else if strings.HasPrefix(task.ID, conflictTaskPrefix) && task.HasExhaustedAttempts() && sr.workflow.MergeInProgress(ctx) {
+		sr.abortMerge(ctx)
+	} else if refused != nil && (!keepsRefusedWork(refused) || task.HasExhaustedAttempts()) {
+		sr.discardRefusedWork(ctx, task.ID, beforeSHA)
 	}
 
 	// Write journal entry for result.
@@ -310,6 +450,250 @@ func (sr *SprintRunner) runIteration(ctx context.Context, task *taskdb.Task) boo
 	return success
 }
 
+// TagFollowUp marks tasks created from follow-ups in an agent's report.
+const TagFollowUp = "follow-up"
+
+// maxFollowUpsPerTask caps how many follow-up tasks one task's reports can
+// add across all of its attempts.
+const maxFollowUpsPerTask = 5
+
+// ingestReport records an agent's structured report in the journal and, when
+// the attempt succeeded, turns its follow-ups into new pending tasks. A
+// failed attempt's follow-ups are dropped: they tend to describe the work
+// the agent did not finish rather than new work.
+func (sr *SprintRunner) ingestReport(task *taskdb.Task, report *ralph.Report, success bool) {
+	if sr.cfg.JournalEnabled {
+		var sb strings.Builder
+		writeList := func(heading string, items []string) {
+			if len(items) == 0 {
+				return
+			}
+			fmt.Fprintf(&sb, "%s:\n", heading)
+			for _, item := range items {
+				fmt.Fprintf(&sb, "- %s\n", item)
+			}
+		}
+		writeList("Learnings", report.Learnings)
+		writeList("Blockers", report.Blockers)
+		writeList("Files touched", report.FilesTouched)
+
+		_ = sr.journal.Add(&store.JournalEntry{
+			Kind:      string(journal.KindAgentReport),
+			TaskID:    task.ID,
+			Sprint:    sr.sprintNum,
+			Iteration: sr.iteration,
+			Summary: fmt.Sprintf("Agent report: confidence %.2f, %d learnings, %d blockers, %d follow-ups",
+				report.Confidence, len(report.Learnings), len(report.Blockers), len(report.FollowUps)),
+			Reflection: strings.TrimSpace(sb.String()),
+		})
+	}
+
+	if !success || len(report.FollowUps) == 0 {
+		return
+	}
+
+	// Retried attempts often propose the same follow-ups, so skip any
+	// whose title already exists.
+	prefix := task.ID + "-followup-"
+	titles := make(map[string]bool)
+	added := 0
+	for _, t := range sr.taskDB.TasksByStatus(taskdb.StatusPending, taskdb.StatusInProgress,
+		taskdb.StatusCompleted, taskdb.StatusFailed, taskdb.StatusDeferred, taskdb.StatusBlocked) {
+		titles[t.Title] = true
+		// A follow-up's own follow-ups extend its ID; count only direct ones.
+		if rest, ok := strings.CutPrefix(t.ID, prefix); ok && !strings.Contains(rest, "-followup-") {
+			added++
+		}
+	}
+
+	for i, f := range report.FollowUps {
+		if titles[f.Title] {
+			continue
+		}
+		if added >= maxFollowUpsPerTask {
+			sr.logger.Warn("follow-up limit reached, dropping the rest",
+				"task", task.ID, "limit", maxFollowUpsPerTask)
+			break
+		}
+		titles[f.Title] = true
+
+		priority := f.Priority
+		if priority <= 0 {
+			priority = task.Priority + 1
+		}
+		followUp := &taskdb.Task{
+			ID:           fmt.Sprintf("%s-followup-%d-%d", task.ID, len(task.Attempts)+1, i+1),
+			Title:        f.Title,
+			Description:  f.Description,
+			Status:       taskdb.StatusPending,
+			Priority:     priority,
+			Complexity:   3,
+			MaxAttempts:  3,
+			ContextNotes: fmt.Sprintf("Proposed by the agent while working on %s (%s).", task.ID, task.Title),
+			Tags:         []string{TagFollowUp},
+			Workdir:      task.Workdir,
+			CreatedAt:    time.Now(),
+		}
+		if err := sr.taskDB.Add(followUp); err != nil {
+			sr.logger.Warn("failed to add follow-up task", "task", followUp.ID, "error", err)
+			continue
+		}
+		added++
+		if err := sr.store.InsertTask(&store.Task{
+			ID:           followUp.ID,
+			SessionID:    sr.sessionID,
+			Title:        followUp.Title,
+			Description:  followUp.Description,
+			Status:       string(followUp.Status),
+			Priority:     followUp.Priority,
+			MaxAttempts:  followUp.MaxAttempts,
+			Complexity:   followUp.Complexity,
+			ContextNotes: followUp.ContextNotes,
+			TagsJSON:     `["` + TagFollowUp + `"]`,
+			Workdir:      followUp.Workdir,
+		}); err != nil {
+			sr.logger.Warn("failed to persist follow-up task", "task", followUp.ID, "error", err)
+		}
+		sr.logger.Info("added follow-up task from agent report", "task", followUp.ID, "title", followUp.Title)
+	}
+}
+
+// commitTask commits a successful iteration's work under the commit policy.
+// With squash "task" the agent's own commits since beforeSHA are folded into
+// it. Only a *workflow.PolicyError or *workflow.ConflictError is returned;
+// other commit failures are logged.
+func (sr *SprintRunner) commitTask(ctx context.Context, beforeSHA string, info workflow.CommitInfo) error {
+	msg, err := workflow.CommitMessage(sr.cfg.Commit, info)
+	if err != nil {
+		sr.logger.Warn("bad commit template, using the default", "error", err)
+		msg, _ = workflow.CommitMessage(config.CommitConfig{}, info)
+	}
+	if err := sr.workflow.Commit(ctx, msg, nil); err != nil {
+		var perr *workflow.PolicyError
+		var cerr *workflow.ConflictError
+		if errors.As(err, &perr) || errors.As(err, &cerr) {
+			return err
+		}
+		sr.logger.Warn("commit failed", "error", err)
+		return nil
+	}
+	sr.committed = append(sr.committed, info)
+	if sr.cfg.Commit.Squash == workflow.SquashTask && beforeSHA != "" {
+		if err := sr.workflow.Squash(ctx, beforeSHA, msg); err != nil {
+			sr.logger.Warn("squashing task commits failed", "task", info.TaskID, "error", err)
+		}
+	}
+	return nil
+}
+
+// keepsRefusedWork reports whether work the commit policy refused stays in
+// the worktree for the task's next attempt: only when revert_protected
+// already undid the protected changes and nothing else was wrong.
+func keepsRefusedWork(perr *workflow.PolicyError) bool {
+	return perr.Reverted && perr.MaxFiles == 0 && perr.MaxLines == 0
+}
+
+// discardRefusedWork resets the worktree to beforeSHA after the commit
+// policy refused an attempt's work, so neither the next attempt nor the
+// next task's commit picks it up. agentbox's own files are kept. A merge
+// of the base branch is left alone; abortMerge undoes that.
+func (sr *SprintRunner) discardRefusedWork(ctx context.Context, taskID, beforeSHA string) {
+	if beforeSHA == "" || sr.workflow.MergeInProgress(ctx) {
+		return
+	}
+	sr.logger.Warn("discarding work the commit policy refused", "task", taskID)
+	if err := sr.workflow.Rollback(ctx, beforeSHA, sr.cfg.bookkeepingPaths()...); err != nil {
+		sr.logger.Warn("could not discard refused work", "task", taskID, "error", err)
+	}
+}
+
+// squashSprint folds the sprint's commits into one when the commit policy
+// squashes per sprint. It runs before anything pushes the branch.
+func (sr *SprintRunner) squashSprint(ctx context.Context) {
+	if sr.sprintBase == "" {
+		return
+	}
+	msg := workflow.SprintCommitMessage(sr.sessionID, sr.sprintNum, sr.committed)
+	if err := sr.workflow.Squash(ctx, sr.sprintBase, msg); err != nil {
+		sr.logger.Warn("squashing sprint commits failed", "sprint", sr.sprintNum, "error", err)
+	}
+}
+
+// runTaskWithRetry runs the task via runner, retrying with backoff while the
+// result is a transient failure. Retries happen inside a single attempt so
+// they are not counted against the task's attempt budget.
+func (sr *SprintRunner) runTaskWithRetry(ctx context.Context, runner AgentRunner, task *ralph.Task, prompt string) *ralph.IterationResult {
+	policy := sr.cfg.Retry
+	for n := 1; ; n++ {
+		result := runner.RunTask(ctx, task, prompt)
+		if result.Success || !result.Transient || n > policy.MaxRetries || ctx.Err() != nil {
+			return result
+		}
+
+		delay := policy.Backoff(n)
+		sr.logger.Warn("transient agent failure, retrying",
+			"task", task.ID,
+			"retry", n,
+			"max_retries", policy.MaxRetries,
+			"delay", delay.Round(time.Second),
+			"error", result.Error,
+		)
+		if sr.cfg.JournalEnabled {
+			_ = sr.journal.Add(&store.JournalEntry{
+				Kind:      string(journal.KindTransientRetry),
+				TaskID:    task.ID,
+				Sprint:    sr.sprintNum,
+				Iteration: sr.iteration,
+				Summary:   fmt.Sprintf("Retrying %s after transient failure (%d/%d)", task.Title, n, policy.MaxRetries),
+				Reflection: fmt.Sprintf("Transient failure, waiting %s before retrying: %s",
+					delay.Round(time.Second), truncate(result.Error, 500)),
+			})
+		}
+
+		if err := retry.Wait(ctx, delay); err != nil {
+			return result
+		}
+	}
+}
+
+// writeEnsembleEntry records how each ensemble candidate fared and which won.
+func (sr *SprintRunner) writeEnsembleEntry(task *taskdb.Task, er *EnsembleResult) {
+	if !sr.cfg.JournalEnabled {
+		return
+	}
+
+	var sb strings.Builder
+	for _, c := range er.Candidates {
+		status := "failed"
+		switch {
+		case c == er.Winner:
+			status = "winner"
+		case c.passed():
+			status = "passed"
+		}
+		fmt.Fprintf(&sb, "- %s: %s, %d diff lines", c.member(), status, c.DiffLines)
+		if c.Err != nil {
+			fmt.Fprintf(&sb, " (%s)", truncate(c.Err.Error(), 200))
+		} else if c.Result != nil && c.Result.Error != "" {
+			fmt.Fprintf(&sb, " (%s)", truncate(c.Result.Error, 200))
+		}
+		sb.WriteString("\n")
+	}
+
+	summary := fmt.Sprintf("Ensemble on %s: no candidate passed", task.Title)
+	if er.Winner != nil {
+		summary = fmt.Sprintf("Ensemble on %s: %s won", task.Title, er.Winner.member())
+	}
+	_ = sr.journal.Add(&store.JournalEntry{
+		Kind:       string(journal.KindEnsemble),
+		TaskID:     task.ID,
+		Sprint:     sr.sprintNum,
+		Iteration:  sr.iteration,
+		Summary:    summary,
+		Reflection: sb.String(),
+	})
+}
+
 // writeSprintRetroEntry writes a journal entry summarizing the sprint retro.
 func (sr *SprintRunner) writeSprintRetroEntry(report *retro.SprintReport) {
 	patternsDesc := ""
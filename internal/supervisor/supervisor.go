@@ -10,8 +10,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/swamp-dev/agentbox/internal/container"
+	"github.com/swamp-dev/agentbox/internal/events"
 	"github.com/swamp-dev/agentbox/internal/journal"
 	"github.com/swamp-dev/agentbox/internal/metrics"
+	"github.com/swamp-dev/agentbox/internal/pause"
 	"github.com/swamp-dev/agentbox/internal/ralph"
 	"github.com/swamp-dev/agentbox/internal/review"
 	"github.com/swamp-dev/agentbox/internal/store"
@@ -26,11 +29,28 @@ type Supervisor struct {
 	sessionID int64
 	workflow  *workflow.GitWorkflow
 	taskDB    *taskdb.DB
+	tasks     *TaskEditor
 	collector *metrics.Collector
 	budget    *metrics.BudgetEnforcer
 	journal   *journal.Journal
 	reviewer  *review.Reviewer
+	gate      *pause.Gate
+	events    events.Sink
 	logger    *slog.Logger
+
+	// prNumber is the pull request the session opened, 0 until there is one.
+	prNumber int
+}
+
+// SetPauseGate lets another goroutine pause the session between iterations.
+func (s *Supervisor) SetPauseGate(g *pause.Gate) {
+	s.gate = g
+}
+
+// SetEventSink sends progress events (iterations, completed tasks, failed
+// quality checks, budget warnings) to sink.
+func (s *Supervisor) SetEventSink(sink events.Sink) {
+	s.events = sink
 }
 
 // New creates a new Supervisor from configuration.
@@ -57,6 +77,10 @@ func New(cfg *Config, logger *slog.Logger) (*Supervisor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("opening store: %w", err)
 	}
+	if err := s.SetTranscriptStorage(cfg.Storage.Transcripts); err != nil {
+		s.Close()
+		return nil, err
+	}
 
 	// Create session.
 	cfgJSON, _ := json.Marshal(cfg)
@@ -65,9 +89,15 @@ func New(cfg *Config, logger *slog.Logger) (*Supervisor, error) {
 		s.Close()
 		return nil, fmt.Errorf("creating session: %w", err)
 	}
+	if err := applyTranscriptRetention(s, sessionID, cfg, logger); err != nil {
+		s.Close()
+		return nil, err
+	}
 
 	// Create git workflow.
 	wf := workflow.NewGitWorkflow(cfg.RepoURL, workDir, logger)
+	wf.SetCodeHostConfig(cfg.CodeHost)
+	wf.SetCommitConfig(cfg.CommitPolicy(), cfg.bookkeepingPaths())
 
 	// Create metrics collector and budget enforcer.
 	collector := metrics.NewCollector(s, sessionID)
@@ -84,6 +114,7 @@ func New(cfg *Config, logger *slog.Logger) (*Supervisor, error) {
 		sessionID: sessionID,
 		workflow:  wf,
 		taskDB:    tdb,
+		tasks:     &TaskEditor{taskDB: tdb, store: s, sessionID: sessionID},
 		collector: collector,
 		budget:    budget,
 		journal:   j,
@@ -129,6 +160,11 @@ func newForResumeWithStore(s *store.Store, sessionID int64, workDir string, logg
 		}
 	}
 
+	if cfg.AddressReview != 0 {
+		return nil, fmt.Errorf("session %d addressed review comments on PR #%d and cannot be resumed; run agentbox sprint --address-review %d again",
+			sessionID, cfg.AddressReview, cfg.AddressReview)
+	}
+
 	if workDir == "" && cfg.WorkDir != "" {
 		workDir = cfg.WorkDir
 	}
@@ -136,55 +172,25 @@ func newForResumeWithStore(s *store.Store, sessionID int64, workDir string, logg
 		workDir = "."
 	}
 	cfg.WorkDir = workDir
+	if err := s.SetTranscriptStorage(cfg.Storage.Transcripts); err != nil {
+		return nil, err
+	}
 
 	// Create workflow and point it at the existing worktree.
 	wf := workflow.NewGitWorkflow(cfg.RepoURL, workDir, logger)
+	wf.SetCodeHostConfig(cfg.CodeHost)
+	wf.SetCommitConfig(cfg.CommitPolicy(), cfg.bookkeepingPaths())
+	if sess.BranchName != "" {
+		cfg.BranchName = sess.BranchName
+	}
+	if sess.WorktreePath != "" {
+		wf.SetWorktreePath(sess.WorktreePath, sess.BranchName)
+	}
 
 	// Restore task state from store.
-	tdb := taskdb.New()
-	tasks, err := s.ListTasks(sessionID)
+	tdb, err := loadTaskDB(s, sessionID)
 	if err != nil {
-		return nil, fmt.Errorf("loading tasks: %w", err)
-	}
-
-	for _, st := range tasks {
-		// Load dependencies.
-		deps, _ := s.GetDependencies(st.ID)
-
-		task := &taskdb.Task{
-			ID:          st.ID,
-			Title:       st.Title,
-			Description: st.Description,
-			Status:      taskdb.TaskStatus(st.Status),
-			Priority:    st.Priority,
-			Complexity:  st.Complexity,
-			ParentID:    st.ParentID,
-			MaxAttempts: st.MaxAttempts,
-			DependsOn:   deps,
-			CreatedAt:   st.CreatedAt,
-			CompletedAt: st.CompletedAt,
-		}
-
-		// Restore attempts.
-		attempts, _ := s.GetAttempts(st.ID)
-		for _, a := range attempts {
-			success := false
-			if a.Success != nil {
-				success = *a.Success
-			}
-			task.Attempts = append(task.Attempts, taskdb.Attempt{
-				Number:    a.Number,
-				AgentName: a.AgentName,
-				Success:   success,
-				ErrorMsg:  a.ErrorMsg,
-				GitCommit: a.GitCommit,
-				StartedAt: a.StartedAt,
-			})
-		}
-
-		if err := tdb.Add(task); err != nil {
-			return nil, fmt.Errorf("restoring task %s: %w", st.ID, err)
-		}
+		return nil, err
 	}
 
 	// Create metrics collector and budget enforcer.
@@ -198,17 +204,19 @@ func newForResumeWithStore(s *store.Store, sessionID int64, workDir string, logg
 		sessionID: sessionID,
 		workflow:  wf,
 		taskDB:    tdb,
+		tasks:     &TaskEditor{taskDB: tdb, store: s, sessionID: sessionID},
 		collector: collector,
 		budget:    budget,
 		journal:   j,
 		logger:    logger,
+		prNumber:  sess.PRNumber,
 	}, nil
 }
 
 // Resume continues a previously interrupted session. It skips the setup
 // phase and picks up the sprint loop from where it left off.
 func (s *Supervisor) Resume(ctx context.Context) error {
-	defer s.store.Close()
+	defer s.closeStore()
 
 	// Mark session as running now that Resume has actually been called.
 	if err := s.store.UpdateSessionStatus(s.sessionID, "running"); err != nil {
@@ -241,9 +249,14 @@ func (s *Supervisor) Resume(ctx context.Context) error {
 		"tasks_failed", failed,
 	)
 
-	// Resolve the worktree path. For resume, the worktree should already exist.
-	// Try to locate it from the branch name.
-	if s.cfg.BranchName != "" {
+	// Resolve the worktree path. For resume, the worktree should already
+	// exist where the session recorded it. Sessions from before worktree
+	// paths were recorded are located from the branch name.
+	if p := s.workflow.WorktreePath(); p != "" {
+		if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+			s.workflow.SetWorktreePath("", s.cfg.BranchName)
+		}
+	} else if s.cfg.BranchName != "" {
 		repoDir := s.workflow.RepoDir()
 		worktreeName := strings.ReplaceAll(s.cfg.BranchName, "/", "-")
 		candidatePath := filepath.Join(filepath.Dir(repoDir), worktreeName)
@@ -290,9 +303,18 @@ func (s *Supervisor) Resume(ctx context.Context) error {
 				s.logger.Warn("failed to close ralph loop", "error", closeErr)
 			}
 		}()
+		loop.SetEventSink(s.events)
+		loop.SetDockerRetryHook(s.journalDockerRetry)
 		agentRunner = NewRalphAgentRunner(loop)
 	}
 
+	ensemble, closeEnsemble, err := s.newEnsembleRunner()
+	if err != nil {
+		_ = s.store.UpdateSessionStatus(s.sessionID, "failed")
+		return fmt.Errorf("creating ensemble runner: %w", err)
+	}
+	defer closeEnsemble()
+
 	// Sprint loop — picks up where we left off.
 	iteration := startIter
 	for sprint := startSprint; sprint <= s.cfg.MaxSprints; sprint++ {
@@ -312,6 +334,9 @@ func (s *Supervisor) Resume(ctx context.Context) error {
 			s.cfg, s.store, s.sessionID,
 			s.workflow, s.taskDB, s.collector, s.budget, s.journal, agentRunner, s.logger,
 		)
+		runner.SetEnsemble(ensemble)
+		runner.SetPauseGate(s.gate)
+		runner.SetEventSink(s.events)
 
 		result, err := runner.RunSprint(ctx, sprint, iteration)
 		if err != nil {
@@ -329,6 +354,10 @@ func (s *Supervisor) Resume(ctx context.Context) error {
 		if recommended, newAgent := runner.SwitchRecommended(); recommended && !s.cfg.DryRun {
 			s.logger.Info("rebuilding agent runner for next sprint", "new_agent", newAgent)
 			s.cfg.Agent = newAgent
+			// The base URL and model override are specific to the previous
+			// agent's provider.
+			s.cfg.AgentBaseURL = ""
+			s.cfg.AgentModel = ""
 
 			if closeErr := loop.Close(); closeErr != nil {
 				s.logger.Warn("failed to close old ralph loop", "error", closeErr)
@@ -339,6 +368,8 @@ func (s *Supervisor) Resume(ctx context.Context) error {
 				s.logger.Error("failed to create ralph loop with new agent", "agent", newAgent, "error", switchErr)
 			} else {
 				loop = newLoop
+				loop.SetEventSink(s.events)
+				loop.SetDockerRetryHook(s.journalDockerRetry)
 				agentRunner = NewRalphAgentRunner(loop)
 			}
 		}
@@ -354,14 +385,57 @@ func (s *Supervisor) Resume(ctx context.Context) error {
 		if s.cfg.ReviewEnabled && s.cfg.ReviewAfter == "sprint" {
 			s.runReviewGate(ctx)
 		}
+
+		s.syncBase(ctx, sprint, iteration)
+		s.runCIGate(ctx, sprint, iteration)
+		s.syncDraftPR(ctx, result)
+	}
+
+	// A cancellation during the last sprint ends the loop without passing
+	// the check at the top; don't finalize a session that was stopped.
+	if ctx.Err() != nil {
+		_ = s.store.UpdateSessionStatus(s.sessionID, "interrupted")
+		return ctx.Err()
 	}
 
 	return s.finalize(ctx)
 }
 
+// newEnsembleRunner builds the ensemble runner when ensemble mode is
+// configured. It returns nil in dry-run mode or when fewer than two agents
+// are listed. The returned close function is always safe to call.
+func (s *Supervisor) newEnsembleRunner() (*EnsembleRunner, func(), error) {
+	noop := func() {}
+	if s.cfg.DryRun || !s.cfg.Ensemble.Enabled() {
+		return nil, noop, nil
+	}
+
+	var judge Judge
+	closeFn := noop
+	if s.cfg.Ensemble.Judge {
+		cm, err := container.NewManager()
+		if err != nil {
+			return nil, noop, fmt.Errorf("creating judge container manager: %w", err)
+		}
+		closeFn = func() {
+			if err := cm.Close(); err != nil {
+				s.logger.Warn("failed to close judge container manager", "error", err)
+			}
+		}
+		judge = NewReviewJudge(review.NewReviewer(s.cfg.ReviewAgent, s.cfg.ToRalphConfig(), cm, s.logger))
+	}
+
+	s.logger.Info("ensemble mode enabled",
+		"agents", s.cfg.Ensemble.Members(),
+		"min_complexity", s.cfg.Ensemble.MinComplexity,
+		"judge", s.cfg.Ensemble.Judge,
+	)
+	return NewEnsembleRunner(s.cfg.Ensemble, s.workflow, newRalphRunnerFactory(s.cfg, s.logger), judge, s.cfg.bookkeepingPaths(), s.logger), closeFn, nil
+}
+
 // Run executes the full supervisor lifecycle.
 func (s *Supervisor) Run(ctx context.Context) error {
-	defer s.store.Close()
+	defer s.closeStore()
 
 	s.logger.Info("supervisor starting",
 		"repo", s.cfg.RepoURL,
@@ -401,9 +475,18 @@ func (s *Supervisor) Run(ctx context.Context) error {
 				s.logger.Warn("failed to close ralph loop", "error", closeErr)
 			}
 		}()
+		loop.SetEventSink(s.events)
+		loop.SetDockerRetryHook(s.journalDockerRetry)
 		agentRunner = NewRalphAgentRunner(loop)
 	}
 
+	ensemble, closeEnsemble, err := s.newEnsembleRunner()
+	if err != nil {
+		_ = s.store.UpdateSessionStatus(s.sessionID, "failed")
+		return fmt.Errorf("creating ensemble runner: %w", err)
+	}
+	defer closeEnsemble()
+
 	// Phase 2: Sprint loop.
 	iteration := 1
 	for sprint := 1; sprint <= s.cfg.MaxSprints; sprint++ {
@@ -423,6 +506,9 @@ func (s *Supervisor) Run(ctx context.Context) error {
 			s.cfg, s.store, s.sessionID,
 			s.workflow, s.taskDB, s.collector, s.budget, s.journal, agentRunner, s.logger,
 		)
+		runner.SetEnsemble(ensemble)
+		runner.SetPauseGate(s.gate)
+		runner.SetEventSink(s.events)
 
 		result, err := runner.RunSprint(ctx, sprint, iteration)
 		if err != nil {
@@ -440,6 +526,10 @@ func (s *Supervisor) Run(ctx context.Context) error {
 		if recommended, newAgent := runner.SwitchRecommended(); recommended && !s.cfg.DryRun {
 			s.logger.Info("rebuilding agent runner for next sprint", "new_agent", newAgent)
 			s.cfg.Agent = newAgent
+			// The base URL and model override are specific to the previous
+			// agent's provider.
+			s.cfg.AgentBaseURL = ""
+			s.cfg.AgentModel = ""
 
 			// Close the old loop and build a new one with the updated agent.
 			if closeErr := loop.Close(); closeErr != nil {
@@ -452,6 +542,8 @@ func (s *Supervisor) Run(ctx context.Context) error {
 				// Continue with old runner rather than failing the session.
 			} else {
 				loop = newLoop
+				loop.SetEventSink(s.events)
+				loop.SetDockerRetryHook(s.journalDockerRetry)
 				agentRunner = NewRalphAgentRunner(loop)
 			}
 		}
@@ -468,9 +560,20 @@ func (s *Supervisor) Run(ctx context.Context) error {
 		if s.cfg.ReviewEnabled && s.cfg.ReviewAfter == "sprint" {
 			s.runReviewGate(ctx)
 		}
+
+		s.syncBase(ctx, sprint, iteration)
+		s.runCIGate(ctx, sprint, iteration)
+		s.syncDraftPR(ctx, result)
 	}
 
 	// Phase 4: Finalize.
+	// A cancellation during the last sprint ends the loop without passing
+	// the check at the top; don't finalize a session that was stopped.
+	if ctx.Err() != nil {
+		_ = s.store.UpdateSessionStatus(s.sessionID, "interrupted")
+		return ctx.Err()
+	}
+
 	return s.finalize(ctx)
 }
 
@@ -483,10 +586,15 @@ func (s *Supervisor) setup(ctx context.Context) error {
 		return fmt.Errorf("opening repository: %w", err)
 	}
 
+	// Finished sessions whose pull requests have merged no longer need
+	// their worktrees.
+	s.cleanupMergedWorktrees(ctx)
+
 	// Create worktree.
 	if err := s.workflow.CreateWorktree(ctx, s.cfg.BranchName); err != nil {
 		return fmt.Errorf("creating worktree: %w", err)
 	}
+	s.recordWorktree()
 
 	// Import PRD into task database.
 	if err := s.importPRD(); err != nil {
@@ -530,6 +638,10 @@ func (s *Supervisor) importPRD() error {
 
 	exportedTasks := prd.ExportTasks()
 	for _, t := range exportedTasks {
+		complexity := t.Complexity
+		if complexity == 0 {
+			complexity = 3
+		}
 		task := &taskdb.Task{
 			ID:          t.ID,
 			Title:       t.Title,
@@ -538,11 +650,20 @@ func (s *Supervisor) importPRD() error {
 			Priority:    t.Priority,
 			DependsOn:   t.DependsOn,
 			MaxAttempts: 3,
-			Complexity:  3,
+			Complexity:  complexity,
+			Workdir:     t.Dir(),
+		}
+		if t.Ensemble {
+			task.Tags = []string{TagEnsemble}
 		}
 		if task.Status == "" {
 			task.Status = taskdb.StatusPending
 		}
+		tagsJSON := ""
+		if len(task.Tags) > 0 {
+			data, _ := json.Marshal(task.Tags)
+			tagsJSON = string(data)
+		}
 		if err := s.taskDB.Add(task); err != nil {
 			return fmt.Errorf("adding task %s to taskDB: %w", t.ID, err)
 		}
@@ -556,7 +677,9 @@ func (s *Supervisor) importPRD() error {
 			Status:      string(task.Status),
 			Priority:    t.Priority,
 			MaxAttempts: 3,
-			Complexity:  3,
+			Complexity:  complexity,
+			TagsJSON:    tagsJSON,
+			Workdir:     task.Workdir,
 		}); err != nil {
 			return fmt.Errorf("inserting task %s into store: %w", t.ID, err)
 		}
@@ -671,6 +794,15 @@ func (s *Supervisor) finalize(ctx context.Context) error {
 		s.runReviewGate(ctx)
 	}
 
+	// A merge of the base branch whose conflicts were never resolved has
+	// nothing to publish.
+	if s.cfg.SyncBase != "" && s.workflow.MergeInProgress(ctx) {
+		s.logger.Warn("abandoning merge of the base branch with unresolved conflicts")
+		if err := s.workflow.AbortMerge(ctx); err != nil {
+			s.logger.Warn("could not abort merge", "error", err)
+		}
+	}
+
 	// Write final journal entry.
 	if s.cfg.JournalEnabled {
 		total, completed, pending, failed, deferred := s.taskDB.Stats()
@@ -689,22 +821,8 @@ func (s *Supervisor) finalize(ctx context.Context) error {
 		})
 	}
 
-	// Generate PR body.
-	prBody, err := s.generatePRBody()
-	if err != nil {
-		s.logger.Warn("could not generate PR body", "error", err)
-		prBody = "Automated PR by agentbox"
-	}
-
-	// Open PR.
-	total, completed, _, _, _ := s.taskDB.Stats()
-	prTitle := fmt.Sprintf("agentbox: %d/%d tasks completed", completed, total)
-	prURL, err := s.workflow.OpenPR(ctx, prTitle, prBody)
-	if err != nil {
-		s.logger.Warn("could not create PR", "error", err)
-	} else {
-		s.logger.Info("pull request created", "url", prURL)
-	}
+	// Open the PR, or mark the draft ready for review.
+	s.publishPR(ctx)
 
 	// Export journal.
 	md, err := s.journal.ExportMarkdown()
@@ -751,6 +869,10 @@ func (s *Supervisor) generatePRBody() (string, error) {
 		body += metricsSummary + "\n\n"
 	}
 
+	body += s.sprintRetroSection()
+	body += s.journalHighlightsSection()
+	body += s.costSection()
+
 	body += "---\n\n🤖 Generated by [agentbox](https://github.com/swamp-dev/agentbox)\n"
 
 	return body, nil
@@ -786,6 +908,32 @@ func (s *Supervisor) SessionID() int64 {
 	return s.sessionID
 }
 
+// Tasks returns an editor for changing this session's tasks while it runs.
+// It returns ErrSessionEnded once Run or Resume has returned.
+func (s *Supervisor) Tasks() *TaskEditor {
+	return s.tasks
+}
+
+// journalDockerRetry records a retried Docker failure in the journal, as
+// the sprint runner does for retried agent failures.
+func (s *Supervisor) journalDockerRetry(n int, delay time.Duration, err error) {
+	if !s.cfg.JournalEnabled {
+		return
+	}
+	_ = s.journal.Add(&store.JournalEntry{
+		Kind:    string(journal.KindTransientRetry),
+		Summary: fmt.Sprintf("Retrying container creation after transient Docker failure (%d/%d)", n, s.cfg.Retry.MaxRetries),
+		Reflection: fmt.Sprintf("Transient Docker failure, waiting %s before retrying: %s",
+			delay.Round(time.Second), truncate(err.Error(), 500)),
+	})
+}
+
+// closeStore detaches the task editor and closes the store.
+func (s *Supervisor) closeStore() {
+	s.tasks.detach()
+	s.store.Close()
+}
+
 // Store returns the underlying store for external access.
 func (s *Supervisor) Store() *store.Store {
 	return s.store
@@ -12,10 +12,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/swamp-dev/agentbox/internal/config"
+	"github.com/swamp-dev/agentbox/internal/events"
 	"github.com/swamp-dev/agentbox/internal/journal"
 	"github.com/swamp-dev/agentbox/internal/metrics"
 	"github.com/swamp-dev/agentbox/internal/ralph"
 	"github.com/swamp-dev/agentbox/internal/retro"
+	"github.com/swamp-dev/agentbox/internal/retry"
 	"github.com/swamp-dev/agentbox/internal/review"
 	"github.com/swamp-dev/agentbox/internal/store"
 	"github.com/swamp-dev/agentbox/internal/taskdb"
@@ -161,6 +164,73 @@ func TestConfig_ToRalphConfig_NoQualityChecks(t *testing.T) {
 	}
 }
 
+func TestConfig_ApplyProjectConfig(t *testing.T) {
+	pc := config.DefaultConfig()
+	pc.Agent.Name = "aider"
+	pc.Docker.Image = "go"
+	pc.Docker.Resources.Memory = "8g"
+	pc.Ralph.QualityChecks = []config.QualityCheck{{Name: "test", Command: "go test ./..."}}
+
+	cfg := DefaultConfig()
+	cfg.ApplyProjectConfig(pc)
+	if cfg.Agent != "aider" || cfg.DockerImage != "go" || cfg.DockerMemory != "8g" {
+		t.Errorf("agent %s image %s memory %s, want aider go 8g", cfg.Agent, cfg.DockerImage, cfg.DockerMemory)
+	}
+	if len(cfg.QualityChecks) != 1 || cfg.QualityChecks[0].Command != "go test ./..." {
+		t.Errorf("quality checks = %+v", cfg.QualityChecks)
+	}
+	// Without a supervisor section the sprint defaults stay.
+	if cfg.SprintSize != 5 || !cfg.JournalEnabled || !cfg.ReviewEnabled {
+		t.Errorf("sprint defaults changed: size %d journal %v review %v", cfg.SprintSize, cfg.JournalEnabled, cfg.ReviewEnabled)
+	}
+
+	on, off := true, false
+	pc.Supervisor = config.SupervisorConfig{SprintSize: 2, MaxSprints: 4, MaxConsecutiveFails: 1, BudgetDuration: "1h", JournalEnabled: &on, ReviewEnabled: &off, DraftPR: &on, CIGate: &on, CITimeout: "45m", SyncBase: "rebase"}
+	cfg.ApplyProjectConfig(pc)
+	if cfg.SprintSize != 2 || cfg.MaxSprints != 4 || cfg.BudgetDuration != "1h" || cfg.ReviewEnabled || !cfg.DraftPR || !cfg.CIGate || cfg.CITimeout != "45m" || cfg.SyncBase != "rebase" {
+		t.Errorf("supervisor section not applied: %+v", cfg)
+	}
+}
+
+func TestApplyProjectConfig_PartialSupervisorSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentbox.yaml")
+	if err := os.WriteFile(path, []byte("supervisor:\n  draft_pr: true\n  ci_timeout: 10m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.ApplyProjectConfig(pc)
+	if !cfg.DraftPR || cfg.CITimeout != "10m" {
+		t.Errorf("draft_pr %v ci_timeout %q, want true 10m", cfg.DraftPR, cfg.CITimeout)
+	}
+	// Keys the section leaves out keep their defaults.
+	if !cfg.JournalEnabled || !cfg.ReviewEnabled || cfg.CIGate {
+		t.Errorf("journal %v review %v ci_gate %v, want the defaults true true false", cfg.JournalEnabled, cfg.ReviewEnabled, cfg.CIGate)
+	}
+}
+
+func TestApplyProjectConfig_Retry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentbox.yaml")
+	if err := os.WriteFile(path, []byte("retry:\n  max_retries: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.ApplyProjectConfig(pc)
+	if cfg.Retry.MaxRetries != 0 {
+		t.Errorf("max_retries = %d, want 0 from agentbox.yaml", cfg.Retry.MaxRetries)
+	}
+	if got := cfg.ToRalphConfig().RetryPolicy(); got != cfg.Retry {
+		t.Errorf("ralph retry policy = %+v, want %+v", got, cfg.Retry)
+	}
+}
+
 func TestSupervisorRun_DryRunUsesNoopRunner(t *testing.T) {
 	// When DryRun is true, Supervisor.Run() should not attempt to create
 	// a ralph.Loop (which requires Docker). Instead it falls through to
@@ -618,6 +688,50 @@ func TestNew_DefaultConfig(t *testing.T) {
 	}
 }
 
+func TestNew_TranscriptStorage(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.WorkDir = dir
+	cfg.Storage.Transcripts = "blobs"
+
+	first, err := New(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s := first.Store()
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: first.SessionID(), Title: "t", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatal(err)
+	}
+	attemptID, err := s.RecordAttempt(&store.Attempt{
+		TaskID: "t-1", SessionID: first.SessionID(), Number: 1, AgentName: "claude",
+		StartedAt: time.Now(), Transcript: "agent output",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blobs, _ := filepath.Glob(filepath.Join(dir, ".agentbox", "blobs", "*", "*.zst")); len(blobs) != 1 {
+		t.Errorf("blobs = %v, want the transcript stored as a blob", blobs)
+	}
+	_ = s.UpdateSessionStatus(first.SessionID(), "completed")
+	s.Close()
+
+	// The next session applies the size limit to the finished one.
+	cfg.Storage.MaxTranscriptSize = "1"
+	second, err := New(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer second.Store().Close()
+	if got, _ := second.Store().GetTranscript(attemptID); got != "" {
+		t.Errorf("transcript = %q, want it pruned by max_transcript_size", got)
+	}
+
+	cfg.TranscriptRetention = "forever-ish"
+	if _, err := New(cfg, testLogger()); err == nil {
+		t.Error("New accepted an invalid transcript_retention")
+	}
+}
+
 func TestGeneratePRBody(t *testing.T) {
 	dir := t.TempDir()
 	cfg := DefaultConfig()
@@ -645,6 +759,15 @@ func TestGeneratePRBody(t *testing.T) {
 	}); err != nil {
 		t.Fatalf("Add t-3: %v", err)
 	}
+	if err := sup.Store().SaveSprintReport(&store.SprintReport{
+		SessionID: sup.SessionID(), SprintNumber: 1, TasksAttempted: 2, TasksCompleted: 1,
+		Velocity: 0.5, QualityTrend: "stable", TestPassRate: 1,
+	}); err != nil {
+		t.Fatalf("SaveSprintReport: %v", err)
+	}
+	_ = sup.journal.Add(&store.JournalEntry{
+		Kind: string(journal.KindAgentSwitch), Sprint: 1, Summary: "Switched from claude to aider",
+	})
 
 	body, err := sup.generatePRBody()
 	if err != nil {
@@ -657,6 +780,9 @@ func TestGeneratePRBody(t *testing.T) {
 		"Broken task", // failed task
 		"Unresolved",  // section header
 		"agentbox",    // footer
+		"Sprint 1: 1/2 tasks",
+		"Sprint 1: Switched from claude to aider",
+		"## Cost",
 	}
 	for _, check := range checks {
 		if !strings.Contains(body, check) {
@@ -760,6 +886,45 @@ func TestSprintRunner_RunSprint_Normal(t *testing.T) {
 	}
 }
 
+func TestSprintRunner_RunSprint_EmitsEvents(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.SprintSize = 2
+	cfg.JournalEnabled = false
+
+	tdb := taskdb.New()
+	if err := tdb.Add(&taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, MaxAttempts: 3}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{{TaskID: "t-1", Success: true, Output: "done"}},
+	}
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+
+	var got []events.Event
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.SetEventSink(func(e events.Event) { got = append(got, e) })
+	if _, err := sr.RunSprint(context.Background(), 1, 1); err != nil {
+		t.Fatalf("RunSprint: %v", err)
+	}
+
+	var kinds []events.Kind
+	for _, e := range got {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []events.Kind{events.IterationStart, events.TaskComplete, events.IterationEnd}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", kinds, want)
+	}
+	if last := got[len(got)-1]; last.Completed != 1 || last.Total != 1 {
+		t.Errorf("last event counts = %d/%d, want 1/1", last.Completed, last.Total)
+	}
+}
+
 func TestSprintRunner_RunSprint_TaskFailure(t *testing.T) {
 	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
 	cfg := DefaultConfig()
@@ -1160,7 +1325,7 @@ func TestImportPRD(t *testing.T) {
 		"name": "Test Project",
 		"tasks": [
 			{"id": "t-1", "title": "Setup", "description": "Set up project", "status": "pending", "priority": 1},
-			{"id": "t-2", "title": "Core", "description": "Core logic", "status": "pending", "priority": 2, "depends_on": ["t-1"]}
+			{"id": "t-2", "title": "Core", "description": "Core logic", "status": "pending", "priority": 2, "depends_on": ["t-1"], "package": "./services/api/"}
 		]
 	}`
 	prdPath := worktreeDir + "/prd.json"
@@ -1189,6 +1354,15 @@ func TestImportPRD(t *testing.T) {
 	if len(deps) != 1 || deps[0] != "t-1" {
 		t.Errorf("expected t-2 depends on [t-1], got %v", deps)
 	}
+
+	// The task's workdir survives a reload from the store.
+	tdb, err := loadTaskDB(sup.Store(), sup.SessionID())
+	if err != nil {
+		t.Fatalf("loadTaskDB: %v", err)
+	}
+	if task, _ := tdb.Get("t-2"); task == nil || task.Workdir != "services/api" {
+		t.Errorf("expected t-2 workdir services/api, got %+v", task)
+	}
 }
 
 func TestSetup_Integration(t *testing.T) {
@@ -1248,6 +1422,15 @@ func TestRun_CancelledContext(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error from cancelled context")
 	}
+
+	// The session is left resumable rather than finalized.
+	sess, err := FindResumableSession(repoDir)
+	if err != nil {
+		t.Fatalf("FindResumableSession: %v", err)
+	}
+	if sess.ID != sup.SessionID() {
+		t.Errorf("resumable session = %d, want %d", sess.ID, sup.SessionID())
+	}
 }
 
 func TestFinalize_Basic(t *testing.T) {
@@ -1649,10 +1832,13 @@ func TestNewForResume_LoadsSessionAndTasks(t *testing.T) {
 		t.Fatalf("CreateSession: %v", err)
 	}
 
-	// Mark as interrupted.
+	// Mark as interrupted, with a draft PR already open.
 	if err := s.UpdateSessionStatus(sessionID, "interrupted"); err != nil {
 		t.Fatalf("UpdateSessionStatus: %v", err)
 	}
+	if err := s.SetSessionPR(sessionID, 7, "https://example.com/pulls/7"); err != nil {
+		t.Fatalf("SetSessionPR: %v", err)
+	}
 
 	// Insert tasks: one completed, one pending.
 	if err := s.InsertTask(&store.Task{
@@ -1712,6 +1898,9 @@ func TestNewForResume_LoadsSessionAndTasks(t *testing.T) {
 	if sup.cfg.Agent != "claude" {
 		t.Errorf("expected agent 'claude', got %q", sup.cfg.Agent)
 	}
+	if sup.prNumber != 7 {
+		t.Errorf("expected PR #7 to be restored, got %d", sup.prNumber)
+	}
 
 	// Verify task DB was restored.
 	total, completed, pending, _, _ := sup.taskDB.Stats()
@@ -1835,6 +2024,38 @@ func TestResume_SkipsCompletedTasks(t *testing.T) {
 	}
 }
 
+func TestResume_UsesRecordedWorktree(t *testing.T) {
+	s := openTestStore(t)
+
+	cfgJSON := `{"sprint_size":2,"max_sprints":1,"agent":"claude","journal_enabled":false,"review_enabled":false}`
+	sessionID, _ := s.CreateSession("", "feat/resume-path", cfgJSON)
+	_ = s.UpdateSessionStatus(sessionID, "interrupted")
+	// The worktree is not where the branch name would put it.
+	worktree := filepath.Join(t.TempDir(), "elsewhere")
+	if err := os.MkdirAll(worktree, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSessionWorktree(sessionID, "feat/resume-path", worktree); err != nil {
+		t.Fatalf("SetSessionWorktree: %v", err)
+	}
+	_ = s.InsertTask(&store.Task{
+		ID: "t-1", SessionID: sessionID, Title: "Pending", Status: "pending", MaxAttempts: 3,
+	})
+
+	sup, err := newForResumeWithStore(s, sessionID, t.TempDir(), testLogger())
+	if err != nil {
+		t.Fatalf("newForResumeWithStore: %v", err)
+	}
+	sup.cfg.DryRun = true
+
+	if err := sup.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if sup.workflow.WorktreePath() != worktree || sup.workflow.BranchName() != "feat/resume-path" {
+		t.Errorf("resumed in %q on %q, want the recorded worktree", sup.workflow.WorktreePath(), sup.workflow.BranchName())
+	}
+}
+
 func TestRun_InterruptMarksSessionInterrupted(t *testing.T) {
 	prdContent := `{"name":"Test","tasks":[{"id":"t-1","title":"Slow Task","description":"Takes a while","status":"pending","priority":1}]}`
 	repoDir := initGitRepo(t, map[string]string{"prd.json": prdContent})
@@ -1989,3 +2210,482 @@ func TestFindResumableSession_NoResumableSession(t *testing.T) {
 		t.Error("expected error when no resumable session exists")
 	}
 }
+
+func TestSprintRunner_RunIteration_TransientRetry(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.JournalEnabled = true
+	cfg.AutoCommit = false
+	cfg.Retry = retry.Policy{MaxRetries: 2}
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, MaxAttempts: 3}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{
+			{TaskID: "t-1", Success: false, Transient: true, Error: "agent reported failure: 529 overloaded"},
+			{TaskID: "t-1", Success: true, Output: "completed task"},
+		},
+	}
+
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if !sr.runIteration(context.Background(), task) {
+		t.Fatal("expected iteration to succeed after transient retry")
+	}
+	if mockRunner.idx != 2 {
+		t.Errorf("expected 2 agent runs, got %d", mockRunner.idx)
+	}
+
+	// The retry must not count as a separate attempt.
+	attempts, _ := s.GetAttempts("t-1")
+	if len(attempts) != 1 {
+		t.Errorf("expected 1 attempt recorded, got %d", len(attempts))
+	}
+	if len(task.Attempts) != 1 {
+		t.Errorf("expected 1 taskdb attempt, got %d", len(task.Attempts))
+	}
+
+	entries, err := j.Entries(&store.JournalQuery{Kind: string(journal.KindTransientRetry)})
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected 1 transient_retry journal entry, got %d", len(entries))
+	}
+}
+
+func TestDockerRetryWritesJournalEntry(t *testing.T) {
+	// Point the Docker client at a socket nobody listens on, so every
+	// container create fails as a transient "cannot connect" error.
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "docker.sock"))
+
+	cfg := DefaultConfig()
+	cfg.WorkDir = t.TempDir()
+	cfg.Retry = retry.Policy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	sup, err := New(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { sup.Store().Close() })
+
+	projectDir := t.TempDir()
+	prd := `{"name":"test","tasks":[{"id":"t-1","title":"Task 1","status":"pending"}]}`
+	if err := os.WriteFile(filepath.Join(projectDir, cfg.PRDFile), []byte(prd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loop, err := ralph.NewLoop(cfg.ToRalphConfig(), projectDir, testLogger())
+	if err != nil {
+		t.Fatalf("NewLoop: %v", err)
+	}
+	defer loop.Close()
+	loop.SetDockerRetryHook(sup.journalDockerRetry)
+
+	result := NewRalphAgentRunner(loop).RunTask(context.Background(), &ralph.Task{ID: "t-1", Title: "Task 1"}, "do it")
+	if result.Success {
+		t.Fatal("task succeeded without a Docker daemon")
+	}
+
+	entries, err := sup.journal.Entries(&store.JournalQuery{Kind: string(journal.KindTransientRetry)})
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != cfg.Retry.MaxRetries {
+		t.Fatalf("got %d transient_retry entries, want %d", len(entries), cfg.Retry.MaxRetries)
+	}
+	if !strings.Contains(entries[0].Summary, "Docker") {
+		t.Errorf("entry summary = %q", entries[0].Summary)
+	}
+}
+
+func TestSprintRunner_RunIteration_TransientRetryExhausted(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.AutoCommit = false
+	cfg.Retry = retry.Policy{MaxRetries: 1}
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, MaxAttempts: 3}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	transient := &ralph.IterationResult{TaskID: "t-1", Success: false, Transient: true, Error: "rate limit"}
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{transient, transient, transient},
+	}
+
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if sr.runIteration(context.Background(), task) {
+		t.Fatal("expected iteration to fail once retries are exhausted")
+	}
+	if mockRunner.idx != 2 {
+		t.Errorf("expected 2 agent runs (1 + 1 retry), got %d", mockRunner.idx)
+	}
+	if len(task.Attempts) != 1 {
+		t.Errorf("expected 1 taskdb attempt, got %d", len(task.Attempts))
+	}
+}
+
+func TestSprintRunner_RunIteration_NonTransientNotRetried(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.AutoCommit = false
+	cfg.Retry = retry.Policy{MaxRetries: 3}
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, MaxAttempts: 3}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{
+			{TaskID: "t-1", Success: false, Error: "quality check failed: tests failed"},
+			{TaskID: "t-1", Success: true},
+		},
+	}
+
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if sr.runIteration(context.Background(), task) {
+		t.Fatal("expected iteration to fail")
+	}
+	if mockRunner.idx != 1 {
+		t.Errorf("expected non-transient failure to run once, got %d runs", mockRunner.idx)
+	}
+}
+
+func TestSprintRunner_RunIteration_IngestsAgentReport(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.JournalEnabled = true
+	cfg.AutoCommit = false
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, Priority: 2, MaxAttempts: 3}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	report := &ralph.Report{
+		Learnings:  []string{"config is cached"},
+		Blockers:   []string{"flaky network test"},
+		FollowUps:  []ralph.FollowUp{{Title: "Add retry to client"}, {Title: "Task 1"}},
+		Confidence: 0.6,
+	}
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{{TaskID: "t-1", Success: true, Report: report}},
+	}
+
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if !sr.runIteration(context.Background(), task) {
+		t.Fatal("expected iteration to succeed")
+	}
+
+	entries, err := j.Entries(&store.JournalQuery{Kind: string(journal.KindAgentReport)})
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].Reflection, "flaky network test") {
+		t.Fatalf("expected one agent_report entry with blockers, got %+v", entries)
+	}
+
+	// The follow-up titled like an existing task is skipped.
+	followUp, ok := tdb.Get("t-1-followup-1-1")
+	if !ok {
+		t.Fatal("expected follow-up task in taskdb")
+	}
+	if followUp.Priority != 3 || !followUp.HasTag(TagFollowUp) {
+		t.Errorf("follow-up = %+v, want priority 3 and follow-up tag", followUp)
+	}
+	if _, ok := tdb.Get("t-1-followup-1-2"); ok {
+		t.Error("expected duplicate-title follow-up to be skipped")
+	}
+	if _, err := s.GetTask("t-1-followup-1-1"); err != nil {
+		t.Errorf("expected follow-up persisted in store: %v", err)
+	}
+}
+
+func TestSprintRunner_RunIteration_FailedAttemptSkipsFollowUps(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.JournalEnabled = true
+	cfg.AutoCommit = false
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, MaxAttempts: 3}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	report := &ralph.Report{
+		Blockers:  []string{"schema migration half done"},
+		FollowUps: []ralph.FollowUp{{Title: "Finish schema migration"}},
+	}
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{{TaskID: "t-1", Error: "tests failed", Report: report}},
+	}
+
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if sr.runIteration(context.Background(), task) {
+		t.Fatal("expected iteration to fail")
+	}
+
+	entries, err := j.Entries(&store.JournalQuery{Kind: string(journal.KindAgentReport)})
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	if len(entries) != 1 || !strings.Contains(entries[0].Reflection, "schema migration half done") {
+		t.Errorf("expected the failed attempt's report in the journal, got %+v", entries)
+	}
+	if _, ok := tdb.Get("t-1-followup-1-1"); ok {
+		t.Error("expected no follow-up from a failed attempt")
+	}
+}
+
+func TestSprintRunner_RunIteration_CapsFollowUps(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	cfg := DefaultConfig()
+	cfg.AutoCommit = false
+
+	tdb := taskdb.New()
+	task := &taskdb.Task{ID: "t-1", Title: "Task 1", Status: taskdb.StatusPending, MaxAttempts: 3}
+	if err := tdb.Add(task); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "Task 1", Status: "pending", MaxAttempts: 3}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+	// Follow-ups from earlier attempts count against the cap; a follow-up's
+	// own follow-up does not.
+	existing := []string{"t-1-followup-8-1", "t-1-followup-8-2", "t-1-followup-9-1", "t-1-followup-9-2", "t-1-followup-8-1-followup-1-1"}
+	for i, id := range existing {
+		if err := tdb.Add(&taskdb.Task{ID: id, Title: fmt.Sprintf("Earlier follow-up %d", i), Status: taskdb.StatusPending, Tags: []string{TagFollowUp}}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	report := &ralph.Report{
+		FollowUps: []ralph.FollowUp{{Title: "One more"}, {Title: "Too many"}, {Title: "Way too many"}},
+	}
+	mockRunner := &MockAgentRunner{
+		results: []*ralph.IterationResult{{TaskID: "t-1", Success: true, Report: report}},
+	}
+
+	wf := workflow.NewGitWorkflow("", t.TempDir(), logger)
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, mockRunner, logger)
+	sr.sprintNum = 1
+	sr.iteration = 1
+
+	if !sr.runIteration(context.Background(), task) {
+		t.Fatal("expected iteration to succeed")
+	}
+	var titles []string
+	for _, tk := range tdb.TasksByStatus(taskdb.StatusPending) {
+		if tk.HasTag(TagFollowUp) && !strings.HasPrefix(tk.Title, "Earlier") {
+			titles = append(titles, tk.Title)
+		}
+	}
+	if len(titles) != 1 || titles[0] != "One more" {
+		t.Errorf("expected only one follow-up under the cap of %d, got %v", maxFollowUpsPerTask, titles)
+	}
+}
+
+// committingRunner writes a file per task and commits part of it itself,
+// the way agents often do, leaving the rest for the sprint to commit.
+type committingRunner struct {
+	dir string
+}
+
+func (r *committingRunner) RunTask(ctx context.Context, task *ralph.Task, _ string) *ralph.IterationResult {
+	for _, step := range [][]string{
+		{"sh", "-c", "echo draft > " + task.ID + ".txt && git add -A && git commit -qm wip"},
+		{"sh", "-c", "echo final > " + task.ID + ".txt"},
+	} {
+		cmd := exec.CommandContext(ctx, step[0], step[1:]...)
+		cmd.Dir = r.dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return &ralph.IterationResult{TaskID: task.ID, Error: fmt.Sprintf("%v: %s", err, out)}
+		}
+	}
+	return &ralph.IterationResult{TaskID: task.ID, Success: true, QualityOK: true, Output: "done"}
+}
+
+func TestSprintRunner_CommitPolicy(t *testing.T) {
+	tests := []struct {
+		squash   string
+		subjects []string
+	}{
+		{squash: "", subjects: []string{"feat(t-2): Second", "wip", "feat(t-1): First", "wip"}},
+		{squash: workflow.SquashTask, subjects: []string{"feat(t-2): Second", "feat(t-1): First"}},
+		{squash: workflow.SquashSprint, subjects: []string{"feat: sprint 1 (2 tasks)"}},
+	}
+	for _, tt := range tests {
+		t.Run("squash="+tt.squash, func(t *testing.T) {
+			s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+			repoDir := initGitRepo(t, nil)
+			wf := workflow.NewGitWorkflow("", repoDir, logger)
+			wf.SetWorktreePath(repoDir, "main")
+			ctx := context.Background()
+			base, _ := wf.CurrentCommit(ctx)
+
+			cfg := DefaultConfig()
+			cfg.SprintSize = 2
+			cfg.Commit.Squash = tt.squash
+			tdb := taskdb.New()
+			for i, title := range []string{"First", "Second"} {
+				id := fmt.Sprintf("t-%d", i+1)
+				if err := tdb.Add(&taskdb.Task{ID: id, Title: title, Status: taskdb.StatusPending, Priority: i, MaxAttempts: 1}); err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+				if err := s.InsertTask(&store.Task{ID: id, SessionID: sessionID, Title: title, Status: "pending", MaxAttempts: 1}); err != nil {
+					t.Fatalf("InsertTask: %v", err)
+				}
+			}
+
+			sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, &committingRunner{dir: repoDir}, logger)
+			if _, err := sr.RunSprint(ctx, 1, 1); err != nil {
+				t.Fatalf("RunSprint: %v", err)
+			}
+
+			out, err := exec.Command("git", "-C", repoDir, "log", "--format=%s", base+"..HEAD").Output()
+			if err != nil {
+				t.Fatalf("git log: %v", err)
+			}
+			if got := strings.Split(strings.TrimSpace(string(out)), "\n"); strings.Join(got, "|") != strings.Join(tt.subjects, "|") {
+				t.Errorf("commits = %q, want %q", got, tt.subjects)
+			}
+
+			body, err := exec.Command("git", "-C", repoDir, "log", "-1", "--format=%B").Output()
+			if err != nil {
+				t.Fatalf("git log: %v", err)
+			}
+			for _, want := range []string{"Agentbox-Task: t-2", fmt.Sprintf("Agentbox-Session: %d", sessionID)} {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("last commit lacks %q:\n%s", want, body)
+				}
+			}
+			if data, _ := os.ReadFile(filepath.Join(repoDir, "t-1.txt")); string(data) != "final\n" {
+				t.Errorf("t-1.txt = %q, want the final content", data)
+			}
+		})
+	}
+}
+
+func TestSprintRunner_CommitPolicyViolationFailsAttempt(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	repoDir := initGitRepo(t, nil)
+	wf := workflow.NewGitWorkflow("", repoDir, logger)
+	wf.SetWorktreePath(repoDir, "main")
+	wf.SetCommitConfig(config.CommitConfig{ProtectedPaths: []string{"t-1.txt"}}, nil)
+
+	cfg := DefaultConfig()
+	cfg.SprintSize = 1
+	tdb := taskdb.New()
+	if err := tdb.Add(&taskdb.Task{ID: "t-1", Title: "First", Status: taskdb.StatusPending, MaxAttempts: 2}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.InsertTask(&store.Task{ID: "t-1", SessionID: sessionID, Title: "First", Status: "pending", MaxAttempts: 2}); err != nil {
+		t.Fatalf("InsertTask: %v", err)
+	}
+
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, &committingRunner{dir: repoDir}, logger)
+	result, err := sr.RunSprint(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("RunSprint: %v", err)
+	}
+	if result.TasksCompleted != 0 || result.TasksFailed != 1 {
+		t.Errorf("result = %+v, want the attempt failed", result)
+	}
+	task, _ := tdb.Get("t-1")
+	if task.Status == taskdb.StatusCompleted {
+		t.Error("task completed despite the policy violation")
+	}
+	history := strings.Join(task.FailureHistory(), "\n")
+	if !strings.Contains(history, "commit policy violation: changed protected paths t-1.txt") {
+		t.Errorf("failure history = %q", history)
+	}
+}
+
+func TestSprintRunner_RefusedWorkDoesNotCarryOver(t *testing.T) {
+	s, sessionID, collector, budget, j, logger := setupTestSupervisorDeps(t)
+	repoDir := initGitRepo(t, nil)
+	wf := workflow.NewGitWorkflow("", repoDir, logger)
+	wf.SetWorktreePath(repoDir, "main")
+	wf.SetCommitConfig(config.CommitConfig{ProtectedPaths: []string{"t-1.txt"}}, nil)
+	ctx := context.Background()
+	base, _ := wf.CurrentCommit(ctx)
+
+	cfg := DefaultConfig()
+	cfg.SprintSize = 2
+	tdb := taskdb.New()
+	for i, title := range []string{"Refused", "Clean"} {
+		id := fmt.Sprintf("t-%d", i+1)
+		if err := tdb.Add(&taskdb.Task{ID: id, Title: title, Status: taskdb.StatusPending, Priority: i, MaxAttempts: 1}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if err := s.InsertTask(&store.Task{ID: id, SessionID: sessionID, Title: title, Status: "pending", MaxAttempts: 1}); err != nil {
+			t.Fatalf("InsertTask: %v", err)
+		}
+	}
+
+	sr := NewSprintRunner(cfg, s, sessionID, wf, tdb, collector, budget, j, &committingRunner{dir: repoDir}, logger)
+	result, err := sr.RunSprint(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("RunSprint: %v", err)
+	}
+	if result.TasksCompleted != 1 || result.TasksFailed != 1 {
+		t.Errorf("result = %+v, want t-1 refused and t-2 committed", result)
+	}
+	if task, _ := tdb.Get("t-2"); task.Status != taskdb.StatusCompleted {
+		t.Errorf("t-2 status = %s, want completed", task.Status)
+	}
+
+	out, err := exec.Command("git", "-C", repoDir, "log", "--format=%s", base+"..HEAD").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "feat(t-2): Clean\nwip" {
+		t.Errorf("commits = %q, want only t-2's", got)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "t-1.txt")); !os.IsNotExist(err) {
+		t.Errorf("t-1.txt still in the worktree: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/events"
+	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// TagBaseSync marks tasks created when bringing the base branch into the
+// sprint branch: conflict resolutions and fixes for checks the new base
+// broke.
+const TagBaseSync = "base-sync"
+
+// conflictTaskPrefix starts the IDs of merge conflict tasks, which end in
+// the base commit being merged.
+const conflictTaskPrefix = "merge-conflict-"
+
+// maxConflictHunksB caps the conflicting hunks put in a task's context.
+const maxConflictHunksB = 8000
+
+// syncBase brings new commits on the base branch into the sprint branch, as
+// sync_base says, and re-runs the quality checks on the result. Failing
+// checks become a fix task. Conflicts are left in the worktree as a merge in
+// progress, and a priority-0 task is queued to resolve them; its commit
+// completes the merge, and the sprint runner runs nothing else until then.
+// Other problems are logged and the session carries on from the base it has.
+func (s *Supervisor) syncBase(ctx context.Context, sprint, iteration int) {
+	if s.cfg.SyncBase == "" || s.cfg.DryRun {
+		return
+	}
+	if s.workflow.MergeInProgress(ctx) {
+		if s.conflictTaskQueued() {
+			s.logger.Info("merge conflicts with the base branch not resolved yet")
+			return
+		}
+		// The resolution task gave up; don't leave the branch mid-merge.
+		s.logger.Warn("abandoning merge of the base branch with unresolved conflicts")
+		if err := s.workflow.AbortMerge(ctx); err != nil {
+			s.logger.Warn("could not abort merge", "error", err)
+		}
+		return
+	}
+
+	before, _ := s.workflow.CurrentCommit(ctx)
+	changed, err := s.workflow.SyncBase(ctx, s.cfg.SyncBase)
+	var cerr *workflow.ConflictError
+	switch {
+	case errors.As(err, &cerr):
+		s.mergeConflict(ctx, sprint, iteration, cerr)
+		return
+	case err != nil:
+		s.logger.Warn("could not sync with the base branch", "error", err)
+		return
+	case !changed:
+		s.logger.Debug("branch is up to date with its base")
+		return
+	}
+
+	head, _ := s.workflow.CurrentCommit(ctx)
+	s.logger.Info("synced branch with its base", "strategy", s.cfg.SyncBase, "head", shortSHA(head))
+	// Run the checks of the packages the new base touched.
+	paths, err := workflow.ChangedPaths(ctx, s.workflow.WorktreePath(), before, s.cfg.bookkeepingPaths())
+	if err != nil {
+		s.logger.Warn("could not list paths changed by the sync", "error", err)
+	}
+	sets := s.cfg.ToRalphConfig().CheckSets("", paths)
+	if len(sets) == 0 {
+		return
+	}
+	if err := ralph.RunCheckSets(ctx, s.workflow.WorktreePath(), sets, s.logger); err != nil {
+		s.syncChecksFailed(head, sprint, iteration, err)
+	}
+}
+
+// conflictTaskQueued reports whether a merge conflict task is still
+// waiting to run, or to be retried.
+func (s *Supervisor) conflictTaskQueued() bool {
+	return queuedConflictTask(s.taskDB) != nil
+}
+
+// queuedConflictTask returns a copy of the merge conflict task still
+// waiting to run, or to be retried, if there is one.
+func queuedConflictTask(tdb *taskdb.DB) *taskdb.Task {
+	for _, t := range tdb.TasksByStatus(taskdb.StatusPending, taskdb.StatusInProgress) {
+		if strings.HasPrefix(t.ID, conflictTaskPrefix) && !t.HasExhaustedAttempts() {
+			return &t
+		}
+	}
+	return nil
+}
+
+// mergeConflict queues a task to resolve the conflicts of a merge of the
+// base branch. When a task for the same base commit already ran and gave
+// up, the merge is abandoned instead, until the base branch moves on.
+func (s *Supervisor) mergeConflict(ctx context.Context, sprint, iteration int, cerr *workflow.ConflictError) {
+	id := conflictTaskPrefix + cerr.Commit[:min(len(cerr.Commit), 7)]
+	s.logger.Warn("conflicts merging the base branch", "base", cerr.Base, "files", strings.Join(cerr.Files, ", "))
+	if _, exists := s.taskDB.Get(id); exists {
+		s.logger.Warn("conflicts with this base commit were not resolved before; staying on the old base",
+			"base", cerr.Base, "commit", shortSHA(cerr.Commit))
+		if err := s.workflow.AbortMerge(ctx); err != nil {
+			s.logger.Warn("could not abort merge", "error", err)
+		}
+		return
+	}
+
+	task := &taskdb.Task{
+		ID:    id,
+		Title: "Resolve merge conflicts with " + cerr.Base,
+		Description: fmt.Sprintf("Merging %s into this branch stopped on conflicts in %d files: %s. "+
+			"The merge is in progress and the conflicts are marked in the files with <<<<<<<, ======= and >>>>>>> lines. "+
+			"Resolve each conflict so that both the branch's changes and the new changes on %s keep working, "+
+			"and remove every conflict marker. Don't make other changes.",
+			cerr.Base, len(cerr.Files), strings.Join(cerr.Files, ", "), cerr.Base),
+		Priority:     0,
+		Complexity:   2,
+		MaxAttempts:  2,
+		ContextNotes: conflictNotes(cerr),
+		Tags:         []string{TagBaseSync},
+	}
+	if err := s.tasks.Add(task); err != nil {
+		s.logger.Warn("could not add merge conflict task", "error", err)
+		if err := s.workflow.AbortMerge(ctx); err != nil {
+			s.logger.Warn("could not abort merge", "error", err)
+		}
+		return
+	}
+
+	if s.cfg.JournalEnabled {
+		_ = s.journal.Add(&store.JournalEntry{
+			Kind:       string(journal.KindBaseSync),
+			Sprint:     sprint,
+			Iteration:  iteration,
+			TaskID:     task.ID,
+			Summary:    fmt.Sprintf("Merge conflicts with %s in %s", cerr.Base, strings.Join(cerr.Files, ", ")),
+			Reflection: "Queued a task to resolve them before other work continues.",
+		})
+	}
+}
+
+// syncChecksFailed records quality checks that fail on the synced branch and
+// queues a fix task.
+func (s *Supervisor) syncChecksFailed(head string, sprint, iteration int, checkErr error) {
+	s.logger.Warn("quality checks failed after syncing with the base branch", "error", checkErr)
+	checksJSON, _ := json.Marshal([]map[string]any{{"name": "base-sync", "passed": false, "output": checkErr.Error()}})
+	if err := s.collector.RecordQuality(&store.QualitySnapshot{
+		Iteration:   iteration,
+		OverallPass: false,
+		ChecksJSON:  string(checksJSON),
+		Timestamp:   time.Now(),
+	}); err != nil {
+		s.logger.Warn("could not record quality checks", "error", err)
+	}
+	s.events.Emit(events.Event{
+		Kind:      events.QualityCheckFailed,
+		Iteration: iteration,
+		Message:   "Quality checks failed after syncing with the base branch",
+	})
+
+	task := &taskdb.Task{
+		ID:    "base-sync-fix-" + head[:min(len(head), 7)],
+		Title: "Fix quality checks after syncing with the base branch",
+		Description: fmt.Sprintf("The quality checks passed before, but fail since the base branch was brought into this branch (now at %s). "+
+			"Adapt the branch's changes to the new base so the checks pass again.", shortSHA(head)),
+		Priority:     0,
+		Complexity:   2,
+		MaxAttempts:  2,
+		ContextNotes: "Output of the failing check:\n\n```\n" + ciLogExcerpt(checkErr.Error()) + "\n```",
+		Tags:         []string{TagBaseSync},
+	}
+	if err := s.tasks.Add(task); err != nil {
+		s.logger.Warn("could not add fix task", "error", err)
+		return
+	}
+
+	if s.cfg.JournalEnabled {
+		_ = s.journal.Add(&store.JournalEntry{
+			Kind:       string(journal.KindBaseSync),
+			Sprint:     sprint,
+			Iteration:  iteration,
+			TaskID:     task.ID,
+			Summary:    "Quality checks failed after syncing with the base branch",
+			Reflection: "Queued a task to fix them.",
+		})
+	}
+}
+
+// conflictNotes lists the conflicting files and hunks for the agent.
+func conflictNotes(cerr *workflow.ConflictError) string {
+	var sb strings.Builder
+	sb.WriteString("Files with conflicts:\n")
+	for _, f := range cerr.Files {
+		sb.WriteString("- " + f + "\n")
+	}
+	hunks := strings.TrimSpace(cerr.Hunks)
+	if hunks == "" {
+		return sb.String()
+	}
+	if len(hunks) > maxConflictHunksB {
+		hunks = hunks[:maxConflictHunksB]
+		if i := strings.LastIndexByte(hunks, '\n'); i >= 0 {
+			hunks = hunks[:i]
+		}
+		hunks += "\n... (truncated; see the files for the rest)"
+	}
+	sb.WriteString("\nConflicting hunks:\n\n```diff\n" + hunks + "\n```")
+	return sb.String()
+}
@@ -0,0 +1,218 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/events"
+	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// newSyncBaseSupervisor returns a supervisor with sync_base set to merge,
+// working on a branch of a clone. upstream commits a file to main and
+// pushes it to the clone's origin.
+func newSyncBaseSupervisor(t *testing.T) (sup *Supervisor, upstream func(name, content string)) {
+	t.Helper()
+	repoDir := initGitRepo(t, nil)
+	bareDir := filepath.Join(t.TempDir(), "origin.git")
+	cloneDir := filepath.Join(t.TempDir(), "clone")
+	for _, args := range [][]string{
+		{"git", "clone", "--bare", repoDir, bareDir},
+		{"git", "clone", bareDir, cloneDir},
+		{"git", "-C", cloneDir, "config", "user.email", "test@test.com"},
+		{"git", "-C", cloneDir, "config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.WorkDir = t.TempDir()
+	cfg.SyncBase = workflow.SyncMerge
+	cfg.JournalEnabled = true
+	sup, err := New(cfg, testLogger())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { sup.Store().Close() })
+	sup.workflow = workflow.NewGitWorkflow("", cloneDir, testLogger())
+	if err := sup.workflow.CreateWorktree(context.Background(), "agentbox/sync"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+
+	upstream = func(name, content string) {
+		t.Helper()
+		gitCommitFile(t, repoDir, name, content, "upstream "+name)
+		if out, err := exec.Command("git", "-C", repoDir, "push", bareDir, "main").CombinedOutput(); err != nil {
+			t.Fatalf("git push: %v\n%s", err, out)
+		}
+	}
+	return sup, upstream
+}
+
+func commitOnBranch(t *testing.T, sup *Supervisor, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(sup.workflow.WorktreePath(), name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sup.workflow.Commit(context.Background(), "branch "+name, nil); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+func TestSyncBase_ChecksFailAfterSync(t *testing.T) {
+	sup, upstream := newSyncBaseSupervisor(t)
+	sup.cfg.QualityChecks = []QualityCheck{{Name: "check", Command: "make check"}}
+	var got []events.Event
+	sup.SetEventSink(func(e events.Event) { got = append(got, e) })
+	ctx := context.Background()
+
+	commitOnBranch(t, sup, "feature.txt", "feature\n")
+	sup.syncBase(ctx, 1, 3)
+	if tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending); len(tasks) != 0 {
+		t.Fatalf("sync with nothing new added tasks: %+v", tasks)
+	}
+
+	upstream("Makefile", "check:\n\t@echo base broke the build && false\n")
+	sup.syncBase(ctx, 1, 3)
+
+	if _, err := os.Stat(filepath.Join(sup.workflow.WorktreePath(), "Makefile")); err != nil {
+		t.Fatalf("base branch not merged: %v", err)
+	}
+	tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending)
+	if len(tasks) != 1 {
+		t.Fatalf("pending tasks = %+v, want one fix task", tasks)
+	}
+	if task := tasks[0]; task.Priority != 0 || !task.HasTag(TagBaseSync) || !strings.Contains(task.ContextNotes, "base broke the build") {
+		t.Errorf("task = %+v", task)
+	}
+	if len(got) != 1 || got[0].Kind != events.QualityCheckFailed {
+		t.Errorf("events = %+v", got)
+	}
+	if snaps, _ := sup.Store().QualitySnapshots(sup.SessionID()); len(snaps) != 1 || snaps[0].OverallPass {
+		t.Errorf("quality snapshots = %+v", snaps)
+	}
+}
+
+func TestSyncBase_ConflictQueuesTask(t *testing.T) {
+	sup, upstream := newSyncBaseSupervisor(t)
+	ctx := context.Background()
+	commitOnBranch(t, sup, "README.md", "# Branch\n")
+	upstream("README.md", "# Upstream\n")
+
+	sup.syncBase(ctx, 1, 3)
+	if !sup.workflow.MergeInProgress(ctx) {
+		t.Fatal("conflicting merge not left in progress")
+	}
+	tasks := sup.taskDB.TasksByStatus(taskdb.StatusPending)
+	if len(tasks) != 1 {
+		t.Fatalf("pending tasks = %+v, want one conflict task", tasks)
+	}
+	task := tasks[0]
+	if !strings.HasPrefix(task.ID, conflictTaskPrefix) || task.Priority != 0 || !task.HasTag(TagBaseSync) ||
+		task.Title != "Resolve merge conflicts with origin/main" {
+		t.Errorf("task = %+v", task)
+	}
+	if !strings.Contains(task.ContextNotes, "- README.md") || !strings.Contains(task.ContextNotes, "# Upstream") {
+		t.Errorf("context notes = %q", task.ContextNotes)
+	}
+	entries, err := sup.journal.Entries(&store.JournalQuery{Kind: string(journal.KindBaseSync)})
+	if err != nil || len(entries) != 1 || entries[0].TaskID != task.ID {
+		t.Errorf("journal = %+v, %v", entries, err)
+	}
+
+	// While the task is queued the merge is left alone.
+	sup.syncBase(ctx, 2, 4)
+	if !sup.workflow.MergeInProgress(ctx) || len(sup.taskDB.TasksByStatus(taskdb.StatusPending)) != 1 {
+		t.Fatal("second sync touched the merge in progress")
+	}
+
+	// The task gave up: the merge is abandoned and not retried for the
+	// same base commit.
+	if _, err := sup.taskDB.Update(task.ID, func(t *taskdb.Task) { t.Status = taskdb.StatusFailed }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	sup.syncBase(ctx, 3, 5)
+	if sup.workflow.MergeInProgress(ctx) {
+		t.Fatal("merge still in progress after the conflict task failed")
+	}
+	sup.syncBase(ctx, 4, 6)
+	if sup.workflow.MergeInProgress(ctx) {
+		t.Error("merge retried for a base commit whose conflicts were not resolved")
+	}
+}
+
+// fileRunner writes <task ID>.txt in dir for each task it runs, and fails
+// the tasks in fail.
+type fileRunner struct {
+	dir   string
+	fail  func(id string) bool
+	calls []string
+}
+
+func (r *fileRunner) RunTask(_ context.Context, task *ralph.Task, _ string) *ralph.IterationResult {
+	r.calls = append(r.calls, task.ID)
+	if r.fail(task.ID) {
+		return &ralph.IterationResult{TaskID: task.ID, Error: "conflicts too hard"}
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, task.ID+".txt"), []byte("done\n"), 0644); err != nil {
+		return &ralph.IterationResult{TaskID: task.ID, Error: err.Error()}
+	}
+	return &ralph.IterationResult{TaskID: task.ID, Success: true, QualityOK: true, Output: "done"}
+}
+
+func TestSprintRunner_ConflictTaskExhaustedAbortsMerge(t *testing.T) {
+	sup, upstream := newSyncBaseSupervisor(t)
+	ctx := context.Background()
+	commitOnBranch(t, sup, "README.md", "# Branch\n")
+	upstream("README.md", "# Upstream\n")
+	before, _ := sup.workflow.CurrentCommit(ctx)
+
+	sup.syncBase(ctx, 1, 1)
+	if !sup.workflow.MergeInProgress(ctx) {
+		t.Fatal("conflicting merge not left in progress")
+	}
+	// Queued before the conflict task at the same priority, so only the
+	// merge in progress keeps it waiting.
+	feature := &taskdb.Task{ID: "feature", Title: "Feature", Status: taskdb.StatusPending, Priority: 0, MaxAttempts: 2,
+		CreatedAt: time.Now().Add(-time.Hour)}
+	if err := sup.tasks.Add(feature); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	runner := &fileRunner{dir: sup.workflow.WorktreePath(), fail: func(id string) bool { return strings.HasPrefix(id, conflictTaskPrefix) }}
+	sup.cfg.SprintSize = 3
+	sr := NewSprintRunner(sup.cfg, sup.store, sup.sessionID, sup.workflow, sup.taskDB, sup.collector, sup.budget, sup.journal, runner, testLogger())
+	if _, err := sr.RunSprint(ctx, 1, 1); err != nil {
+		t.Fatalf("RunSprint: %v", err)
+	}
+
+	if len(runner.calls) != 3 || !strings.HasPrefix(runner.calls[0], conflictTaskPrefix) ||
+		!strings.HasPrefix(runner.calls[1], conflictTaskPrefix) || runner.calls[2] != "feature" {
+		t.Fatalf("tasks run = %v, want the conflict task twice, then feature", runner.calls)
+	}
+	if sup.workflow.MergeInProgress(ctx) {
+		t.Error("merge still in progress after the conflict task gave up")
+	}
+	if task, _ := sup.taskDB.Get("feature"); task.Status != taskdb.StatusCompleted || len(task.Attempts) != 1 {
+		t.Errorf("feature = %+v, want completed on its first attempt", task)
+	}
+	out, err := exec.Command("git", "-C", sup.workflow.WorktreePath(), "log", "--format=%s %P", before+"..HEAD").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	commits := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(commits) != 1 || !strings.Contains(commits[0], "Feature") || len(strings.Fields(commits[0])) > 3 {
+		t.Errorf("commits = %q, want one non-merge commit for feature", commits)
+	}
+}
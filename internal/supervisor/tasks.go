@@ -0,0 +1,283 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+)
+
+// ErrSessionEnded is returned by a TaskEditor whose sprint has finished and
+// closed its store.
+var ErrSessionEnded = errors.New("sprint session has ended")
+
+// TaskEditor changes a session's tasks from outside the sprint loop, for
+// example from MCP tools. Every change is applied to the in-memory task DB
+// and persisted to the store, so a running sprint picks it up at its next
+// iteration and a resumed sprint starts from it.
+type TaskEditor struct {
+	mu        sync.Mutex
+	taskDB    *taskdb.DB
+	store     *store.Store
+	sessionID int64
+}
+
+// TaskUpdate lists the task fields to change. Nil fields are left as is.
+type TaskUpdate struct {
+	Title        *string
+	Description  *string
+	Status       *string
+	Priority     *int
+	ContextNotes *string
+}
+
+// NewTaskEditor returns an editor for a session that is not running in this
+// process. Its task DB is loaded from the store.
+func NewTaskEditor(s *store.Store, sessionID int64) (*TaskEditor, error) {
+	tdb, err := loadTaskDB(s, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskEditor{taskDB: tdb, store: s, sessionID: sessionID}, nil
+}
+
+// detach stops the editor from touching the store, which is about to close.
+func (e *TaskEditor) detach() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store = nil
+}
+
+// Add inserts a new pending task. Its dependencies must already exist.
+func (e *TaskEditor) Add(t *taskdb.Task) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.store == nil {
+		return ErrSessionEnded
+	}
+	if t.ID == "" || t.Title == "" {
+		return fmt.Errorf("task id and title are required")
+	}
+	for _, dep := range t.DependsOn {
+		if _, ok := e.taskDB.Get(dep); !ok {
+			return fmt.Errorf("dependency %s not found", dep)
+		}
+	}
+	if t.Status == "" {
+		t.Status = taskdb.StatusPending
+	}
+	if !t.Status.Valid() {
+		return fmt.Errorf("invalid status %q", t.Status)
+	}
+
+	if err := e.taskDB.Add(t); err != nil {
+		return err
+	}
+	return e.insertLocked(t)
+}
+
+// Update changes a task's fields and returns the updated task.
+func (e *TaskEditor) Update(taskID string, u TaskUpdate) (taskdb.Task, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.store == nil {
+		return taskdb.Task{}, ErrSessionEnded
+	}
+	if u.Status != nil && !taskdb.TaskStatus(*u.Status).Valid() {
+		return taskdb.Task{}, fmt.Errorf("invalid status %q", *u.Status)
+	}
+	if u.Priority != nil && (*u.Priority < 0 || *u.Priority > taskdb.MaxPriority) {
+		return taskdb.Task{}, fmt.Errorf("priority must be between 0 and %d", taskdb.MaxPriority)
+	}
+
+	updated, err := e.taskDB.Update(taskID, func(t *taskdb.Task) {
+		if u.Title != nil {
+			t.Title = *u.Title
+		}
+		if u.Description != nil {
+			t.Description = *u.Description
+		}
+		if u.Status != nil {
+			status := taskdb.TaskStatus(*u.Status)
+			switch {
+			case status != taskdb.StatusCompleted:
+				t.CompletedAt = nil
+			case t.Status != taskdb.StatusCompleted || t.CompletedAt == nil:
+				now := time.Now()
+				t.CompletedAt = &now
+			}
+			t.Status = status
+		}
+		if u.Priority != nil {
+			t.Priority = *u.Priority
+		}
+		if u.ContextNotes != nil {
+			t.ContextNotes = *u.ContextNotes
+		}
+	})
+	if err != nil {
+		return taskdb.Task{}, err
+	}
+
+	if err := e.store.UpdateTask(&store.Task{
+		ID:           updated.ID,
+		Title:        updated.Title,
+		Description:  updated.Description,
+		Status:       string(updated.Status),
+		Priority:     updated.Priority,
+		ContextNotes: updated.ContextNotes,
+	}); err != nil {
+		return taskdb.Task{}, fmt.Errorf("persisting task %s: %w", taskID, err)
+	}
+	return updated, nil
+}
+
+// Split replaces a task with subtasks and returns their IDs. The parent is
+// deferred; the subtasks inherit its dependencies, and tasks that depended
+// on the parent now wait for the last subtask.
+func (e *TaskEditor) Split(parentID string, subtasks []*taskdb.Task) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.store == nil {
+		return nil, ErrSessionEnded
+	}
+	for _, sub := range subtasks {
+		if sub.ID == "" || sub.Title == "" {
+			return nil, fmt.Errorf("subtask id and title are required")
+		}
+		if sub.Status == "" {
+			sub.Status = taskdb.StatusPending
+		}
+	}
+
+	if err := e.taskDB.SplitTask(parentID, subtasks); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(subtasks))
+	for i, sub := range subtasks {
+		ids[i] = sub.ID
+		if err := e.insertLocked(sub); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.store.ReplaceDependency(parentID, ids[len(ids)-1]); err != nil {
+		return nil, fmt.Errorf("rewiring dependents of %s: %w", parentID, err)
+	}
+	if err := e.store.UpdateTaskStatus(parentID, string(taskdb.StatusDeferred)); err != nil {
+		return nil, fmt.Errorf("deferring task %s: %w", parentID, err)
+	}
+	return ids, nil
+}
+
+// Depend makes taskID wait for dependsOn. Edges that would create a cycle
+// are rejected.
+func (e *TaskEditor) Depend(taskID, dependsOn string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.store == nil {
+		return ErrSessionEnded
+	}
+	if err := e.taskDB.AddDependency(taskID, dependsOn); err != nil {
+		return err
+	}
+	if err := e.store.AddDependency(taskID, dependsOn); err != nil {
+		return fmt.Errorf("persisting dependency %s -> %s: %w", taskID, dependsOn, err)
+	}
+	return nil
+}
+
+// insertLocked persists a task that is already in the task DB, along with
+// its dependencies. The caller must hold e.mu.
+func (e *TaskEditor) insertLocked(t *taskdb.Task) error {
+	tagsJSON := ""
+	if len(t.Tags) > 0 {
+		data, _ := json.Marshal(t.Tags)
+		tagsJSON = string(data)
+	}
+	if err := e.store.InsertTask(&store.Task{
+		ID:           t.ID,
+		SessionID:    e.sessionID,
+		Title:        t.Title,
+		Description:  t.Description,
+		Status:       string(t.Status),
+		Priority:     t.Priority,
+		Complexity:   t.Complexity,
+		ParentID:     t.ParentID,
+		MaxAttempts:  t.MaxAttempts,
+		ContextNotes: t.ContextNotes,
+		TagsJSON:     tagsJSON,
+		Workdir:      t.Workdir,
+	}); err != nil {
+		return fmt.Errorf("inserting task %s into store: %w", t.ID, err)
+	}
+	for _, dep := range t.DependsOn {
+		if err := e.store.AddDependency(t.ID, dep); err != nil {
+			return fmt.Errorf("adding dependency %s -> %s: %w", t.ID, dep, err)
+		}
+	}
+	return nil
+}
+
+// loadTaskDB rebuilds a session's task DB, including attempt history, from
+// the store.
+func loadTaskDB(s *store.Store, sessionID int64) (*taskdb.DB, error) {
+	tdb := taskdb.New()
+	tasks, err := s.ListTasks(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("loading tasks: %w", err)
+	}
+
+	for _, st := range tasks {
+		// Load dependencies.
+		deps, _ := s.GetDependencies(st.ID)
+
+		task := &taskdb.Task{
+			ID:           st.ID,
+			Title:        st.Title,
+			Description:  st.Description,
+			Status:       taskdb.TaskStatus(st.Status),
+			Priority:     st.Priority,
+			Complexity:   st.Complexity,
+			ParentID:     st.ParentID,
+			MaxAttempts:  st.MaxAttempts,
+			ContextNotes: st.ContextNotes,
+			DependsOn:    deps,
+			Workdir:      st.Workdir,
+			CreatedAt:    st.CreatedAt,
+			CompletedAt:  st.CompletedAt,
+		}
+		if st.TagsJSON != "" {
+			_ = json.Unmarshal([]byte(st.TagsJSON), &task.Tags)
+		}
+
+		// Restore attempts.
+		attempts, _ := s.GetAttempts(st.ID)
+		for _, a := range attempts {
+			success := false
+			if a.Success != nil {
+				success = *a.Success
+			}
+			task.Attempts = append(task.Attempts, taskdb.Attempt{
+				Number:    a.Number,
+				AgentName: a.AgentName,
+				Success:   success,
+				ErrorMsg:  a.ErrorMsg,
+				GitCommit: a.GitCommit,
+				StartedAt: a.StartedAt,
+			})
+		}
+
+		if err := tdb.Add(task); err != nil {
+			return nil, fmt.Errorf("restoring task %s: %w", st.ID, err)
+		}
+	}
+	return tdb, nil
+}
@@ -0,0 +1,259 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swamp-dev/agentbox/internal/journal"
+	"github.com/swamp-dev/agentbox/internal/metrics"
+	"github.com/swamp-dev/agentbox/internal/ralph"
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/taskdb"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+func newTestTaskEditor(t *testing.T) (*TaskEditor, int64) {
+	t.Helper()
+	s, sessionID, _, _, _, _ := setupTestSupervisorDeps(t)
+	e, err := NewTaskEditor(s, sessionID)
+	if err != nil {
+		t.Fatalf("NewTaskEditor: %v", err)
+	}
+	for _, task := range []*taskdb.Task{
+		{ID: "a", Title: "Task A", Priority: 1},
+		{ID: "b", Title: "Task B", Priority: 2, DependsOn: []string{"a"}},
+	} {
+		if err := e.Add(task); err != nil {
+			t.Fatalf("Add(%s): %v", task.ID, err)
+		}
+	}
+	return e, sessionID
+}
+
+func TestTaskEditor_AddPersists(t *testing.T) {
+	e, sessionID := newTestTaskEditor(t)
+
+	if err := e.Add(&taskdb.Task{ID: "a", Title: "dup"}); err == nil {
+		t.Error("adding a duplicate task succeeded, want error")
+	}
+	if err := e.Add(&taskdb.Task{ID: "c", Title: "C", DependsOn: []string{"missing"}}); err == nil {
+		t.Error("adding a task with an unknown dependency succeeded, want error")
+	}
+
+	// A fresh editor reloads what the first one persisted.
+	reloaded, err := NewTaskEditor(e.store, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := reloaded.taskDB.Get("b")
+	if !ok {
+		t.Fatal("task b not persisted")
+	}
+	if b.Status != taskdb.StatusPending || len(b.DependsOn) != 1 || b.DependsOn[0] != "a" {
+		t.Errorf("task b = status %s deps %v, want pending [a]", b.Status, b.DependsOn)
+	}
+}
+
+func TestTaskEditor_Update(t *testing.T) {
+	e, _ := newTestTaskEditor(t)
+
+	status, priority, notes := "blocked", 7, "waiting on API keys"
+	got, err := e.Update("a", TaskUpdate{Status: &status, Priority: &priority, ContextNotes: &notes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != taskdb.StatusBlocked || got.Priority != 7 || got.ContextNotes != notes {
+		t.Errorf("updated task = %+v", got)
+	}
+	stored, err := e.store.GetTask("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != "blocked" || stored.Priority != 7 || stored.ContextNotes != notes {
+		t.Errorf("stored task = %+v", stored)
+	}
+
+	completed, pending := "completed", "pending"
+	got, err = e.Update("a", TaskUpdate{Status: &completed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CompletedAt == nil {
+		t.Error("task marked completed has no CompletedAt")
+	}
+	if got, err = e.Update("a", TaskUpdate{Status: &pending}); err != nil || got.CompletedAt != nil {
+		t.Errorf("task reopened = %+v, %v; want CompletedAt cleared", got, err)
+	}
+
+	bad := "done"
+	if _, err := e.Update("a", TaskUpdate{Status: &bad}); err == nil {
+		t.Error("invalid status succeeded, want error")
+	}
+	if _, err := e.Update("missing", TaskUpdate{Priority: &priority}); err == nil {
+		t.Error("updating an unknown task succeeded, want error")
+	}
+}
+
+func TestTaskEditor_Split(t *testing.T) {
+	e, _ := newTestTaskEditor(t)
+
+	ids, err := e.Split("a", []*taskdb.Task{
+		{ID: "a1", Title: "First half"},
+		{ID: "a2", Title: "Second half", DependsOn: []string{"a1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids, ",") != "a1,a2" {
+		t.Errorf("ids = %v, want [a1 a2]", ids)
+	}
+
+	parent, err := e.store.GetTask("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.Status != "deferred" {
+		t.Errorf("parent status = %s, want deferred", parent.Status)
+	}
+	sub, err := e.store.GetTask("a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.ParentID != "a" {
+		t.Errorf("subtask parent = %q, want a", sub.ParentID)
+	}
+	deps, err := e.store.GetDependencies("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 1 || deps[0] != "a2" {
+		t.Errorf("b depends on %v in store, want [a2]", deps)
+	}
+	if b, _ := e.taskDB.Get("b"); len(b.DependsOn) != 1 || b.DependsOn[0] != "a2" {
+		t.Errorf("b depends on %v in task DB, want [a2]", b.DependsOn)
+	}
+}
+
+func TestTaskEditor_Depend(t *testing.T) {
+	e, _ := newTestTaskEditor(t)
+	if err := e.Add(&taskdb.Task{ID: "c", Title: "Task C"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Depend("c", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Depend("a", "c"); err == nil {
+		t.Error("dependency creating a cycle succeeded, want error")
+	}
+	deps, err := e.store.GetDependencies("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(deps)
+	if len(deps) != 1 || deps[0] != "b" {
+		t.Errorf("c depends on %v, want [b]", deps)
+	}
+	if deps, _ := e.store.GetDependencies("a"); len(deps) != 0 {
+		t.Errorf("rejected edge was persisted: a depends on %v", deps)
+	}
+}
+
+func TestTaskEditor_Detached(t *testing.T) {
+	e, _ := newTestTaskEditor(t)
+	e.detach()
+
+	if err := e.Add(&taskdb.Task{ID: "c", Title: "C"}); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("Add after detach = %v, want ErrSessionEnded", err)
+	}
+	if err := e.Depend("b", "a"); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("Depend after detach = %v, want ErrSessionEnded", err)
+	}
+}
+
+// sleepyRunner succeeds at every task after a short pause, giving edits
+// time to land while the task runs.
+type sleepyRunner struct{}
+
+func (sleepyRunner) RunTask(_ context.Context, task *ralph.Task, _ string) *ralph.IterationResult {
+	time.Sleep(5 * time.Millisecond)
+	return &ralph.IterationResult{TaskID: task.ID, Success: true, QualityOK: true, Output: "done"}
+}
+
+func TestTaskEditor_UpdateDuringSprint(t *testing.T) {
+	// The editor writes from its own connection, so the store can't be
+	// in memory.
+	s, err := store.Open(filepath.Join(t.TempDir(), "agentbox.db"))
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	sessionID, err := s.CreateSession("", "main", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	logger := testLogger()
+	e, err := NewTaskEditor(s, sessionID)
+	if err != nil {
+		t.Fatalf("NewTaskEditor: %v", err)
+	}
+	ids := []string{"a", "b", "c", "d"}
+	for i, id := range ids {
+		if err := e.Add(&taskdb.Task{ID: id, Title: "Task " + id, Priority: i}); err != nil {
+			t.Fatalf("Add(%s): %v", id, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.SprintSize = len(ids)
+	repoDir := initGitRepo(t, nil)
+	wf := workflow.NewGitWorkflow("", repoDir, logger)
+	wf.SetWorktreePath(repoDir, "main")
+	sr := NewSprintRunner(cfg, s, sessionID, wf, e.taskDB, metrics.NewCollector(s, sessionID),
+		metrics.NewBudgetEnforcer(metrics.DefaultBudget()), journal.New(s, sessionID), sleepyRunner{}, logger)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; ; n++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			title := fmt.Sprintf("Edit %d", n)
+			for _, id := range ids {
+				if _, err := e.Update(id, TaskUpdate{Title: &title}); err != nil {
+					t.Errorf("Update(%s): %v", id, err)
+					return
+				}
+			}
+		}
+	}()
+	result, err := sr.RunSprint(context.Background(), 1, 1)
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("RunSprint: %v", err)
+	}
+
+	if result.TasksCompleted != len(ids) {
+		t.Errorf("completed %d tasks, want %d", result.TasksCompleted, len(ids))
+	}
+	for _, id := range ids {
+		task, _ := e.taskDB.Snapshot(id)
+		if task.Status != taskdb.StatusCompleted || task.CompletedAt == nil || len(task.Attempts) != 1 ||
+			!strings.HasPrefix(task.Title, "Edit ") {
+			t.Errorf("task %s = %+v", id, task)
+		}
+	}
+}
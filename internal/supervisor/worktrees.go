@@ -0,0 +1,163 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/swamp-dev/agentbox/internal/store"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// SessionWorktree is a git worktree together with the session that worked
+// in it, as far as the store knows.
+type SessionWorktree struct {
+	Path    string         `json:"path"`
+	Branch  string         `json:"branch,omitempty"`
+	Session *store.Session `json:"session,omitempty"` // nil if no session recorded it
+	// Missing is set for a session's worktree that git no longer has, or
+	// whose directory is gone.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Ended reports whether the worktree's session has finished, so nothing
+// will resume in it.
+func (w SessionWorktree) Ended() bool {
+	return w.Session != nil && (w.Session.Status == "completed" || w.Session.Status == "failed")
+}
+
+// ListSessionWorktrees cross-references the repository's worktrees with the
+// sessions in st. Each worktree is paired with the latest session that
+// recorded it; sessions whose recorded worktree no longer exists are listed
+// as missing, with their branch if it is still there.
+func ListSessionWorktrees(ctx context.Context, st *store.Store, wf *workflow.GitWorkflow) ([]SessionWorktree, error) {
+	wts, err := wf.ListWorktrees(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing worktrees: %w", err)
+	}
+	sessions, err := st.ListSessions(0)
+	if err != nil {
+		return nil, fmt.Errorf("loading sessions: %w", err)
+	}
+
+	var out []SessionWorktree
+	claimed := make(map[int64]bool)
+	for _, wt := range wts {
+		sw := SessionWorktree{Path: wt.Path, Branch: wt.Branch, Missing: wt.Prunable}
+		// Sessions are newest first.
+		for _, sess := range sessions {
+			if sess.WorktreePath != "" && samePath(sess.WorktreePath, wt.Path) ||
+				sess.WorktreePath == "" && sess.BranchName != "" && sess.BranchName == wt.Branch {
+				if sw.Session == nil {
+					sw.Session = sess
+				}
+				claimed[sess.ID] = true
+			}
+		}
+		out = append(out, sw)
+	}
+	seen := make(map[string]bool)
+	for _, sess := range sessions {
+		if sess.WorktreePath == "" || claimed[sess.ID] || seen[sess.WorktreePath] {
+			continue
+		}
+		seen[sess.WorktreePath] = true
+		sw := SessionWorktree{Path: sess.WorktreePath, Session: sess, Missing: true}
+		if sess.BranchName != "" && wf.BranchExists(ctx, sess.BranchName) {
+			sw.Branch = sess.BranchName
+		}
+		out = append(out, sw)
+	}
+	return out, nil
+}
+
+// RemoveSessionWorktree removes a worktree and then its branch, and clears
+// the session's record of it. Without force a worktree with uncommitted
+// changes is kept, and the branch is only deleted if git considers it
+// merged. It reports whether the branch was deleted.
+func RemoveSessionWorktree(ctx context.Context, st *store.Store, wf *workflow.GitWorkflow, w SessionWorktree, force bool) (bool, error) {
+	switch {
+	case w.Missing:
+		if err := wf.PruneWorktrees(ctx); err != nil {
+			return false, err
+		}
+	case force:
+		if err := wf.RemoveWorktree(ctx, w.Path); err != nil {
+			return false, err
+		}
+	default:
+		if err := wf.RemoveWorktreeIfClean(ctx, w.Path); err != nil {
+			return false, err
+		}
+	}
+	if w.Session != nil {
+		if err := st.SetSessionWorktree(w.Session.ID, w.Session.BranchName, ""); err != nil {
+			return false, fmt.Errorf("updating session %d: %w", w.Session.ID, err)
+		}
+	}
+	if w.Branch == "" {
+		return false, nil
+	}
+	return wf.DeleteBranch(ctx, w.Branch, force) == nil, nil
+}
+
+// cleanupMergedWorktrees removes the worktrees and branches of finished
+// sessions whose pull requests have merged. Failures are logged; they never
+// stop the session.
+func (s *Supervisor) cleanupMergedWorktrees(ctx context.Context) {
+	wts, err := ListSessionWorktrees(ctx, s.store, s.workflow)
+	if err != nil {
+		s.logger.Warn("could not list worktrees for cleanup", "error", err)
+		return
+	}
+	for _, w := range wts {
+		if !w.Ended() || w.Session.PRNumber == 0 || w.Session.ID == s.sessionID {
+			continue
+		}
+		host, err := s.workflow.CodeHost(ctx)
+		if err != nil {
+			s.logger.Debug("no code host, skipping worktree cleanup", "error", err)
+			return
+		}
+		pr, err := host.GetPR(ctx, w.Session.PRNumber)
+		if err != nil {
+			s.logger.Warn("could not check pull request", "pr", w.Session.PRNumber, "error", err)
+			continue
+		}
+		if pr.State != "merged" {
+			continue
+		}
+		// The work is on the base branch now, even if the PR was squashed,
+		// so nothing in the worktree or branch is worth keeping.
+		if _, err := RemoveSessionWorktree(ctx, s.store, s.workflow, w, true); err != nil {
+			s.logger.Warn("could not remove merged worktree", "path", w.Path, "error", err)
+			continue
+		}
+		s.logger.Info("removed worktree of merged pull request",
+			"session", w.Session.ID, "pr", pr.Number, "path", w.Path, "branch", w.Branch)
+	}
+}
+
+// recordWorktree stores the session's branch and worktree, so resume and
+// cleanup can find them.
+func (s *Supervisor) recordWorktree() {
+	path, err := filepath.Abs(s.workflow.WorktreePath())
+	if err != nil {
+		path = s.workflow.WorktreePath()
+	}
+	if err := s.store.SetSessionWorktree(s.sessionID, s.workflow.BranchName(), path); err != nil {
+		s.logger.Warn("could not record worktree", "error", err)
+	}
+}
+
+// samePath reports whether a and b name the same directory, resolving
+// symlinks when the paths exist.
+func samePath(a, b string) bool {
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
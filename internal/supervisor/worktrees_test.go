@@ -0,0 +1,170 @@
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/workflow"
+)
+
+// addSessionWorktree creates a session with status that worked in a worktree
+// on branch, with an open pull request, and returns its ID and worktree path.
+func addSessionWorktree(t *testing.T, sup *Supervisor, host *codehost.Fake, branch, status string) (int64, string) {
+	t.Helper()
+	ctx := context.Background()
+	wf := workflow.NewGitWorkflow("", sup.workflow.RepoDir(), testLogger())
+	if err := wf.CreateWorktree(ctx, branch); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	st := sup.Store()
+	id, err := st.CreateSession("", branch, "{}")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := st.SetSessionWorktree(id, branch, wf.WorktreePath()); err != nil {
+		t.Fatalf("SetSessionWorktree: %v", err)
+	}
+	pr, err := host.OpenPR(ctx, codehost.PROptions{Title: branch, Head: branch})
+	if err != nil {
+		t.Fatalf("OpenPR: %v", err)
+	}
+	if err := st.SetSessionPR(id, pr.Number, pr.URL); err != nil {
+		t.Fatalf("SetSessionPR: %v", err)
+	}
+	if err := st.UpdateSessionStatus(id, status); err != nil {
+		t.Fatalf("UpdateSessionStatus: %v", err)
+	}
+	return id, wf.WorktreePath()
+}
+
+func findSessionWorktree(wts []SessionWorktree, id int64) *SessionWorktree {
+	for i := range wts {
+		if wts[i].Session != nil && wts[i].Session.ID == id {
+			return &wts[i]
+		}
+	}
+	return nil
+}
+
+func TestListSessionWorktrees(t *testing.T) {
+	sup, host := newCodeHostSupervisor(t)
+	ctx := context.Background()
+	st := sup.Store()
+
+	doneID, donePath := addSessionWorktree(t, sup, host, "agentbox/done", "completed")
+	goneID, gonePath := addSessionWorktree(t, sup, host, "agentbox/gone", "failed")
+	if err := sup.workflow.RemoveWorktree(ctx, gonePath); err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	// A worktree the user made by hand belongs to no session.
+	other := workflow.NewGitWorkflow("", sup.workflow.RepoDir(), testLogger())
+	if err := other.CreateWorktree(ctx, "mine"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+
+	wts, err := ListSessionWorktrees(ctx, st, sup.workflow)
+	if err != nil {
+		t.Fatalf("ListSessionWorktrees: %v", err)
+	}
+	if len(wts) != 3 {
+		t.Fatalf("ListSessionWorktrees = %+v, want 3 entries", wts)
+	}
+	done := findSessionWorktree(wts, doneID)
+	if done == nil || !samePath(done.Path, donePath) || done.Branch != "agentbox/done" || done.Missing || !done.Ended() {
+		t.Errorf("completed session's worktree = %+v", done)
+	}
+	gone := findSessionWorktree(wts, goneID)
+	if gone == nil || !gone.Missing || gone.Branch != "agentbox/gone" {
+		t.Errorf("removed worktree = %+v, want it listed as missing", gone)
+	}
+
+	// Clearing a missing worktree drops it from the list.
+	if _, err := RemoveSessionWorktree(ctx, st, sup.workflow, *gone, false); err != nil {
+		t.Fatalf("RemoveSessionWorktree: %v", err)
+	}
+	wts, err = ListSessionWorktrees(ctx, st, sup.workflow)
+	if err != nil {
+		t.Fatalf("ListSessionWorktrees: %v", err)
+	}
+	if findSessionWorktree(wts, goneID) != nil || len(wts) != 2 {
+		t.Errorf("after removing the missing worktree = %+v", wts)
+	}
+}
+
+func TestRemoveSessionWorktree_KeepsDirtyWorktree(t *testing.T) {
+	sup, host := newCodeHostSupervisor(t)
+	ctx := context.Background()
+	st := sup.Store()
+
+	id, path := addSessionWorktree(t, sup, host, "agentbox/dirty", "completed")
+	if err := os.WriteFile(filepath.Join(path, "wip.txt"), []byte("wip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wts, err := ListSessionWorktrees(ctx, st, sup.workflow)
+	if err != nil {
+		t.Fatalf("ListSessionWorktrees: %v", err)
+	}
+	w := findSessionWorktree(wts, id)
+	if w == nil {
+		t.Fatalf("session worktree not listed: %+v", wts)
+	}
+
+	if _, err := RemoveSessionWorktree(ctx, st, sup.workflow, *w, false); err == nil {
+		t.Fatal("removed a worktree with uncommitted changes without force")
+	}
+	if sess, _ := st.GetSession(id); sess.WorktreePath == "" {
+		t.Error("worktree path cleared although the worktree was kept")
+	}
+
+	deleted, err := RemoveSessionWorktree(ctx, st, sup.workflow, *w, true)
+	if err != nil {
+		t.Fatalf("RemoveSessionWorktree with force: %v", err)
+	}
+	if !deleted {
+		t.Error("branch not deleted with force")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree still exists: %v", err)
+	}
+	if sess, _ := st.GetSession(id); sess.WorktreePath != "" {
+		t.Errorf("worktree path = %q after removal", sess.WorktreePath)
+	}
+}
+
+func TestCleanupMergedWorktrees(t *testing.T) {
+	sup, host := newCodeHostSupervisor(t)
+	ctx := context.Background()
+	st := sup.Store()
+
+	mergedID, mergedPath := addSessionWorktree(t, sup, host, "agentbox/merged", "completed")
+	_, openPath := addSessionWorktree(t, sup, host, "agentbox/open", "failed")
+	pausedID, pausedPath := addSessionWorktree(t, sup, host, "agentbox/paused", "interrupted")
+	// An interrupted session can still be resumed, merged or not.
+	for _, id := range []int64{mergedID, pausedID} {
+		sess, err := st.GetSession(id)
+		if err != nil {
+			t.Fatalf("GetSession: %v", err)
+		}
+		host.SetPRState(sess.PRNumber, "merged")
+	}
+
+	sup.cleanupMergedWorktrees(ctx)
+
+	if _, err := os.Stat(mergedPath); !os.IsNotExist(err) {
+		t.Errorf("worktree of merged PR still exists: %v", err)
+	}
+	if sess, _ := st.GetSession(mergedID); sess.WorktreePath != "" {
+		t.Errorf("merged session's worktree path = %q", sess.WorktreePath)
+	}
+	if out, _ := sup.workflow.ListWorktrees(ctx); len(out) != 2 {
+		t.Errorf("worktrees left = %+v, want the open and interrupted ones", out)
+	}
+	for _, path := range []string{openPath, pausedPath} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("worktree %s was removed: %v", path, err)
+		}
+	}
+}
@@ -58,6 +58,18 @@ func (db *DB) Get(id string) (*Task, bool) {
 	return t, ok
 }
 
+// Snapshot returns a copy of a task taken under the read lock. Unlike the
+// task Get returns, it can be read while other goroutines update the DB.
+func (db *DB) Snapshot(id string) (Task, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	t, ok := db.Tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return t.clone(), true
+}
+
 // AddDependency adds a dependency edge and checks for cycles.
 func (db *DB) AddDependency(taskID, dependsOn string) error {
 	db.mu.Lock()
@@ -212,6 +224,20 @@ func (db *DB) NextTask() *Task {
 	return candidates[0]
 }
 
+// Update applies fn to a task under the write lock and returns a copy of the
+// result.
+func (db *DB) Update(taskID string, fn func(*Task)) (Task, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	task, ok := db.Tasks[taskID]
+	if !ok {
+		return Task{}, fmt.Errorf("task %s not found", taskID)
+	}
+	fn(task)
+	return task.clone(), nil
+}
+
 // UpdatePriority changes the priority of a task.
 func (db *DB) UpdatePriority(taskID string, priority int) error {
 	db.mu.Lock()
@@ -15,6 +15,15 @@ const (
 	StatusBlocked    TaskStatus = "blocked"
 )
 
+// Valid reports whether s is a known task status.
+func (s TaskStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusInProgress, StatusCompleted, StatusFailed, StatusDeferred, StatusBlocked:
+		return true
+	}
+	return false
+}
+
 // Task represents a unit of work with dependencies and execution history.
 type Task struct {
 	ID                 string               `json:"id"`
@@ -29,6 +38,7 @@ type Task struct {
 	ContextNotes       string               `json:"context_notes,omitempty"`
 	AcceptanceCriteria []AcceptanceCriteria `json:"acceptance_criteria,omitempty"`
 	Tags               []string             `json:"tags,omitempty"`
+	Workdir            string               `json:"workdir,omitempty"` // Directory the agent works in, relative to the project root.
 	Attempts           []Attempt            `json:"attempts,omitempty"`
 	CreatedAt          time.Time            `json:"created_at"`
 	CompletedAt        *time.Time           `json:"completed_at,omitempty"`
@@ -54,6 +64,16 @@ type Attempt struct {
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
+// clone returns a copy of the task that shares no slices with it.
+func (t *Task) clone() Task {
+	c := *t
+	c.DependsOn = append([]string(nil), t.DependsOn...)
+	c.AcceptanceCriteria = append([]AcceptanceCriteria(nil), t.AcceptanceCriteria...)
+	c.Tags = append([]string(nil), t.Tags...)
+	c.Attempts = append([]Attempt(nil), t.Attempts...)
+	return c
+}
+
 // HasExhaustedAttempts returns true if the task has used all allowed attempts.
 func (t *Task) HasExhaustedAttempts() bool {
 	return len(t.Attempts) >= t.MaxAttempts
@@ -67,6 +87,16 @@ func (t *Task) LastAttempt() *Attempt {
 	return &t.Attempts[len(t.Attempts)-1]
 }
 
+// HasTag reports whether the task carries the given tag.
+func (t *Task) HasTag(tag string) bool {
+	for _, tg := range t.Tags {
+		if tg == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // FailureHistory returns error messages from all failed attempts.
 func (t *Task) FailureHistory() []string {
 	var failures []string
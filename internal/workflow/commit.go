@@ -0,0 +1,147 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+// DefaultCommitTemplate is the commit message used when none is configured.
+const DefaultCommitTemplate = "feat({{.TaskID}}): {{.Title}}"
+
+// Squash strategies for config.CommitConfig.Squash.
+const (
+	SquashIteration = "iteration"
+	SquashTask      = "task"
+	SquashSprint    = "sprint"
+)
+
+// CommitInfo is what a commit message template can refer to.
+type CommitInfo struct {
+	TaskID    string
+	Title     string
+	Attempt   int    // attempt number on the task, from 1; 0 if unknown
+	Agent     string // agent that did the work
+	Tokens    int    // estimated tokens the iteration used
+	Iteration int
+	Sprint    int   // 0 outside a sprint
+	SessionID int64 // 0 outside a supervisor session
+}
+
+// CommitMessage renders policy's template for info and appends the
+// machine-readable Agentbox-Task, Agentbox-Session and Agentbox-Attempt
+// trailers.
+func CommitMessage(policy config.CommitConfig, info CommitInfo) (string, error) {
+	text := policy.Template
+	if text == "" {
+		text = DefaultCommitTemplate
+	}
+	tmpl, err := template.New("commit").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing commit template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, info); err != nil {
+		return "", fmt.Errorf("rendering commit template: %w", err)
+	}
+	msg := strings.TrimSpace(sb.String())
+	if msg == "" {
+		return "", fmt.Errorf("commit template rendered an empty message")
+	}
+
+	trailers := []string{"Agentbox-Task: " + info.TaskID}
+	if info.SessionID > 0 {
+		trailers = append(trailers, "Agentbox-Session: "+strconv.FormatInt(info.SessionID, 10))
+	}
+	if info.Attempt > 0 {
+		trailers = append(trailers, "Agentbox-Attempt: "+strconv.Itoa(info.Attempt))
+	}
+	return msg + "\n\n" + strings.Join(trailers, "\n"), nil
+}
+
+// SprintCommitMessage is the message for a sprint squashed into one commit:
+// a summary line, the tasks completed, and an Agentbox-Task trailer for each.
+func SprintCommitMessage(sessionID int64, sprint int, tasks []CommitInfo) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "feat: sprint %d", sprint)
+	if len(tasks) == 1 {
+		fmt.Fprintf(&sb, " (%s)", tasks[0].Title)
+	} else if len(tasks) > 1 {
+		fmt.Fprintf(&sb, " (%d tasks)", len(tasks))
+	}
+	sb.WriteString("\n\n")
+	for _, t := range tasks {
+		fmt.Fprintf(&sb, "- %s: %s\n", t.TaskID, t.Title)
+	}
+	if len(tasks) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, t := range tasks {
+		fmt.Fprintf(&sb, "Agentbox-Task: %s\n", t.TaskID)
+	}
+	if sessionID > 0 {
+		fmt.Fprintf(&sb, "Agentbox-Session: %d\n", sessionID)
+	}
+	fmt.Fprintf(&sb, "Agentbox-Sprint: %d", sprint)
+	return sb.String()
+}
+
+// CommitArgs returns the git arguments that commit the staged changes with
+// msg, signed as policy asks.
+func CommitArgs(policy config.CommitConfig, msg string) []string {
+	return signedArgs(policy, "commit", "-m", msg)
+}
+
+// signedArgs returns the git arguments for a subcommand that writes
+// commits, such as commit, merge or rebase, signed as policy asks.
+func signedArgs(policy config.CommitConfig, subcommand string, rest ...string) []string {
+	var args []string
+	switch policy.Sign {
+	case "gpg":
+		args = append(args, "-c", "gpg.format=openpgp")
+	case "ssh":
+		args = append(args, "-c", "gpg.format=ssh")
+	}
+	if policy.Sign != "" && policy.SigningKey != "" {
+		args = append(args, "-c", "user.signingkey="+policy.SigningKey)
+	}
+	args = append(args, subcommand)
+	if policy.Sign != "" {
+		args = append(args, "--gpg-sign")
+	}
+	return append(args, rest...)
+}
+
+// Squash folds the commits after base into a single commit with msg. It
+// does nothing when there is at most one such commit. A merge of the base
+// branch is never folded away, as that would turn the merged changes into
+// the branch's own: only the commits after the latest merge are squashed.
+func (g *GitWorkflow) Squash(ctx context.Context, base, msg string) error {
+	dir := g.workDir()
+	merge, err := g.gitOutput(ctx, dir, "rev-list", "--merges", "-1", base+"..HEAD")
+	if err != nil {
+		return err
+	}
+	if merge = strings.TrimSpace(merge); merge != "" {
+		base = merge
+	}
+	out, err := g.gitOutput(ctx, dir, "rev-list", "--count", base+"..HEAD")
+	if err != nil {
+		return err
+	}
+	if n, _ := strconv.Atoi(strings.TrimSpace(out)); n < 2 {
+		return nil
+	}
+	if err := g.git(ctx, dir, "reset", "--soft", base); err != nil {
+		return fmt.Errorf("squashing onto %s: %w", base, err)
+	}
+	// The commits may cancel out.
+	if out, err := g.gitOutput(ctx, dir, "diff", "--cached", "--name-only"); err != nil || strings.TrimSpace(out) == "" {
+		return err
+	}
+	return g.git(ctx, dir, CommitArgs(g.commitPolicy, msg)...)
+}
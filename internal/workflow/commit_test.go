@@ -0,0 +1,118 @@
+package workflow
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+func TestCommitMessage(t *testing.T) {
+	info := CommitInfo{TaskID: "t-3", Title: "Add login", Attempt: 2, Agent: "aider", Tokens: 1200, Iteration: 7, SessionID: 4}
+
+	msg, err := CommitMessage(config.CommitConfig{}, info)
+	if err != nil {
+		t.Fatalf("CommitMessage: %v", err)
+	}
+	want := "feat(t-3): Add login\n\nAgentbox-Task: t-3\nAgentbox-Session: 4\nAgentbox-Attempt: 2"
+	if msg != want {
+		t.Errorf("default message = %q, want %q", msg, want)
+	}
+
+	msg, err = CommitMessage(config.CommitConfig{
+		Template: "{{.Title}} [{{.TaskID}}]\n\nBy {{.Agent}} in iteration {{.Iteration}}, ~{{.Tokens}} tokens.",
+	}, info)
+	if err != nil {
+		t.Fatalf("CommitMessage: %v", err)
+	}
+	if !strings.HasPrefix(msg, "Add login [t-3]\n\nBy aider in iteration 7, ~1200 tokens.\n\nAgentbox-Task: t-3\n") {
+		t.Errorf("templated message = %q", msg)
+	}
+
+	if _, err := CommitMessage(config.CommitConfig{Template: "{{.Ticket}}"}, info); err == nil {
+		t.Error("template with an unknown field rendered")
+	}
+	if _, err := CommitMessage(config.CommitConfig{Template: "{{if false}}x{{end}}"}, info); err == nil {
+		t.Error("template rendering an empty message accepted")
+	}
+}
+
+func TestCommitArgs(t *testing.T) {
+	if got := CommitArgs(config.CommitConfig{}, "msg"); strings.Join(got, " ") != "commit -m msg" {
+		t.Errorf("unsigned args = %q", got)
+	}
+	got := CommitArgs(config.CommitConfig{Sign: "ssh", SigningKey: "/keys/id.pub"}, "msg")
+	want := "-c gpg.format=ssh -c user.signingkey=/keys/id.pub commit --gpg-sign -m msg"
+	if strings.Join(got, " ") != want {
+		t.Errorf("ssh args = %q, want %q", got, want)
+	}
+}
+
+func TestCommit_SSHSigned(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	repoDir := filepath.Join(initTestRepo(t), "repo")
+	key := filepath.Join(t.TempDir(), "id_ed25519")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v\n%s", err, out)
+	}
+
+	gw := NewGitWorkflow("", repoDir, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+	gw.SetWorktreePath(repoDir, "main")
+	gw.SetCommitConfig(config.CommitConfig{Sign: "ssh", SigningKey: key + ".pub"}, nil)
+	if err := os.WriteFile(filepath.Join(repoDir, "signed.txt"), []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Commit(context.Background(), "feat: signed", nil); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	out, err := exec.Command("git", "-C", repoDir, "cat-file", "commit", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git cat-file: %v", err)
+	}
+	if !strings.Contains(string(out), "-----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("commit is not SSH-signed:\n%s", out)
+	}
+}
+
+func TestSquash(t *testing.T) {
+	repoDir := filepath.Join(initTestRepo(t), "repo")
+	gw := NewGitWorkflow("", repoDir, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+	gw.SetWorktreePath(repoDir, "main")
+	ctx := context.Background()
+	base, _ := gw.CurrentCommit(ctx)
+
+	if err := gw.Squash(ctx, base, "nothing to squash"); err != nil {
+		t.Fatalf("Squash with no commits: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.Commit(ctx, "add "+name, nil); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+
+	if err := gw.Squash(ctx, base, "feat: a and b"); err != nil {
+		t.Fatalf("Squash: %v", err)
+	}
+	out, err := exec.Command("git", "-C", repoDir, "log", "--format=%s", base+"..HEAD").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "feat: a and b" {
+		t.Errorf("commits after squash = %q", out)
+	}
+	files, err := gw.DiffFiles(ctx, base)
+	if err != nil || len(files) != 2 {
+		t.Errorf("squashed commit files = %v, %v", files, err)
+	}
+}
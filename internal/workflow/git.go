@@ -11,6 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
+	"github.com/swamp-dev/agentbox/internal/config"
 )
 
 // GitWorkflow manages git operations for the supervisor lifecycle.
@@ -19,7 +22,16 @@ type GitWorkflow struct {
 	baseDir      string
 	worktreePath string
 	branchName   string
+	baseBranch   string
+	hostConfig   config.CodeHostConfig
+	commitPolicy config.CommitConfig
+	ownPaths     []string
+	host         codehost.CodeHost
 	logger       *slog.Logger
+
+	// forcePush is set when SyncBase rebased the branch, so the next push
+	// replaces the old commits on origin.
+	forcePush bool
 }
 
 // NewGitWorkflow creates a new GitWorkflow.
@@ -48,6 +60,48 @@ func (g *GitWorkflow) SetWorktreePath(worktreePath, branchName string) {
 	g.branchName = branchName
 }
 
+// SetCodeHostConfig sets how the code host is found when one is needed.
+func (g *GitWorkflow) SetCodeHostConfig(cfg config.CodeHostConfig) {
+	g.hostConfig = cfg
+}
+
+// SetCommitConfig sets the commit policy Commit and Squash follow.
+// ownPaths are the files agentbox itself writes in the worktree, which the
+// protected-path and size checks ignore.
+func (g *GitWorkflow) SetCommitConfig(cfg config.CommitConfig, ownPaths []string) {
+	g.commitPolicy = cfg
+	g.ownPaths = ownPaths
+}
+
+// SetCodeHost sets the code host used for pull requests, in place of the
+// one found from the origin remote.
+func (g *GitWorkflow) SetCodeHost(h codehost.CodeHost) {
+	g.host = h
+}
+
+// CodeHost returns the code host for the repository's origin remote,
+// finding it on first use.
+func (g *GitWorkflow) CodeHost(ctx context.Context) (codehost.CodeHost, error) {
+	if g.host == nil {
+		h, err := codehost.ForRepo(ctx, g.workDir(), g.hostConfig)
+		if err != nil {
+			return nil, err
+		}
+		g.host = h
+	}
+	return g.host, nil
+}
+
+// BaseBranch returns the branch the worktree was created from, without the
+// remote name, or "" when it is not known.
+func (g *GitWorkflow) BaseBranch() string {
+	b := strings.TrimPrefix(g.baseBranch, "origin/")
+	if b == "HEAD" {
+		return ""
+	}
+	return b
+}
+
 // RepoDir returns the path to the main repo clone.
 func (g *GitWorkflow) RepoDir() string {
 	if g.repoURL == "" {
@@ -95,6 +149,7 @@ func (g *GitWorkflow) CreateWorktree(ctx context.Context, branchName string) err
 	if err != nil {
 		return fmt.Errorf("detecting base branch: %w", err)
 	}
+	g.baseBranch = baseBranch
 
 	// Create worktree path as sibling to repo.
 	worktreeName := strings.ReplaceAll(branchName, "/", "-")
@@ -114,10 +169,105 @@ func (g *GitWorkflow) CreateWorktree(ctx context.Context, branchName string) err
 	return nil
 }
 
-// Commit stages specified files and commits with a conventional message.
+// CheckoutWorktree opens a worktree on an existing branch, such as the head
+// of a pull request, after fetching it from origin. A worktree left at the
+// usual path by an earlier session is reused. baseBranch is the branch the
+// pull request merges into.
+func (g *GitWorkflow) CheckoutWorktree(ctx context.Context, branchName, baseBranch string) error {
+	g.branchName = branchName
+	g.baseBranch = baseBranch
+
+	repoDir := g.RepoDir()
+	worktreeName := strings.ReplaceAll(branchName, "/", "-")
+	g.worktreePath = filepath.Join(filepath.Dir(repoDir), worktreeName)
+
+	if err := g.git(ctx, repoDir, "fetch", "origin", branchName); err != nil {
+		return fmt.Errorf("fetching %s: %w", branchName, err)
+	}
+
+	if fi, err := os.Stat(g.worktreePath); err == nil && fi.IsDir() {
+		g.logger.Info("reusing worktree", "branch", branchName, "path", g.worktreePath)
+	} else {
+		g.logger.Info("checking out worktree", "branch", branchName, "path", g.worktreePath)
+		// With only origin/<branch> present, git creates a tracking branch.
+		if err := g.git(ctx, repoDir, "worktree", "add", g.worktreePath, branchName); err != nil {
+			return fmt.Errorf("creating worktree: %w", err)
+		}
+	}
+
+	// Pick up commits pushed since the branch was last checked out.
+	if err := g.git(ctx, g.worktreePath, "merge", "--ff-only", "origin/"+branchName); err != nil {
+		return fmt.Errorf("updating %s from origin: %w", branchName, err)
+	}
+	return nil
+}
+
+// AddDetachedWorktree checks out the current HEAD of the working worktree
+// into path without creating a branch. Used for short-lived scratch copies
+// such as ensemble candidates.
+func (g *GitWorkflow) AddDetachedWorktree(ctx context.Context, path string) error {
+	if err := g.git(ctx, g.workDir(), "worktree", "add", "--detach", path, "HEAD"); err != nil {
+		return fmt.Errorf("adding worktree %s: %w", path, err)
+	}
+	return nil
+}
+
+// RemoveWorktree force-removes a worktree and prunes its metadata.
+func (g *GitWorkflow) RemoveWorktree(ctx context.Context, path string) error {
+	if err := g.git(ctx, g.workDir(), "worktree", "remove", "--force", path); err != nil {
+		return fmt.Errorf("removing worktree %s: %w", path, err)
+	}
+	return nil
+}
+
+// WorkingPatch stages every change in dir and returns a binary diff against
+// HEAD, skipping any paths in exclude.
+func (g *GitWorkflow) WorkingPatch(ctx context.Context, dir string, exclude []string) (string, error) {
+	if _, err := g.gitOutput(ctx, dir, "add", "-A"); err != nil {
+		return "", err
+	}
+	args := []string{"diff", "--cached", "--binary", "HEAD", "--", "."}
+	for _, p := range exclude {
+		args = append(args, ":(exclude)"+p)
+	}
+	return g.gitOutput(ctx, dir, args...)
+}
+
+// ApplyPatch applies a patch produced by WorkingPatch to the working worktree
+// and stages the result.
+func (g *GitWorkflow) ApplyPatch(ctx context.Context, patch string) error {
+	if strings.TrimSpace(patch) == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "apply", "--index", "--whitespace=nowarn", "-")
+	cmd.Dir = g.workDir()
+	cmd.Stdin = strings.NewReader(patch)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git apply: %s: %w", stderr.String(), err)
+	}
+	return nil
+}
+
+// Commit stages the specified files, or all changes, and commits them with
+// msg, signed if the commit config asks for it. Build msg with
+// CommitMessage. Staged changes the commit policy refuses are left
+// uncommitted and a *PolicyError is returned. During a merge of the base
+// branch (see SyncBase) the commit completes the merge, and a
+// *ConflictError is returned while conflict markers remain.
 func (g *GitWorkflow) Commit(ctx context.Context, msg string, files []string) error {
 	dir := g.workDir()
 
+	// Staging a file with conflict markers would mark it resolved.
+	unresolved, err := g.unresolvedConflicts(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if len(unresolved) > 0 {
+		return &ConflictError{Files: unresolved}
+	}
+
 	if len(files) == 0 {
 		// Stage all changes.
 		if err := g.git(ctx, dir, "add", "-A"); err != nil {
@@ -130,38 +280,61 @@ func (g *GitWorkflow) Commit(ctx context.Context, msg string, files []string) er
 		}
 	}
 
-	// Check if there's anything to commit.
+	// Check if there's anything to commit. A merge is committed even if
+	// its resolution leaves the tree as it was.
 	out, err := g.gitOutput(ctx, dir, "status", "--porcelain")
 	if err != nil {
 		return err
 	}
-	if strings.TrimSpace(out) == "" {
+	if strings.TrimSpace(out) == "" && !g.MergeInProgress(ctx) {
 		g.logger.Debug("nothing to commit")
 		return nil
 	}
+	if err := EnforcePolicy(ctx, dir, g.commitPolicy, g.ownPaths); err != nil {
+		return err
+	}
 
-	commitMsg := msg + "\n\nCo-Authored-By: agentbox <noreply@agentbox.dev>"
-	return g.git(ctx, dir, "commit", "-m", commitMsg)
+	return g.git(ctx, dir, CommitArgs(g.commitPolicy, msg)...)
 }
 
-// OpenPR creates a pull request via gh CLI.
-func (g *GitWorkflow) OpenPR(ctx context.Context, title, body string) (string, error) {
-	dir := g.workDir()
+// Push pushes the branch to origin and sets it as the upstream. After
+// SyncBase rebased the branch, the push replaces what origin had, provided
+// nobody else pushed to it meanwhile.
+func (g *GitWorkflow) Push(ctx context.Context) error {
+	args := []string{"push", "-u", "origin", g.branchName}
+	if g.forcePush {
+		args = []string{"push", "--force-with-lease", "-u", "origin", g.branchName}
+	}
+	if err := g.git(ctx, g.workDir(), args...); err != nil {
+		return fmt.Errorf("pushing branch: %w", err)
+	}
+	g.forcePush = false
+	return nil
+}
 
-	// Push the branch.
-	if err := g.git(ctx, dir, "push", "-u", "origin", g.branchName); err != nil {
-		return "", fmt.Errorf("pushing branch: %w", err)
+// OpenPR pushes the branch and opens a pull request on the code host.
+func (g *GitWorkflow) OpenPR(ctx context.Context, title, body string, draft bool) (*codehost.PullRequest, error) {
+	host, err := g.CodeHost(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("finding code host: %w", err)
+	}
+	if err := g.Push(ctx); err != nil {
+		return nil, err
 	}
 
-	// Create PR.
-	out, err := g.cmdOutput(ctx, dir, "gh", "pr", "create", "--title", title, "--body", body)
+	pr, err := host.OpenPR(ctx, codehost.PROptions{
+		Title: title,
+		Body:  body,
+		Head:  g.branchName,
+		Base:  g.BaseBranch(),
+		Draft: draft,
+	})
 	if err != nil {
-		return "", fmt.Errorf("creating PR: %w", err)
+		return nil, fmt.Errorf("creating PR: %w", err)
 	}
 
-	prURL := strings.TrimSpace(out)
-	g.logger.Info("pull request created", "url", prURL)
-	return prURL, nil
+	g.logger.Info("pull request created", "url", pr.URL, "draft", pr.Draft)
+	return pr, nil
 }
 
 // CurrentCommit returns the HEAD SHA.
@@ -173,10 +346,25 @@ func (g *GitWorkflow) CurrentCommit(ctx context.Context) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
-// Rollback resets the worktree to a specific commit.
-func (g *GitWorkflow) Rollback(ctx context.Context, commitSHA string) error {
+// Rollback resets the branch and worktree to a specific commit and removes
+// untracked files, except the paths in keep, whose contents are left as
+// they are. Ignored files are not removed.
+func (g *GitWorkflow) Rollback(ctx context.Context, commitSHA string, keep ...string) error {
 	g.logger.Warn("rolling back", "commit", commitSHA)
-	return g.git(ctx, g.workDir(), "reset", "--hard", commitSHA)
+	dir := g.workDir()
+	if err := g.git(ctx, dir, "reset", "-q", commitSHA); err != nil {
+		return err
+	}
+	checkout := []string{"checkout", commitSHA, "--", "."}
+	clean := []string{"clean", "-f", "-d", "-q"}
+	for _, p := range keep {
+		checkout = append(checkout, ":(exclude)"+p)
+		clean = append(clean, "-e", "/"+p)
+	}
+	if err := g.git(ctx, dir, checkout...); err != nil {
+		return err
+	}
+	return g.git(ctx, dir, clean...)
 }
 
 // Diff returns the diff between the current branch and its base.
@@ -251,19 +439,6 @@ func (g *GitWorkflow) gitGlobal(ctx context.Context, args ...string) error {
 	return cmd.Run()
 }
 
-// cmdOutput runs any command and returns stdout.
-func (g *GitWorkflow) cmdOutput(ctx context.Context, dir string, name string, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, name, args...)
-	cmd.Dir = dir
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("%s: %s: %w", name, stderr.String(), err)
-	}
-	return stdout.String(), nil
-}
-
 // repoNameFromURL extracts the repository name from a URL.
 func repoNameFromURL(url string) string {
 	url = strings.TrimSuffix(url, ".git")
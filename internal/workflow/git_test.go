@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/codehost"
 )
 
 func initTestRepo(t *testing.T) string {
@@ -219,6 +221,70 @@ func TestCurrentCommitAndRollback(t *testing.T) {
 	}
 }
 
+func TestRollbackKeepsPaths(t *testing.T) {
+	dir := initTestRepo(t)
+	repoDir := filepath.Join(dir, "repo")
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	gw := NewGitWorkflow("", repoDir, logger)
+
+	ctx := context.Background()
+	if err := gw.CloneOrOpen(ctx); err != nil {
+		t.Fatalf("CloneOrOpen: %v", err)
+	}
+	if err := gw.CreateWorktree(ctx, "feat/rollback-keep"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	wt := gw.WorktreePath()
+	beforeSHA, _ := gw.CurrentCommit(ctx)
+
+	if err := os.WriteFile(filepath.Join(wt, "README.md"), []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := gw.Commit(ctx, "feat: change readme", nil); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	files := map[string]string{
+		"staged.txt":         "s",
+		"untracked/new.txt":  "u",
+		".agentbox/note.txt": "keep",
+		"progress.txt":       "keep",
+	}
+	for name, content := range files {
+		path := filepath.Join(wt, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	if out, err := exec.Command("git", "-C", wt, "add", "staged.txt").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v: %s", err, out)
+	}
+
+	if err := gw.Rollback(ctx, beforeSHA, ".agentbox", "progress.txt"); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	if sha, _ := gw.CurrentCommit(ctx); sha != beforeSHA {
+		t.Errorf("expected SHA %s after rollback, got %s", beforeSHA, sha)
+	}
+	if data, _ := os.ReadFile(filepath.Join(wt, "README.md")); string(data) == "changed\n" {
+		t.Error("README.md not restored")
+	}
+	for _, name := range []string{"staged.txt", "untracked/new.txt"} {
+		if _, err := os.Stat(filepath.Join(wt, name)); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", name, err)
+		}
+	}
+	for _, name := range []string{".agentbox/note.txt", "progress.txt"} {
+		if data, _ := os.ReadFile(filepath.Join(wt, name)); string(data) != "keep" {
+			t.Errorf("%s = %q, want it kept", name, data)
+		}
+	}
+}
+
 func TestDiff(t *testing.T) {
 	dir := initTestRepo(t)
 	repoDir := filepath.Join(dir, "repo")
@@ -644,27 +710,117 @@ func TestSetWorktreePath(t *testing.T) {
 	}
 }
 
-func TestCmdOutput_Success(t *testing.T) {
+func TestDetachedWorktreePatchRoundTrip(t *testing.T) {
+	dir := initTestRepo(t)
+	repoDir := filepath.Join(dir, "repo")
+
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
-	gw := NewGitWorkflow("", t.TempDir(), logger)
+	gw := NewGitWorkflow("", repoDir, logger)
+	ctx := context.Background()
+
+	scratch := filepath.Join(dir, "scratch")
+	if err := gw.AddDetachedWorktree(ctx, scratch); err != nil {
+		t.Fatalf("AddDetachedWorktree: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(scratch, "new.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(scratch, "skip.txt"), []byte("skip\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	patch, err := gw.WorkingPatch(ctx, scratch, []string{"skip.txt"})
+	if err != nil {
+		t.Fatalf("WorkingPatch: %v", err)
+	}
+	if !strings.Contains(patch, "new.txt") || strings.Contains(patch, "skip.txt") {
+		t.Fatalf("unexpected patch:\n%s", patch)
+	}
+
+	if err := gw.ApplyPatch(ctx, patch); err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(repoDir, "new.txt"))
+	if err != nil || string(data) != "hello\n" {
+		t.Errorf("expected new.txt applied, got %q (err %v)", data, err)
+	}
+
+	if err := gw.RemoveWorktree(ctx, scratch); err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+		t.Error("expected scratch worktree to be removed")
+	}
+}
+
+func TestOpenPR_UsesCodeHost(t *testing.T) {
+	cloneDir := initClonedRepo(t, "main")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	gw := NewGitWorkflow("", cloneDir, logger)
+	host := codehost.NewFake()
+	gw.SetCodeHost(host)
 
 	ctx := context.Background()
-	out, err := gw.cmdOutput(ctx, t.TempDir(), "git", "version")
+	if err := gw.CreateWorktree(ctx, "feat/pr"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	if gw.BaseBranch() != "main" {
+		t.Errorf("BaseBranch = %q, want main", gw.BaseBranch())
+	}
+
+	pr, err := gw.OpenPR(ctx, "Add feature", "body", true)
 	if err != nil {
-		t.Fatalf("cmdOutput(git version): %v", err)
+		t.Fatalf("OpenPR: %v", err)
+	}
+	prs := host.PRs()
+	if len(prs) != 1 || prs[0].URL != pr.URL || prs[0].Head != "feat/pr" || prs[0].Base != "main" || !prs[0].Draft {
+		t.Fatalf("PRs = %+v", prs)
 	}
-	if !strings.Contains(out, "git version") {
-		t.Errorf("expected 'git version' in output, got %q", out)
+
+	out, err := exec.Command("git", "-C", cloneDir, "ls-remote", "origin", "feat/pr").Output()
+	if err != nil || !strings.Contains(string(out), "refs/heads/feat/pr") {
+		t.Errorf("branch not pushed: %s %v", out, err)
 	}
 }
 
-func TestCmdOutput_Failure(t *testing.T) {
+func TestCodeHost_UnknownRemote(t *testing.T) {
+	cloneDir := initClonedRepo(t, "main")
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
-	gw := NewGitWorkflow("", t.TempDir(), logger)
+	gw := NewGitWorkflow("", cloneDir, logger)
 
+	// The origin is a local path, which names no code host.
+	if _, err := gw.OpenPR(context.Background(), "t", "b", false); err == nil || !strings.Contains(err.Error(), "code host") {
+		t.Errorf("OpenPR = %v, want a code host error", err)
+	}
+}
+
+func TestCheckoutWorktree_ExistingBranch(t *testing.T) {
+	cloneDir := initClonedRepo(t, "main")
+	if out, err := exec.Command("git", "-C", cloneDir, "push", "origin", "main:feat/review").CombinedOutput(); err != nil {
+		t.Fatalf("push: %v\n%s", err, out)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	gw := NewGitWorkflow("", cloneDir, logger)
 	ctx := context.Background()
-	_, err := gw.cmdOutput(ctx, t.TempDir(), "git", "invalid-subcommand-that-does-not-exist")
-	if err == nil {
-		t.Error("expected error for invalid git subcommand")
+
+	if err := gw.CheckoutWorktree(ctx, "feat/review", "main"); err != nil {
+		t.Fatalf("CheckoutWorktree: %v", err)
+	}
+	if gw.BranchName() != "feat/review" || gw.BaseBranch() != "main" {
+		t.Errorf("branch %q base %q", gw.BranchName(), gw.BaseBranch())
+	}
+	out, err := exec.Command("git", "-C", gw.WorktreePath(), "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "feat/review" {
+		t.Fatalf("worktree HEAD = %q, %v", out, err)
+	}
+
+	// A second checkout reuses the worktree.
+	again := NewGitWorkflow("", cloneDir, logger)
+	if err := again.CheckoutWorktree(ctx, "feat/review", "main"); err != nil {
+		t.Fatalf("CheckoutWorktree again: %v", err)
+	}
+	if again.WorktreePath() != gw.WorktreePath() {
+		t.Errorf("worktree path = %s, want %s", again.WorktreePath(), gw.WorktreePath())
 	}
 }
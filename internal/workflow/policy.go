@@ -0,0 +1,261 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+// FileChange is one file in a staged diff.
+type FileChange struct {
+	Path    string
+	Added   int // lines added; 0 for binary files
+	Deleted int // lines deleted; 0 for binary files
+}
+
+// PolicyError reports staged changes the commit policy does not allow. Its
+// message is written for the agent, which sees it on its next attempt.
+type PolicyError struct {
+	Protected []string // protected paths that were changed
+	Reverted  bool     // whether the protected changes were undone
+	Files     int      // files changed, when over MaxFiles
+	MaxFiles  int
+	Lines     int // diff lines, when over MaxLines
+	MaxLines  int
+}
+
+func (e *PolicyError) Error() string {
+	var problems []string
+	if len(e.Protected) > 0 {
+		p := "changed protected paths " + strings.Join(e.Protected, ", ") + "; these must not be modified"
+		if e.Reverted {
+			p += " (the changes were reverted)"
+		}
+		problems = append(problems, p)
+	}
+	if e.MaxFiles > 0 {
+		problems = append(problems, fmt.Sprintf("changed %d files, more than the %d allowed; make a smaller change", e.Files, e.MaxFiles))
+	}
+	if e.MaxLines > 0 {
+		problems = append(problems, fmt.Sprintf("changed %d lines, more than the %d allowed; make a smaller change", e.Lines, e.MaxLines))
+	}
+	return "commit policy violation: " + strings.Join(problems, "; ")
+}
+
+// CheckChanges checks a staged diff against the policy's protected paths and
+// size limits. Changes to exempt paths, the files agentbox itself writes,
+// are ignored. It returns a *PolicyError, or nil when the diff is allowed.
+func CheckChanges(policy config.CommitConfig, changes []FileChange, exempt []string) *PolicyError {
+	var perr PolicyError
+	files, lines := 0, 0
+	for _, c := range changes {
+		if matchAny(exempt, c.Path) {
+			continue
+		}
+		files++
+		lines += c.Added + c.Deleted
+		if matchAny(policy.ProtectedPaths, c.Path) {
+			perr.Protected = append(perr.Protected, c.Path)
+		}
+	}
+	if policy.MaxFilesChanged > 0 && files > policy.MaxFilesChanged {
+		perr.Files, perr.MaxFiles = files, policy.MaxFilesChanged
+	}
+	if policy.MaxDiffLines > 0 && lines > policy.MaxDiffLines {
+		perr.Lines, perr.MaxLines = lines, policy.MaxDiffLines
+	}
+	if len(perr.Protected) == 0 && perr.MaxFiles == 0 && perr.MaxLines == 0 {
+		return nil
+	}
+	return &perr
+}
+
+// MatchPath reports whether a slash-separated path relative to the
+// repository root matches a protected_paths pattern. Patterns use path.Match
+// syntax plus "**" for any number of directories. A pattern without a slash
+// matches a file name at any depth, and a pattern matching a directory
+// covers everything under it.
+func MatchPath(pattern, p string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	if pattern == "" {
+		return false
+	}
+	segs := strings.Split(p, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, s := range segs {
+			if ok, _ := path.Match(pattern, s); ok {
+				return true
+			}
+		}
+		return false
+	}
+	pat := strings.Split(pattern, "/")
+	for n := 1; n <= len(segs); n++ {
+		if matchSegments(pat, segs[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny reports whether p matches any of patterns.
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if MatchPath(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches zero or more segments.
+func matchSegments(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pat[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pat[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
+
+// EnforcePolicy checks the changes staged in dir against policy, ignoring
+// exempt paths (see CheckChanges). On a violation with revert_protected
+// set, the protected paths are restored to HEAD, in the index and the
+// working tree, before the *PolicyError is returned. gitOpts are passed to
+// every git command before the subcommand, e.g. "-c", "safe.directory=...".
+func EnforcePolicy(ctx context.Context, dir string, policy config.CommitConfig, exempt []string, gitOpts ...string) error {
+	if len(policy.ProtectedPaths) == 0 && policy.MaxFilesChanged <= 0 && policy.MaxDiffLines <= 0 {
+		return nil
+	}
+	run := func(args ...string) (string, error) {
+		return runGit(ctx, dir, gitOpts, args...)
+	}
+
+	out, err := run("diff", "--cached", "--numstat", "--no-renames", "-z")
+	if err != nil {
+		return err
+	}
+	changes := parseNumstat(out)
+	if _, err := run("rev-parse", "-q", "--verify", "MERGE_HEAD"); err == nil {
+		// A merge is checked on its combined diff: files that match the
+		// merged branch came from it, and only the rest are the merge's own.
+		out, err := run("diff", "--cached", "--name-only", "--no-renames", "-z", "MERGE_HEAD")
+		if err != nil {
+			return err
+		}
+		changes = onlyPaths(changes, strings.Split(out, "\x00"))
+	}
+	perr := CheckChanges(policy, changes, exempt)
+	if perr == nil {
+		return nil
+	}
+	if policy.RevertProtected && len(perr.Protected) > 0 {
+		for _, p := range perr.Protected {
+			var revertErr error
+			if _, err := run("cat-file", "-e", "HEAD:"+p); err == nil {
+				_, revertErr = run("checkout", "HEAD", "--", p)
+			} else {
+				// New file: drop it from the index and the working tree.
+				_, revertErr = run("rm", "-q", "-f", "--", p)
+			}
+			if revertErr != nil {
+				return fmt.Errorf("%w (reverting %s failed: %v)", perr, p, revertErr)
+			}
+		}
+		perr.Reverted = true
+	}
+	return perr
+}
+
+// runGit runs git in dir with gitOpts before the subcommand and returns its
+// output. Errors include git's stderr.
+func runGit(ctx context.Context, dir string, gitOpts []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append(append([]string(nil), gitOpts...), args...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return "", fmt.Errorf("git %s: %s: %w", strings.Join(args, " "), ee.Stderr, err)
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// ChangedPaths lists the files in dir that differ from commit since, or
+// from HEAD when since is empty, whether committed since then or not, and
+// untracked files. Exempt paths are left out. Paths are relative to the
+// repository root, which dir must be. gitOpts work as for EnforcePolicy.
+func ChangedPaths(ctx context.Context, dir, since string, exempt []string, gitOpts ...string) ([]string, error) {
+	if since == "" {
+		since = "HEAD"
+	}
+	diff, err := runGit(ctx, dir, gitOpts, "diff", "--name-only", "--no-renames", "-z", since)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := runGit(ctx, dir, gitOpts, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, p := range strings.Split(diff+untracked, "\x00") {
+		if p == "" || seen[p] || matchAny(exempt, p) {
+			continue
+		}
+		seen[p] = true
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// onlyPaths returns the changes to the given paths.
+func onlyPaths(changes []FileChange, paths []string) []FileChange {
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+	}
+	var out []FileChange
+	for _, c := range changes {
+		if keep[c.Path] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// parseNumstat parses `git diff --numstat -z` output.
+func parseNumstat(out string) []FileChange {
+	var changes []FileChange
+	for _, rec := range strings.Split(out, "\x00") {
+		fields := strings.SplitN(rec, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0])
+		deleted, _ := strconv.Atoi(fields[1])
+		changes = append(changes, FileChange{Path: fields[2], Added: added, Deleted: deleted})
+	}
+	return changes
+}
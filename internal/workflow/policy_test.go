@@ -0,0 +1,145 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"go.mod", "go.mod", true},
+		{"go.mod", "tools/go.mod", true},
+		{"*.lock", "web/yarn.lock", true},
+		{".github", ".github/workflows/ci.yml", true},
+		{".github/", ".github/workflows/ci.yml", true},
+		{"migrations/*.sql", "migrations/001.sql", true},
+		{"migrations/*.sql", "db/migrations/001.sql", false},
+		{"**/migrations/*.sql", "db/migrations/001.sql", true},
+		{"**/migrations/*.sql", "migrations/001.sql", true},
+		{"internal/**/testdata", "internal/a/b/testdata/x.json", true},
+		{"/Makefile", "Makefile", true},
+		{"src/main.go", "src/main_test.go", false},
+		{"", "anything", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckChanges(t *testing.T) {
+	changes := []FileChange{
+		{Path: "go.mod", Added: 1, Deleted: 1},
+		{Path: "main.go", Added: 30},
+		{Path: "prd.json", Added: 500, Deleted: 400},
+	}
+	exempt := []string{"prd.json", ".agentbox"}
+
+	if perr := CheckChanges(config.CommitConfig{MaxDiffLines: 40, MaxFilesChanged: 2}, changes, exempt); perr != nil {
+		t.Errorf("diff within limits refused: %v", perr)
+	}
+
+	perr := CheckChanges(config.CommitConfig{ProtectedPaths: []string{"go.mod", "prd.json"}, MaxDiffLines: 20}, changes, exempt)
+	if perr == nil {
+		t.Fatal("protected change allowed")
+	}
+	if strings.Join(perr.Protected, ",") != "go.mod" || perr.Lines != 32 || perr.MaxFiles != 0 {
+		t.Errorf("policy error = %+v", perr)
+	}
+	for _, want := range []string{"commit policy violation", "go.mod", "32 lines, more than the 20 allowed"} {
+		if !strings.Contains(perr.Error(), want) {
+			t.Errorf("message %q lacks %q", perr.Error(), want)
+		}
+	}
+}
+
+func TestCommit_PolicyRevertsProtectedPaths(t *testing.T) {
+	repoDir := filepath.Join(initTestRepo(t), "repo")
+	gw := NewGitWorkflow("", repoDir, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+	gw.SetWorktreePath(repoDir, "main")
+	gw.SetCommitConfig(config.CommitConfig{ProtectedPaths: []string{"README.md", "secrets"}, RevertProtected: true}, nil)
+	ctx := context.Background()
+	base, _ := gw.CurrentCommit(ctx)
+
+	if err := os.Mkdir(filepath.Join(repoDir, "secrets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"README.md": "changed\n", "secrets/key": "k\n", "main.go": "package main\n"} {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := gw.Commit(ctx, "feat: touch everything", nil)
+	var perr *PolicyError
+	if !errors.As(err, &perr) || !perr.Reverted || len(perr.Protected) != 2 {
+		t.Fatalf("Commit = %v, want a reverted policy error", err)
+	}
+	if head, _ := gw.CurrentCommit(ctx); head != base {
+		t.Error("Commit committed despite the violation")
+	}
+	if data, _ := os.ReadFile(filepath.Join(repoDir, "README.md")); string(data) == "changed\n" {
+		t.Error("README.md was not reverted")
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "secrets", "key")); !os.IsNotExist(err) {
+		t.Errorf("new protected file was not removed: %v", err)
+	}
+
+	// What is left is allowed.
+	if err := gw.Commit(ctx, "feat: main", nil); err != nil {
+		t.Fatalf("Commit after revert: %v", err)
+	}
+	out, err := exec.Command("git", "-C", repoDir, "show", "--name-only", "--format=", "HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "main.go" {
+		t.Errorf("committed files = %q, %v", out, err)
+	}
+}
+
+func TestChangedPaths(t *testing.T) {
+	repoDir := filepath.Join(initTestRepo(t), "repo")
+	gw := NewGitWorkflow("", repoDir, slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+	gw.SetWorktreePath(repoDir, "main")
+	ctx := context.Background()
+	base, _ := gw.CurrentCommit(ctx)
+
+	if err := os.MkdirAll(filepath.Join(repoDir, "services", "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("services/api/main.go", "package main\n")
+	if err := gw.Commit(ctx, "feat: api", nil); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	write("README.md", "changed\n")
+	write("progress.txt", "bookkeeping\n")
+	write("web.ts", "new\n")
+
+	got, err := ChangedPaths(ctx, repoDir, base, []string{"progress.txt"})
+	if err != nil {
+		t.Fatalf("ChangedPaths: %v", err)
+	}
+	if strings.Join(got, ",") != "README.md,services/api/main.go,web.ts" {
+		t.Errorf("ChangedPaths since base = %v", got)
+	}
+	got, _ = ChangedPaths(ctx, repoDir, "", nil)
+	if strings.Join(got, ",") != "README.md,progress.txt,web.ts" {
+		t.Errorf("ChangedPaths since HEAD = %v", got)
+	}
+}
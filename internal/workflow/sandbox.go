@@ -0,0 +1,200 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Sandbox is a private copy of a project for an agent to work in, so that
+// the project itself only changes when the agent's work is applied as a
+// patch. The copy's starting state is recorded in a git directory of its
+// own, which also makes this work for projects that are not repositories.
+type Sandbox struct {
+	Dir     string // the copy the agent works in
+	project string
+	root    string // temporary directory holding the copy and gitDir
+	gitDir  string
+	base    string // tree of the copy before the agent ran
+}
+
+// NewSandbox copies project, including uncommitted and ignored files, into
+// a temporary directory and records its state. Call Remove when done.
+func NewSandbox(ctx context.Context, project string) (*Sandbox, error) {
+	project, err := filepath.Abs(project)
+	if err != nil {
+		return nil, fmt.Errorf("resolving project path: %w", err)
+	}
+	root, err := os.MkdirTemp("", "agentbox-sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("creating sandbox: %w", err)
+	}
+	sb := &Sandbox{
+		Dir:     filepath.Join(root, "workspace"),
+		project: project,
+		root:    root,
+		gitDir:  filepath.Join(root, "snapshot.git"),
+	}
+	if err := sb.init(ctx); err != nil {
+		_ = os.RemoveAll(root)
+		return nil, err
+	}
+	return sb, nil
+}
+
+func (s *Sandbox) init(ctx context.Context) error {
+	if err := copyTree(s.project, s.Dir); err != nil {
+		return fmt.Errorf("copying project into sandbox: %w", err)
+	}
+	if out, err := exec.CommandContext(ctx, "git", "init", "-q", "--bare", s.gitDir).CombinedOutput(); err != nil {
+		return fmt.Errorf("git init: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	// Honour the project's local excludes as well as its .gitignore files,
+	// so ignored build output stays out of the patch.
+	if data, err := os.ReadFile(filepath.Join(s.project, ".git", "info", "exclude")); err == nil {
+		if err := os.WriteFile(filepath.Join(s.gitDir, "info", "exclude"), data, 0o644); err != nil {
+			return fmt.Errorf("copying excludes: %w", err)
+		}
+	}
+	base, err := s.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	s.base = base
+	return nil
+}
+
+// Changes returns the agent's changes to the copy as a binary patch, and the
+// files it touches. Files the project ignores are left out. Commits the
+// agent made in the copy are included only through the files they changed.
+func (s *Sandbox) Changes(ctx context.Context) (string, []FileChange, error) {
+	tree, err := s.snapshot(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if tree == s.base {
+		return "", nil, nil
+	}
+	patch, err := s.git(ctx, s.Dir, "diff", "--binary", s.base, tree)
+	if err != nil {
+		return "", nil, err
+	}
+	numstat, err := s.git(ctx, s.Dir, "diff", "--numstat", "--no-renames", "-z", s.base, tree)
+	if err != nil {
+		return "", nil, err
+	}
+	return patch, parseNumstat(numstat), nil
+}
+
+// Apply applies a patch from Changes to the project. Nothing is changed
+// unless the whole patch applies.
+func (s *Sandbox) Apply(ctx context.Context, patch string) error {
+	if strings.TrimSpace(patch) == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "git", append(s.opts(s.project), "apply", "--whitespace=nowarn", "-")...)
+	cmd.Dir = s.project
+	cmd.Stdin = strings.NewReader(patch)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git apply: %s: %w", stderr.String(), err)
+	}
+	return nil
+}
+
+// Remove deletes the copy.
+func (s *Sandbox) Remove() error {
+	return os.RemoveAll(s.root)
+}
+
+// snapshot records the copy's current content and returns its tree.
+func (s *Sandbox) snapshot(ctx context.Context) (string, error) {
+	if _, err := s.git(ctx, s.Dir, "add", "-A"); err != nil {
+		return "", err
+	}
+	tree, err := s.git(ctx, s.Dir, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// opts points git at the snapshot directory with workTree as the work tree.
+// The container chowns the copy to its agent user, so git's ownership
+// check is waived for it.
+func (s *Sandbox) opts(workTree string) []string {
+	return []string{
+		"-c", "safe.directory=" + workTree,
+		"-c", "safe.directory=" + s.gitDir,
+		"--git-dir=" + s.gitDir,
+		"--work-tree=" + workTree,
+	}
+}
+
+func (s *Sandbox) git(ctx context.Context, workTree string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append(s.opts(workTree), args...)...)
+	cmd.Dir = workTree
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s: %w", args[0], strings.TrimSpace(stderr.String()), err)
+	}
+	return string(out), nil
+}
+
+// copyTree copies the directory src to dst, keeping file modes and
+// symlinks. Sockets, devices and other special files are skipped.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,122 @@
+package workflow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandbox(t *testing.T) {
+	project := filepath.Join(initTestRepo(t), "repo")
+	for name, content := range map[string]string{
+		".gitignore":   "build/\n",
+		"draft.txt":    "uncommitted\n",
+		"build/out.js": "generated\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(project, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(project, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	sb, err := NewSandbox(ctx, project)
+	if err != nil {
+		t.Fatalf("NewSandbox: %v", err)
+	}
+	defer sb.Remove()
+
+	if data, _ := os.ReadFile(filepath.Join(sb.Dir, "draft.txt")); string(data) != "uncommitted\n" {
+		t.Errorf("sandbox draft.txt = %q, want the uncommitted content", data)
+	}
+	if patch, _, err := sb.Changes(ctx); err != nil || patch != "" {
+		t.Fatalf("Changes before the agent ran = %q, %v", patch, err)
+	}
+
+	// The agent edits, adds, deletes, touches ignored output and commits.
+	if err := os.WriteFile(filepath.Join(sb.Dir, "README.md"), []byte("# Changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sb.Dir, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(sb.Dir, "draft.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sb.Dir, "build", "out.js"), []byte("regenerated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(project, "README.md")); string(data) != "# Test\n" {
+		t.Fatalf("project changed while the agent worked: %q", data)
+	}
+
+	patch, changes, err := sb.Changes(ctx)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	if strings.Join(paths, ",") != "README.md,draft.txt,new.go" {
+		t.Errorf("changed paths = %v", paths)
+	}
+
+	if err := sb.Apply(ctx, patch); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "README.md")); string(data) != "# Changed\n" {
+		t.Errorf("README.md = %q after Apply", data)
+	}
+	if _, err := os.Stat(filepath.Join(project, "new.go")); err != nil {
+		t.Errorf("new.go not created: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(project, "draft.txt")); !os.IsNotExist(err) {
+		t.Errorf("draft.txt not deleted: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(project, "build", "out.js")); string(data) != "generated\n" {
+		t.Errorf("ignored build/out.js = %q, want it untouched", data)
+	}
+
+	if err := sb.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(sb.Dir); !os.IsNotExist(err) {
+		t.Errorf("sandbox still exists: %v", err)
+	}
+}
+
+func TestSandbox_ApplyIsAllOrNothing(t *testing.T) {
+	project := filepath.Join(initTestRepo(t), "repo")
+	ctx := context.Background()
+	sb, err := NewSandbox(ctx, project)
+	if err != nil {
+		t.Fatalf("NewSandbox: %v", err)
+	}
+	defer sb.Remove()
+
+	for _, name := range []string{"README.md", "other.txt"} {
+		if err := os.WriteFile(filepath.Join(sb.Dir, name), []byte("agent\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patch, _, err := sb.Changes(ctx)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	// Someone edits the project meanwhile.
+	if err := os.WriteFile(filepath.Join(project, "README.md"), []byte("# Edited by hand\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sb.Apply(ctx, patch); err == nil {
+		t.Fatal("Apply over a conflicting edit succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(project, "other.txt")); !os.IsNotExist(err) {
+		t.Errorf("part of a failed patch was applied: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package workflow
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Strategies for SyncBase.
+const (
+	SyncMerge  = "merge"
+	SyncRebase = "rebase"
+)
+
+// ConflictError reports a merge of the base branch with unresolved
+// conflicts. The merge is left in progress in the worktree, with conflict
+// markers in Files, until they are resolved and committed.
+type ConflictError struct {
+	Base   string   // the base branch being merged, e.g. origin/main
+	Commit string   // the commit of Base being merged
+	Files  []string // files with unresolved conflicts
+	Hunks  string   // the conflicting hunks, as git diff shows them
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("unresolved merge conflicts in %s; resolve them and remove the conflict markers",
+		strings.Join(e.Files, ", "))
+}
+
+// SyncBase fetches origin and brings new commits on the base branch into
+// the worktree's branch, by merging the base branch or by rebasing onto it
+// as mode says. It reports whether the branch changed.
+//
+// When the base branch does not go in cleanly, a merge is left in progress
+// and a *ConflictError is returned; Commit completes the merge once the
+// conflicts are resolved. A rebase that stops on conflicts is abandoned for
+// such a merge, as a half-done rebase cannot be committed like that.
+func (g *GitWorkflow) SyncBase(ctx context.Context, mode string) (bool, error) {
+	dir := g.workDir()
+	if g.baseBranch == "" {
+		base, err := g.detectBaseBranch(ctx, g.RepoDir())
+		if err != nil {
+			return false, err
+		}
+		g.baseBranch = base
+	}
+	if g.BaseBranch() == "" {
+		return false, nil
+	}
+	base := "origin/" + g.BaseBranch()
+	if g.MergeInProgress(ctx) {
+		return false, fmt.Errorf("a merge of %s is already in progress", base)
+	}
+	if out, err := g.gitOutput(ctx, dir, "status", "--porcelain", "--untracked-files=no"); err != nil {
+		return false, err
+	} else if strings.TrimSpace(out) != "" {
+		return false, fmt.Errorf("worktree has uncommitted changes")
+	}
+
+	if _, err := g.gitOutput(ctx, dir, "fetch", "origin"); err != nil {
+		return false, fmt.Errorf("fetching origin: %w", err)
+	}
+	if _, err := g.gitOutput(ctx, dir, "merge-base", "--is-ancestor", base, "HEAD"); err == nil {
+		return false, nil
+	}
+
+	if mode == SyncRebase {
+		if _, err := g.gitOutput(ctx, dir, signedArgs(g.commitPolicy, "rebase", base)...); err == nil {
+			g.forcePush = true
+			return true, nil
+		}
+		g.logger.Info("rebase stopped on conflicts, merging instead", "base", base)
+		if _, err := g.gitOutput(ctx, dir, "rebase", "--abort"); err != nil {
+			return false, fmt.Errorf("aborting rebase: %w", err)
+		}
+	}
+
+	_, mergeErr := g.gitOutput(ctx, dir, signedArgs(g.commitPolicy, "merge", "--no-ff", "--no-edit", base)...)
+	if mergeErr == nil {
+		return true, nil
+	}
+	files, err := g.conflictedFiles(ctx, dir)
+	if err != nil || len(files) == 0 {
+		// Not a conflict; don't leave a broken merge behind.
+		_, _ = g.gitOutput(ctx, dir, "merge", "--abort")
+		return false, fmt.Errorf("merging %s: %w", base, mergeErr)
+	}
+	commit, _ := g.gitOutput(ctx, dir, "rev-parse", "MERGE_HEAD")
+	hunks, _ := g.gitOutput(ctx, dir, "diff")
+	return false, &ConflictError{Base: base, Commit: strings.TrimSpace(commit), Files: files, Hunks: hunks}
+}
+
+// MergeInProgress reports whether the worktree has a merge waiting to be
+// committed.
+func (g *GitWorkflow) MergeInProgress(ctx context.Context) bool {
+	_, err := g.gitOutput(ctx, g.workDir(), "rev-parse", "-q", "--verify", "MERGE_HEAD")
+	return err == nil
+}
+
+// AbortMerge abandons a merge in progress, restoring the branch as it was
+// before the merge.
+func (g *GitWorkflow) AbortMerge(ctx context.Context) error {
+	if _, err := g.gitOutput(ctx, g.workDir(), "merge", "--abort"); err != nil {
+		return fmt.Errorf("aborting merge: %w", err)
+	}
+	return nil
+}
+
+// unresolvedConflicts returns the files of a merge in progress that still
+// contain conflict markers.
+func (g *GitWorkflow) unresolvedConflicts(ctx context.Context, dir string) ([]string, error) {
+	if !g.MergeInProgress(ctx) {
+		return nil, nil
+	}
+	files, err := g.conflictedFiles(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	var unresolved []string
+	for _, f := range files {
+		if hasConflictMarkers(filepath.Join(dir, f)) {
+			unresolved = append(unresolved, f)
+		}
+	}
+	return unresolved, nil
+}
+
+// conflictedFiles lists the files git has not recorded as merged.
+func (g *GitWorkflow) conflictedFiles(ctx context.Context, dir string) ([]string, error) {
+	out, err := g.gitOutput(ctx, dir, "diff", "--name-only", "--diff-filter=U", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// hasConflictMarkers reports whether the file at path has a line starting
+// a conflict (<<<<<<<) or ending one (>>>>>>>).
+func hasConflictMarkers(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swamp-dev/agentbox/internal/config"
+)
+
+// newSyncTestWorkflow returns a workflow with a worktree on feat/sync in a
+// clone of a repository with a bare origin. upstream commits a file to main
+// in the original repository and pushes it to origin.
+func newSyncTestWorkflow(t *testing.T) (gw *GitWorkflow, upstream func(name, content string)) {
+	t.Helper()
+	cloneDir := initClonedRepo(t, "main")
+	for _, args := range [][]string{
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", cloneDir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	gw = NewGitWorkflow("", cloneDir, logger)
+	if err := gw.CreateWorktree(context.Background(), "feat/sync"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+
+	repoDir := filepath.Join(filepath.Dir(cloneDir), "repo")
+	bareDir := filepath.Join(filepath.Dir(cloneDir), "bare.git")
+	upstream = func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{
+			{"add", "-A"},
+			{"commit", "-m", "upstream " + name},
+			{"push", bareDir, "main"},
+		} {
+			if out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+	}
+	return gw, upstream
+}
+
+func commitInWorktree(t *testing.T, gw *GitWorkflow, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(gw.WorktreePath(), name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Commit(context.Background(), "branch "+name, nil); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+func gitLines(t *testing.T, dir string, args ...string) []string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return strings.Fields(string(out))
+}
+
+func TestSyncBase(t *testing.T) {
+	for _, mode := range []string{SyncMerge, SyncRebase} {
+		t.Run(mode, func(t *testing.T) {
+			gw, upstream := newSyncTestWorkflow(t)
+			ctx := context.Background()
+			commitInWorktree(t, gw, "feature.txt", "feature\n")
+
+			if changed, err := gw.SyncBase(ctx, mode); err != nil || changed {
+				t.Fatalf("SyncBase with nothing new = %v, %v", changed, err)
+			}
+
+			upstream("upstream.txt", "upstream\n")
+			changed, err := gw.SyncBase(ctx, mode)
+			if err != nil || !changed {
+				t.Fatalf("SyncBase = %v, %v", changed, err)
+			}
+			for _, name := range []string{"feature.txt", "upstream.txt"} {
+				if _, err := os.Stat(filepath.Join(gw.WorktreePath(), name)); err != nil {
+					t.Errorf("%s missing after sync: %v", name, err)
+				}
+			}
+			merges := gitLines(t, gw.WorktreePath(), "rev-list", "--merges", "HEAD")
+			if mode == SyncMerge && len(merges) != 1 {
+				t.Errorf("merge mode made %d merge commits, want 1", len(merges))
+			}
+			if mode == SyncRebase && (len(merges) != 0 || !gw.forcePush) {
+				t.Errorf("rebase mode: %d merge commits, forcePush %v", len(merges), gw.forcePush)
+			}
+		})
+	}
+}
+
+func TestSyncBase_Conflict(t *testing.T) {
+	for _, mode := range []string{SyncMerge, SyncRebase} {
+		t.Run(mode, func(t *testing.T) {
+			gw, upstream := newSyncTestWorkflow(t)
+			ctx := context.Background()
+			commitInWorktree(t, gw, "README.md", "# Branch\n")
+			upstream("README.md", "# Upstream\n")
+
+			_, err := gw.SyncBase(ctx, mode)
+			var cerr *ConflictError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("SyncBase = %v, want a *ConflictError", err)
+			}
+			if cerr.Base != "origin/main" || cerr.Commit == "" || len(cerr.Files) != 1 || cerr.Files[0] != "README.md" ||
+				!strings.Contains(cerr.Hunks, "<<<<<<<") || !strings.Contains(cerr.Hunks, "# Upstream") {
+				t.Errorf("ConflictError = %+v", cerr)
+			}
+			if !gw.MergeInProgress(ctx) {
+				t.Fatal("no merge in progress after a conflict")
+			}
+
+			// The merge can't be committed with the markers still in.
+			if err := gw.Commit(ctx, "resolve", nil); !errors.As(err, &cerr) {
+				t.Fatalf("Commit with conflict markers = %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(gw.WorktreePath(), "README.md"), []byte("# Both\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := gw.Commit(ctx, "resolve", nil); err != nil {
+				t.Fatalf("Commit after resolving: %v", err)
+			}
+			if gw.MergeInProgress(ctx) {
+				t.Error("merge still in progress after commit")
+			}
+			if merges := gitLines(t, gw.WorktreePath(), "rev-list", "--merges", "HEAD"); len(merges) != 1 {
+				t.Errorf("%d merge commits after resolving, want 1", len(merges))
+			}
+		})
+	}
+}
+
+func TestSyncBase_AbortMerge(t *testing.T) {
+	gw, upstream := newSyncTestWorkflow(t)
+	ctx := context.Background()
+	commitInWorktree(t, gw, "README.md", "# Branch\n")
+	head, _ := gw.CurrentCommit(ctx)
+	upstream("README.md", "# Upstream\n")
+
+	if _, err := gw.SyncBase(ctx, SyncMerge); err == nil {
+		t.Fatal("SyncBase succeeded despite a conflict")
+	}
+	if _, err := gw.SyncBase(ctx, SyncMerge); err == nil {
+		t.Error("SyncBase started a second merge")
+	}
+	if err := gw.AbortMerge(ctx); err != nil {
+		t.Fatalf("AbortMerge: %v", err)
+	}
+	if after, _ := gw.CurrentCommit(ctx); after != head || gw.MergeInProgress(ctx) {
+		t.Errorf("after AbortMerge HEAD = %s (was %s), merging %v", after, head, gw.MergeInProgress(ctx))
+	}
+}
+
+func TestSyncBase_PolicyChecksOnlyTheResolution(t *testing.T) {
+	gw, upstream := newSyncTestWorkflow(t)
+	ctx := context.Background()
+	gw.SetCommitConfig(config.CommitConfig{ProtectedPaths: []string{"go.mod"}, MaxFilesChanged: 1}, nil)
+	commitInWorktree(t, gw, "README.md", "# Branch\n")
+	upstream("go.mod", "module example\n")
+	upstream("extra.txt", "extra\n")
+	upstream("README.md", "# Upstream\n")
+
+	if _, err := gw.SyncBase(ctx, SyncMerge); err == nil {
+		t.Fatal("SyncBase succeeded despite a conflict")
+	}
+	if err := os.WriteFile(filepath.Join(gw.WorktreePath(), "README.md"), []byte("# Both\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// go.mod and extra.txt come from the base branch; only README.md is
+	// the merge's own change.
+	if err := gw.Commit(ctx, "resolve", nil); err != nil {
+		t.Fatalf("Commit of the merge: %v", err)
+	}
+}
+
+func TestSquashKeepsMerges(t *testing.T) {
+	gw, upstream := newSyncTestWorkflow(t)
+	ctx := context.Background()
+	base, _ := gw.CurrentCommit(ctx)
+	commitInWorktree(t, gw, "a.txt", "a\n")
+	upstream("upstream.txt", "upstream\n")
+	if _, err := gw.SyncBase(ctx, SyncMerge); err != nil {
+		t.Fatalf("SyncBase: %v", err)
+	}
+	commitInWorktree(t, gw, "b.txt", "b\n")
+	commitInWorktree(t, gw, "c.txt", "c\n")
+
+	if err := gw.Squash(ctx, base, "squashed"); err != nil {
+		t.Fatalf("Squash: %v", err)
+	}
+	if merges := gitLines(t, gw.WorktreePath(), "rev-list", "--merges", base+"..HEAD"); len(merges) != 1 {
+		t.Errorf("merge commits after squash = %d, want 1", len(merges))
+	}
+	// a, the merge, and b+c squashed together.
+	if n := gitLines(t, gw.WorktreePath(), "rev-list", "--count", "--first-parent", base+"..HEAD"); n[0] != "3" {
+		t.Errorf("first-parent commits after squash = %s, want 3", n[0])
+	}
+}
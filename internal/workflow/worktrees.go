@@ -0,0 +1,90 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a linked working tree of the repository, as git lists it.
+type Worktree struct {
+	Path     string `json:"path"`
+	Branch   string `json:"branch,omitempty"` // empty when detached
+	Head     string `json:"head,omitempty"`
+	Prunable bool   `json:"prunable,omitempty"` // its directory is gone
+}
+
+// ListWorktrees returns the repository's linked worktrees. The main working
+// tree is left out.
+func (g *GitWorkflow) ListWorktrees(ctx context.Context) ([]Worktree, error) {
+	out, err := g.gitOutput(ctx, g.RepoDir(), "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	wts := parseWorktreeList(out)
+	if len(wts) > 0 {
+		wts = wts[1:]
+	}
+	return wts, nil
+}
+
+// parseWorktreeList parses `git worktree list --porcelain` output.
+func parseWorktreeList(out string) []Worktree {
+	var wts []Worktree
+	for _, block := range strings.Split(strings.TrimSpace(out), "\n\n") {
+		var wt Worktree
+		for _, line := range strings.Split(block, "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "worktree":
+				wt.Path = filepath.Clean(value)
+			case "HEAD":
+				wt.Head = value
+			case "branch":
+				wt.Branch = strings.TrimPrefix(value, "refs/heads/")
+			case "prunable":
+				wt.Prunable = true
+			}
+		}
+		if wt.Path != "" {
+			wts = append(wts, wt)
+		}
+	}
+	return wts
+}
+
+// RemoveWorktreeIfClean removes the worktree at path unless it has
+// uncommitted changes. RemoveWorktree discards them instead.
+func (g *GitWorkflow) RemoveWorktreeIfClean(ctx context.Context, path string) error {
+	if _, err := g.gitOutput(ctx, g.RepoDir(), "worktree", "remove", path); err != nil {
+		return fmt.Errorf("removing worktree %s: %w", path, err)
+	}
+	return nil
+}
+
+// PruneWorktrees drops git's records of worktrees whose directories are
+// gone.
+func (g *GitWorkflow) PruneWorktrees(ctx context.Context) error {
+	_, err := g.gitOutput(ctx, g.RepoDir(), "worktree", "prune")
+	return err
+}
+
+// DeleteBranch deletes a local branch. Without force, git refuses to
+// delete a branch that is not merged into its upstream or HEAD.
+func (g *GitWorkflow) DeleteBranch(ctx context.Context, name string, force bool) error {
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+	if _, err := g.gitOutput(ctx, g.RepoDir(), "branch", flag, name); err != nil {
+		return fmt.Errorf("deleting branch %s: %w", name, err)
+	}
+	return nil
+}
+
+// BranchExists reports whether the repository has a local branch name.
+func (g *GitWorkflow) BranchExists(ctx context.Context, name string) bool {
+	_, err := g.gitOutput(ctx, g.RepoDir(), "show-ref", "--verify", "--quiet", "refs/heads/"+name)
+	return err == nil
+}
@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseWorktreeList(t *testing.T) {
+	out := `worktree /src/repo
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /src/.worktrees/feat-a
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/agentbox/feat-a
+
+worktree /tmp/gone
+HEAD 3333333333333333333333333333333333333333
+detached
+prunable gitdir file points to non-existent location
+`
+	got := parseWorktreeList(out)
+	want := []Worktree{
+		{Path: "/src/repo", Branch: "main", Head: "1111111111111111111111111111111111111111"},
+		{Path: "/src/.worktrees/feat-a", Branch: "agentbox/feat-a", Head: "2222222222222222222222222222222222222222"},
+		{Path: "/tmp/gone", Head: "3333333333333333333333333333333333333333", Prunable: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseWorktreeList = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("worktree %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestListAndRemoveWorktrees(t *testing.T) {
+	repoDir := filepath.Join(initTestRepo(t), "repo")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	ctx := context.Background()
+
+	clean := NewGitWorkflow("", repoDir, logger)
+	if err := clean.CreateWorktree(ctx, "feat/clean"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	dirty := NewGitWorkflow("", repoDir, logger)
+	if err := dirty.CreateWorktree(ctx, "feat/dirty"); err != nil {
+		t.Fatalf("CreateWorktree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dirty.WorktreePath(), "wip.txt"), []byte("wip\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gw := NewGitWorkflow("", repoDir, logger)
+	wts, err := gw.ListWorktrees(ctx)
+	if err != nil {
+		t.Fatalf("ListWorktrees: %v", err)
+	}
+	branches := map[string]bool{}
+	for _, wt := range wts {
+		branches[wt.Branch] = true
+	}
+	if len(wts) != 2 || !branches["feat/clean"] || !branches["feat/dirty"] {
+		t.Fatalf("ListWorktrees = %+v, want the two linked worktrees", wts)
+	}
+
+	if err := gw.RemoveWorktreeIfClean(ctx, dirty.WorktreePath()); err == nil {
+		t.Error("RemoveWorktreeIfClean removed a worktree with uncommitted changes")
+	}
+	if err := gw.RemoveWorktreeIfClean(ctx, clean.WorktreePath()); err != nil {
+		t.Fatalf("RemoveWorktreeIfClean: %v", err)
+	}
+	if err := gw.DeleteBranch(ctx, "feat/clean", false); err != nil {
+		t.Errorf("DeleteBranch of a merged branch: %v", err)
+	}
+	if gw.BranchExists(ctx, "feat/clean") || !gw.BranchExists(ctx, "feat/dirty") {
+		t.Error("BranchExists does not match the branches left")
+	}
+
+	// An unmerged branch is only deleted with force.
+	if err := dirty.Commit(ctx, "wip", nil); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := gw.RemoveWorktree(ctx, dirty.WorktreePath()); err != nil {
+		t.Fatalf("RemoveWorktree: %v", err)
+	}
+	if err := gw.DeleteBranch(ctx, "feat/dirty", false); err == nil {
+		t.Error("DeleteBranch without force deleted an unmerged branch")
+	}
+	if err := gw.DeleteBranch(ctx, "feat/dirty", true); err != nil {
+		t.Errorf("DeleteBranch with force: %v", err)
+	}
+
+	if wts, err := gw.ListWorktrees(ctx); err != nil || len(wts) != 0 {
+		t.Errorf("ListWorktrees after removal = %+v, %v", wts, err)
+	}
+}